@@ -0,0 +1,160 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// AdapterOption configures the adapter NewMongoAdapter connects. Client
+// settings override the ones in the URI.
+type AdapterOption func(config *adapterConfig)
+
+type adapterConfig struct {
+	logger             Logger
+	tracer             Tracer
+	metrics            *Metrics
+	slowQueryThreshold time.Duration
+	clientOptions      *options.ClientOptions
+	skipPing           bool
+}
+
+func newAdapterConfig(opts []AdapterOption) *adapterConfig {
+	config := &adapterConfig{
+		logger:        NopLogger(),
+		clientOptions: options.Client(),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// WithLogger logs through logger (see Logger); adapters log nothing without
+// one.
+func WithLogger(logger Logger) AdapterOption {
+	return func(config *adapterConfig) {
+		config.logger = logger
+	}
+}
+
+func WithMaxPoolSize(size uint64) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetMaxPoolSize(size)
+	}
+}
+
+func WithMinPoolSize(size uint64) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetMinPoolSize(size)
+	}
+}
+
+func WithConnectTimeout(timeout time.Duration) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetConnectTimeout(timeout)
+	}
+}
+
+func WithServerSelectionTimeout(timeout time.Duration) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetServerSelectionTimeout(timeout)
+	}
+}
+
+// WithAppName names the application in the server logs, currentOp and the
+// profiler.
+func WithAppName(name string) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetAppName(name)
+	}
+}
+
+func WithRetryWrites(retryWrites bool) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetRetryWrites(retryWrites)
+	}
+}
+
+func WithReadPreference(rp *readpref.ReadPref) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions.SetReadPreference(rp)
+	}
+}
+
+// WithClientOptions applies client options the other AdapterOptions don't
+// cover (TLS, auth, compressors, monitors...).
+func WithClientOptions(clientOptions *options.ClientOptions) AdapterOption {
+	return func(config *adapterConfig) {
+		config.clientOptions = options.MergeClientOptions(config.clientOptions, clientOptions)
+	}
+}
+
+// WithoutPing returns the adapter without pinging the server first, e.g. for
+// services that must start while the database is unreachable. Connection
+// errors then surface on the first operation.
+func WithoutPing() AdapterOption {
+	return func(config *adapterConfig) {
+		config.skipPing = true
+	}
+}
+
+// WithTracing starts a span per querier operation with tracer, and installs
+// commandMonitor, when not nil, on the client, e.g. otelmongo.NewMonitor()
+// for spans of the commands the operations send. Tracing is off without it,
+// and can be turned off later by clearing the adapter's Tracer.
+func WithTracing(tracer Tracer, commandMonitor *event.CommandMonitor) AdapterOption {
+	return func(config *adapterConfig) {
+		config.tracer = tracer
+		if commandMonitor != nil {
+			config.clientOptions.SetMonitor(commandMonitor)
+		}
+	}
+}
+
+// combineMonitors fans the command events out to every monitor, in order.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// WithMetrics records the metrics of querier operations and of the client's
+// connection pools in metrics.
+func WithMetrics(metrics *Metrics) AdapterOption {
+	return func(config *adapterConfig) {
+		config.metrics = metrics
+		config.clientOptions.SetPoolMonitor(metrics.PoolMonitor())
+	}
+}
+
+// WithSlowQueryThreshold logs the querier operations taking longer than
+// threshold at Warn, with their collection, query shape and duration.
+func WithSlowQueryThreshold(threshold time.Duration) AdapterOption {
+	return func(config *adapterConfig) {
+		config.slowQueryThreshold = threshold
+	}
+}
@@ -0,0 +1,416 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCrossDatabaseStage = errors.New("pipeline stage reaches another database")
+
+// PipelineError identifies the aggregation stage a server error was raised
+// by. Stage is -1 when the error couldn't be attributed to a stage.
+type PipelineError struct {
+	Stage     int
+	StageName string
+	Code      int32
+	Err       error
+}
+
+func (e *PipelineError) Error() string {
+	if e.Stage < 0 {
+		return fmt.Sprintf("aggregation pipeline failed: %v", e.Err)
+	}
+	return fmt.Sprintf("aggregation pipeline stage %d (%s) failed: %v", e.Stage, e.StageName, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// mapPipelineError attributes server errors to the stage named in the error
+// message; other errors (network, context) are returned unchanged.
+func mapPipelineError(pipeline mongo.Pipeline, err error) error {
+	var serverErr mongo.ServerError
+	if err == nil || !errors.As(err, &serverErr) {
+		return err
+	}
+
+	pipelineErr := &PipelineError{Stage: -1, Err: err}
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		pipelineErr.Code = commandErr.Code
+	}
+
+	message := err.Error()
+	for i, stage := range pipeline {
+		if len(stage) == 0 {
+			continue
+		}
+
+		// Match whole stage names so $group doesn't match $groupBy
+		name := stage[0].Key
+		index := strings.Index(message, name)
+		for index >= 0 {
+			end := index + len(name)
+			if end == len(message) || !isIdentifierByte(message[end]) {
+				pipelineErr.Stage = i
+				pipelineErr.StageName = name
+				return pipelineErr
+			}
+
+			next := strings.Index(message[end:], name)
+			if next < 0 {
+				break
+			}
+			index = end + next
+		}
+	}
+	return pipelineErr
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// pipelineAccess lists the collections a pipeline reaches besides the one it
+// runs on: the ones it reads through $lookup, $graphLookup and $unionWith,
+// and the ones it writes through $out and $merge, in sub-pipelines too.
+type pipelineAccess struct {
+	reads  []string
+	writes []string
+}
+
+func (a *pipelineAccess) scan(pipeline []bson.D) error {
+	for _, stage := range pipeline {
+		for _, e := range stage {
+			var err error
+			switch e.Key {
+			case "$lookup", "$graphLookup":
+				spec, _ := asDocument(e.Value)
+				for _, field := range spec {
+					switch field.Key {
+					case "from":
+						err = a.add(&a.reads, e.Key, field.Value)
+					case "pipeline":
+						err = a.scan(asPipeline(field.Value))
+					}
+					if err != nil {
+						return err
+					}
+				}
+			case "$unionWith":
+				if _, ok := e.Value.(string); ok {
+					err = a.add(&a.reads, e.Key, e.Value)
+					break
+				}
+				spec, _ := asDocument(e.Value)
+				for _, field := range spec {
+					switch field.Key {
+					case "coll":
+						err = a.add(&a.reads, e.Key, field.Value)
+					case "pipeline":
+						err = a.scan(asPipeline(field.Value))
+					}
+					if err != nil {
+						return err
+					}
+				}
+			case "$facet":
+				spec, _ := asDocument(e.Value)
+				for _, field := range spec {
+					if err = a.scan(asPipeline(field.Value)); err != nil {
+						return err
+					}
+				}
+			case "$out":
+				err = a.add(&a.writes, e.Key, e.Value)
+			case "$merge":
+				into := e.Value
+				if spec, ok := asDocument(e.Value); ok {
+					into = nil
+					for _, field := range spec {
+						if field.Key == "into" {
+							into = field.Value
+						}
+					}
+				}
+				err = a.add(&a.writes, e.Key, into)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add appends the collection named by a stage's target, either a name or
+// a {db, coll} document, to collections. Targets in another database would
+// escape the tenant's and are rejected.
+func (a *pipelineAccess) add(collections *[]string, stage string, target interface{}) error {
+	if name, ok := target.(string); ok {
+		*collections = append(*collections, name)
+		return nil
+	}
+	spec, ok := asDocument(target)
+	if !ok {
+		return fmt.Errorf("%s has no collection", stage)
+	}
+	var name string
+	for _, field := range spec {
+		switch field.Key {
+		case "db":
+			return fmt.Errorf("%w: %s into database %v", ErrCrossDatabaseStage, stage, field.Value)
+		case "coll":
+			name, _ = field.Value.(string)
+		}
+	}
+	*collections = append(*collections, name)
+	return nil
+}
+
+func asPipeline(value interface{}) []bson.D {
+	switch value := value.(type) {
+	case mongo.Pipeline:
+		return value
+	case []bson.D:
+		return value
+	}
+	values, _ := asArray(value)
+	pipeline := make([]bson.D, 0, len(values))
+	for _, value := range values {
+		if stage, ok := asDocument(value); ok {
+			pipeline = append(pipeline, stage)
+		}
+	}
+	return pipeline
+}
+
+// leadingMatch returns the filter of pipeline's first stage when it's a
+// $match, the part of the pipeline the index and shard key policies and
+// the allowlist can judge; nil otherwise.
+func leadingMatch(pipeline mongo.Pipeline) primitive.M {
+	if len(pipeline) == 0 || len(pipeline[0]) == 0 || pipeline[0][0].Key != "$match" {
+		return nil
+	}
+	match, ok := asDocument(pipeline[0][0].Value)
+	if !ok {
+		return nil
+	}
+	filter := make(primitive.M, len(match))
+	for _, e := range match {
+		filter[e.Key] = e.Value
+	}
+	return filter
+}
+
+// preflightPipeline authorizes the collections pipeline reaches besides the
+// querier's, as reads or writes, then runs preflight with its leading $match
+// as the filter, which it returns.
+func (q *Querier[Model, IDModel]) preflightPipeline(ctx context.Context, operation string, pipeline mongo.Pipeline) (primitive.M, error) {
+	start := time.Now()
+	filter := leadingMatch(pipeline)
+
+	var access pipelineAccess
+	if err := access.scan(pipeline); err != nil {
+		return filter, q.opError(err, start, operation, filter)
+	}
+	for _, kind := range []OperationKind{OperationRead, OperationWrite} {
+		collections := access.reads
+		if kind == OperationWrite {
+			collections = access.writes
+		}
+		for _, name := range collections {
+			descriptor := describeOperation(name, operation, nil)
+			descriptor.Kind = kind
+			if err := q.MongoAdapter.authorize(ctx, descriptor); err != nil {
+				return filter, q.opError(err, start, operation, filter)
+			}
+		}
+	}
+	return filter, q.preflight(ctx, operation, filter)
+}
+
+// aggregate runs pipeline without preflight checks, for callers that did
+// their own.
+func (q *Querier[Model, IDModel]) aggregate(ctx context.Context, operation string, pipeline mongo.Pipeline, opts ...*options.AggregateOptions) ([]*Model, error) {
+	cursor, err := q.aggregateIter(ctx, operation, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return cursor.All(ctx)
+}
+
+func (q *Querier[Model, IDModel]) aggregateIter(ctx context.Context, operation string, pipeline mongo.Pipeline, opts ...*options.AggregateOptions) (*Cursor[Model], error) {
+	mongoCursor, err := retrying(ctx, q, operation, func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Aggregate(ctx, pipeline, q.aggregateOptions(ctx, operation, opts)...)
+	})
+	if err != nil {
+		return nil, mapPipelineError(pipeline, err)
+	}
+
+	cursor := newCursor(q, mongoCursor)
+	// Pipelines may reshape, compute or merge in documents
+	cursor.decode = func(ctx context.Context, raw bson.Raw) (*Model, error) {
+		return q.decode(partialRead(ctx), raw)
+	}
+	cursor.mapErr = func(err error) error {
+		return mapPipelineError(pipeline, err)
+	}
+	return cursor, nil
+}
+
+// Aggregate runs pipeline and decodes every result into Model. Use
+// options.Aggregate() for allowDiskUse, maxTime, collation and hint.
+func (q *Querier[Model, IDModel]) Aggregate(ctx context.Context, pipeline mongo.Pipeline, opts ...*options.AggregateOptions) (documents []*Model, err error) {
+	filter, err := q.preflightPipeline(ctx, "Aggregate", pipeline)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "Aggregate", filter)
+	defer q.observe(span, time.Now(), "Aggregate", filter, &err)
+
+	documents, err = q.aggregate(ctx, "Aggregate", pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", len(documents)))
+
+	q.MongoAdapter.Debug(
+		"Aggregated documents",
+		LogField("collection_name", q.collection.Name()),
+		LogField("stages_count", len(pipeline)),
+		LogField("documents_count", len(documents)),
+	)
+
+	return documents, nil
+}
+
+// AggregateIter runs pipeline and streams its results through a Cursor
+// instead of loading them all in memory.
+func (q *Querier[Model, IDModel]) AggregateIter(ctx context.Context, pipeline mongo.Pipeline, opts ...*options.AggregateOptions) (cursor *Cursor[Model], err error) {
+	filter, err := q.preflightPipeline(ctx, "AggregateIter", pipeline)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "AggregateIter", filter)
+	defer q.observe(span, time.Now(), "AggregateIter", filter, &err)
+
+	return q.aggregateIter(ctx, "AggregateIter", pipeline, opts...)
+}
+
+func (q *Querier[Model, IDModel]) FindDistinctBy(ctx context.Context, filter Model, keyFields ...string) ([]*Model, error) {
+	filterM, err := q.structToM("FindDistinctBy", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindDistinctByM(ctx, filterM, keyFields...)
+}
+
+// FindDistinctByM returns the first matching document for every unique
+// combination of keyFields, the equivalent of SQL's DISTINCT ON: the one with
+// the lowest _id. Documents are sorted by keyFields then _id, which an index
+// on keyFields serves.
+func (q *Querier[Model, IDModel]) FindDistinctByM(ctx context.Context, filter primitive.M, keyFields ...string) (documents []*Model, err error) {
+	if err = q.preflight(ctx, "FindDistinctByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindDistinctByM", filter)
+	defer q.observe(span, time.Now(), "FindDistinctByM", filter, &err)
+
+	// Group keys can't contain dots, and field names mangled into keys
+	// could collide (a.b and a_b), so keys are positional
+	groupID := bson.D{}
+	sort := bson.D{}
+	for i, field := range keyFields {
+		groupID = append(groupID, bson.E{Key: "k" + strconv.Itoa(i), Value: "$" + field})
+		sort = append(sort, bson.E{Key: field, Value: 1})
+	}
+	if !containsString(keyFields, "_id") {
+		sort = append(sort, bson.E{Key: "_id", Value: 1})
+	}
+
+	// $first is only meaningful over a defined order
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: sort}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: groupID},
+			{Key: "document", Value: bson.D{{Key: "$first", Value: "$$ROOT"}}},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$document"}}}},
+	}
+
+	documents, err = q.aggregate(ctx, "FindDistinctByM", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Found distinct documents by key fields",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("key_fields", keyFields),
+		LogField("documents_count", len(documents)),
+	)
+
+	return documents, nil
+}
+
+func (q *Querier[Model, IDModel]) FindUnion(ctx context.Context, filter Model, otherCollections ...string) ([]*Model, error) {
+	filterM, err := q.structToM("FindUnion", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindUnionByM(ctx, filterM, otherCollections...)
+}
+
+// FindUnionByM runs filter against this collection and every one of
+// otherCollections (e.g. yearly partitions), all in the database of ctx's
+// tenant, and returns the combined results. Each of otherCollections is
+// authorized as a read of its own.
+func (q *Querier[Model, IDModel]) FindUnionByM(ctx context.Context, filter primitive.M, otherCollections ...string) (documents []*Model, err error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	for _, collectionName := range otherCollections {
+		pipeline = append(pipeline, bson.D{{Key: "$unionWith", Value: bson.D{
+			{Key: "coll", Value: collectionName},
+			{Key: "pipeline", Value: mongo.Pipeline{{{Key: "$match", Value: filter}}}},
+		}}})
+	}
+
+	// $unionWith reads the other collections in the aggregation's database,
+	// the tenant's one
+	if _, err = q.preflightPipeline(ctx, "FindUnionByM", pipeline); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindUnionByM", filter)
+	defer q.observe(span, time.Now(), "FindUnionByM", filter, &err)
+
+	documents, err = q.aggregate(ctx, "FindUnionByM", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Found documents across collections",
+		LogField("collection_name", q.collection.Name()),
+		LogField("other_collections", otherCollections),
+		q.logValue("filter", filter),
+		LogField("documents_count", len(documents)),
+	)
+
+	return documents, nil
+}
@@ -0,0 +1,166 @@
+package mongoquerier
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fieldAlias describes the stored key of a struct field together with the
+// legacy keys declared through `mq:"alias=old_name"` tags.
+type fieldAlias struct {
+	key     string
+	aliases []string
+	nested  []fieldAlias
+}
+
+var aliasCache sync.Map // reflect.Type -> []fieldAlias
+
+// parseMQTag splits an `mq` struct tag into its options, e.g.
+// `mq:"alias=old_name,alias=older_name"` yields {"alias": [old_name older_name]}.
+func parseMQTag(tag string) map[string][]string {
+	options := map[string][]string{}
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(part, "=")
+		options[name] = append(options[name], value)
+	}
+	return options
+}
+
+// bsonKey returns the key the default BSON codec stores a field under.
+func bsonKey(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("bson"), ",")[0]
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	return key
+}
+
+func aliasesFor(t reflect.Type) []fieldAlias {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if cached, ok := aliasCache.Load(t); ok {
+		return cached.([]fieldAlias)
+	}
+
+	result := collectAliases(t, map[reflect.Type]bool{})
+	aliasCache.Store(t, result)
+	return result
+}
+
+// collectAliases walks t's fields, skipping the types already being walked
+// so self-referential models terminate.
+func collectAliases(t reflect.Type, visiting map[reflect.Type]bool) []fieldAlias {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	if cached, ok := aliasCache.Load(t); ok {
+		return cached.([]fieldAlias)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var result []fieldAlias
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("bson") == "-" {
+			continue
+		}
+
+		alias := fieldAlias{
+			key:     bsonKey(field),
+			aliases: parseMQTag(field.Tag.Get("mq"))["alias"],
+			nested:  collectAliases(field.Type, visiting),
+		}
+		if len(alias.aliases) > 0 || len(alias.nested) > 0 {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+// resolveAliases renames legacy keys in doc to their current field key when
+// the current key is absent, so old documents decode into renamed fields.
+func resolveAliases(doc bson.D, aliases []fieldAlias) bson.D {
+	for _, alias := range aliases {
+		index := indexOfKey(doc, alias.key)
+		if index < 0 {
+			for _, old := range alias.aliases {
+				if index = indexOfKey(doc, old); index >= 0 {
+					doc[index].Key = alias.key
+					break
+				}
+			}
+		}
+
+		if index >= 0 && len(alias.nested) > 0 {
+			if nested, ok := doc[index].Value.(bson.D); ok {
+				doc[index].Value = resolveAliases(nested, alias.nested)
+			}
+		}
+	}
+	return doc
+}
+
+// dualWriteAliases copies every aliased key in doc to its legacy names so
+// readers that haven't been migrated yet keep working.
+func dualWriteAliases(doc bson.M, aliases []fieldAlias, prefix string) {
+	for _, alias := range aliases {
+		key := prefix + alias.key
+		if value, ok := doc[key]; ok {
+			for _, old := range alias.aliases {
+				if _, exists := doc[prefix+old]; !exists {
+					doc[prefix+old] = value
+				}
+			}
+		}
+
+		if len(alias.nested) > 0 {
+			// Whole documents carry nested structs as subdocuments while
+			// StructToM flattens them into dotted keys
+			if nested, ok := doc[key].(bson.M); ok {
+				dualWriteAliases(nested, alias.nested, "")
+			} else if nested, ok := doc[key].(bson.D); ok {
+				nestedM := bson.M{}
+				for _, e := range nested {
+					nestedM[e.Key] = e.Value
+				}
+				dualWriteAliases(nestedM, alias.nested, "")
+				for _, e := range nested {
+					delete(nestedM, e.Key)
+				}
+				// Legacy keys are appended after the document's fields
+				for old, value := range nestedM {
+					nested = append(nested, bson.E{Key: old, Value: value})
+				}
+				doc[key] = nested
+			} else {
+				dualWriteAliases(doc, alias.nested, key+".")
+			}
+		}
+	}
+}
+
+func indexOfKey(doc bson.D, key string) int {
+	for i, e := range doc {
+		if e.Key == key {
+			return i
+		}
+	}
+	return -1
+}
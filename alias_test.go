@@ -0,0 +1,23 @@
+package mongoquerier
+
+import (
+	"testing"
+)
+
+type aliasedNode struct {
+	Title  string       `bson:"title" mq:"alias=name"`
+	Parent *aliasedNode `bson:"parent"`
+}
+
+func TestAliasesRecursiveModel(t *testing.T) {
+	aliases := aliasesFor(modelType[aliasedNode]())
+	if len(aliases) != 1 || aliases[0].key != "title" {
+		t.Fatalf("aliasesFor() = %+v, want the title alias", aliases)
+	}
+
+	q := NewQuerier[aliasedNode](newTestAdapter(t), "nodes")
+	q.DualWriteAliases = true
+	if _, err := q.prepareDocument(aliasedNode{Title: "root", Parent: &aliasedNode{Title: "parent"}}); err != nil {
+		t.Fatal(err)
+	}
+}
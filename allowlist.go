@@ -0,0 +1,257 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrQueryNotAllowed = errors.New("query not allowed by policy")
+
+// DefaultAllowedOperators are the operators a QueryAllowlist without
+// Operators permits: comparisons, membership, existence and logic. Implicit
+// equality ({"name": "x"}) counts as $eq.
+var DefaultAllowedOperators = []string{"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin", "$exists", "$and", "$or"}
+
+// QueryAllowlist lists the fields and operators untrusted filters may use on
+// a collection, as a defense in depth on top of validating them:
+//
+//	orders.QueryAllowlist = &mongoquerier.QueryAllowlist{
+//		Fields: []string{"status", "total", "shipping.*"},
+//	}
+//	documents, err := orders.FindByM(mongoquerier.Untrusted(ctx), clientFilter)
+//
+// Fields are dotted paths; "shipping.*" allows every path under shipping.
+// The allowlist is only enforced on calls made with Untrusted. Untrusted
+// calls without a filter to check, such as Aggregate, Watch or
+// DeleteCollection, are rejected; inserts, whose documents aren't queries,
+// pass.
+type QueryAllowlist struct {
+	Fields []string
+	// Operators defaults to DefaultAllowedOperators.
+	Operators []string
+}
+
+type untrustedKey struct{}
+
+// Untrusted marks the filters of the calls made with the returned context as
+// client-provided: they're checked against the querier's QueryAllowlist, and
+// rejected with ErrQueryNotAllowed when the querier has none.
+func Untrusted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untrustedKey{}, true)
+}
+
+func isUntrusted(ctx context.Context) bool {
+	untrusted, _ := ctx.Value(untrustedKey{}).(bool)
+	return untrusted
+}
+
+func (a *QueryAllowlist) allowsField(field string) bool {
+	for _, allowed := range a.Fields {
+		if allowed == field {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *QueryAllowlist) allowsOperator(operator string) bool {
+	operators := a.Operators
+	if operators == nil {
+		operators = DefaultAllowedOperators
+	}
+	for _, allowed := range operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an ErrQueryNotAllowed naming the first field or operator of
+// filter the allowlist doesn't permit.
+func (a *QueryAllowlist) Check(filter primitive.M) error {
+	return a.checkQuery(filter, "")
+}
+
+// checkQuery checks a query document, whose keys are fields (under prefix,
+// for $elemMatch) or top-level operators.
+func (a *QueryAllowlist) checkQuery(query interface{}, prefix string) error {
+	document, ok := asDocument(query)
+	if !ok {
+		return fmt.Errorf("%w: malformed query %v", ErrQueryNotAllowed, query)
+	}
+
+	for _, e := range document {
+		if !strings.HasPrefix(e.Key, "$") {
+			field := prefix + e.Key
+			if !a.allowsField(field) {
+				return fmt.Errorf("%w: field %q", ErrQueryNotAllowed, field)
+			}
+			if err := a.checkCondition(e.Value, field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !a.allowsOperator(e.Key) {
+			return fmt.Errorf("%w: operator %s", ErrQueryNotAllowed, e.Key)
+		}
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			clauses, ok := asArray(e.Value)
+			if !ok {
+				return fmt.Errorf("%w: %s takes an array", ErrQueryNotAllowed, e.Key)
+			}
+			for _, clause := range clauses {
+				if err := a.checkQuery(clause, prefix); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkCondition checks the condition on field: a value matched for
+// equality, or an operator document.
+func (a *QueryAllowlist) checkCondition(condition interface{}, field string) error {
+	document, ok := asDocument(condition)
+	if !ok || !hasOperator(document) {
+		if !a.allowsOperator("$eq") {
+			return fmt.Errorf("%w: equality on %q", ErrQueryNotAllowed, field)
+		}
+		return nil
+	}
+
+	for _, e := range document {
+		if !strings.HasPrefix(e.Key, "$") {
+			return fmt.Errorf("%w: malformed condition on %q", ErrQueryNotAllowed, field)
+		}
+		if !a.allowsOperator(e.Key) {
+			return fmt.Errorf("%w: operator %s on %q", ErrQueryNotAllowed, e.Key, field)
+		}
+		switch e.Key {
+		case "$not":
+			if err := a.checkCondition(e.Value, field); err != nil {
+				return err
+			}
+		case "$elemMatch":
+			if err := a.checkElemMatch(e.Value, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkElemMatch checks $elemMatch, holding either conditions on the
+// elements themselves or a query on their fields.
+func (a *QueryAllowlist) checkElemMatch(match interface{}, field string) error {
+	document, ok := asDocument(match)
+	if ok && hasOperator(document) && !hasLogicalOperator(document) {
+		return a.checkCondition(match, field)
+	}
+	return a.checkQuery(match, field+".")
+}
+
+func hasOperator(document bson.D) bool {
+	for _, e := range document {
+		if strings.HasPrefix(e.Key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLogicalOperator(document bson.D) bool {
+	for _, e := range document {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			return true
+		}
+	}
+	return false
+}
+
+func asDocument(value interface{}) (bson.D, bool) {
+	switch value := value.(type) {
+	case bson.D:
+		return value, true
+	case bson.M:
+		return mToD(value), true
+	case map[string]interface{}:
+		return mToD(value), true
+	}
+	return nil, false
+}
+
+func mToD(m map[string]interface{}) bson.D {
+	d := make(bson.D, 0, len(m))
+	for key, value := range m {
+		d = append(d, bson.E{Key: key, Value: value})
+	}
+	return d
+}
+
+func asArray(value interface{}) ([]interface{}, bool) {
+	switch value := value.(type) {
+	case bson.A:
+		return value, true
+	case []interface{}:
+		return value, true
+	case []bson.M:
+		values := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			values = append(values, v)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// filterlessWrites are the operations without a filter that untrusted calls
+// may run: their documents aren't queries, and the filters of a BulkWrite's
+// models are checked one by one.
+var filterlessWrites = []string{"InsertOne", "InsertMany", "BulkWrite", "Import"}
+
+// pipelineOperations are the operations running a caller's pipeline, which
+// untrusted calls may not.
+var pipelineOperations = []string{"Aggregate", "AggregateIter", "AggregateToWriter"}
+
+// checkAllowlist enforces the querier's allowlist on untrusted filters.
+func (q *Querier[Model, IDModel]) checkAllowlist(ctx context.Context, operation string, filter primitive.M) error {
+	if !isUntrusted(ctx) {
+		return nil
+	}
+
+	var err error
+	if q.QueryAllowlist == nil {
+		err = fmt.Errorf("%w: %s has no query allowlist", ErrQueryNotAllowed, q.collection.Name())
+	} else if containsString(pipelineOperations, operation) {
+		// Only a leading $match would be checked, not the stages after it
+		err = fmt.Errorf("%w: %s runs a pipeline", ErrQueryNotAllowed, operation)
+	} else if filter != nil {
+		err = q.QueryAllowlist.Check(filter)
+	} else if !containsString(filterlessWrites, operation) {
+		// Pipelines and whole-collection operations escape the allowlist
+		err = fmt.Errorf("%w: %s has no filter to check", ErrQueryNotAllowed, operation)
+	}
+	if err != nil {
+		q.MongoAdapter.Warn(
+			"Rejected untrusted query",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("query_shape", QueryShape(filter)),
+			LogError(err),
+		)
+	}
+	return err
+}
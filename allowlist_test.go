@@ -0,0 +1,34 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCheckAllowlistRejectsFilterlessOperations(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	q.QueryAllowlist = &QueryAllowlist{Fields: []string{"email"}}
+	ctx := Untrusted(context.Background())
+
+	for _, operation := range []string{"Aggregate", "AggregateIter", "AggregateToWriter", "Watch", "DeleteCollection"} {
+		if err := q.checkAllowlist(ctx, operation, nil); !errors.Is(err, ErrQueryNotAllowed) {
+			t.Errorf("checkAllowlist(%s) = %v, want ErrQueryNotAllowed", operation, err)
+		}
+	}
+	if err := q.checkAllowlist(ctx, "InsertOne", nil); err != nil {
+		t.Errorf("checkAllowlist(InsertOne) = %v, want nil", err)
+	}
+	if err := q.checkAllowlist(ctx, "FindByM", bson.M{"email": "a@b.c"}); err != nil {
+		t.Errorf("checkAllowlist(FindByM) = %v, want nil", err)
+	}
+	if err := q.checkAllowlist(ctx, "FindByM", bson.M{"parent": nil}); !errors.Is(err, ErrQueryNotAllowed) {
+		t.Errorf("checkAllowlist(FindByM) on parent = %v, want ErrQueryNotAllowed", err)
+	}
+	// Trusted calls aren't checked
+	if err := q.checkAllowlist(context.Background(), "Aggregate", nil); err != nil {
+		t.Errorf("trusted checkAllowlist(Aggregate) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,98 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type analyticsKey struct{}
+
+// Analytics routes the reads issued with the returned context to the
+// adapter's analytics cluster, when one is configured.
+func Analytics(ctx context.Context) context.Context {
+	return context.WithValue(ctx, analyticsKey{}, true)
+}
+
+func IsAnalytics(ctx context.Context) bool {
+	analytics, _ := ctx.Value(analyticsKey{}).(bool)
+	return analytics
+}
+
+// routesToAnalytics reports whether a read goes to the analytics cluster,
+// either because the querier is pinned to it or the call asked for it.
+// Sessions (transactions, snapshot bundles) are bound to the primary cluster.
+func (q *Querier[Model, IDModel]) routesToAnalytics(ctx context.Context) bool {
+	return q.MongoAdapter.Analytics != nil && (q.UseAnalytics || IsAnalytics(ctx)) && !inSession(ctx)
+}
+
+// readCollection returns the collection reads should go through. Writes
+// always go to the primary cluster.
+func (q *Querier[Model, IDModel]) readCollection(ctx context.Context) *mongo.Collection {
+	collection := q.tenantCollection(ctx)
+	if q.routesToAnalytics(ctx) {
+		if q.MongoAdapter.Tenants != nil {
+			return q.MongoAdapter.Analytics.GetCollectionIn(collection.Database().Name(), q.collection.Name())
+		}
+		return q.MongoAdapter.Analytics.GetCollection(q.collection.Name())
+	}
+	if q.AdaptiveReads != nil && !inSession(ctx) {
+		return q.adaptiveCollection(collection)
+	}
+	return collection
+}
+
+// RoutedResult carries documents together with where they were read from.
+// Results from the analytics cluster are a mirror of the primary cluster and
+// may lag behind recent writes.
+type RoutedResult[Model any] struct {
+	Documents            []*Model
+	Cluster              string
+	EventuallyConsistent bool
+	ReadAt               time.Time
+}
+
+const (
+	ClusterPrimary   = "primary"
+	ClusterAnalytics = "analytics"
+)
+
+func (q *Querier[Model, IDModel]) routedResult(ctx context.Context, documents []*Model) *RoutedResult[Model] {
+	result := &RoutedResult[Model]{
+		Documents: documents,
+		Cluster:   ClusterPrimary,
+		ReadAt:    time.Now(),
+	}
+	if q.routesToAnalytics(ctx) {
+		result.Cluster = ClusterAnalytics
+		result.EventuallyConsistent = true
+	}
+	return result
+}
+
+func (q *Querier[Model, IDModel]) FindRouted(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) (*RoutedResult[Model], error) {
+	documents, err := q.FindByM(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.routedResult(ctx, documents), nil
+}
+
+func (q *Querier[Model, IDModel]) AggregateRouted(ctx context.Context, pipeline mongo.Pipeline, opts ...*options.AggregateOptions) (*RoutedResult[Model], error) {
+	documents, err := q.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := q.routedResult(ctx, documents)
+	q.MongoAdapter.Debug(
+		"Aggregated documents with routing",
+		LogField("collection_name", q.collection.Name()),
+		LogField("cluster", result.Cluster),
+	)
+	return result, nil
+}
@@ -0,0 +1,299 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrOverlappingMaskRules = errors.New("masking rule paths overlap")
+
+const (
+	DefaultAnonymizeBatchSize = 500
+	DefaultAnonymizePause     = 100 * time.Millisecond
+)
+
+// Masker replaces a stored value with an anonymized one.
+type Masker func(value interface{}) interface{}
+
+// HashMasker replaces values with their salted SHA-256 (hex). Equal values
+// hash alike, so joins and uniqueness survive anonymization.
+func HashMasker(salt string) Masker {
+	return func(value interface{}) interface{} {
+		return saltedHash(salt, value)
+	}
+}
+
+// FakeEmailMasker replaces values with deterministic, undeliverable emails.
+func FakeEmailMasker(salt string) Masker {
+	return func(value interface{}) interface{} {
+		return "user-" + saltedHash(salt, value)[:12] + "@example.invalid"
+	}
+}
+
+// FakeNameMasker replaces values with deterministic placeholder names.
+func FakeNameMasker(salt string) Masker {
+	return func(value interface{}) interface{} {
+		return "Person " + strings.ToUpper(saltedHash(salt, value)[:8])
+	}
+}
+
+// ConstantMasker replaces every value with value (nil to null fields out).
+func ConstantMasker(value interface{}) Masker {
+	return func(interface{}) interface{} {
+		return value
+	}
+}
+
+func saltedHash(salt string, value interface{}) string {
+	sum := sha256.Sum256([]byte(salt + fmt.Sprint(value)))
+	return hex.EncodeToString(sum[:])
+}
+
+// PIIMaskingRules builds Anonymize rules from a PII classification (see
+// PIIFields): emails and names get fakes, anything else is hashed. Fields
+// within a classified field are masked with it, so they get no rule.
+func PIIMaskingRules(fields map[string]string, salt string) map[string]Masker {
+	rules := make(map[string]Masker, len(fields))
+	for path, kind := range fields {
+		if withinField(fields, path) {
+			continue
+		}
+		switch kind {
+		case PIIEmail:
+			rules[path] = FakeEmailMasker(salt)
+		case PIIName:
+			rules[path] = FakeNameMasker(salt)
+		default:
+			rules[path] = HashMasker(salt)
+		}
+	}
+	return rules
+}
+
+type AnonymizeOptions struct {
+	BatchSize int
+	// Pause between batches keeps the rewrite from starving other traffic.
+	Pause time.Duration
+}
+
+// Anonymize rewrites the documents matching filter, replacing the value of
+// every rule's (dotted) field path with its Masker's result. Paths run
+// through arrays of documents (contacts.email masks the email of every
+// contact) and arrays of scalars are masked element by element. Rule paths
+// mustn't be prefixes of one another (ErrOverlappingMaskRules). Documents
+// are processed in _id order, in paced batches; it returns the number of
+// documents rewritten.
+func (q *Querier[Model, IDModel]) Anonymize(ctx context.Context, filter primitive.M, rules map[string]Masker, opts ...*AnonymizeOptions) (anonymized int64, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	for path := range rules {
+		if other := overlappingPath(rules, path); other != "" {
+			return 0, q.opError(fmt.Errorf("%w: %s and %s", ErrOverlappingMaskRules, path, other), time.Now(), "Anonymize", filter)
+		}
+	}
+	if err = q.preflight(ctx, "Anonymize", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "Anonymize", filter)
+	defer q.observe(span, time.Now(), "Anonymize", filter, &err)
+	if filter == nil {
+		filter = primitive.M{}
+	}
+
+	batchSize, pause := DefaultAnonymizeBatchSize, DefaultAnonymizePause
+	for _, opt := range opts {
+		if opt.BatchSize > 0 {
+			batchSize = opt.BatchSize
+		}
+		if opt.Pause > 0 {
+			pause = opt.Pause
+		}
+	}
+
+	// Whole top-level fields are read and written back: projecting a path
+	// through an array would drop the elements' other fields
+	projection := bson.M{"_id": 1}
+	for path := range rules {
+		projection[strings.SplitN(path, ".", 2)[0]] = 1
+	}
+
+	var lastID interface{}
+	for {
+		batchFilter := filter
+		if lastID != nil {
+			batchFilter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(int64(batchSize)).
+			SetProjection(projection)
+		cursor, err := retrying(ctx, q, "Anonymize", func() (*mongo.Cursor, error) {
+			return q.tenantCollection(ctx).Find(ctx, batchFilter, findOptions)
+		})
+		if err != nil {
+			return anonymized, err
+		}
+		var batch []bson.M
+		if err = cursor.All(ctx, &batch); err != nil {
+			return anonymized, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		var models []mongo.WriteModel
+		for _, document := range batch {
+			lastID = document["_id"]
+
+			set := bson.M{}
+			for path, mask := range rules {
+				key := strings.SplitN(path, ".", 2)[0]
+				value, ok := set[key]
+				if !ok {
+					if value, ok = document[key]; !ok {
+						continue
+					}
+				}
+				if masked, ok := maskPath(value, strings.Split(path, ".")[1:], mask); ok {
+					set[key] = masked
+				}
+			}
+			if len(set) > 0 {
+				models = append(models, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": lastID}).
+					SetUpdate(bson.M{"$set": set}))
+			}
+		}
+
+		if len(models) > 0 {
+			res, err := retrying(ctx, q, "Anonymize", func() (*mongo.BulkWriteResult, error) {
+				return q.writeCollection(ctx).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			})
+			if err != nil {
+				q.logWriteFailure(ctx, "Anonymize", err)
+				return anonymized, err
+			}
+			anonymized += res.MatchedCount
+		}
+
+		q.MongoAdapter.Debug(
+			"Anonymized a batch of documents",
+			LogField("collection_name", q.collection.Name()),
+			LogField("documents_count", len(models)),
+			LogField("documents_anonymized", anonymized),
+		)
+
+		if len(batch) < batchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return anonymized, ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+
+	q.MongoAdapter.Info(
+		"Anonymized documents",
+		LogField("collection_name", q.collection.Name()),
+		LogField("rules_count", len(rules)),
+		LogField("documents_anonymized", anonymized),
+	)
+	return anonymized, nil
+}
+
+// withinField reports whether a path of fields is a prefix of path.
+func withinField(fields map[string]string, path string) bool {
+	for field := range fields {
+		if strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// overlappingPath returns a path of rules that is a prefix of path, or that
+// path is a prefix of, if any.
+func overlappingPath[Rule any](rules map[string]Rule, path string) string {
+	for other := range rules {
+		if other != path && (strings.HasPrefix(other, path+".") || strings.HasPrefix(path, other+".")) {
+			return other
+		}
+	}
+	return ""
+}
+
+// maskPath returns a copy of value with the values at path (its remaining
+// keys) masked, walking into every element of the arrays on the way or the
+// one a numeric key picks, and whether path led anywhere.
+func maskPath(value interface{}, path []string, mask Masker) (interface{}, bool) {
+	if len(path) == 0 {
+		return maskValue(value, mask), true
+	}
+
+	switch value := value.(type) {
+	case bson.M:
+		element, ok := value[path[0]]
+		if !ok {
+			return value, false
+		}
+		masked, ok := maskPath(element, path[1:], mask)
+		if !ok {
+			return value, false
+		}
+		copied := make(bson.M, len(value))
+		for k, v := range value {
+			copied[k] = v
+		}
+		copied[path[0]] = masked
+		return copied, true
+	case bson.A:
+		copied := make(bson.A, len(value))
+		copy(copied, value)
+		if i, err := strconv.Atoi(path[0]); err == nil {
+			if i < 0 || i >= len(value) {
+				return value, false
+			}
+			masked, ok := maskPath(value[i], path[1:], mask)
+			copied[i] = masked
+			return copied, ok
+		}
+
+		found := false
+		for i, element := range value {
+			if masked, ok := maskPath(element, path, mask); ok {
+				copied[i] = masked
+				found = true
+			}
+		}
+		return copied, found
+	}
+	return value, false
+}
+
+func maskValue(value interface{}, mask Masker) interface{} {
+	if values, ok := value.(bson.A); ok {
+		masked := make(bson.A, len(values))
+		for i, element := range values {
+			masked[i] = mask(element)
+		}
+		return masked
+	}
+	if value == nil {
+		return nil
+	}
+	return mask(value)
+}
@@ -0,0 +1,66 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMaskPathThroughArrays(t *testing.T) {
+	mask := ConstantMasker("x")
+	document := bson.M{
+		"contacts": bson.A{
+			bson.M{"email": "a@b.c", "kind": "work"},
+			bson.M{"kind": "fax"},
+			bson.M{"email": bson.A{"c@d.e", "f@g.h"}},
+		},
+	}
+
+	tests := []struct {
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{"contacts.email", bson.A{
+			bson.M{"email": "x", "kind": "work"},
+			bson.M{"kind": "fax"},
+			bson.M{"email": bson.A{"x", "x"}},
+		}, true},
+		{"contacts.0.email", bson.A{
+			bson.M{"email": "x", "kind": "work"},
+			bson.M{"kind": "fax"},
+			bson.M{"email": bson.A{"c@d.e", "f@g.h"}},
+		}, true},
+		{"contacts.phone", document["contacts"], false},
+		{"contacts.5.email", document["contacts"], false},
+	}
+	for _, tt := range tests {
+		got, found := maskPath(document["contacts"], strings.Split(tt.path, ".")[1:], mask)
+		if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("maskPath(%s) = %v, %v, want %v, %v", tt.path, got, found, tt.want, tt.found)
+		}
+	}
+
+	// The document itself is left alone
+	if email := document["contacts"].(bson.A)[0].(bson.M)["email"]; email != "a@b.c" {
+		t.Errorf("document email = %v after masking, want a@b.c", email)
+	}
+}
+
+func TestAnonymizeRejectsOverlappingRules(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	rules := map[string]Masker{"link": ConstantMasker(nil), "link.name": ConstantMasker("x")}
+	if _, err := q.Anonymize(context.Background(), primitive.M{}, rules); !errors.Is(err, ErrOverlappingMaskRules) {
+		t.Errorf("Anonymize() error = %v, want ErrOverlappingMaskRules", err)
+	}
+
+	fields := map[string]string{"link": PIIID, "link.name": PIIName, "email": PIIEmail}
+	if rules := PIIMaskingRules(fields, "salt"); len(rules) != 2 || rules["link.name"] != nil {
+		t.Errorf("PIIMaskingRules() paths = %v, want link and email", reflect.ValueOf(rules).MapKeys())
+	}
+}
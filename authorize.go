@@ -0,0 +1,98 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnauthorized is matched by every error returned from an Authorize hook.
+var ErrUnauthorized = errors.New("operation not authorized")
+
+type OperationKind string
+
+const (
+	OperationRead  OperationKind = "read"
+	OperationWrite OperationKind = "write"
+)
+
+// OperationDescriptor describes an operation about to run, for
+// MongoAdapter.Authorize. Besides queriers' operations, it describes those
+// the adapter runs on collections itself: ExportSubject, EraseSubject,
+// ApplyRetention, ApplyRollup, RebuildRollup and AggregateRollup (on a
+// rollup's source), Reserve, Confirm, Release and Lookup on reservations,
+// SaveCheckpoint, LoadCheckpoint and DeleteCheckpoint, and CreateSubjectKey,
+// LookupSubjectKey and ShredSubjectKey on subject keys.
+//
+// Aggregations are also described once per other collection their pipeline
+// reaches: as reads for $lookup, $graphLookup and $unionWith, as writes for
+// $out and $merge.
+type OperationDescriptor struct {
+	Collection string
+	// Operation is the method name, e.g. "FindOneByM" or "InsertMany".
+	Operation string
+	Kind      OperationKind
+	// FilterShape is the filter with values stripped (see QueryShape) and
+	// FilterFields its top-level field names; both are empty without filter.
+	FilterShape  string
+	FilterFields []string
+	Filter       primitive.M
+}
+
+// writeOperationPrefixes classify operation names as writes, anything else
+// reads.
+var writeOperationPrefixes = []string{"Insert", "Update", "Replace", "Delete", "Push", "Create", "Anonymize", "Claim", "Bulk", "Upsert", "Import", "Apply", "Erase", "Rebuild", "Reserve", "Confirm", "Release", "Save", "Shred"}
+
+func operationKind(operation string) OperationKind {
+	for _, prefix := range writeOperationPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return OperationWrite
+		}
+	}
+	return OperationRead
+}
+
+func describeOperation(collectionName string, operation string, filter primitive.M) OperationDescriptor {
+	descriptor := OperationDescriptor{
+		Collection: collectionName,
+		Operation:  operation,
+		Kind:       operationKind(operation),
+		Filter:     filter,
+	}
+	if filter != nil {
+		descriptor.FilterShape = QueryShape(filter)
+		for field := range filter {
+			descriptor.FilterFields = append(descriptor.FilterFields, field)
+		}
+		sort.Strings(descriptor.FilterFields)
+	}
+	return descriptor
+}
+
+// authorize runs the adapter's Authorize hook, if any.
+func (madp *MongoAdapter) authorize(ctx context.Context, descriptor OperationDescriptor) error {
+	if madp.Authorize == nil {
+		return nil
+	}
+
+	err := madp.Authorize(ctx, descriptor)
+	if err == nil {
+		return nil
+	}
+
+	madp.Warn(
+		"Denied operation",
+		LogField("collection_name", descriptor.Collection),
+		LogField("operation", descriptor.Operation),
+		LogField("query_shape", descriptor.FilterShape),
+		LogError(err),
+	)
+	if !errors.Is(err, ErrUnauthorized) {
+		err = fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAdapterOperationsAreAuthorized(t *testing.T) {
+	madp := newTestAdapter(t)
+	var denied []string
+	madp.Authorize = func(ctx context.Context, op OperationDescriptor) error {
+		denied = append(denied, op.Operation+" "+string(op.Kind))
+		return errors.New("denied")
+	}
+	ctx := context.Background()
+	subject := bson.M{"user_id": "u1"}
+
+	checks := map[string]error{}
+	_, checks["ExportSubject"] = madp.ExportSubject(ctx, subject, "users")
+	_, checks["EraseSubject"] = madp.EraseSubject(ctx, subject, "users")
+	_, checks["ApplyRetention"] = NewRetentionEngine(madp).Apply(ctx, RetentionPolicy{Collection: "events", MaxAge: time.Hour})
+	rollup := Rollup{Name: "daily", Source: "events", Target: "daily", Aggregations: map[string]bson.M{"count": {"$sum": 1}}}
+	_, checks["ApplyRollup"] = (&RollupEngine{MongoAdapter: madp}).Apply(ctx, rollup)
+	reservations := &ReservationStore{MongoAdapter: madp, collection: madp.GetCollection("reservations")}
+	_, checks["Reserve"] = reservations.Reserve(ctx, "username", "ada", "u1", time.Minute)
+	checks["SaveCheckpoint"] = NewCheckpointStore(madp, "").SaveCheckpoint(ctx, "job", 1)
+
+	for operation, err := range checks {
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s error = %v, want ErrUnauthorized", operation, err)
+		}
+	}
+	want := []string{"ExportSubject read", "EraseSubject write", "ApplyRetention write", "AggregateRollup read", "Reserve write", "SaveCheckpoint write"}
+	if len(denied) != len(want) {
+		t.Fatalf("authorized %v, want %v", denied, want)
+	}
+	for i := range want {
+		if denied[i] != want[i] {
+			t.Errorf("authorized %v, want %v", denied, want)
+			break
+		}
+	}
+}
+
+func TestPipelineCollectionsAreAuthorized(t *testing.T) {
+	madp := newTestAdapter(t)
+	var authorized []string
+	madp.Authorize = func(ctx context.Context, op OperationDescriptor) error {
+		authorized = append(authorized, op.Collection+" "+string(op.Kind))
+		if op.Kind == OperationWrite {
+			return errors.New("read-only")
+		}
+		return nil
+	}
+	q := NewQuerier[recursiveNode](madp, "nodes")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"email": "a@b.c"}}},
+		{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "links"}, {Key: "pipeline", Value: mongo.Pipeline{
+			{{Key: "$unionWith", Value: "archived_links"}},
+		}}}}},
+		{{Key: "$merge", Value: bson.D{{Key: "into", Value: "reports"}}}},
+	}
+	_, err := q.Aggregate(context.Background(), pipeline)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Aggregate() = %v, want ErrUnauthorized", err)
+	}
+	want := []string{"links read", "archived_links read", "reports write"}
+	if len(authorized) != len(want) {
+		t.Fatalf("authorized %v, want %v", authorized, want)
+	}
+	for i := range want {
+		if authorized[i] != want[i] {
+			t.Errorf("authorized %v, want %v", authorized, want)
+			break
+		}
+	}
+
+	pipeline = mongo.Pipeline{{{Key: "$out", Value: bson.D{{Key: "db", Value: "admin"}, {Key: "coll", Value: "users"}}}}}
+	if _, err := q.Aggregate(context.Background(), pipeline); !errors.Is(err, ErrCrossDatabaseStage) {
+		t.Errorf("Aggregate() into admin = %v, want ErrCrossDatabaseStage", err)
+	}
+
+	filter := leadingMatch(mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "email", Value: "a@b.c"}}}}})
+	if filter["email"] != "a@b.c" {
+		t.Errorf("leadingMatch() = %v, want the $match filter", filter)
+	}
+}
+
+func TestFindUnionAuthorizesEveryCollection(t *testing.T) {
+	madp := newTestAdapter(t)
+	var authorized []string
+	madp.Authorize = func(ctx context.Context, op OperationDescriptor) error {
+		authorized = append(authorized, op.Collection)
+		if op.Collection == "payroll" {
+			return errors.New("denied")
+		}
+		return nil
+	}
+	q := NewQuerier[recursiveNode](madp, "nodes")
+
+	_, err := q.FindUnionByM(context.Background(), bson.M{"email": "a@b.c"}, "nodes_2025", "payroll")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("FindUnionByM() = %v, want ErrUnauthorized", err)
+	}
+	if len(authorized) != 2 || authorized[0] != "nodes_2025" || authorized[1] != "payroll" {
+		t.Errorf("authorized %v, want nodes_2025 and payroll", authorized)
+	}
+}
@@ -0,0 +1,238 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrUnsupportedWriteModel = errors.New("unsupported write model")
+
+// WriteModel is one operation of a BulkWrite: InsertOneModel,
+// UpdateOneModel, UpdateManyModel, ReplaceOneModel, DeleteOneModel or
+// DeleteManyModel. Filters and updates are models converted with StructToM,
+// and updates $set their non-zero fields, as in UpdateOne.
+type WriteModel[Model any] interface {
+	writeModel(Model)
+}
+
+type InsertOneModel[Model any] struct {
+	Document Model
+}
+
+// UpdateOneModel sets the non-zero fields of Update, or every field with
+// IncludeZero (see Update.SetAll), on the first document matching Filter.
+type UpdateOneModel[Model any] struct {
+	Filter      Model
+	Update      Model
+	Upsert      bool
+	IncludeZero bool
+}
+
+// UpdateManyModel is UpdateOneModel for every document matching Filter.
+type UpdateManyModel[Model any] struct {
+	Filter      Model
+	Update      Model
+	Upsert      bool
+	IncludeZero bool
+}
+
+type ReplaceOneModel[Model any] struct {
+	Filter      Model
+	Replacement Model
+	Upsert      bool
+}
+
+type DeleteOneModel[Model any] struct {
+	Filter Model
+}
+
+type DeleteManyModel[Model any] struct {
+	Filter Model
+}
+
+func (InsertOneModel[Model]) writeModel(Model)  {}
+func (UpdateOneModel[Model]) writeModel(Model)  {}
+func (UpdateManyModel[Model]) writeModel(Model) {}
+func (ReplaceOneModel[Model]) writeModel(Model) {}
+func (DeleteOneModel[Model]) writeModel(Model)  {}
+func (DeleteManyModel[Model]) writeModel(Model) {}
+
+type BulkWriteResult[IDModel any] struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	// UpsertedIDs maps the index of each upserting model to the _id of the
+	// document it inserted.
+	UpsertedIDs map[int64]IDModel
+}
+
+// BulkWrite sends models to the server in one batch. On a partial failure
+// (mongo.BulkWriteException) the result of the writes that succeeded is
+// returned along with the error.
+func (q *Querier[Model, IDModel]) BulkWrite(ctx context.Context, models []WriteModel[Model], opts ...*options.BulkWriteOptions) (result *BulkWriteResult[IDModel], err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "BulkWrite", nil); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "BulkWrite", nil)
+	defer q.observe(span, time.Now(), "BulkWrite", nil, &err)
+
+	writeModels := make([]mongo.WriteModel, 0, len(models))
+	for _, model := range models {
+		writeModel, filterM, err := q.bulkWriteModel(ctx, model)
+		if err != nil {
+			return nil, err
+		}
+		if filterM != nil {
+			if err = q.checkOperation(ctx, "BulkWrite", filterM); err != nil {
+				return nil, err
+			}
+		}
+		writeModels = append(writeModels, writeModel)
+	}
+
+	res, err := retrying(ctx, q, "BulkWrite", func() (*mongo.BulkWriteResult, error) {
+		return q.writeCollection(ctx).BulkWrite(ctx, writeModels, opts...)
+	})
+	if err != nil {
+		q.logWriteFailure(ctx, "BulkWrite", err)
+		if res == nil {
+			return nil, err
+		}
+	}
+
+	result = &BulkWriteResult[IDModel]{
+		InsertedCount: res.InsertedCount,
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		DeletedCount:  res.DeletedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedIDs:   make(map[int64]IDModel, len(res.UpsertedIDs)),
+	}
+	for index, id := range res.UpsertedIDs {
+		q.trackInserted(ctx, id)
+		upsertedID, castErr := castUpsertedID[IDModel](id)
+		if castErr != nil {
+			return result, castErr
+		}
+		result.UpsertedIDs[index] = upsertedID
+	}
+
+	q.MongoAdapter.Debug(
+		"Bulk wrote documents",
+		LogField("collection_name", q.collection.Name()),
+		LogField("models_count", len(models)),
+		LogField("documents_inserted", result.InsertedCount),
+		LogField("documents_modified", result.ModifiedCount),
+		LogField("documents_deleted", result.DeletedCount),
+		LogField("documents_upserted", result.UpsertedCount),
+	)
+	return result, err
+}
+
+// bulkWriteModel converts a typed model to the driver's, returning its filter
+// (nil for inserts) for the preflight checks.
+func (q *Querier[Model, IDModel]) bulkWriteModel(ctx context.Context, model WriteModel[Model]) (mongo.WriteModel, primitive.M, error) {
+	switch m := model.(type) {
+	case InsertOneModel[Model]:
+		document, err := q.prepareDocument(m.Document)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = q.checkSize("BulkWrite", document); err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewInsertOneModel().SetDocument(document), nil, nil
+
+	case UpdateOneModel[Model]:
+		filterM, updateM, err := q.bulkUpdate(m.Filter, m.Update, m.IncludeZero)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewUpdateOneModel().SetFilter(filterM).SetUpdate(updateM).SetUpsert(m.Upsert), filterM, nil
+
+	case UpdateManyModel[Model]:
+		filterM, updateM, err := q.bulkUpdate(m.Filter, m.Update, m.IncludeZero)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewUpdateManyModel().SetFilter(filterM).SetUpdate(updateM).SetUpsert(m.Upsert), filterM, nil
+
+	case ReplaceOneModel[Model]:
+		filterM, err := StructToM(m.Filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		replacementM, err := q.prepareReplacement(m.Replacement)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = q.checkSize("BulkWrite", replacementM); err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewReplaceOneModel().SetFilter(filterM).SetReplacement(replacementM).SetUpsert(m.Upsert), filterM, nil
+
+	case DeleteOneModel[Model]:
+		filterM, err := StructToM(m.Filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewDeleteOneModel().SetFilter(filterM), filterM, nil
+
+	case DeleteManyModel[Model]:
+		filterM, err := StructToM(m.Filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mongo.NewDeleteManyModel().SetFilter(filterM), filterM, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %T", ErrUnsupportedWriteModel, model)
+}
+
+func (q *Querier[Model, IDModel]) bulkUpdate(filter Model, update Model, includeZero bool) (primitive.M, primitive.M, error) {
+	filterM, err := StructToM(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	updateM, err := StructToM(update)
+	if includeZero {
+		updateM, err = StructToMWithZero(update)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("BulkWrite", updateM); err != nil {
+		return nil, nil, err
+	}
+	return filterM, updateM, nil
+}
+
+// castUpsertedID casts an upserted _id to IDModel, decoding composite IDs
+// (embedded documents) through BSON.
+func castUpsertedID[IDModel any](id interface{}) (IDModel, error) {
+	if upsertedID, ok := castID[IDModel](id); ok {
+		return upsertedID, nil
+	}
+
+	var upsertedID IDModel
+	t, data, err := bson.MarshalValue(id)
+	if err != nil {
+		return upsertedID, err
+	}
+	if err = (bson.RawValue{Type: t, Value: data}).Unmarshal(&upsertedID); err != nil {
+		return upsertedID, fmt.Errorf("%w: %v", ErrFailedToCastInsertedID, err)
+	}
+	return upsertedID, nil
+}
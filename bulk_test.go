@@ -0,0 +1,44 @@
+package mongoquerier
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBulkUpdateIncludeZero(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+
+	model, _, err := q.bulkWriteModel(context.Background(), UpdateOneModel[recursiveNode]{Filter: recursiveNode{Email: "a@b.c"}, Update: recursiveNode{}, IncludeZero: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := model.(*mongo.UpdateOneModel).Update.(bson.M)["$set"].(bson.M)
+	if value, ok := set["email"]; !ok || value != "" {
+		t.Errorf("$set = %v, want email cleared", set)
+	}
+
+	// Without IncludeZero, the next model's zero fields are left alone
+	model, _, err = q.bulkWriteModel(context.Background(), UpdateManyModel[recursiveNode]{Filter: recursiveNode{Email: "a@b.c"}, Update: recursiveNode{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set := model.(*mongo.UpdateManyModel).Update.(bson.M)["$set"].(bson.M); len(set) != 0 {
+		t.Errorf("$set = %v, want no fields", set)
+	}
+}
+
+func TestBulkReplaceKeepsZeroFields(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+
+	model, _, err := q.bulkWriteModel(context.Background(), ReplaceOneModel[recursiveNode]{Filter: recursiveNode{Email: "a@b.c"}, Replacement: recursiveNode{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := model.(*mongo.ReplaceOneModel).Replacement.(bson.M)
+	if value, ok := replacement["email"]; !ok || value != "" {
+		t.Errorf("replacement = %v, want email cleared", replacement)
+	}
+}
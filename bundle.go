@@ -0,0 +1,103 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrBundleClientMismatch = errors.New("bundle reads must use the adapter's client")
+
+// BundleSpec is one read of a bundle, built with BundleFind, BundleFindOne
+// or BundleCount, storing its result in the destination it was given.
+type BundleSpec interface {
+	client() *mongo.Client
+	read(ctx context.Context) error
+}
+
+type bundleRead struct {
+	madp *MongoAdapter
+	fn   func(ctx context.Context) error
+}
+
+func (r bundleRead) client() *mongo.Client {
+	return r.madp.Client
+}
+
+func (r bundleRead) read(ctx context.Context) error {
+	return r.fn(ctx)
+}
+
+// BundleFind reads the documents matching filter into into.
+func BundleFind[Model any, IDModel any](q *Querier[Model, IDModel], filter primitive.M, into *[]*Model, opts ...*options.FindOptions) BundleSpec {
+	return bundleRead{q.MongoAdapter, func(ctx context.Context) (err error) {
+		*into, err = q.FindByM(ctx, filter, opts...)
+		return err
+	}}
+}
+
+// BundleFindOne reads the document matching filter into into.
+// mongo.ErrNoDocuments fails the bundle.
+func BundleFindOne[Model any, IDModel any](q *Querier[Model, IDModel], filter primitive.M, into **Model, opts ...*options.FindOneOptions) BundleSpec {
+	return bundleRead{q.MongoAdapter, func(ctx context.Context) (err error) {
+		*into, err = q.FindOneByM(ctx, filter, opts...)
+		return err
+	}}
+}
+
+// BundleCount counts the documents matching filter into into.
+func BundleCount[Model any, IDModel any](q *Querier[Model, IDModel], filter primitive.M, into *int64, opts ...*options.CountOptions) BundleSpec {
+	return bundleRead{q.MongoAdapter, func(ctx context.Context) (err error) {
+		*into, err = q.CountDocumentsByM(ctx, filter, opts...)
+		return err
+	}}
+}
+
+// ReadBundle runs reads across collections in one snapshot session, so they
+// all see the data as of the same point in time:
+//
+//	var invoice *Invoice
+//	var lines []*InvoiceLine
+//	var paymentsCount int64
+//	err := mongoAdapter.ReadBundle(ctx,
+//		mongoquerier.BundleFindOne(invoices, bson.M{"_id": id}, &invoice),
+//		mongoquerier.BundleFind(invoiceLines, bson.M{"invoice_id": id}, &lines),
+//		mongoquerier.BundleCount(payments, bson.M{"invoice_id": id}, &paymentsCount),
+//	)
+//
+// Snapshot reads need MongoDB 5.0 on a replica set or sharded cluster. The
+// reads run one after the other on the primary cluster, whatever the
+// queriers' routing.
+func (madp *MongoAdapter) ReadBundle(ctx context.Context, specs ...BundleSpec) error {
+	for _, spec := range specs {
+		if spec.client() != madp.Client {
+			return ErrBundleClientMismatch
+		}
+	}
+
+	start := time.Now()
+	session, err := madp.Client.StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		madp.Error("unable to start session", LogError(err))
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	sessCtx := mongo.NewSessionContext(ctx, session)
+	for _, spec := range specs {
+		if err := spec.read(sessCtx); err != nil {
+			return err
+		}
+	}
+
+	madp.Debug(
+		"Read a snapshot bundle",
+		LogField("reads_count", len(specs)),
+		LogField("duration", time.Since(start)),
+	)
+	return nil
+}
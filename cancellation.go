@@ -0,0 +1,80 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+)
+
+// Operations honor the cancellation of their context: an operation started
+// with a done context returns its error without reaching the server, and one
+// whose context is done midway (server selection, connection checkout, the
+// command itself) is abandoned by the driver. Cursors and change streams stop
+// in Next once the context passed to it is done, with Err reporting why.
+// Either way the error matches context.Canceled or context.DeadlineExceeded
+// with errors.Is, and writes interrupted that way aren't queued by the
+// OfflineQueue: a canceled write may or may not have been applied, and the
+// caller is the one to know whether to retry it.
+//
+// Mutations that must not be cut short by their caller going away use
+// WithGracePeriod.
+
+type gracePeriodKey struct{}
+
+// WithGracePeriod lets the mutations issued with the returned context run
+// for up to gracePeriod after ctx is done, e.g. to finish a write whose HTTP
+// client disconnected:
+//
+//	ctx = mongoquerier.WithGracePeriod(r.Context(), 5*time.Second)
+//	insertedID, err := orders.InsertOne(ctx, order)
+//
+// The grace period covers the querier's mutations (the inserts, updates,
+// replacements, deletes and upserts, CreateUnlessExists, BulkWrite,
+// ClaimOne, PushByM, Anonymize, Import, ApplyDataFix, RunDataFix,
+// DeleteCollection and CreateEncryptedCollection) and the adapter-level
+// ones: EraseSubject, retention and rollup runs, reservations, checkpoint
+// saves and deletes, and offline queue replays. Reads still stop as soon as ctx is done. A
+// mutation already running with a grace period, e.g. one calling another,
+// isn't granted a second one.
+func WithGracePeriod(ctx context.Context, gracePeriod time.Duration) context.Context {
+	return context.WithValue(ctx, gracePeriodKey{}, gracePeriod)
+}
+
+type graceKey struct{}
+
+// detachedContext carries the values of its parent without its deadline and
+// cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// gracefully returns the context a mutation runs with: ctx, or when it has a
+// grace period one canceled that long after ctx is done. The mutation calls
+// the returned cancel when it returns.
+func gracefully(ctx context.Context) (context.Context, context.CancelFunc) {
+	gracePeriod, _ := ctx.Value(gracePeriodKey{}).(time.Duration)
+	if gracePeriod <= 0 || ctx.Value(graceKey{}) != nil {
+		return ctx, func() {}
+	}
+
+	graceCtx, cancel := context.WithCancel(context.WithValue(detachedContext{ctx}, graceKey{}, true))
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-graceCtx.Done():
+			return
+		}
+
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graceCtx.Done():
+		}
+	}()
+	return graceCtx, cancel
+}
@@ -0,0 +1,168 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type valueKey struct{}
+
+func TestGracefullyWithoutGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	graceCtx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if graceCtx != ctx {
+		t.Error("gracefully() without a grace period returned another context")
+	}
+}
+
+func TestGracefullyOutlivesCancellation(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.WithValue(context.Background(), valueKey{}, "v"))
+	ctx := WithGracePeriod(parent, 50*time.Millisecond)
+	graceCtx, cancel := gracefully(ctx)
+	defer cancel()
+
+	cancelParent()
+	select {
+	case <-graceCtx.Done():
+		t.Fatal("mutation context done without a grace period")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if graceCtx.Value(valueKey{}) != "v" {
+		t.Error("mutation context lost the values of its parent")
+	}
+
+	select {
+	case <-graceCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("mutation context not done after its grace period")
+	}
+	if !errors.Is(graceCtx.Err(), context.Canceled) {
+		t.Errorf("Err() = %v, want context.Canceled", graceCtx.Err())
+	}
+}
+
+func TestGracefullyIsNotNested(t *testing.T) {
+	ctx := WithGracePeriod(context.Background(), time.Second)
+	graceCtx, cancel := gracefully(ctx)
+	defer cancel()
+
+	nestedCtx, nestedCancel := gracefully(graceCtx)
+	defer nestedCancel()
+	if nestedCtx != graceCtx {
+		t.Error("gracefully() granted a second grace period")
+	}
+}
+
+func TestMutationsHonorCancellation(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mutations := map[string]func(ctx context.Context) error{
+		"InsertOne": func(ctx context.Context) error {
+			_, err := q.InsertOne(ctx, recursiveNode{Email: "a@b.c"})
+			return err
+		},
+		"UpdateManyWithByM": func(ctx context.Context) error {
+			_, err := q.UpdateManyWithByM(ctx, primitive.M{"email": "a@b.c"}, NewUpdate().SetField("email", "b@c.d"))
+			return err
+		},
+		"Anonymize": func(ctx context.Context) error {
+			_, err := q.Anonymize(ctx, primitive.M{"email": "a@b.c"}, map[string]Masker{})
+			return err
+		},
+		"Import": func(ctx context.Context) error {
+			_, err := q.Import(ctx, NDJSONSource(strings.NewReader(`{"email":"a@b.c"}`)), ImportOptions[recursiveNode]{})
+			return err
+		},
+		"DeleteCollection": func(ctx context.Context) error {
+			return q.DeleteCollection(ctx, "nodes")
+		},
+	}
+	for name, mutation := range mutations {
+		if err := mutation(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s() with a canceled context = %v, want context.Canceled", name, err)
+		}
+		// With a grace period the mutation goes on to the server, here
+		// failing on the disconnected client
+		if err := mutation(WithGracePeriod(ctx, time.Second)); errors.Is(err, context.Canceled) {
+			t.Errorf("%s() within its grace period = %v, want it to reach the server", name, err)
+		}
+	}
+}
+
+func TestReadsHonorCancellation(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	reads := map[string]func(ctx context.Context) error{
+		"FindByM": func(ctx context.Context) error {
+			_, err := q.FindByM(ctx, primitive.M{"email": "a@b.c"})
+			return err
+		},
+		"FindIter": func(ctx context.Context) error {
+			_, err := q.FindIter(ctx, recursiveNode{Email: "a@b.c"})
+			return err
+		},
+		"CountDocumentsByM": func(ctx context.Context) error {
+			_, err := q.CountDocumentsByM(ctx, primitive.M{"email": "a@b.c"})
+			return err
+		},
+		"AggregateIter": func(ctx context.Context) error {
+			_, err := q.AggregateIter(ctx, nil)
+			return err
+		},
+		"Watch": func(ctx context.Context) error {
+			_, err := q.Watch(ctx, nil)
+			return err
+		},
+	}
+	for name, read := range reads {
+		if err := read(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s() with a canceled context = %v, want context.Canceled", name, err)
+		}
+		// Reads aren't granted a grace period
+		if err := read(WithGracePeriod(ctx, time.Second)); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s() with a grace period = %v, want context.Canceled", name, err)
+		}
+	}
+}
+
+func TestRetriesStopOnCancellation(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	q.Retry = &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Hour,
+		Retryable:      func(error) bool { return true },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	transient := errors.New("transient")
+	attempts := 0
+	done := make(chan error, 1)
+	go func() {
+		_, err := retrying(ctx, q, "FindByM", func() (int, error) {
+			attempts++
+			return 0, transient
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, transient) || attempts != 1 {
+			t.Errorf("retrying() = %v after %d attempts, want the deadline and the first attempt's error", err, attempts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retrying() kept backing off after its context was done")
+	}
+}
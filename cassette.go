@@ -0,0 +1,403 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/wiremessage"
+)
+
+var (
+	ErrCassetteMismatch  = errors.New("command doesn't match the recorded interaction")
+	ErrCassetteExhausted = errors.New("no recorded interaction left")
+)
+
+// Interaction is a command sent to the server and the reply it got.
+type Interaction struct {
+	Command  string   `bson:"command"`
+	Database string   `bson:"database"`
+	Request  bson.Raw `bson:"request"`
+	Reply    bson.Raw `bson:"reply"`
+}
+
+// Cassette records the commands an adapter sends and replays their replies
+// without a server, for deterministic tests:
+//
+//	// Once, against a real server
+//	cassette := &mongoquerier.Cassette{}
+//	madp, err := mongoquerier.NewRecordingAdapter(ctx, uri, "shop", cassette)
+//	... run the scenario ...
+//	err = cassette.Save(file)
+//
+//	// In tests
+//	cassette, err := mongoquerier.LoadCassette(file)
+//	madp, err := mongoquerier.NewReplayAdapter(ctx, "shop", cassette)
+//
+// Interactions are replayed in the order they were recorded, so scenarios
+// must issue their operations sequentially.
+type Cassette struct {
+	// Strict also requires replayed commands to match the recorded ones
+	// field by field, ignoring session and cluster time fields. By default
+	// only the command, database and target (e.g. the collection) are
+	// compared, as inserted _ids and timestamps differ between runs.
+	Strict       bool
+	Interactions []Interaction
+
+	mu      sync.Mutex
+	started map[int64]Interaction // request ID -> recording in flight
+	next    int
+}
+
+// cassetteVolatileFields differ between runs of the same scenario.
+var cassetteVolatileFields = map[string]bool{
+	"lsid":          true,
+	"$clusterTime":  true,
+	"txnNumber":     true,
+	"signature":     true,
+	"operationTime": true,
+	// Comments carry the trace IDs of requests
+	"comment": true,
+}
+
+// LoadCassette reads a cassette written by Save.
+func LoadCassette(r io.Reader) (*Cassette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored struct {
+		Interactions []Interaction `bson:"interactions"`
+	}
+	if err := bson.UnmarshalExtJSON(data, true, &stored); err != nil {
+		return nil, err
+	}
+	return &Cassette{Interactions: stored.Interactions}, nil
+}
+
+// Save writes the recorded interactions as canonical extended JSON.
+func (c *Cassette) Save(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := bson.MarshalExtJSONIndent(bson.M{"interactions": c.Interactions}, true, false, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Rewind starts replaying from the first interaction again.
+func (c *Cassette) Rewind() {
+	c.mu.Lock()
+	c.next = 0
+	c.mu.Unlock()
+}
+
+// Monitor returns a command monitor recording into the cassette.
+func (c *Cassette) Monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if e.CommandName == "endSessions" {
+				return
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.started == nil {
+				c.started = make(map[int64]Interaction)
+			}
+			c.started[e.RequestID] = Interaction{
+				Command:  e.CommandName,
+				Database: e.DatabaseName,
+				Request:  append(bson.Raw(nil), e.Command...),
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			c.finish(e.RequestID, e.Reply)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			reply, _ := bson.Marshal(bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: e.Failure}})
+			c.finish(e.RequestID, reply)
+		},
+	}
+}
+
+func (c *Cassette) finish(requestID int64, reply bson.Raw) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	interaction, ok := c.started[requestID]
+	if !ok {
+		return
+	}
+	delete(c.started, requestID)
+	interaction.Reply = append(bson.Raw(nil), reply...)
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// replay returns the reply recorded for the next interaction, checking it's
+// for request.
+func (c *Cassette) replay(request bson.Raw) (bson.Raw, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.Interactions) {
+		return nil, fmt.Errorf("%w: %s", ErrCassetteExhausted, commandSummary(request))
+	}
+	recorded := c.Interactions[c.next]
+	if !c.matches(recorded, request) {
+		return nil, fmt.Errorf("%w: got %s, recorded %s (interaction %d)",
+			ErrCassetteMismatch, commandSummary(request), commandSummary(recorded.Request), c.next)
+	}
+
+	c.next++
+	return recorded.Reply, nil
+}
+
+func (c *Cassette) matches(recorded Interaction, request bson.Raw) bool {
+	elements, err := request.Elements()
+	if err != nil || len(elements) == 0 {
+		return false
+	}
+	database, _ := request.Lookup("$db").StringValueOK()
+	if elements[0].Key() != recorded.Command || database != recorded.Database {
+		return false
+	}
+	if !elements[0].Value().Equal(recorded.Request.Lookup(recorded.Command)) {
+		return false
+	}
+	if !c.Strict {
+		return true
+	}
+
+	return reflect.DeepEqual(stableCommand(recorded.Request), stableCommand(request))
+}
+
+// stableCommand drops the fields of a command that differ between runs.
+func stableCommand(command bson.Raw) bson.M {
+	var m bson.M
+	if err := bson.Unmarshal(command, &m); err != nil {
+		return nil
+	}
+	for field := range cassetteVolatileFields {
+		delete(m, field)
+	}
+	return m
+}
+
+func commandSummary(command bson.Raw) string {
+	elements, err := command.Elements()
+	if err != nil || len(elements) == 0 {
+		return "<invalid command>"
+	}
+	return fmt.Sprintf("%s %s", elements[0].Key(), elements[0].Value())
+}
+
+// NewRecordingAdapter connects like NewMongoAdapter, recording every command
+// the adapter sends into cassette. A command monitor given with WithTracing
+// receives the commands too.
+func NewRecordingAdapter(ctx context.Context, uri string, database string, cassette *Cassette, opts ...AdapterOption) (*MongoAdapter, error) {
+	clientOptions := options.Client().ApplyURI(uri).SetMonitor(cassette.Monitor())
+	return connectMongoAdapter(ctx, clientOptions, database, opts)
+}
+
+// NewReplayAdapter returns an adapter answering from cassette instead of a
+// server. Commands that don't match the next recorded interaction fail with
+// ErrCassetteMismatch.
+//
+// Replaying plugs into the driver through options.ClientOptions.Deployment
+// and the driver's x/ packages, which are exempt from its compatibility
+// guarantees: it's tied to the driver version this module requires and may
+// need changes when the driver is upgraded.
+func NewReplayAdapter(ctx context.Context, database string, cassette *Cassette, opts ...AdapterOption) (*MongoAdapter, error) {
+	clientOptions := options.Client()
+	clientOptions.Deployment = &replayDeployment{cassette: cassette}
+	return connectMongoAdapter(ctx, clientOptions, database, opts)
+}
+
+var replayTimeoutMinutes int64 = 30
+
+var replayDescription = description.Server{
+	Addr:                     replayAddress,
+	CanonicalAddr:            replayAddress,
+	Kind:                     description.RSPrimary,
+	MaxDocumentSize:          16 * 1024 * 1024,
+	MaxMessageSize:           48000000,
+	MaxBatchCount:            100000,
+	SessionTimeoutMinutes:    uint32(replayTimeoutMinutes),
+	SessionTimeoutMinutesPtr: &replayTimeoutMinutes,
+	WireVersion:              &description.VersionRange{Min: 6, Max: 21},
+}
+
+const replayAddress = address.Address("replay:27017")
+
+// replayDeployment is a driver deployment whose single server answers from
+// a cassette.
+type replayDeployment struct {
+	cassette *Cassette
+	updates  chan description.Topology
+	once     sync.Once
+}
+
+func (d *replayDeployment) SelectServer(context.Context, description.ServerSelector) (driver.Server, error) {
+	return d, nil
+}
+
+func (d *replayDeployment) Kind() description.TopologyKind {
+	return description.Single
+}
+
+func (d *replayDeployment) Connection(context.Context) (driver.Connection, error) {
+	return &replayConnection{cassette: d.cassette}, nil
+}
+
+func (d *replayDeployment) RTTMonitor() driver.RTTMonitor {
+	return replayRTTMonitor{}
+}
+
+func (d *replayDeployment) Connect() error {
+	return nil
+}
+
+func (d *replayDeployment) Disconnect(context.Context) error {
+	return nil
+}
+
+// Subscribe reports session support, which the client checks before using
+// sessions.
+func (d *replayDeployment) Subscribe() (*driver.Subscription, error) {
+	d.once.Do(func() {
+		d.updates = make(chan description.Topology, 1)
+		d.updates <- description.Topology{
+			Kind:                     description.Single,
+			Servers:                  []description.Server{replayDescription},
+			SessionTimeoutMinutes:    uint32(replayTimeoutMinutes),
+			SessionTimeoutMinutesPtr: &replayTimeoutMinutes,
+		}
+	})
+	return &driver.Subscription{Updates: d.updates}, nil
+}
+
+func (d *replayDeployment) Unsubscribe(*driver.Subscription) error {
+	return nil
+}
+
+type replayRTTMonitor struct{}
+
+func (replayRTTMonitor) EWMA() time.Duration { return 0 }
+func (replayRTTMonitor) Min() time.Duration  { return 0 }
+func (replayRTTMonitor) P90() time.Duration  { return 0 }
+func (replayRTTMonitor) Stats() string       { return "" }
+
+// replayConnection answers each wire message written to it with the
+// recorded reply.
+type replayConnection struct {
+	cassette  *Cassette
+	requestID int32
+	reply     bson.Raw
+}
+
+func (c *replayConnection) WriteWireMessage(_ context.Context, wm []byte) error {
+	requestID, command, err := readCommand(wm)
+	if err != nil {
+		return err
+	}
+	c.requestID = requestID
+
+	// The client ends its sessions on disconnect; they aren't recorded
+	if elements, err := command.Elements(); err == nil && len(elements) > 0 && elements[0].Key() == "endSessions" {
+		c.reply, _ = bson.Marshal(bson.D{{Key: "ok", Value: 1}})
+		return nil
+	}
+
+	c.reply, err = c.cassette.replay(command)
+	return err
+}
+
+func (c *replayConnection) ReadWireMessage(context.Context) ([]byte, error) {
+	if c.reply == nil {
+		return nil, ErrCassetteExhausted
+	}
+
+	index, wm := wiremessage.AppendHeaderStart(nil, wiremessage.NextRequestID(), c.requestID, wiremessage.OpMsg)
+	wm = wiremessage.AppendMsgFlags(wm, 0)
+	wm = wiremessage.AppendMsgSectionType(wm, wiremessage.SingleDocument)
+	wm = append(wm, c.reply...)
+	c.reply = nil
+	return bsoncore.UpdateLength(wm, index, int32(len(wm[index:]))), nil
+}
+
+func (c *replayConnection) Description() description.Server { return replayDescription }
+func (c *replayConnection) Close() error                    { return nil }
+func (c *replayConnection) ID() string                      { return "replay" }
+func (c *replayConnection) DriverConnectionID() uint64      { return 0 }
+func (c *replayConnection) ServerConnectionID() *int64      { return nil }
+func (c *replayConnection) Address() address.Address        { return replayAddress }
+func (c *replayConnection) Stale() bool                     { return false }
+
+// readCommand extracts the command of an OP_MSG, with its document sequences
+// (e.g. the documents of an insert) folded in as arrays like command
+// monitoring shows them.
+func readCommand(wm []byte) (int32, bson.Raw, error) {
+	_, requestID, _, opcode, rem, ok := wiremessage.ReadHeader(wm)
+	if !ok || opcode != wiremessage.OpMsg {
+		return 0, nil, fmt.Errorf("replay only supports OP_MSG, got %s", opcode)
+	}
+	flags, rem, ok := wiremessage.ReadMsgFlags(rem)
+	if !ok {
+		return 0, nil, errors.New("malformed OP_MSG flags")
+	}
+	if flags&wiremessage.ChecksumPresent != 0 && len(rem) >= 4 {
+		rem = rem[:len(rem)-4]
+	}
+
+	var body bson.D
+	var sequences bson.D
+	for len(rem) > 0 {
+		var sectionType wiremessage.SectionType
+		if sectionType, rem, ok = wiremessage.ReadMsgSectionType(rem); !ok {
+			return 0, nil, errors.New("malformed OP_MSG section")
+		}
+
+		switch sectionType {
+		case wiremessage.SingleDocument:
+			var document bsoncore.Document
+			if document, rem, ok = wiremessage.ReadMsgSectionSingleDocument(rem); !ok {
+				return 0, nil, errors.New("malformed OP_MSG body")
+			}
+			if err := bson.Unmarshal(document, &body); err != nil {
+				return 0, nil, err
+			}
+		case wiremessage.DocumentSequence:
+			var identifier string
+			var documents []bsoncore.Document
+			if identifier, documents, rem, ok = wiremessage.ReadMsgSectionDocumentSequence(rem); !ok {
+				return 0, nil, errors.New("malformed OP_MSG document sequence")
+			}
+			array := make(bson.A, 0, len(documents))
+			for _, document := range documents {
+				array = append(array, bson.Raw(document))
+			}
+			sequences = append(sequences, bson.E{Key: identifier, Value: array})
+		default:
+			return 0, nil, fmt.Errorf("unknown OP_MSG section type %d", sectionType)
+		}
+	}
+
+	command, err := bson.Marshal(append(body, sequences...))
+	return requestID, command, err
+}
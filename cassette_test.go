@@ -0,0 +1,76 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestCassetteReplay(t *testing.T) {
+	command := func(d bson.D) bson.Raw {
+		data, err := bson.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	recorded := Interaction{
+		Command:  "find",
+		Database: "shop",
+		Request:  command(bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.M{"a": 1}}, {Key: "$db", Value: "shop"}}),
+		Reply:    command(bson.D{{Key: "ok", Value: 1}}),
+	}
+
+	tests := []struct {
+		name    string
+		strict  bool
+		request bson.D
+		wantErr error
+	}{
+		{"same command", false, bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.M{"a": 1}}, {Key: "$db", Value: "shop"}}, nil},
+		{"other filter", false, bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.M{"a": 2}}, {Key: "$db", Value: "shop"}}, nil},
+		{"other filter strict", true, bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.M{"a": 2}}, {Key: "$db", Value: "shop"}}, ErrCassetteMismatch},
+		{"volatile fields strict", true, bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.M{"a": 1}}, {Key: "$db", Value: "shop"}, {Key: "lsid", Value: bson.M{"id": 1}}}, nil},
+		{"other collection", false, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "shop"}}, ErrCassetteMismatch},
+		{"other database", false, bson.D{{Key: "find", Value: "orders"}, {Key: "$db", Value: "crm"}}, ErrCassetteMismatch},
+		{"other command", false, bson.D{{Key: "insert", Value: "orders"}, {Key: "$db", Value: "shop"}}, ErrCassetteMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cassette := &Cassette{Strict: tt.strict, Interactions: []Interaction{recorded}}
+			reply, err := cassette.replay(command(tt.request))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("replay() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if !bytes.Equal(reply, recorded.Reply) {
+					t.Errorf("replay() = %v, want %v", reply, recorded.Reply)
+				}
+				if _, err = cassette.replay(command(tt.request)); !errors.Is(err, ErrCassetteExhausted) {
+					t.Errorf("second replay() error = %v, want ErrCassetteExhausted", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCombinedMonitorsRecordAndTrace(t *testing.T) {
+	cassette := &Cassette{}
+	traced := 0
+	tracing := &event.CommandMonitor{
+		Succeeded: func(context.Context, *event.CommandSucceededEvent) { traced++ },
+	}
+	monitor := combineMonitors(cassette.Monitor(), tracing)
+
+	request, _ := bson.Marshal(bson.D{{Key: "ping", Value: 1}})
+	monitor.Started(context.Background(), &event.CommandStartedEvent{Command: request, DatabaseName: "shop", CommandName: "ping", RequestID: 1})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1}})
+
+	if len(cassette.Interactions) != 1 || traced != 1 {
+		t.Errorf("recorded %d interactions and traced %d, want both 1", len(cassette.Interactions), traced)
+	}
+}
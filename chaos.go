@@ -0,0 +1,143 @@
+package mongoquerier
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrChaosTransient is the error ChaosRules inject by default. It looks like
+// a primary stepping down, labelled retryable like the server would.
+var ErrChaosTransient = mongo.CommandError{
+	Code:    189,
+	Name:    "PrimarySteppedDown",
+	Message: "injected by chaos policy",
+	Labels:  []string{"RetryableWriteError", "TransientTransactionError"},
+}
+
+// ChaosRule degrades the operations it matches: with Probability (0 to 1)
+// a matching operation is delayed by Latency, then fails with Err when
+// Fail is set.
+type ChaosRule struct {
+	// Collection and Operation restrict the rule; empty matches any. Kind
+	// restricts it to reads or writes.
+	Collection string
+	Operation  string
+	Kind       OperationKind
+
+	Probability float64
+	Latency     time.Duration
+	Fail        bool
+	// Err is the injected failure, ErrChaosTransient when nil.
+	Err error
+}
+
+func (r ChaosRule) matches(op OperationDescriptor) bool {
+	return (r.Collection == "" || r.Collection == op.Collection) &&
+		(r.Operation == "" || r.Operation == op.Operation) &&
+		(r.Kind == "" || r.Kind == op.Kind)
+}
+
+// Chaos injects latency and errors into querier operations by policy, to
+// rehearse degradations in staging:
+//
+//	mongoAdapter.Chaos = mongoquerier.NewChaos(
+//		mongoquerier.ChaosRule{Collection: "orders", Probability: 0.1, Latency: 200 * time.Millisecond},
+//		mongoquerier.ChaosRule{Collection: "orders", Kind: mongoquerier.OperationWrite, Probability: 0.01, Fail: true},
+//	)
+//	mongoAdapter.Chaos.Enable()
+//
+// Rules apply in order, each rolled independently. Chaos is disabled until
+// Enable is called; rules and the switch can change at runtime.
+type Chaos struct {
+	enabled atomic.Bool
+
+	mu    sync.RWMutex
+	rules []ChaosRule
+	rand  *rand.Rand
+}
+
+func NewChaos(rules ...ChaosRule) *Chaos {
+	return &Chaos{rules: rules}
+}
+
+func (c *Chaos) Enable() {
+	c.enabled.Store(true)
+}
+
+func (c *Chaos) Disable() {
+	c.enabled.Store(false)
+}
+
+func (c *Chaos) Enabled() bool {
+	return c.enabled.Load()
+}
+
+// SetRules replaces the rules.
+func (c *Chaos) SetRules(rules ...ChaosRule) {
+	c.mu.Lock()
+	c.rules = rules
+	c.mu.Unlock()
+}
+
+func (c *Chaos) Rules() []ChaosRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]ChaosRule(nil), c.rules...)
+}
+
+// roll picks the rules firing for op.
+func (c *Chaos) roll(op OperationDescriptor) []ChaosRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	var fired []ChaosRule
+	for _, rule := range c.rules {
+		if rule.matches(op) && c.rand.Float64() < rule.Probability {
+			fired = append(fired, rule)
+		}
+	}
+	return fired
+}
+
+// injectChaos applies the adapter's chaos rules to op, returning the
+// injected failure, if any.
+func (madp *MongoAdapter) injectChaos(ctx context.Context, op OperationDescriptor) error {
+	if madp.Chaos == nil || !madp.Chaos.Enabled() {
+		return nil
+	}
+
+	for _, rule := range madp.Chaos.roll(op) {
+		madp.Warn(
+			"Injected chaos",
+			LogField("collection_name", op.Collection),
+			LogField("operation", op.Operation),
+			LogField("latency", rule.Latency),
+			LogField("fail", rule.Fail),
+		)
+
+		if rule.Latency > 0 {
+			timer := time.NewTimer(rule.Latency)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		if rule.Fail {
+			if rule.Err != nil {
+				return rule.Err
+			}
+			return ErrChaosTransient
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestChaosIsRetried(t *testing.T) {
+	madp := newTestAdapter(t)
+	madp.Chaos = NewChaos(ChaosRule{Collection: "nodes", Probability: 1, Fail: true})
+	madp.Chaos.Enable()
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	q.Retry = &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	calls := 0
+	_, err := retrying(context.Background(), q, "FindByM", func() (int, error) {
+		calls++
+		return calls, nil
+	})
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) || commandErr.Code != ErrChaosTransient.Code {
+		t.Fatalf("retrying() = %v, want ErrChaosTransient", err)
+	}
+	if calls != 0 {
+		t.Errorf("server called %d times, want 0", calls)
+	}
+	if stats := q.Retry.Stats(); stats.Retries != 1 || stats.Exhausted != 1 {
+		t.Errorf("Stats() = %+v, want 1 retry exhausted", stats)
+	}
+
+	madp.Chaos.SetRules()
+	if result, err := retrying(context.Background(), q, "FindByM", func() (int, error) {
+		calls++
+		return calls, nil
+	}); err != nil || result != 1 {
+		t.Errorf("retrying() without rules = %d, %v, want 1, nil", result, err)
+	}
+}
@@ -0,0 +1,121 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultCheckpointCollection = "mq_checkpoints"
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// CheckpointStore persists how far long-running jobs (scans, change stream
+// consumers, reconcilers) got, one document per job, so they resume where
+// they stopped after a crash. A token is anything BSON can encode, e.g. an
+// IterationCheckpoint or a change stream resume token. When the adapter
+// routes tenants, checkpoints are kept in the database of ctx's tenant.
+type CheckpointStore struct {
+	*MongoAdapter
+	collection *mongo.Collection
+}
+
+func NewCheckpointStore(madp *MongoAdapter, collectionName string) *CheckpointStore {
+	if collectionName == "" {
+		collectionName = DefaultCheckpointCollection
+	}
+	return &CheckpointStore{
+		MongoAdapter: madp,
+		collection:   madp.GetCollection(collectionName),
+	}
+}
+
+// SaveCheckpoint records token as job's progress, replacing the previous one.
+func (cs *CheckpointStore) SaveCheckpoint(ctx context.Context, job string, token interface{}) error {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	collection, err := cs.authorizedCollection(ctx, "SaveCheckpoint", job)
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": job},
+		bson.M{"$set": bson.M{"token": token, "updated_at": time.Now().UTC()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		cs.MongoAdapter.Error(
+			"unable to save checkpoint",
+			LogField("collection_name", collection.Name()),
+			LogField("job", job),
+			LogError(err),
+		)
+		return err
+	}
+
+	cs.MongoAdapter.Debug(
+		"Saved checkpoint",
+		LogField("collection_name", collection.Name()),
+		LogField("job", job),
+	)
+	return nil
+}
+
+// LoadCheckpoint decodes job's last saved token into token, which must be a
+// pointer. It returns ErrCheckpointNotFound when job never saved one.
+func (cs *CheckpointStore) LoadCheckpoint(ctx context.Context, job string, token interface{}) error {
+	collection, err := cs.authorizedCollection(ctx, "LoadCheckpoint", job)
+	if err != nil {
+		return err
+	}
+	raw, err := collection.FindOne(ctx, bson.M{"_id": job}).Raw()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrCheckpointNotFound
+		}
+		return err
+	}
+
+	value, err := raw.LookupErr("token")
+	if err != nil {
+		return ErrCheckpointNotFound
+	}
+	return value.Unmarshal(token)
+}
+
+// DeleteCheckpoint forgets job's progress, e.g. once it completed.
+func (cs *CheckpointStore) DeleteCheckpoint(ctx context.Context, job string) error {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	collection, err := cs.authorizedCollection(ctx, "DeleteCheckpoint", job)
+	if err != nil {
+		return err
+	}
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": job}); err != nil {
+		return err
+	}
+
+	cs.MongoAdapter.Debug(
+		"Deleted checkpoint",
+		LogField("collection_name", collection.Name()),
+		LogField("job", job),
+	)
+	return nil
+}
+
+// authorizedCollection runs the Authorize hook for operation on job and
+// returns the store's collection in the database of ctx's tenant.
+func (cs *CheckpointStore) authorizedCollection(ctx context.Context, operation string, job string) (*mongo.Collection, error) {
+	if err := cs.MongoAdapter.authorize(ctx, describeOperation(cs.collection.Name(), operation, primitive.M{"_id": job})); err != nil {
+		return nil, err
+	}
+	return cs.MongoAdapter.collectionFor(ctx, cs.collection.Name())
+}
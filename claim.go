@@ -0,0 +1,58 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClaimOne atomically picks the first document matching filter in sort order
+// and applies update (an update document with operators) to it, returning
+// the document as updated. Concurrent claimers never get the same document:
+//
+//	job, err := querier.ClaimOne(ctx,
+//		bson.M{"status": "pending"},
+//		bson.D{{Key: "created_at", Value: 1}},
+//		bson.M{"$set": bson.M{"status": "processing", "claimed_at": time.Now()}},
+//	)
+//
+// It returns ErrNotFound when nothing matches.
+func (q *Querier[Model, IDModel]) ClaimOne(ctx context.Context, filter primitive.M, sort bson.D, update primitive.M, opts ...*options.FindOneAndUpdateOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "ClaimOne", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "ClaimOne", filter)
+	defer q.observe(span, time.Now(), "ClaimOne", filter, &err)
+
+	if err := q.checkSize("ClaimOne", update); err != nil {
+		return nil, err
+	}
+
+	claimOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if len(sort) > 0 {
+		claimOptions.SetSort(sort)
+	}
+	opts = append([]*options.FindOneAndUpdateOptions{claimOptions}, opts...)
+
+	document, err = retrying(ctx, q, "ClaimOne", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndUpdate(ctx, filter, update, opts...))
+	})
+	if err != nil {
+		q.logWriteFailure(ctx, "ClaimOne", err)
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Claimed one document",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("claimed_document", document),
+	)
+	return document, nil
+}
@@ -0,0 +1,282 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+var ErrCannotCoerce = errors.New("cannot coerce value")
+
+// Coercion converts a stored value whose BSON type doesn't match its field
+// into a value the field decodes from.
+type Coercion func(value bson.RawValue) (interface{}, error)
+
+// fieldCoercion describes how a field's stored values are coerced, either
+// through a `mq:"coerce"` tag or a registered Coercion.
+type fieldCoercion struct {
+	key    string
+	target reflect.Type
+	// rule is the tag's value: "" converts between strings, numbers and
+	// booleans, "unix" and "unixms" read numbers as epoch seconds and
+	// milliseconds.
+	rule   string
+	tagged bool
+	custom Coercion
+	nested []fieldCoercion
+}
+
+var (
+	coercionCache      sync.Map // reflect.Type -> []fieldCoercion
+	coercionRegistry   sync.Map // reflect.Type -> map[string]Coercion
+	coercionRegistryMu sync.Mutex
+)
+
+// RegisterCoercion coerces the values stored under path (a dotted BSON key,
+// e.g. "address.zip") whenever their type doesn't match Model's field. It
+// takes precedence over a `mq:"coerce"` tag on the same field.
+func RegisterCoercion[Model any](path string, coercion Coercion) {
+	t := modelType[Model]()
+
+	coercionRegistryMu.Lock()
+	defer coercionRegistryMu.Unlock()
+
+	registered := map[string]Coercion{}
+	if existing, ok := coercionRegistry.Load(t); ok {
+		for key, value := range existing.(map[string]Coercion) {
+			registered[key] = value
+		}
+	}
+	registered[path] = coercion
+	coercionRegistry.Store(t, registered)
+	coercionCache.Delete(t)
+}
+
+func coercionsFor(t reflect.Type) []fieldCoercion {
+	if cached, ok := coercionCache.Load(t); ok {
+		return cached.([]fieldCoercion)
+	}
+
+	registered := map[string]Coercion{}
+	if existing, ok := coercionRegistry.Load(t); ok {
+		registered = existing.(map[string]Coercion)
+	}
+	result := coercionsAt(t, "", registered)
+	coercionCache.Store(t, result)
+	return result
+}
+
+func coercionsAt(t reflect.Type, prefix string, registered map[string]Coercion) []fieldCoercion {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return nil
+	}
+
+	var result []fieldCoercion
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("bson") == "-" {
+			continue
+		}
+
+		key := bsonKey(field)
+		rules, tagged := parseMQTag(field.Tag.Get("mq"))["coerce"]
+		coercion := fieldCoercion{
+			key:    key,
+			target: field.Type,
+			tagged: tagged,
+			custom: registered[prefix+key],
+			nested: coercionsAt(field.Type, prefix+key+".", registered),
+		}
+		if tagged {
+			coercion.rule = rules[0]
+		}
+		if coercion.tagged || coercion.custom != nil || len(coercion.nested) > 0 {
+			result = append(result, coercion)
+		}
+	}
+	return result
+}
+
+// coerceDocument rewrites the values of doc that don't match their field's
+// type according to coercions.
+func coerceDocument(doc bson.D, coercions []fieldCoercion) (bson.D, error) {
+	for _, coercion := range coercions {
+		index := indexOfKey(doc, coercion.key)
+		if index < 0 {
+			continue
+		}
+
+		if coercion.tagged || coercion.custom != nil {
+			value, err := coerceValue(doc[index].Value, coercion)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", coercion.key, err)
+			}
+			doc[index].Value = value
+		}
+
+		if nested, ok := doc[index].Value.(bson.D); ok && len(coercion.nested) > 0 {
+			value, err := coerceDocument(nested, coercion.nested)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%w", coercion.key, err)
+			}
+			doc[index].Value = value
+		}
+	}
+	return doc, nil
+}
+
+func coerceValue(value interface{}, coercion fieldCoercion) (interface{}, error) {
+	t, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return nil, err
+	}
+	raw := bson.RawValue{Type: t, Value: data}
+	if t == bsontype.Null || t == bsontype.Undefined || decodesFrom(coercion.target, t) {
+		return value, nil
+	}
+
+	if coercion.custom != nil {
+		return coercion.custom(raw)
+	}
+	return coerceBuiltin(raw, coercion.target, coercion.rule)
+}
+
+// decodesFrom reports whether the default codecs decode BSON values of type
+// t into target.
+func decodesFrom(target reflect.Type, t bsontype.Type) bool {
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target == reflect.TypeOf(time.Time{}) {
+		return t == bsontype.DateTime
+	}
+
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return t == bsontype.Int32 || t == bsontype.Int64 || t == bsontype.Double
+	case reflect.Bool:
+		return t == bsontype.Boolean
+	case reflect.String:
+		return t == bsontype.String || t == bsontype.Symbol
+	case reflect.Struct, reflect.Map:
+		return t == bsontype.EmbeddedDocument
+	case reflect.Slice, reflect.Array:
+		return t == bsontype.Array || t == bsontype.Binary
+	}
+	return true
+}
+
+func coerceBuiltin(raw bson.RawValue, target reflect.Type, rule string) (interface{}, error) {
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	fail := func() (interface{}, error) {
+		return nil, fmt.Errorf("%w: %s %s into %s", ErrCannotCoerce, raw.Type, raw, target)
+	}
+
+	if target == reflect.TypeOf(time.Time{}) {
+		if raw.Type == bsontype.String {
+			parsed, err := time.Parse(time.RFC3339Nano, raw.StringValue())
+			if err != nil {
+				return fail()
+			}
+			return parsed, nil
+		}
+		if raw.Type == bsontype.Timestamp {
+			seconds, _ := raw.Timestamp()
+			return time.Unix(int64(seconds), 0).UTC(), nil
+		}
+		number, ok := rawNumber(raw)
+		if !ok {
+			return fail()
+		}
+		if rule == "unixms" {
+			return time.UnixMilli(int64(number)).UTC(), nil
+		}
+		seconds, fraction := math.Modf(number)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+	}
+
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch raw.Type {
+		case bsontype.String:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(raw.StringValue()), 10, 64)
+			if err != nil {
+				return fail()
+			}
+			return parsed, nil
+		case bsontype.Boolean:
+			if raw.Boolean() {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch raw.Type {
+		case bsontype.String:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(raw.StringValue()), 64)
+			if err != nil {
+				return fail()
+			}
+			return parsed, nil
+		case bsontype.Boolean:
+			if raw.Boolean() {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		}
+	case reflect.Bool:
+		if raw.Type == bsontype.String {
+			parsed, err := strconv.ParseBool(strings.TrimSpace(raw.StringValue()))
+			if err != nil {
+				return fail()
+			}
+			return parsed, nil
+		}
+		if number, ok := rawNumber(raw); ok {
+			return number != 0, nil
+		}
+	case reflect.String:
+		switch raw.Type {
+		case bsontype.Int32, bsontype.Int64:
+			return strconv.FormatInt(raw.AsInt64(), 10), nil
+		case bsontype.Double:
+			return strconv.FormatFloat(raw.Double(), 'f', -1, 64), nil
+		case bsontype.Boolean:
+			return strconv.FormatBool(raw.Boolean()), nil
+		case bsontype.ObjectID:
+			return raw.ObjectID().Hex(), nil
+		case bsontype.DateTime:
+			return raw.Time().UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+	return fail()
+}
+
+func rawNumber(raw bson.RawValue) (float64, bool) {
+	switch raw.Type {
+	case bsontype.Int32, bsontype.Int64:
+		return float64(raw.AsInt64()), true
+	case bsontype.Double:
+		return raw.Double(), true
+	case bsontype.Decimal128:
+		parsed, err := strconv.ParseFloat(raw.Decimal128().String(), 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,342 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ColumnType string
+
+const (
+	ColumnInt64     ColumnType = "int64"
+	ColumnFloat64   ColumnType = "float64"
+	ColumnBool      ColumnType = "bool"
+	ColumnString    ColumnType = "string"
+	ColumnTimestamp ColumnType = "timestamp"
+	ColumnBinary    ColumnType = "binary"
+	// ColumnJSON holds arrays, maps and other nested values as relaxed
+	// extended JSON strings.
+	ColumnJSON ColumnType = "json"
+)
+
+// ColumnField is a column of a ColumnarSchema. Nested struct fields are
+// flattened into columns named by their dotted path.
+type ColumnField struct {
+	Name     string
+	Type     ColumnType
+	Nullable bool
+
+	path []int
+}
+
+type ColumnarSchema struct {
+	Fields []ColumnField
+}
+
+var (
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	uuidType       = reflect.TypeOf(UUID{})
+	columnarSchema sync.Map // reflect.Type -> ColumnarSchema
+)
+
+// SchemaOf derives the columnar schema of Model from its stored fields.
+// Pointer fields are nullable; ObjectIDs, UUIDs and decimals are strings.
+// Nested structs are flattened, except those of a type already being
+// flattened (e.g. a Parent *Node in Node), stored as JSON.
+func SchemaOf[Model any]() ColumnarSchema {
+	t := modelType[Model]()
+	if cached, ok := columnarSchema.Load(t); ok {
+		return cached.(ColumnarSchema)
+	}
+
+	var schema ColumnarSchema
+	if t.Kind() == reflect.Struct {
+		appendColumns(&schema, t, "", nil, false, map[reflect.Type]bool{t: true})
+	}
+	columnarSchema.Store(t, schema)
+	return schema
+}
+
+func appendColumns(schema *ColumnarSchema, t reflect.Type, prefix string, path []int, nullable bool, flattening map[reflect.Type]bool) {
+	for _, meta := range structLayoutOf(t) {
+		fieldType := t.Field(meta.index).Type
+		fieldNullable := nullable
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			fieldNullable = true
+		}
+		fieldPath := append(append([]int(nil), path...), meta.index)
+
+		name := prefix + meta.key
+		if fieldType.Kind() == reflect.Struct && columnType(fieldType) == "" && !flattening[fieldType] {
+			if meta.inline {
+				name = prefix
+			} else {
+				name += "."
+			}
+			flattening[fieldType] = true
+			appendColumns(schema, fieldType, name, fieldPath, fieldNullable, flattening)
+			delete(flattening, fieldType)
+			continue
+		}
+
+		typ := columnType(fieldType)
+		if typ == "" {
+			typ = ColumnJSON
+		}
+		if typ == ColumnJSON || typ == ColumnBinary {
+			fieldNullable = true
+		}
+		schema.Fields = append(schema.Fields, ColumnField{Name: name, Type: typ, Nullable: fieldNullable, path: fieldPath})
+	}
+}
+
+// columnType maps a Go type to its column type, or "" for structs to
+// flatten and types to store as JSON.
+func columnType(t reflect.Type) ColumnType {
+	switch t {
+	case timeType, dateTimeType:
+		return ColumnTimestamp
+	case objectIDType, decimalType, uuidType:
+		return ColumnString
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ColumnInt64
+	case reflect.Float32, reflect.Float64:
+		return ColumnFloat64
+	case reflect.Bool:
+		return ColumnBool
+	case reflect.String:
+		return ColumnString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ColumnBinary
+		}
+	case reflect.Struct:
+		if encodesAsValue(t) {
+			return ColumnJSON
+		}
+		return ""
+	}
+	return ColumnJSON
+}
+
+// RecordBatch holds up to a batch size of documents column by column.
+// Columns[i] is the values of Schema.Fields[i], a []int64, []float64,
+// []bool, []string, []time.Time or [][]byte depending on its type (JSON
+// columns are []string); Valid[i][row] is false where the value is null.
+type RecordBatch struct {
+	Schema  ColumnarSchema
+	Len     int
+	Columns []interface{}
+	Valid   [][]bool
+}
+
+func newRecordBatch(schema ColumnarSchema, capacity int) *RecordBatch {
+	batch := &RecordBatch{
+		Schema:  schema,
+		Columns: make([]interface{}, len(schema.Fields)),
+		Valid:   make([][]bool, len(schema.Fields)),
+	}
+	for i, field := range schema.Fields {
+		switch field.Type {
+		case ColumnInt64:
+			batch.Columns[i] = make([]int64, 0, capacity)
+		case ColumnFloat64:
+			batch.Columns[i] = make([]float64, 0, capacity)
+		case ColumnBool:
+			batch.Columns[i] = make([]bool, 0, capacity)
+		case ColumnTimestamp:
+			batch.Columns[i] = make([]time.Time, 0, capacity)
+		case ColumnBinary:
+			batch.Columns[i] = make([][]byte, 0, capacity)
+		default:
+			batch.Columns[i] = make([]string, 0, capacity)
+		}
+		batch.Valid[i] = make([]bool, 0, capacity)
+	}
+	return batch
+}
+
+func (b *RecordBatch) append(document reflect.Value) error {
+	for i, field := range b.Schema.Fields {
+		value, valid := columnValue(document, field.path)
+		b.Valid[i] = append(b.Valid[i], valid)
+
+		switch field.Type {
+		case ColumnInt64:
+			var v int64
+			if valid {
+				if value.CanInt() {
+					v = value.Int()
+				} else {
+					v = int64(value.Uint())
+				}
+			}
+			b.Columns[i] = append(b.Columns[i].([]int64), v)
+		case ColumnFloat64:
+			var v float64
+			if valid {
+				v = value.Float()
+			}
+			b.Columns[i] = append(b.Columns[i].([]float64), v)
+		case ColumnBool:
+			b.Columns[i] = append(b.Columns[i].([]bool), valid && value.Bool())
+		case ColumnTimestamp:
+			var v time.Time
+			if valid {
+				switch t := value.Interface().(type) {
+				case time.Time:
+					v = t
+				case primitive.DateTime:
+					v = t.Time()
+				}
+			}
+			b.Columns[i] = append(b.Columns[i].([]time.Time), v)
+		case ColumnBinary:
+			var v []byte
+			if valid {
+				v = value.Bytes()
+			}
+			b.Columns[i] = append(b.Columns[i].([][]byte), v)
+		case ColumnString:
+			var v string
+			if valid {
+				switch s := value.Interface().(type) {
+				case primitive.ObjectID:
+					v = s.Hex()
+				case primitive.Decimal128:
+					v = s.String()
+				case UUID:
+					v = s.String()
+				default:
+					v = value.String()
+				}
+			}
+			b.Columns[i] = append(b.Columns[i].([]string), v)
+		case ColumnJSON:
+			var v string
+			if valid {
+				data, err := jsonColumnValue(value)
+				if err != nil {
+					return fmt.Errorf("column %s: %w", field.Name, err)
+				}
+				v = data
+			}
+			b.Columns[i] = append(b.Columns[i].([]string), v)
+		}
+	}
+	b.Len++
+	return nil
+}
+
+// columnValue follows path from document, reporting false on a nil pointer
+// or a nil slice or map.
+func columnValue(document reflect.Value, path []int) (reflect.Value, bool) {
+	value := document
+	for _, index := range path {
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(index)
+	}
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.IsNil() {
+		return reflect.Value{}, false
+	}
+	return value, true
+}
+
+func jsonColumnValue(value reflect.Value) (string, error) {
+	converted, err := bsonValue(value)
+	if err != nil {
+		return "", err
+	}
+	t, data, err := bson.MarshalValue(converted)
+	if err != nil {
+		return "", err
+	}
+	// Extended JSON only marshals documents, wrap the value in one
+	wrapped, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: bson.RawValue{Type: t, Value: data}}}, false, false)
+	if err != nil {
+		return "", err
+	}
+	var unwrapped struct {
+		V json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(wrapped, &unwrapped); err != nil {
+		return "", err
+	}
+	return string(unwrapped.V), nil
+}
+
+// ColumnarSink receives record batches, e.g. to write them as Parquet row
+// groups or hand them over as Arrow records, converting the columns with
+// the library of choice. The package doesn't encode Arrow or Parquet
+// itself, which would tie every application to those libraries: sinks
+// adapt RecordBatch, whose columns are plain typed slices, to them.
+type ColumnarSink interface {
+	WriteBatch(batch *RecordBatch) error
+	Close() error
+}
+
+// WriteColumnar drains cursor into sink in record batches of batchSize
+// documents, then closes both. The schema is derived from Model (see
+// SchemaOf):
+//
+//	cursor, err := orders.FindIterByM(ctx, filter)
+//	if err != nil { ... }
+//	count, err := mongoquerier.WriteColumnar(ctx, cursor, parquetSink, 10000)
+//
+// It returns the number of documents written.
+func WriteColumnar[Model any](ctx context.Context, cursor *Cursor[Model], sink ColumnarSink, batchSize int) (count int64, err error) {
+	defer cursor.Close(ctx)
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	schema := SchemaOf[Model]()
+	batch := newRecordBatch(schema, batchSize)
+	for cursor.Next(ctx) {
+		if err := batch.append(reflect.ValueOf(cursor.Document())); err != nil {
+			return count, err
+		}
+		if batch.Len == batchSize {
+			if err := sink.WriteBatch(batch); err != nil {
+				return count, err
+			}
+			count += int64(batch.Len)
+			batch = newRecordBatch(schema, batchSize)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+
+	if batch.Len > 0 {
+		if err := sink.WriteBatch(batch); err != nil {
+			return count, err
+		}
+		count += int64(batch.Len)
+	}
+	return count, sink.Close()
+}
@@ -0,0 +1,31 @@
+package mongoquerier
+
+import (
+	"testing"
+)
+
+type columnarNode struct {
+	Name   string        `bson:"name"`
+	Parent *columnarNode `bson:"parent"`
+	Meta   struct {
+		Depth int `bson:"depth"`
+	} `bson:"meta"`
+}
+
+func TestSchemaOfRecursiveModel(t *testing.T) {
+	schema := SchemaOf[columnarNode]()
+
+	want := map[string]ColumnType{
+		"name":       ColumnString,
+		"parent":     ColumnJSON,
+		"meta.depth": ColumnInt64,
+	}
+	if len(schema.Fields) != len(want) {
+		t.Fatalf("SchemaOf() = %+v, want %d columns", schema.Fields, len(want))
+	}
+	for _, field := range schema.Fields {
+		if want[field.Name] != field.Type {
+			t.Errorf("column %s is %s, want %s", field.Name, field.Type, want[field.Name])
+		}
+	}
+}
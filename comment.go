@@ -0,0 +1,88 @@
+package mongoquerier
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	commentKey struct{}
+	traceIDKey struct{}
+	maxTimeKey struct{}
+)
+
+// WithComment attaches comment to the reads issued with the returned
+// context, alongside the collection and operation every read is commented
+// with, e.g. "mongoquerier orders.FindByM trace_id=4bf92f35: monthly report".
+// The comment shows up in the profiler, currentOp and slow query logs, for
+// the find or aggregate command and for every getMore continuing its cursor.
+func WithComment(ctx context.Context, comment string) context.Context {
+	return context.WithValue(ctx, commentKey{}, comment)
+}
+
+// WithTraceID attaches the trace identifier of the request to the comment of
+// the reads issued with the returned context.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// WithMaxTime bounds the server time of the reads issued with the returned
+// context. The server counts it across the cursor's getMores too, so a
+// cursor iterated for longer is killed instead of running on.
+func WithMaxTime(ctx context.Context, maxTime time.Duration) context.Context {
+	return context.WithValue(ctx, maxTimeKey{}, maxTime)
+}
+
+func operationComment(ctx context.Context, collectionName string, operation string) string {
+	var comment strings.Builder
+	comment.WriteString("mongoquerier ")
+	comment.WriteString(collectionName)
+	comment.WriteString(".")
+	comment.WriteString(operation)
+	if traceID, _ := ctx.Value(traceIDKey{}).(string); traceID != "" {
+		comment.WriteString(" trace_id=")
+		comment.WriteString(traceID)
+	}
+	if userComment, _ := ctx.Value(commentKey{}).(string); userComment != "" {
+		comment.WriteString(": ")
+		comment.WriteString(userComment)
+	}
+	return comment.String()
+}
+
+func contextMaxTime(ctx context.Context) (time.Duration, bool) {
+	maxTime, ok := ctx.Value(maxTimeKey{}).(time.Duration)
+	return maxTime, ok && maxTime > 0
+}
+
+// findOptions prepends the operation's comment and maxTime to opts, where
+// options the caller set take precedence. The driver sends a find's comment
+// with its getMores too.
+func (q *Querier[Model, IDModel]) findOptions(ctx context.Context, operation string, opts []*options.FindOptions) []*options.FindOptions {
+	tagged := options.Find().SetComment(operationComment(ctx, q.collection.Name(), operation))
+	if maxTime, ok := contextMaxTime(ctx); ok {
+		tagged.SetMaxTime(maxTime)
+	}
+	return append([]*options.FindOptions{tagged}, opts...)
+}
+
+func (q *Querier[Model, IDModel]) findOneOptions(ctx context.Context, operation string, opts []*options.FindOneOptions) []*options.FindOneOptions {
+	tagged := options.FindOne().SetComment(operationComment(ctx, q.collection.Name(), operation))
+	if maxTime, ok := contextMaxTime(ctx); ok {
+		tagged.SetMaxTime(maxTime)
+	}
+	return append([]*options.FindOneOptions{tagged}, opts...)
+}
+
+// aggregateOptions is findOptions for aggregations, whose getMores carry the
+// comment the same way.
+func (q *Querier[Model, IDModel]) aggregateOptions(ctx context.Context, operation string, opts []*options.AggregateOptions) []*options.AggregateOptions {
+	tagged := options.Aggregate().SetComment(operationComment(ctx, q.collection.Name(), operation))
+	if maxTime, ok := contextMaxTime(ctx); ok {
+		tagged.SetMaxTime(maxTime)
+	}
+	return append([]*options.AggregateOptions{tagged}, opts...)
+}
@@ -0,0 +1,388 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+var ErrInvalidConfig = errors.New("invalid adapter config")
+
+// Duration is a time.Duration read from strings such as "5s" or "1m30s" in
+// JSON, YAML and environment variables.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Config is the configuration of an adapter, for NewMongoAdapterFromConfig,
+// loadable from JSON or YAML (through their tags) and from environment
+// variables (see LoadEnv). Zero values keep the driver's defaults or the
+// URI's settings.
+type Config struct {
+	// URI is the connection string, mongodb:// or mongodb+srv://. Required.
+	URI string `json:"uri" yaml:"uri" env:"URI"`
+	// Database is the adapter's database. Required.
+	Database string `json:"database" yaml:"database" env:"DATABASE"`
+	// AppName names the application in the server logs, currentOp and the
+	// profiler.
+	AppName string `json:"appName,omitempty" yaml:"appName,omitempty" env:"APP_NAME"`
+
+	// MaxPoolSize and MinPoolSize bound the connections per server.
+	MaxPoolSize uint64 `json:"maxPoolSize,omitempty" yaml:"maxPoolSize,omitempty" env:"MAX_POOL_SIZE"`
+	MinPoolSize uint64 `json:"minPoolSize,omitempty" yaml:"minPoolSize,omitempty" env:"MIN_POOL_SIZE"`
+	// MaxConnIdleTime closes pooled connections idle for longer.
+	MaxConnIdleTime Duration `json:"maxConnIdleTime,omitempty" yaml:"maxConnIdleTime,omitempty" env:"MAX_CONN_IDLE_TIME"`
+
+	ConnectTimeout         Duration `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty" env:"CONNECT_TIMEOUT"`
+	ServerSelectionTimeout Duration `json:"serverSelectionTimeout,omitempty" yaml:"serverSelectionTimeout,omitempty" env:"SERVER_SELECTION_TIMEOUT"`
+	// Timeout bounds every operation without a deadline of its own.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" env:"TIMEOUT"`
+
+	TLS TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty" env:"TLS_"`
+
+	// ReadPreference is a read preference mode: primary, primaryPreferred,
+	// secondary, secondaryPreferred or nearest.
+	ReadPreference string `json:"readPreference,omitempty" yaml:"readPreference,omitempty" env:"READ_PREFERENCE"`
+	// ReadConcern is a read concern level: local, available, majority,
+	// linearizable or snapshot.
+	ReadConcern string `json:"readConcern,omitempty" yaml:"readConcern,omitempty" env:"READ_CONCERN"`
+	// WriteConcern is "majority", a number of nodes or a tag set name.
+	WriteConcern string `json:"writeConcern,omitempty" yaml:"writeConcern,omitempty" env:"WRITE_CONCERN"`
+	// Journal waits for writes to reach the on-disk journal.
+	Journal *bool `json:"journal,omitempty" yaml:"journal,omitempty" env:"JOURNAL"`
+	// WTimeout bounds the wait for WriteConcern.
+	WTimeout Duration `json:"wTimeout,omitempty" yaml:"wTimeout,omitempty" env:"WTIMEOUT"`
+
+	RetryWrites *bool `json:"retryWrites,omitempty" yaml:"retryWrites,omitempty" env:"RETRY_WRITES"`
+	RetryReads  *bool `json:"retryReads,omitempty" yaml:"retryReads,omitempty" env:"RETRY_READS"`
+	// Compressors are the wire compressors to negotiate, in order of
+	// preference: snappy, zlib and zstd.
+	Compressors []string `json:"compressors,omitempty" yaml:"compressors,omitempty" env:"COMPRESSORS"`
+
+	// SlowQueryThreshold logs the querier operations taking longer at Warn.
+	SlowQueryThreshold Duration `json:"slowQueryThreshold,omitempty" yaml:"slowQueryThreshold,omitempty" env:"SLOW_QUERY_THRESHOLD"`
+	// SkipPing returns the adapter without pinging the server first.
+	SkipPing bool `json:"skipPing,omitempty" yaml:"skipPing,omitempty" env:"SKIP_PING"`
+}
+
+// TLSConfig enables TLS with the certificate authority and client
+// certificate of PEM files; Enabled with no files uses the system's
+// certificate authorities.
+type TLSConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty" env:"ENABLED"`
+	// CAFile holds the certificate authorities verifying the servers.
+	CAFile string `json:"caFile,omitempty" yaml:"caFile,omitempty" env:"CA_FILE"`
+	// CertificateKeyFile holds the client certificate and its private key.
+	CertificateKeyFile string `json:"certificateKeyFile,omitempty" yaml:"certificateKeyFile,omitempty" env:"CERTIFICATE_KEY_FILE"`
+	// InsecureSkipVerify doesn't verify the servers' certificates, for
+	// development only.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty" env:"INSECURE_SKIP_VERIFY"`
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// LoadEnv sets the fields whose environment variable, prefix followed by the
+// field's env tag, is set, over the current values, e.g. a file's:
+//
+//	var config mongoquerier.Config
+//	err := config.LoadEnv("ORDERS_MONGO_") // ORDERS_MONGO_URI, ORDERS_MONGO_MAX_POOL_SIZE, ORDERS_MONGO_TLS_CA_FILE...
+//
+// Booleans are parsed by strconv.ParseBool, durations by time.ParseDuration,
+// and lists are comma separated.
+func (c *Config) LoadEnv(prefix string) error {
+	return loadEnv(reflect.ValueOf(c).Elem(), prefix)
+}
+
+func loadEnv(v reflect.Value, prefix string) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name = prefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			errs = append(errs, loadEnv(v.Field(i), name))
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		return v.Addr().Interface().(*Duration).UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.New("expected a non-negative integer")
+		}
+		v.SetUint(n)
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setEnvValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+var (
+	readConcernLevels = []string{"local", "available", "majority", "linearizable", "snapshot"}
+	compressors       = []string{"snappy", "zlib", "zstd"}
+)
+
+// Validate returns every problem of the config, each wrapping
+// ErrInvalidConfig and naming the field, joined.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(field string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: %s %s", ErrInvalidConfig, field, fmt.Sprintf(format, args...)))
+	}
+
+	switch {
+	case c.URI == "":
+		invalid("uri", "is required")
+	case !strings.HasPrefix(c.URI, "mongodb://") && !strings.HasPrefix(c.URI, "mongodb+srv://"):
+		// The URI may hold credentials, it's left out of the error
+		invalid("uri", "must start with mongodb:// or mongodb+srv://")
+	default:
+		if err := options.Client().ApplyURI(c.URI).Validate(); err != nil {
+			invalid("uri", "is malformed: %v", err)
+		}
+	}
+	if c.Database == "" {
+		invalid("database", "is required")
+	} else if strings.ContainsAny(c.Database, "/\\. \"$") {
+		invalid("database", "%q can't contain /, \\, ., spaces, \" or $", c.Database)
+	}
+
+	if c.MaxPoolSize != 0 && c.MinPoolSize > c.MaxPoolSize {
+		invalid("minPoolSize", "%d exceeds maxPoolSize %d", c.MinPoolSize, c.MaxPoolSize)
+	}
+	for field, duration := range map[string]Duration{
+		"maxConnIdleTime":        c.MaxConnIdleTime,
+		"connectTimeout":         c.ConnectTimeout,
+		"serverSelectionTimeout": c.ServerSelectionTimeout,
+		"timeout":                c.Timeout,
+		"wTimeout":               c.WTimeout,
+		"slowQueryThreshold":     c.SlowQueryThreshold,
+	} {
+		if duration < 0 {
+			invalid(field, "%s is negative", time.Duration(duration))
+		}
+	}
+
+	if !c.TLS.Enabled && (c.TLS.CAFile != "" || c.TLS.CertificateKeyFile != "" || c.TLS.InsecureSkipVerify) {
+		invalid("tls", "has settings but isn't enabled")
+	}
+	for field, path := range map[string]string{"tls.caFile": c.TLS.CAFile, "tls.certificateKeyFile": c.TLS.CertificateKeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			invalid(field, "%q isn't readable: %v", path, err)
+		}
+	}
+
+	if c.ReadPreference != "" {
+		if _, err := readpref.ModeFromString(c.ReadPreference); err != nil {
+			invalid("readPreference", "%q isn't one of primary, primaryPreferred, secondary, secondaryPreferred or nearest", c.ReadPreference)
+		}
+	}
+	if c.ReadConcern != "" && !containsString(readConcernLevels, c.ReadConcern) {
+		invalid("readConcern", "%q isn't one of %s", c.ReadConcern, strings.Join(readConcernLevels, ", "))
+	}
+	if w, err := strconv.Atoi(c.WriteConcern); err == nil && w < 0 {
+		invalid("writeConcern", "%d is negative", w)
+	}
+	if c.WriteConcern == "0" && c.Journal != nil && *c.Journal {
+		invalid("journal", "can't be set on unacknowledged writes (writeConcern 0)")
+	}
+	for _, compressor := range c.Compressors {
+		if !containsString(compressors, compressor) {
+			invalid("compressors", "%q isn't one of %s", compressor, strings.Join(compressors, ", "))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clientOptions returns the client options of the config, over its URI's.
+func (c Config) clientOptions() (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(c.URI)
+	if c.AppName != "" {
+		clientOptions.SetAppName(c.AppName)
+	}
+	if c.MaxPoolSize != 0 {
+		clientOptions.SetMaxPoolSize(c.MaxPoolSize)
+	}
+	if c.MinPoolSize != 0 {
+		clientOptions.SetMinPoolSize(c.MinPoolSize)
+	}
+	if c.MaxConnIdleTime != 0 {
+		clientOptions.SetMaxConnIdleTime(time.Duration(c.MaxConnIdleTime))
+	}
+	if c.ConnectTimeout != 0 {
+		clientOptions.SetConnectTimeout(time.Duration(c.ConnectTimeout))
+	}
+	if c.ServerSelectionTimeout != 0 {
+		clientOptions.SetServerSelectionTimeout(time.Duration(c.ServerSelectionTimeout))
+	}
+	if c.Timeout != 0 {
+		clientOptions.SetTimeout(time.Duration(c.Timeout))
+	}
+
+	if c.TLS.Enabled {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	if c.ReadPreference != "" {
+		mode, _ := readpref.ModeFromString(c.ReadPreference)
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("%w: readPreference %v", ErrInvalidConfig, err)
+		}
+		clientOptions.SetReadPreference(rp)
+	}
+	if c.ReadConcern != "" {
+		clientOptions.SetReadConcern(&readconcern.ReadConcern{Level: c.ReadConcern})
+	}
+	if wc := c.writeConcern(); wc != nil {
+		clientOptions.SetWriteConcern(wc)
+	}
+
+	if c.RetryWrites != nil {
+		clientOptions.SetRetryWrites(*c.RetryWrites)
+	}
+	if c.RetryReads != nil {
+		clientOptions.SetRetryReads(*c.RetryReads)
+	}
+	if len(c.Compressors) > 0 {
+		clientOptions.SetCompressors(c.Compressors)
+	}
+	return clientOptions, nil
+}
+
+// writeConcern returns the write concern of the config, nil to keep the
+// URI's.
+func (c Config) writeConcern() *writeconcern.WriteConcern {
+	if c.WriteConcern == "" && c.Journal == nil && c.WTimeout == 0 {
+		return nil
+	}
+
+	wc := &writeconcern.WriteConcern{Journal: c.Journal, WTimeout: time.Duration(c.WTimeout)}
+	if w, err := strconv.Atoi(c.WriteConcern); err == nil {
+		wc.W = w
+	} else if c.WriteConcern != "" {
+		// "majority" or a tag set name
+		wc.W = c.WriteConcern
+	}
+	return wc
+}
+
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tls.caFile: %v", ErrInvalidConfig, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: tls.caFile %q has no PEM certificate", ErrInvalidConfig, c.CAFile)
+		}
+	}
+	if c.CertificateKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertificateKeyFile, c.CertificateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tls.certificateKeyFile: %v", ErrInvalidConfig, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// NewMongoAdapterFromConfig validates config and connects the adapter it
+// describes; opts (logger, tracing, metrics...) apply over it:
+//
+//	var config mongoquerier.Config
+//	if err := config.LoadEnv("MONGO_"); err != nil {
+//		return err
+//	}
+//	mongoAdapter, err := mongoquerier.NewMongoAdapterFromConfig(ctx, config,
+//		mongoquerier.WithLogger(logger),
+//	)
+func NewMongoAdapterFromConfig(ctx context.Context, config Config, opts ...AdapterOption) (*MongoAdapter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	clientOptions, err := config.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	configOpts := []AdapterOption{WithSlowQueryThreshold(time.Duration(config.SlowQueryThreshold))}
+	if config.SkipPing {
+		configOpts = append(configOpts, WithoutPing())
+	}
+	return connectMongoAdapter(ctx, clientOptions, config.Database, append(configOpts, opts...))
+}
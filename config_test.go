@@ -0,0 +1,134 @@
+package mongoquerier
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func validConfig() Config {
+	return Config{URI: "mongodb://localhost:27017", Database: "shop"}
+}
+
+func TestConfigValidate(t *testing.T) {
+	journal := true
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		field  string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing uri", func(c *Config) { c.URI = "" }, "uri"},
+		{"uri scheme", func(c *Config) { c.URI = "postgres://localhost" }, "uri"},
+		{"missing database", func(c *Config) { c.Database = "" }, "database"},
+		{"database name", func(c *Config) { c.Database = "shop.v2" }, "database"},
+		{"pool sizes", func(c *Config) { c.MinPoolSize, c.MaxPoolSize = 10, 5 }, "minPoolSize"},
+		{"negative duration", func(c *Config) { c.Timeout = Duration(-time.Second) }, "timeout"},
+		{"tls settings without tls", func(c *Config) { c.TLS.InsecureSkipVerify = true }, "tls"},
+		{"missing tls file", func(c *Config) { c.TLS = TLSConfig{Enabled: true, CAFile: "/nonexistent/ca.pem"} }, "tls.caFile"},
+		{"read preference", func(c *Config) { c.ReadPreference = "closest" }, "readPreference"},
+		{"read concern", func(c *Config) { c.ReadConcern = "strong" }, "readConcern"},
+		{"negative write concern", func(c *Config) { c.WriteConcern = "-1" }, "writeConcern"},
+		{"journaled unacknowledged writes", func(c *Config) { c.WriteConcern, c.Journal = "0", &journal }, "journal"},
+		{"compressor", func(c *Config) { c.Compressors = []string{"gzip"} }, "compressors"},
+	}
+	for _, tt := range tests {
+		config := validConfig()
+		tt.modify(&config)
+		err := config.Validate()
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), ": "+tt.field+" ") {
+			t.Errorf("%s: Validate() = %v, want an ErrInvalidConfig naming %s", tt.name, err, tt.field)
+		}
+	}
+}
+
+func TestConfigValidateReportsEveryProblem(t *testing.T) {
+	err := Config{ReadConcern: "strong"}.Validate()
+	for _, field := range []string{"uri", "database", "readConcern"} {
+		if err == nil || !strings.Contains(err.Error(), ": "+field+" ") {
+			t.Errorf("Validate() = %v, want it to name %s", err, field)
+		}
+	}
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	t.Setenv("TEST_MONGO_URI", "mongodb://db:27017")
+	t.Setenv("TEST_MONGO_MAX_POOL_SIZE", "50")
+	t.Setenv("TEST_MONGO_TIMEOUT", "1m30s")
+	t.Setenv("TEST_MONGO_RETRY_WRITES", "false")
+	t.Setenv("TEST_MONGO_COMPRESSORS", "zstd, snappy,")
+	t.Setenv("TEST_MONGO_TLS_ENABLED", "true")
+
+	config := Config{Database: "shop", URI: "mongodb://localhost:27017"}
+	if err := config.LoadEnv("TEST_MONGO_"); err != nil {
+		t.Fatal(err)
+	}
+
+	retryWrites := false
+	want := Config{
+		URI:         "mongodb://db:27017",
+		Database:    "shop",
+		MaxPoolSize: 50,
+		Timeout:     Duration(90 * time.Second),
+		RetryWrites: &retryWrites,
+		Compressors: []string{"zstd", "snappy"},
+		TLS:         TLSConfig{Enabled: true},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("LoadEnv() = %+v, want %+v", config, want)
+	}
+}
+
+func TestConfigLoadEnvRejectsMalformedValues(t *testing.T) {
+	t.Setenv("TEST_MONGO_MAX_POOL_SIZE", "-1")
+	t.Setenv("TEST_MONGO_TIMEOUT", "soon")
+
+	var config Config
+	err := config.LoadEnv("TEST_MONGO_")
+	for _, name := range []string{"TEST_MONGO_MAX_POOL_SIZE", "TEST_MONGO_TIMEOUT"} {
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), name) {
+			t.Errorf("LoadEnv() = %v, want an ErrInvalidConfig naming %s", err, name)
+		}
+	}
+}
+
+func TestConfigDurationFromJSON(t *testing.T) {
+	var config Config
+	if err := json.Unmarshal([]byte(`{"connectTimeout": "5s"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.ConnectTimeout != Duration(5*time.Second) {
+		t.Errorf("ConnectTimeout = %s, want 5s", time.Duration(config.ConnectTimeout))
+	}
+}
+
+func TestConfigWriteConcern(t *testing.T) {
+	journal := true
+	tests := []struct {
+		name   string
+		config Config
+		want   *writeconcern.WriteConcern
+	}{
+		{"unset keeps the uri's", Config{}, nil},
+		{"majority", Config{WriteConcern: "majority"}, &writeconcern.WriteConcern{W: "majority"}},
+		{"node count", Config{WriteConcern: "2"}, &writeconcern.WriteConcern{W: 2}},
+		{"journal alone", Config{Journal: &journal}, &writeconcern.WriteConcern{Journal: &journal}},
+		{"timeout", Config{WriteConcern: "1", WTimeout: Duration(time.Second)}, &writeconcern.WriteConcern{W: 1, WTimeout: time.Second}},
+	}
+	for _, tt := range tests {
+		if got := tt.config.writeConcern(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: writeConcern() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
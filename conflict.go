@@ -0,0 +1,124 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// duplicateKeyCodes are the server error codes of unique index violations.
+var duplicateKeyCodes = []int{11000, 11001, 12582}
+
+// duplicateKeyMessage parses the index name and key values out of servers'
+// messages, for servers not reporting them as fields:
+// "E11000 duplicate key error collection: shop.users index: email_1 dup key: { email: "a@b.c" }"
+var duplicateKeyMessage = regexp.MustCompile(`index: (\S+) dup key: (.*)$`)
+
+// ConflictError is a write rejected by a unique index. It matches
+// ErrDuplicateKey with errors.Is, and the driver error stays reachable:
+//
+//	var conflict *mongoquerier.ConflictError
+//	if errors.As(err, &conflict) {
+//		return fmt.Errorf("%v already taken (%s)", conflict.KeyValue, conflict.Index)
+//	}
+type ConflictError struct {
+	// Index is the name of the unique index violated.
+	Index string
+	// KeyPattern is the index's key and KeyValue the duplicated values, nil
+	// when the server doesn't report them.
+	KeyPattern bson.D
+	KeyValue   bson.D
+	// Key is the duplicated key as printed in the server's message.
+	Key string
+	// WriteIndex is the position of the rejected write in an InsertMany or
+	// BulkWrite.
+	WriteIndex int
+	Err        error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Key == "" && len(e.KeyValue) > 0 {
+		return fmt.Sprintf("duplicate key on index %s: %v", e.Index, e.KeyValue)
+	}
+	return fmt.Sprintf("duplicate key on index %s: %s", e.Index, e.Key)
+}
+
+func (e *ConflictError) Unwrap() []error {
+	return []error{ErrDuplicateKey, e.Err}
+}
+
+// IsDuplicateKey reports whether err, or any of the writes it reports, was
+// rejected by a unique index.
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrDuplicateKey) || mongo.IsDuplicateKeyError(err)
+}
+
+// Conflicts returns a ConflictError per write err reports rejected by a
+// unique index, e.g. every duplicate of an unordered InsertMany.
+func Conflicts(err error) []*ConflictError {
+	var conflicts []*ConflictError
+
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeErr := range writeException.WriteErrors {
+			if isDuplicateKeyCode(writeErr.Code) {
+				conflicts = append(conflicts, newConflictError(writeErr.Raw, writeErr.Message, writeErr.Index, err))
+			}
+		}
+	}
+	var bulkException mongo.BulkWriteException
+	if errors.As(err, &bulkException) {
+		for _, writeErr := range bulkException.WriteErrors {
+			if isDuplicateKeyCode(writeErr.Code) {
+				conflicts = append(conflicts, newConflictError(writeErr.Raw, writeErr.Message, writeErr.Index, err))
+			}
+		}
+	}
+	// Find-and-modify upserts fail with a command error
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && isDuplicateKeyCode(int(commandErr.Code)) {
+		conflicts = append(conflicts, newConflictError(commandErr.Raw, commandErr.Message, 0, err))
+	}
+	return conflicts
+}
+
+// conflictError wraps err in a ConflictError for its first duplicate key.
+func conflictError(err error) error {
+	var conflict *ConflictError
+	if err == nil || errors.As(err, &conflict) {
+		return err
+	}
+	if conflicts := Conflicts(err); len(conflicts) > 0 {
+		return conflicts[0]
+	}
+	return err
+}
+
+func isDuplicateKeyCode(code int) bool {
+	for _, duplicateKeyCode := range duplicateKeyCodes {
+		if code == duplicateKeyCode {
+			return true
+		}
+	}
+	return false
+}
+
+func newConflictError(raw bson.Raw, message string, writeIndex int, err error) *ConflictError {
+	conflict := &ConflictError{WriteIndex: writeIndex, Err: err}
+	if match := duplicateKeyMessage.FindStringSubmatch(message); match != nil {
+		conflict.Index, conflict.Key = match[1], match[2]
+	}
+
+	if value, lookupErr := raw.LookupErr("keyPattern"); lookupErr == nil {
+		_ = value.Unmarshal(&conflict.KeyPattern)
+	}
+	if value, lookupErr := raw.LookupErr("keyValue"); lookupErr == nil {
+		_ = value.Unmarshal(&conflict.KeyValue)
+	}
+	return conflict
+}
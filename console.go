@@ -0,0 +1,435 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrConsoleDenied = errors.New("query denied by console policy")
+
+const (
+	DefaultConsolePageSize = 20
+	DefaultConsoleMaxPage  = 100
+	DefaultConsoleMaxTime  = 10 * time.Second
+)
+
+// Console operations; all of them are read-only.
+const (
+	ConsoleFind      = "find"
+	ConsoleCount     = "count"
+	ConsoleAggregate = "aggregate"
+)
+
+// DefaultConsoleStages are the aggregation stages allowed when a console has
+// no AllowedStages. Writing ($out, $merge) and cross-collection stages
+// ($lookup, $unionWith) are left out.
+var DefaultConsoleStages = []string{
+	"$match", "$project", "$addFields", "$set", "$unset", "$group", "$sort",
+	"$limit", "$skip", "$count", "$unwind", "$sortByCount", "$bucket",
+}
+
+// consoleDeniedOperators run server-side JavaScript.
+var consoleDeniedOperators = []string{"$where", "$function", "$accumulator"}
+
+// consoleSafeVariables are the system variables holding no document data.
+var consoleSafeVariables = []string{"NOW", "CLUSTER_TIME", "REMOVE"}
+
+// ConsoleRequest is a read-only query written in canonical or relaxed
+// extended JSON, e.g. {"collection": "orders", "filter": {"total": {"$gt": 10}}}.
+type ConsoleRequest struct {
+	Collection string          `json:"collection"`
+	Operation  string          `json:"operation,omitempty"` // defaults to find
+	Filter     json.RawMessage `json:"filter,omitempty"`
+	Projection json.RawMessage `json:"projection,omitempty"`
+	Sort       json.RawMessage `json:"sort,omitempty"`
+	Pipeline   json.RawMessage `json:"pipeline,omitempty"`
+	Page       int             `json:"page,omitempty"` // zero based
+	PageSize   int             `json:"page_size,omitempty"`
+}
+
+type ConsoleResult struct {
+	Collection string
+	Operation  string
+	Page       int
+	PageSize   int
+	HasMore    bool
+	Count      int64
+	Documents  []bson.M
+}
+
+// MarshalJSON renders documents as relaxed extended JSON.
+func (cr *ConsoleResult) MarshalJSON() ([]byte, error) {
+	documents := make([]json.RawMessage, 0, len(cr.Documents))
+	for _, document := range cr.Documents {
+		data, err := bson.MarshalExtJSON(document, false, false)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, data)
+	}
+
+	return json.Marshal(struct {
+		Collection string            `json:"collection"`
+		Operation  string            `json:"operation"`
+		Page       int               `json:"page"`
+		PageSize   int               `json:"page_size"`
+		HasMore    bool              `json:"has_more"`
+		Count      int64             `json:"count"`
+		Documents  []json.RawMessage `json:"documents"`
+	}{cr.Collection, cr.Operation, cr.Page, cr.PageSize, cr.HasMore, cr.Count, documents})
+}
+
+// ConsoleDocuments decodes a console result into Model.
+func ConsoleDocuments[Model any](result *ConsoleResult) ([]*Model, error) {
+	documents := make([]*Model, 0, len(result.Documents))
+	for _, document := range result.Documents {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		var model Model
+		if err = bson.Unmarshal(data, &model); err != nil {
+			return nil, err
+		}
+		documents = append(documents, &model)
+	}
+	return documents, nil
+}
+
+// QueryConsole runs ad-hoc read-only queries for support tooling, limited to
+// an allowlist of collections and aggregation stages, with paginated results
+// and classified PII redacted. It's also an http.Handler accepting a
+// ConsoleRequest as a POST body.
+type QueryConsole struct {
+	*MongoAdapter
+	Collections   []string
+	AllowedStages []string
+	MaxPageSize   int
+	MaxTime       time.Duration
+	// ShowPII disables redaction of classified fields.
+	ShowPII bool
+}
+
+func NewQueryConsole(madp *MongoAdapter, collections ...string) *QueryConsole {
+	return &QueryConsole{MongoAdapter: madp, Collections: collections}
+}
+
+func (qc *QueryConsole) Run(ctx context.Context, req ConsoleRequest) (*ConsoleResult, error) {
+	if !containsString(qc.Collections, req.Collection) {
+		return nil, fmt.Errorf("%w: collection %q is not allowed", ErrConsoleDenied, req.Collection)
+	}
+	switch req.Operation {
+	case "":
+		req.Operation = ConsoleFind
+	case ConsoleFind, ConsoleCount, ConsoleAggregate:
+	default:
+		return nil, fmt.Errorf("%w: operation %q is not allowed", ErrConsoleDenied, req.Operation)
+	}
+
+	maxPageSize := qc.MaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = DefaultConsoleMaxPage
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = DefaultConsolePageSize
+	}
+	if req.PageSize > maxPageSize {
+		req.PageSize = maxPageSize
+	}
+	if req.Page < 0 {
+		req.Page = 0
+	}
+
+	maxTime := qc.MaxTime
+	if maxTime <= 0 {
+		maxTime = DefaultConsoleMaxTime
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxTime)
+	defer cancel()
+
+	filter, err := consoleDocument(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := consoleDocument(req.Projection)
+	if err != nil {
+		return nil, err
+	}
+	// Sort keys are ordered, bson.M would shuffle them
+	var sort bson.D
+	if len(req.Sort) > 0 {
+		if err = bson.UnmarshalExtJSON(req.Sort, false, &sort); err != nil {
+			return nil, err
+		}
+	}
+	// Projections take aggregation expressions, $function included
+	for _, document := range []interface{}{filter, projection, sort} {
+		if err = checkConsoleOperators(document); err != nil {
+			return nil, err
+		}
+	}
+	// Redaction goes by stored field names, so expressions mustn't copy
+	// classified values under other names
+	piiFields := qc.MongoAdapter.PIIFieldsOf(req.Collection)
+	if qc.ShowPII {
+		piiFields = nil
+	}
+	if err = checkConsolePII(projection, piiFields); err != nil {
+		return nil, err
+	}
+
+	operation := "Console" + strings.ToUpper(req.Operation[:1]) + req.Operation[1:]
+	if err = qc.MongoAdapter.authorize(ctx, describeOperation(req.Collection, operation, filter)); err != nil {
+		return nil, err
+	}
+
+	result := &ConsoleResult{
+		Collection: req.Collection,
+		Operation:  req.Operation,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}
+	collection, err := qc.MongoAdapter.collectionFor(ctx, req.Collection)
+	if err != nil {
+		return nil, err
+	}
+	skip := int64(req.Page) * int64(req.PageSize)
+
+	var cursor *mongo.Cursor
+	switch req.Operation {
+	case ConsoleCount:
+		result.Count, err = collection.CountDocuments(ctx, filter, options.Count().SetMaxTime(maxTime))
+		if err != nil {
+			return nil, err
+		}
+	case ConsoleFind:
+		// Fetch one extra document to know whether there's a next page
+		opts := options.Find().
+			SetSkip(skip).
+			SetLimit(int64(req.PageSize) + 1).
+			SetMaxTime(maxTime)
+		if len(projection) > 0 {
+			opts.SetProjection(projection)
+		}
+		if len(sort) > 0 {
+			opts.SetSort(sort)
+		}
+		cursor, err = collection.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+	case ConsoleAggregate:
+		// Extended JSON must be a document at the top level
+		var wrapper struct {
+			Pipeline mongo.Pipeline `bson:"pipeline"`
+		}
+		if len(req.Pipeline) > 0 {
+			data := append(append([]byte(`{"pipeline":`), req.Pipeline...), '}')
+			if err = bson.UnmarshalExtJSON(data, false, &wrapper); err != nil {
+				return nil, err
+			}
+		}
+		pipeline := wrapper.Pipeline
+		if err = qc.checkPipeline(pipeline, piiFields); err != nil {
+			return nil, err
+		}
+		if len(filter) > 0 {
+			pipeline = append(mongo.Pipeline{{{Key: "$match", Value: filter}}}, pipeline...)
+		}
+		pipeline = append(pipeline,
+			bson.D{{Key: "$skip", Value: skip}},
+			bson.D{{Key: "$limit", Value: int64(req.PageSize) + 1}},
+		)
+
+		cursor, err = collection.Aggregate(ctx, pipeline, options.Aggregate().SetMaxTime(maxTime))
+		if err != nil {
+			return nil, mapPipelineError(pipeline, err)
+		}
+	}
+
+	if cursor != nil {
+		if err = cursor.All(ctx, &result.Documents); err != nil {
+			return nil, err
+		}
+		if len(result.Documents) > req.PageSize {
+			result.Documents = result.Documents[:req.PageSize]
+			result.HasMore = true
+		}
+		result.Count = int64(len(result.Documents))
+
+		if len(piiFields) > 0 {
+			for i, document := range result.Documents {
+				result.Documents[i] = RedactPII(document, piiFields).(bson.M)
+			}
+		}
+	}
+
+	qc.MongoAdapter.Info(
+		"Ran console query",
+		LogField("collection_name", req.Collection),
+		LogField("operation", req.Operation),
+		LogField("query_shape", QueryShape(filter)),
+		LogField("documents_count", result.Count),
+	)
+	return result, nil
+}
+
+func (qc *QueryConsole) checkPipeline(pipeline mongo.Pipeline, piiFields map[string]string) error {
+	allowed := qc.AllowedStages
+	if allowed == nil {
+		allowed = DefaultConsoleStages
+	}
+
+	for i, stage := range pipeline {
+		if len(stage) != 1 {
+			return fmt.Errorf("%w: stage %d must have exactly one operator", ErrConsoleDenied, i)
+		}
+		if !containsString(allowed, stage[0].Key) {
+			return fmt.Errorf("%w: stage %d (%s) is not allowed", ErrConsoleDenied, i, stage[0].Key)
+		}
+		if err := checkConsoleOperators(stage[0].Value); err != nil {
+			return err
+		}
+		if err := checkConsolePII(stage[0].Value, piiFields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (qc *QueryConsole) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ConsoleRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := qc.Run(r.Context(), req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrConsoleDenied) || errors.Is(err, ErrUnauthorized) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func consoleDocument(data json.RawMessage) (bson.M, error) {
+	document := bson.M{}
+	if len(data) == 0 || string(data) == "null" {
+		return document, nil
+	}
+	if err := bson.UnmarshalExtJSON(data, false, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// checkConsoleOperators rejects operators running server-side JavaScript
+// anywhere in value.
+func checkConsoleOperators(value interface{}) error {
+	switch value := value.(type) {
+	case bson.M:
+		for key, element := range value {
+			if containsString(consoleDeniedOperators, key) {
+				return fmt.Errorf("%w: operator %s is not allowed", ErrConsoleDenied, key)
+			}
+			if err := checkConsoleOperators(element); err != nil {
+				return err
+			}
+		}
+	case bson.D:
+		for _, e := range value {
+			if err := checkConsoleOperators(bson.M{e.Key: e.Value}); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, element := range value {
+			if err := checkConsoleOperators(element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkConsolePII rejects the field paths in value ("$email", "$$ROOT",
+// "$$this.email") that may reach a classified field, which expressions could
+// otherwise copy out under a name redaction doesn't know.
+func checkConsolePII(value interface{}, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch value := value.(type) {
+	case string:
+		if !strings.HasPrefix(value, "$") {
+			return nil
+		}
+		path := strings.TrimPrefix(value, "$")
+		if strings.HasPrefix(path, "$") {
+			if containsString(consoleSafeVariables, path[1:]) {
+				return nil
+			}
+			// $$ROOT and $$CURRENT are the whole document; other variables
+			// ($$this, user $let names) may be bound to any part of it
+			return fmt.Errorf("%w: variable %s may expose classified fields", ErrConsoleDenied, value)
+		}
+		for field := range fields {
+			if field == path || strings.HasPrefix(field, path+".") || strings.HasPrefix(path, field+".") {
+				return fmt.Errorf("%w: %s references classified field %s", ErrConsoleDenied, value, field)
+			}
+		}
+	case bson.M:
+		for _, element := range value {
+			if err := checkConsolePII(element, fields); err != nil {
+				return err
+			}
+		}
+	case bson.D:
+		for _, e := range value {
+			if err := checkConsolePII(e.Value, fields); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, element := range value {
+			if err := checkConsolePII(element, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestConsoleChecksEveryDocument(t *testing.T) {
+	qc := NewQueryConsole(newTestAdapter(t), "orders")
+
+	requests := []ConsoleRequest{
+		{Collection: "orders", Filter: json.RawMessage(`{"$where": "sleep(1000)"}`)},
+		{Collection: "orders", Projection: json.RawMessage(`{"total": {"$function": {"body": "function() {}", "args": [], "lang": "js"}}}`)},
+		{Collection: "orders", Sort: json.RawMessage(`{"total": {"$accumulator": {}}}`)},
+		{Collection: "orders", Operation: ConsoleCount, Projection: json.RawMessage(`{"nested": {"deep": {"$function": {}}}}`)},
+	}
+	for _, req := range requests {
+		if _, err := qc.Run(context.Background(), req); !errors.Is(err, ErrConsoleDenied) {
+			t.Errorf("Run(%s) error = %v, want ErrConsoleDenied", req.Projection, err)
+		}
+	}
+}
+
+func TestConsoleRejectsExpressionsOnPII(t *testing.T) {
+	madp := newTestAdapter(t)
+	NewQuerier[recursiveNode](madp, "nodes")
+	qc := NewQueryConsole(madp, "nodes")
+
+	denied := []ConsoleRequest{
+		{Collection: "nodes", Projection: json.RawMessage(`{"contact": "$email"}`)},
+		{Collection: "nodes", Projection: json.RawMessage(`{"contact": {"$concat": ["$link.name", "!"]}}`)},
+		{Collection: "nodes", Projection: json.RawMessage(`{"l": "$link"}`)},
+		{Collection: "nodes", Operation: ConsoleAggregate, Pipeline: json.RawMessage(`[{"$group": {"_id": "$email"}}]`)},
+		{Collection: "nodes", Operation: ConsoleAggregate, Pipeline: json.RawMessage(`[{"$group": {"_id": null, "all": {"$push": "$$ROOT"}}}]`)},
+	}
+	for _, req := range denied {
+		if _, err := qc.Run(context.Background(), req); !errors.Is(err, ErrConsoleDenied) {
+			t.Errorf("Run(%s%s) error = %v, want ErrConsoleDenied", req.Projection, req.Pipeline, err)
+		}
+	}
+
+	// Redaction covers plain inclusions, and nothing is redacted with ShowPII
+	if err := checkConsolePII(bson.M{"email": 1, "total": "$total", "at": "$$NOW"}, PIIFields[recursiveNode]()); err != nil {
+		t.Errorf("checkConsolePII() error = %v", err)
+	}
+	if err := checkConsolePII(bson.M{"contact": "$email"}, nil); err != nil {
+		t.Errorf("checkConsolePII() without classification error = %v", err)
+	}
+}
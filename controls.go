@@ -0,0 +1,320 @@
+package mongoquerier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrUnknownControl = errors.New("unknown control")
+
+// Control is a setting that can be read and changed at runtime. Values are
+// exchanged as strings, e.g. "true", "250ms" or "debug".
+type Control struct {
+	Name        string
+	Description string
+	Get         func() string
+	Set         func(value string) error
+}
+
+// ControlValue is the state of a control, as listed by ControlPanel.
+type ControlValue struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value"`
+}
+
+// ControlPanel gathers runtime controls so incident response can flip them
+// without a redeploy. It's also an http.Handler: GET lists the controls and
+// POST {"name": "chaos", "value": "false"} sets one.
+//
+//	panel := mongoquerier.NewControlPanel(mongoAdapter)
+//	panel.RegisterLogLevel("log_level", atomicLevel)
+//	panel.RegisterCountCache("orders_count_cache", orders.CountCache)
+//	http.Handle("/mongo/controls", panel)
+//
+// The handler changes production behaviour, so it denies every request
+// until Authorize is set:
+//
+//	panel.Authorize = func(r *http.Request) error {
+//		if !isOperator(r) {
+//			return errors.New("operators only")
+//		}
+//		return nil
+//	}
+type ControlPanel struct {
+	*MongoAdapter
+	// Authorize is called before serving a request; a non-nil error denies
+	// it. The handler denies every request while it's nil.
+	Authorize func(r *http.Request) error
+
+	mu       sync.RWMutex
+	controls map[string]Control
+}
+
+// NewControlPanel returns a panel with the adapter's controls registered:
+//   - "chaos" switches MongoAdapter.Chaos on and off
+//   - "slow_query_threshold" sets the adapter's slow query threshold, "0s"
+//     to stop logging slow queries
+//   - "strict" switches strict mode, see MongoAdapter.SetStrict
+//   - "rate_limit" sets the operations per second of MongoAdapter.RateLimit,
+//     0 to lift the limit
+func NewControlPanel(madp *MongoAdapter) *ControlPanel {
+	cp := &ControlPanel{MongoAdapter: madp}
+	cp.RegisterBool("chaos", "inject the adapter's chaos rules",
+		func() bool { return madp.Chaos != nil && madp.Chaos.Enabled() },
+		func(enabled bool) error {
+			if madp.Chaos == nil {
+				if enabled {
+					return errors.New("the adapter has no chaos rules")
+				}
+				return nil
+			}
+			if enabled {
+				madp.Chaos.Enable()
+			} else {
+				madp.Chaos.Disable()
+			}
+			return nil
+		},
+	)
+	cp.RegisterDuration("slow_query_threshold", "log operations taking longer at Warn",
+		madp.slowQueryThresholdOrDefault,
+		func(threshold time.Duration) error {
+			madp.SetSlowQueryThreshold(threshold)
+			return nil
+		},
+	)
+	cp.RegisterBool("strict", "reject the queries the index and shard key policies flag",
+		madp.Strict,
+		func(strict bool) error {
+			madp.SetStrict(strict)
+			return nil
+		},
+	)
+	cp.RegisterInt("rate_limit", "operations per second admitted, 0 for no limit",
+		func() int64 {
+			if madp.RateLimit == nil {
+				return 0
+			}
+			return int64(madp.RateLimit.Rate())
+		},
+		func(rate int64) error {
+			if madp.RateLimit == nil {
+				if rate > 0 {
+					return errors.New("the adapter has no rate limiter")
+				}
+				return nil
+			}
+			madp.RateLimit.SetRate(float64(rate))
+			return nil
+		},
+	)
+	return cp
+}
+
+// SetSlowQueryThreshold overrides SlowQueryThreshold, safely for the
+// operations running concurrently; zero stops logging slow queries. Queriers
+// with their own SlowQueryThreshold keep it.
+func (madp *MongoAdapter) SetSlowQueryThreshold(threshold time.Duration) {
+	madp.slowQueryThreshold.Store(&threshold)
+}
+
+// slowQueryThresholdOrDefault returns the threshold set with
+// SetSlowQueryThreshold, SlowQueryThreshold when none was.
+func (madp *MongoAdapter) slowQueryThresholdOrDefault() time.Duration {
+	if threshold := madp.slowQueryThreshold.Load(); threshold != nil {
+		return *threshold
+	}
+	return madp.SlowQueryThreshold
+}
+
+// SetStrict switches strict mode, in which the index and shard key policies
+// of the adapter's queriers reject the queries they flag, as in
+// IndexPolicyDeny and ShardKeyDeny, whatever their mode.
+func (madp *MongoAdapter) SetStrict(strict bool) {
+	madp.strict.Store(strict)
+}
+
+func (madp *MongoAdapter) Strict() bool {
+	return madp.strict.Load()
+}
+
+// Register adds control, replacing any control of the same name.
+func (cp *ControlPanel) Register(control Control) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.controls == nil {
+		cp.controls = make(map[string]Control)
+	}
+	cp.controls[control.Name] = control
+}
+
+func (cp *ControlPanel) RegisterBool(name string, description string, get func() bool, set func(bool) error) {
+	cp.Register(Control{
+		Name:        name,
+		Description: description,
+		Get:         func() string { return strconv.FormatBool(get()) },
+		Set: func(value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			return set(parsed)
+		},
+	})
+}
+
+func (cp *ControlPanel) RegisterInt(name string, description string, get func() int64, set func(int64) error) {
+	cp.Register(Control{
+		Name:        name,
+		Description: description,
+		Get:         func() string { return strconv.FormatInt(get(), 10) },
+		Set: func(value string) error {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return err
+			}
+			return set(parsed)
+		},
+	})
+}
+
+func (cp *ControlPanel) RegisterDuration(name string, description string, get func() time.Duration, set func(time.Duration) error) {
+	cp.Register(Control{
+		Name:        name,
+		Description: description,
+		Get:         func() string { return get().String() },
+		Set: func(value string) error {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			return set(parsed)
+		},
+	})
+}
+
+// LogLevel is the adjustable minimum level of a logger, e.g. a
+// *slog.LevelVar or zaplog.Level(zapConfig.Level).
+type LogLevel interface {
+	String() string
+	UnmarshalText(text []byte) error
+}
+
+// RegisterLogLevel controls level, the level of the logger it was built
+// with, to turn debug logging on during an incident.
+func (cp *ControlPanel) RegisterLogLevel(name string, level LogLevel) {
+	cp.Register(Control{
+		Name:        name,
+		Description: "minimum level of the logger",
+		Get:         func() string { return level.String() },
+		Set:         func(value string) error { return level.UnmarshalText([]byte(value)) },
+	})
+}
+
+// RegisterCountCache switches cache on and off.
+func (cp *ControlPanel) RegisterCountCache(name string, cache *CountCache) {
+	cp.RegisterBool(name, "serve counts from the count cache",
+		cache.Enabled,
+		func(enabled bool) error {
+			if enabled {
+				cache.Enable()
+			} else {
+				cache.Disable()
+			}
+			return nil
+		},
+	)
+}
+
+// Values lists the controls by name.
+func (cp *ControlPanel) Values() []ControlValue {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	values := make([]ControlValue, 0, len(cp.controls))
+	for _, control := range cp.controls {
+		values = append(values, ControlValue{Name: control.Name, Description: control.Description, Value: control.Get()})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+	return values
+}
+
+func (cp *ControlPanel) Get(name string) (string, error) {
+	cp.mu.RLock()
+	control, ok := cp.controls[name]
+	cp.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownControl, name)
+	}
+	return control.Get(), nil
+}
+
+func (cp *ControlPanel) Set(name string, value string) error {
+	cp.mu.RLock()
+	control, ok := cp.controls[name]
+	cp.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownControl, name)
+	}
+
+	previous := control.Get()
+	if err := control.Set(value); err != nil {
+		return fmt.Errorf("control %q: %w", name, err)
+	}
+
+	cp.Warn(
+		"Changed runtime control",
+		LogField("control", name),
+		LogField("previous_value", previous),
+		LogField("value", control.Get()),
+	)
+	return nil
+}
+
+func (cp *ControlPanel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if cp.Authorize == nil {
+		http.Error(w, "control panel has no Authorize", http.StatusForbidden)
+		return
+	}
+	if err := cp.Authorize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := cp.Set(req.Name, req.Value); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrUnknownControl) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cp.Values())
+}
@@ -0,0 +1,74 @@
+package mongoquerier
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlPanelDeniesWithoutAuthorize(t *testing.T) {
+	panel := NewControlPanel(newTestAdapter(t))
+
+	rec := httptest.NewRecorder()
+	panel.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "strict", "value": "true"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST without Authorize = %d, want 403", rec.Code)
+	}
+	if panel.Strict() {
+		t.Error("POST without Authorize switched strict mode on")
+	}
+
+	panel.Authorize = func(r *http.Request) error { return nil }
+	rec = httptest.NewRecorder()
+	panel.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "strict", "value": "true"}`)))
+	if rec.Code != http.StatusOK || !panel.Strict() {
+		t.Errorf("authorized POST = %d, strict %t, want 200 and strict", rec.Code, panel.Strict())
+	}
+}
+
+func TestControlPanelSlowQueryThreshold(t *testing.T) {
+	madp := newTestAdapter(t)
+	madp.SlowQueryThreshold = time.Second
+	panel := NewControlPanel(madp)
+
+	if value, err := panel.Get("slow_query_threshold"); err != nil || value != "1s" {
+		t.Errorf("Get(slow_query_threshold) = %q, %v, want 1s", value, err)
+	}
+	if err := panel.Set("slow_query_threshold", "250ms"); err != nil {
+		t.Fatal(err)
+	}
+	if threshold := madp.slowQueryThresholdOrDefault(); threshold != 250*time.Millisecond {
+		t.Errorf("slow query threshold = %s, want 250ms", threshold)
+	}
+}
+
+func TestControlPanelRateLimit(t *testing.T) {
+	madp := newTestAdapter(t)
+	panel := NewControlPanel(madp)
+	if err := panel.Set("rate_limit", "10"); err == nil {
+		t.Error("Set(rate_limit) without a limiter = nil, want an error")
+	}
+
+	madp.RateLimit = NewRateLimiter(0, 2)
+	if err := panel.Set("rate_limit", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := madp.RateLimit.admit(); err != nil {
+			t.Fatalf("admit() #%d = %v, want nil within the burst", i, err)
+		}
+	}
+	if err := madp.RateLimit.admit(); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("admit() over the burst = %v, want ErrRateLimited", err)
+	}
+
+	if err := panel.Set("rate_limit", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := madp.RateLimit.admit(); err != nil {
+		t.Errorf("admit() without a limit = %v, want nil", err)
+	}
+}
@@ -0,0 +1,52 @@
+package mongoquerier
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// converter converts a value of its registered type to its stored form.
+type converter func(value reflect.Value) (interface{}, error)
+
+var converters sync.Map // reflect.Type -> converter
+
+// RegisterConverter converts values of type T to the value convert returns
+// wherever StructToM and update builders convert fields, at any depth
+// (nested structs, arrays, maps), so filters and updates hold the same BSON
+// representation the type's stored under:
+//
+//	mongoquerier.RegisterConverter(func(id uuid.UUID) (interface{}, error) {
+//		return primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: id[:]}, nil
+//	})
+//	mongoquerier.RegisterConverter(func(s Status) (interface{}, error) {
+//		return int32(s), nil
+//	})
+//
+// Converters take precedence over the default conversions: a registered
+// struct type is converted whole rather than flattened. Register them at
+// init, before the types are converted.
+func RegisterConverter[T any](convert func(T) (interface{}, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	converters.Store(t, converter(func(value reflect.Value) (interface{}, error) {
+		converted, err := convert(value.Interface().(T))
+		if err != nil {
+			return nil, fmt.Errorf("converting %s: %w", t, err)
+		}
+		return converted, nil
+	}))
+
+	// Layouts of structs holding T picked its conversion already
+	structLayouts.Range(func(key, _ interface{}) bool {
+		structLayouts.Delete(key)
+		return true
+	})
+}
+
+func converterFor(t reflect.Type) (converter, bool) {
+	convert, ok := converters.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return convert.(converter), true
+}
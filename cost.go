@@ -0,0 +1,215 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultCostSampleSize is the number of random documents EstimateCost
+// matches the filter against to estimate its selectivity.
+const DefaultCostSampleSize = 1000
+
+type CostOptions struct {
+	// Execute runs the query (an executionStats explain) to report the keys
+	// and documents it actually examines instead of estimating them, at the
+	// cost of the query itself.
+	Execute bool
+}
+
+// QueryCost summarizes how the server would run a filter, for refusing
+// overly broad queries before running them:
+//
+//	cost, err := products.EstimateCostByM(ctx, searchFilter)
+//	if err == nil && (!cost.Indexed || cost.EstimatedDocsExamined > 50_000) {
+//		return ErrQueryTooBroad
+//	}
+type QueryCost struct {
+	// Indexed is false when the winning plan scans the whole collection.
+	Indexed bool
+	// IndexName is the index of the winning plan's first index scan.
+	IndexName string
+	// Stages lists the stages of the winning plan, root first.
+	Stages []string
+	// CollectionDocs is the collection's estimated document count.
+	CollectionDocs int64
+	// EstimatedDocsMatched extrapolates the share of sampled documents
+	// matching the filter to the collection.
+	EstimatedDocsMatched int64
+	// EstimatedKeysExamined and EstimatedDocsExamined assume an index scan
+	// reads only the matching entries, a lower bound when the index covers
+	// part of the filter only: the plan's index bounds aren't evaluated. A
+	// collection scan examines every document.
+	EstimatedKeysExamined int64
+	EstimatedDocsExamined int64
+	// Executed is set when the query was run (CostOptions.Execute): the
+	// estimates are then the actual counts of the run.
+	Executed bool
+}
+
+func (q *Querier[Model, IDModel]) EstimateCost(ctx context.Context, filter Model, opts ...*CostOptions) (*QueryCost, error) {
+	filterM, err := q.structToM("EstimateCost", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.EstimateCostByM(ctx, filterM, opts...)
+}
+
+// EstimateCostByM explains filter (queryPlanner only, the query isn't run)
+// and estimates its selectivity by running it against a random $sample of
+// DefaultCostSampleSize documents, which reads that many documents whatever
+// the filter. With CostOptions.Execute, the query is run instead and its
+// actual counts are reported.
+func (q *Querier[Model, IDModel]) EstimateCostByM(ctx context.Context, filter primitive.M, opts ...*CostOptions) (cost *QueryCost, err error) {
+	if filter == nil {
+		filter = primitive.M{}
+	}
+	if err = q.preflight(ctx, "EstimateCostByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "EstimateCostByM", filter)
+	defer q.observe(span, time.Now(), "EstimateCostByM", filter, &err)
+
+	execute := false
+	for _, opt := range opts {
+		execute = execute || opt.Execute
+	}
+	verbosity := "queryPlanner"
+	if execute {
+		verbosity = "executionStats"
+	}
+	explain, err := q.explainWith(ctx, filter, verbosity)
+	if err != nil {
+		return nil, err
+	}
+	planner, _ := explain["queryPlanner"].(bson.M)
+	plan := planner["winningPlan"]
+
+	cost = &QueryCost{
+		Indexed:   !planHasStage(plan, "COLLSCAN"),
+		IndexName: planIndexName(plan),
+		Stages:    planStages(plan, nil),
+	}
+
+	collection := q.readCollection(ctx)
+	if cost.CollectionDocs, err = collection.EstimatedDocumentCount(ctx); err != nil {
+		return nil, err
+	}
+
+	if execute {
+		var stats struct {
+			Returned     int64 `bson:"nReturned"`
+			KeysExamined int64 `bson:"totalKeysExamined"`
+			DocsExamined int64 `bson:"totalDocsExamined"`
+		}
+		data, err := bson.Marshal(explain["executionStats"])
+		if err != nil {
+			return nil, err
+		}
+		if err = bson.Unmarshal(data, &stats); err != nil {
+			return nil, err
+		}
+		cost.Executed = true
+		cost.EstimatedDocsMatched = stats.Returned
+		cost.EstimatedKeysExamined = stats.KeysExamined
+		cost.EstimatedDocsExamined = stats.DocsExamined
+		q.logCost(filter, cost)
+		return cost, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sample", Value: bson.M{"size": DefaultCostSampleSize}}},
+		{{Key: "$facet", Value: bson.M{
+			"sampled": bson.A{bson.M{"$count": "n"}},
+			"matched": bson.A{bson.M{"$match": filter}, bson.M{"$count": "n"}},
+		}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, q.aggregateOptions(ctx, "EstimateCostByM", nil)...)
+	if err != nil {
+		return nil, mapPipelineError(pipeline, err)
+	}
+	var facets []struct {
+		Sampled []struct{ N int64 } `bson:"sampled"`
+		Matched []struct{ N int64 } `bson:"matched"`
+	}
+	if err = cursor.All(ctx, &facets); err != nil {
+		return nil, mapPipelineError(pipeline, err)
+	}
+	if len(facets) > 0 && len(facets[0].Sampled) > 0 && len(facets[0].Matched) > 0 {
+		selectivity := float64(facets[0].Matched[0].N) / float64(facets[0].Sampled[0].N)
+		cost.EstimatedDocsMatched = int64(selectivity*float64(cost.CollectionDocs) + 0.5)
+	}
+
+	if cost.Indexed {
+		cost.EstimatedKeysExamined = cost.EstimatedDocsMatched
+		cost.EstimatedDocsExamined = cost.EstimatedDocsMatched
+	} else {
+		cost.EstimatedDocsExamined = cost.CollectionDocs
+	}
+
+	q.logCost(filter, cost)
+	return cost, nil
+}
+
+func (q *Querier[Model, IDModel]) logCost(filter primitive.M, cost *QueryCost) {
+	q.MongoAdapter.Debug(
+		"Estimated query cost",
+		LogField("collection_name", q.collection.Name()),
+		LogField("query_shape", QueryShape(filter)),
+		LogField("indexed", cost.Indexed),
+		LogField("index_name", cost.IndexName),
+		LogField("executed", cost.Executed),
+		LogField("estimated_docs_examined", cost.EstimatedDocsExamined),
+	)
+}
+
+// planStages lists the stages of a plan tree, depth first.
+func planStages(plan interface{}, stages []string) []string {
+	node, ok := plan.(bson.M)
+	if !ok {
+		return stages
+	}
+	if stage, ok := node["stage"].(string); ok {
+		stages = append(stages, stage)
+	}
+	for _, key := range []string{"inputStage", "queryPlan"} {
+		stages = planStages(node[key], stages)
+	}
+	// Sharded plans list the shards' winning plans under shards
+	for _, key := range []string{"inputStages", "shards"} {
+		children, _ := node[key].(bson.A)
+		for _, child := range children {
+			if shard, ok := child.(bson.M); ok && shard["winningPlan"] != nil {
+				child = shard["winningPlan"]
+			}
+			stages = planStages(child, stages)
+		}
+	}
+	return stages
+}
+
+// planIndexName returns the index of the first index scan of a plan tree.
+func planIndexName(plan interface{}) string {
+	switch plan := plan.(type) {
+	case bson.M:
+		if name, ok := plan["indexName"].(string); ok {
+			return name
+		}
+		for _, value := range plan {
+			if name := planIndexName(value); name != "" {
+				return name
+			}
+		}
+	case bson.A:
+		for _, value := range plan {
+			if name := planIndexName(value); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package mongoquerier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultCountCacheTTL = 5 * time.Second
+
+// CountCache memoizes CountDocuments results for TTL, keyed by collection and
+// normalized filter. Concurrent counts for the same key share one query.
+// Counts with options (limit, skip, hint...) are never cached.
+type CountCache struct {
+	TTL time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*countEntry
+	disabled atomic.Bool
+}
+
+type countEntry struct {
+	done    chan struct{}
+	count   int64
+	err     error
+	expires time.Time
+}
+
+func NewCountCache(ttl time.Duration) *CountCache {
+	return &CountCache{TTL: ttl}
+}
+
+// Disable bypasses the cache, dropping its counts, until Enable is called.
+func (c *CountCache) Disable() {
+	c.disabled.Store(true)
+	c.Invalidate()
+}
+
+func (c *CountCache) Enable() {
+	c.disabled.Store(false)
+}
+
+func (c *CountCache) Enabled() bool {
+	return !c.disabled.Load()
+}
+
+// Invalidate drops every memoized count.
+func (c *CountCache) Invalidate() {
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+func (c *CountCache) count(key string, count func() (int64, error)) (int64, bool, error) {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultCountCacheTTL
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*countEntry)
+	}
+	entry, ok := c.entries[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+			// In flight, wait for it below
+		}
+	}
+	if ok {
+		c.mu.Unlock()
+		<-entry.done
+		return entry.count, true, entry.err
+	}
+
+	entry = &countEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	entry.count, entry.err = count()
+	entry.expires = time.Now().Add(ttl)
+	if entry.err != nil {
+		// Don't memoize failures, the next caller retries
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	close(entry.done)
+	return entry.count, false, entry.err
+}
+
+// countDocuments counts through the querier's CountCache, if any.
+func (q *Querier[Model, IDModel]) countDocuments(ctx context.Context, filter primitive.M, opts ...*options.CountOptions) (int64, error) {
+	count := func() (int64, error) {
+		return retrying(ctx, q, "CountDocuments", func() (int64, error) {
+			return q.readCollection(ctx).CountDocuments(ctx, filter, opts...)
+		})
+	}
+	// Counts inside a transaction see its own uncommitted writes, and in a
+	// snapshot session the data as of its snapshot
+	if q.CountCache == nil || !q.CountCache.Enabled() || len(opts) > 0 || inSession(ctx) {
+		return count()
+	}
+
+	cluster := ClusterPrimary
+	if q.routesToAnalytics(ctx) {
+		cluster = ClusterAnalytics
+	}
+	key, err := normalizeFilter(filter)
+	if err != nil {
+		return count()
+	}
+
+	result, cached, err := q.CountCache.count(q.namespace(ctx)+" "+cluster+" "+key, count)
+	if cached {
+		q.MongoAdapter.Debug(
+			"Served count from cache",
+			LogField("collection_name", q.collection.Name()),
+			LogField("query_shape", QueryShape(filter)),
+		)
+	}
+	return result, err
+}
+
+// normalizeFilter renders filter as canonical extended JSON with document
+// keys sorted, so equivalent filters map to the same string.
+func normalizeFilter(filter interface{}) (string, error) {
+	data, err := bson.MarshalExtJSON(sortedDocument(filter), true, false)
+	return string(data), err
+}
+
+func sortedDocument(value interface{}) interface{} {
+	switch value := value.(type) {
+	case bson.M:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		sorted := make(bson.D, 0, len(value))
+		for _, key := range keys {
+			sorted = append(sorted, bson.E{Key: key, Value: sortedDocument(value[key])})
+		}
+		return sorted
+	case map[string]interface{}:
+		return sortedDocument(bson.M(value))
+	case bson.D:
+		m := make(bson.M, len(value))
+		for _, e := range value {
+			m[e.Key] = e.Value
+		}
+		return sortedDocument(m)
+	case bson.A:
+		sorted := make(bson.A, len(value))
+		for i, element := range value {
+			sorted[i] = sortedDocument(element)
+		}
+		return sorted
+	case []interface{}:
+		return sortedDocument(bson.A(value))
+	}
+	return value
+}
@@ -0,0 +1,145 @@
+package mongoquerier
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (q *Querier[Model, IDModel]) CreateUnlessExists(ctx context.Context, filter Model, document Model) (*Model, bool, error) {
+	filterM, err := q.structToM("CreateUnlessExists", filter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return q.CreateUnlessExistsByM(ctx, filterM, document)
+}
+
+// CreateUnlessExistsByM inserts document unless a document matching filter
+// exists, returning the stored document and whether it was created. Fields
+// filter matches by equality, dotted paths included, are stored with
+// filter's values. The check
+// and the insert are a single upsert; with a unique index on the filter
+// fields, a concurrent creator losing the race sees the winner's document.
+func (q *Querier[Model, IDModel]) CreateUnlessExistsByM(ctx context.Context, filter primitive.M, document Model) (stored *Model, created bool, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "CreateUnlessExistsByM", filter); err != nil {
+		return nil, false, err
+	}
+	ctx, span := q.startOperation(ctx, "CreateUnlessExistsByM", filter)
+	defer q.observe(span, time.Now(), "CreateUnlessExistsByM", filter, &err)
+
+	insertDocument, err := q.prepareDocument(document)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := bson.Marshal(insertDocument)
+	if err != nil {
+		return nil, false, err
+	}
+	var setOnInsert bson.M
+	if err = bson.Unmarshal(data, &setOnInsert); err != nil {
+		return nil, false, err
+	}
+	// The upsert seeds filter's equality fields, which document's zero
+	// values mustn't overwrite
+	for key, value := range filter {
+		if !strings.HasPrefix(key, "$") && !isOperatorDocument(value) {
+			withoutPath(setOnInsert, key)
+		}
+	}
+	if err = q.checkSize("CreateUnlessExistsByM", setOnInsert); err != nil {
+		return nil, false, err
+	}
+
+	res, err := retrying(ctx, q, "CreateUnlessExistsByM", func() (*mongo.UpdateResult, error) {
+		return q.writeCollection(ctx).UpdateOne(
+			ctx,
+			filter,
+			bson.M{"$setOnInsert": setOnInsert},
+			options.Update().SetUpsert(true),
+		)
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		q.logWriteFailure(ctx, "CreateUnlessExistsByM", err)
+		return nil, false, err
+	}
+
+	// A duplicate key means another creator won the race in between
+	created = err == nil && res.UpsertedID != nil
+	readFilter := filter
+	if created {
+		q.trackInserted(ctx, res.UpsertedID)
+		readFilter = bson.M{"_id": res.UpsertedID}
+	}
+
+	stored, err = retrying(ctx, q, "CreateUnlessExistsByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.tenantCollection(ctx).FindOne(ctx, readFilter))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Created document unless it existed",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("created", created),
+	)
+
+	return stored, created, nil
+}
+
+// isOperatorDocument reports whether a filter value is a document of query
+// operators ({"$gt": 1}) rather than a value to match.
+// withoutPath removes the (dotted) path from set. Documents on the way are
+// flattened into dotted keys, so their other fields are still set without
+// conflicting with the path, which the upsert seeds.
+func withoutPath(set bson.M, path string) {
+	if _, ok := set[path]; ok {
+		delete(set, path)
+		return
+	}
+
+	for prefix := path; ; {
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			return
+		}
+		prefix = prefix[:i]
+
+		value, ok := set[prefix]
+		if !ok {
+			continue
+		}
+		delete(set, prefix)
+		if document, ok := value.(bson.M); ok {
+			for key, element := range document {
+				set[prefix+"."+key] = element
+			}
+			withoutPath(set, path)
+		}
+		return
+	}
+}
+
+func isOperatorDocument(value interface{}) bool {
+	document, ok := value.(bson.M)
+	if !ok || len(document) == 0 {
+		return false
+	}
+	for key := range document {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
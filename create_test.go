@@ -0,0 +1,28 @@
+package mongoquerier
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithoutPath(t *testing.T) {
+	tests := []struct {
+		path string
+		set  bson.M
+		want bson.M
+	}{
+		{"email", bson.M{"email": "", "name": "Ada"}, bson.M{"name": "Ada"}},
+		{"profile.email", bson.M{"profile": bson.M{"email": "", "age": 36}}, bson.M{"profile.age": 36}},
+		{"a.b.c", bson.M{"a": bson.M{"b": bson.M{"c": 1, "d": 2}, "e": 3}}, bson.M{"a.b.d": 2, "a.e": 3}},
+		{"tags.0", bson.M{"tags": bson.A{"x"}, "name": "Ada"}, bson.M{"name": "Ada"}},
+		{"missing.path", bson.M{"name": "Ada"}, bson.M{"name": "Ada"}},
+	}
+	for _, tt := range tests {
+		withoutPath(tt.set, tt.path)
+		if !reflect.DeepEqual(tt.set, tt.want) {
+			t.Errorf("withoutPath(%s) = %v, want %v", tt.path, tt.set, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const DefaultCriticalWTimeout = 30 * time.Second
+
+type criticalKey struct{}
+
+// Critical marks the writes issued with the returned context as critical:
+// they're acknowledged by a journaled majority, get a longer write concern
+// timeout and are logged as alerts when they fail.
+//
+//	insertedID, err := payments.InsertOne(mongoquerier.Critical(ctx), payment)
+func Critical(ctx context.Context) context.Context {
+	return context.WithValue(ctx, criticalKey{}, true)
+}
+
+func IsCritical(ctx context.Context) bool {
+	critical, _ := ctx.Value(criticalKey{}).(bool)
+	return critical
+}
+
+func (madp *MongoAdapter) criticalWriteConcern() *writeconcern.WriteConcern {
+	journal := true
+	wc := &writeconcern.WriteConcern{
+		W:        "majority",
+		Journal:  &journal,
+		WTimeout: DefaultCriticalWTimeout,
+	}
+	if madp.CriticalWTimeout > 0 {
+		wc.WTimeout = madp.CriticalWTimeout
+	}
+	return wc
+}
+
+// writeCollection returns the collection writes should go through, with the
+// write concern of their durability class (see Durability), e.g. escalated
+// to the critical one when the context asks for it.
+func (q *Querier[Model, IDModel]) writeCollection(ctx context.Context) *mongo.Collection {
+	collection := q.tenantCollection(ctx)
+	wc := q.MongoAdapter.durabilityWriteConcern(q.durability(ctx))
+	if wc == nil {
+		return collection
+	}
+
+	escalated, err := collection.Clone(options.Collection().SetWriteConcern(wc))
+	if err != nil {
+		// Cloning only fails on invalid options, which ours never are
+		q.MongoAdapter.Error("unable to escalate write concern", LogError(err))
+		return collection
+	}
+	return escalated
+}
+
+func (q *Querier[Model, IDModel]) logWriteFailure(ctx context.Context, operation string, err error) {
+	// A find-and-modify that matched nothing isn't a durability failure
+	if err == nil || q.durability(ctx) != DurabilityCritical || errors.Is(err, mongo.ErrNoDocuments) {
+		return
+	}
+
+	q.MongoAdapter.Error(
+		"Critical write failed",
+		LogField("alert", true),
+		LogField("collection_name", q.collection.Name()),
+		LogField("operation", operation),
+		LogError(err),
+	)
+}
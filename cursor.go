@@ -0,0 +1,102 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Cursor decodes documents lazily while iterating a server cursor.
+//
+//	cursor, err := querier.AggregateIter(ctx, pipeline)
+//	if err != nil { ... }
+//	defer cursor.Close(ctx)
+//	for cursor.Next(ctx) {
+//		document := cursor.Document()
+//	}
+//	err = cursor.Err()
+type Cursor[Model any] struct {
+	cursor  *mongo.Cursor
+	decode  func(ctx context.Context, raw bson.Raw) (*Model, error)
+	mapErr  func(err error) error
+	current *Model
+	err     error
+	// release ends the cursor's tracking as an operation in flight, once
+	// it's exhausted or closed
+	release func()
+}
+
+func newCursor[Model any, IDModel any](q *Querier[Model, IDModel], cursor *mongo.Cursor) *Cursor[Model] {
+	return &Cursor[Model]{
+		cursor:  cursor,
+		decode:  q.decode,
+		mapErr:  func(err error) error { return err },
+		release: q.MongoAdapter.trackCursor(),
+	}
+}
+
+// Next advances to the next document, returning false when the cursor is
+// exhausted or a document failed to decode (unless skipped, see
+// SkipUndecodable).
+func (c *Cursor[Model]) Next(ctx context.Context) bool {
+	for c.err == nil && c.cursor.Next(ctx) {
+		c.current, c.err = c.decode(ctx, c.cursor.Current)
+		if c.err == nil {
+			return true
+		}
+		if skipUndecodable(ctx, c.err) {
+			c.err = nil
+		}
+	}
+	c.release()
+	return false
+}
+
+func (c *Cursor[Model]) Document() *Model {
+	return c.current
+}
+
+// Raw returns the undecoded current document.
+func (c *Cursor[Model]) Raw() bson.Raw {
+	return c.cursor.Current
+}
+
+func (c *Cursor[Model]) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.cursor.Err(); err != nil {
+		return c.mapErr(err)
+	}
+	return nil
+}
+
+func (c *Cursor[Model]) Close(ctx context.Context) error {
+	defer c.release()
+	return c.cursor.Close(ctx)
+}
+
+// Each calls fn for every document, stopping at the first error, and closes
+// the cursor.
+func (c *Cursor[Model]) Each(ctx context.Context, fn func(document *Model) error) error {
+	defer c.Close(ctx)
+
+	for c.Next(ctx) {
+		if err := fn(c.current); err != nil {
+			return err
+		}
+	}
+	return c.Err()
+}
+
+// All drains and closes the cursor.
+func (c *Cursor[Model]) All(ctx context.Context) ([]*Model, error) {
+	defer c.Close(ctx)
+
+	var documents []*Model
+	for c.Next(ctx) {
+		documents = append(documents, c.current)
+	}
+	return documents, c.Err()
+}
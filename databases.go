@@ -0,0 +1,70 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListDatabases lists the databases of the cluster matching filter (every
+// one when nil), with their size on disk.
+func (madp *MongoAdapter) ListDatabases(ctx context.Context, filter primitive.M, opts ...*options.ListDatabasesOptions) ([]mongo.DatabaseSpecification, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	result, err := madp.Client.ListDatabases(ctx, filter, opts...)
+	if err != nil {
+		madp.Error("unable to list databases", LogError(err))
+		return nil, err
+	}
+
+	madp.Debug(
+		"Listed databases",
+		LogField("databases_count", len(result.Databases)),
+		LogField("total_size", result.TotalSize),
+	)
+	return result.Databases, nil
+}
+
+// InDatabase returns an adapter on database sharing the client, logger and
+// settings of madp, for queriers on databases other than the default:
+//
+//	for _, database := range databases {
+//		orders := mongoquerier.NewQuerier[Order](mongoAdapter.InDatabase(database.Name), "orders")
+//		...
+//	}
+//
+// Disconnecting it leaves the client connected. The adapter doesn't route
+// tenants (see Tenants): its database is the one it's asked for.
+func (madp *MongoAdapter) InDatabase(database string) *MongoAdapter {
+	adapter := &MongoAdapter{
+		Logger:             madp.Logger.With(LogField("database", database)),
+		Client:             madp.Client,
+		Database:           database,
+		WriteConcern:       madp.WriteConcern,
+		CriticalWTimeout:   madp.CriticalWTimeout,
+		DurabilityPresets:  madp.DurabilityPresets,
+		Authorize:          madp.Authorize,
+		Latency:            madp.Latency,
+		Chaos:              madp.Chaos,
+		Tracer:             madp.Tracer,
+		Metrics:            madp.Metrics,
+		SlowQueryThreshold: madp.SlowQueryThreshold,
+		databaseOptions:    madp.databaseOptions,
+		borrowedClient:     true,
+		operations:         madp.inFlight(),
+	}
+	if madp.Analytics != nil {
+		adapter.Analytics = madp.Analytics.InDatabase(database)
+	}
+	return adapter
+}
+
+// GetCollectionIn is GetCollection on another database than the adapter's.
+func (madp *MongoAdapter) GetCollectionIn(database string, collection string, opts ...*options.CollectionOptions) *mongo.Collection {
+	return madp.InDatabase(database).GetCollection(collection, opts...)
+}
@@ -0,0 +1,477 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultDataFixCollection = "mq_datafixes"
+	DefaultDataFixBatchSize  = 500
+	DefaultDataFixPause      = 100 * time.Millisecond
+	DefaultDataFixSamples    = 10
+
+	// dataFixRecordTimeout bounds recording a stage's outcome
+	dataFixRecordTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidDataFix      = errors.New("invalid data fix")
+	ErrDataFixVerification = errors.New("data fix verification failed")
+	ErrDataFixDeclined     = errors.New("data fix declined")
+)
+
+// DataFix is a one-off correction of production data: Transform fixes, in
+// place, every document matching Filter. It's run in stages, each recorded
+// in the operations collection: PreviewDataFix reports what it would change
+// without writing, ApplyDataFix writes it in paced, checkpointed batches,
+// and VerifyDataFix checks its post-conditions (RunDataFix chains them):
+//
+//	fix := &mongoquerier.DataFix[Order]{
+//		Name:   "2024-03-normalize-currency",
+//		Filter: bson.M{"currency": bson.M{"$in": bson.A{"usd", "Usd"}}},
+//		Transform: func(ctx context.Context, order *Order) error {
+//			order.Currency = strings.ToUpper(order.Currency)
+//			return nil
+//		},
+//	}
+//
+// Only the fields Transform changes are written ($set, or $unset when it
+// clears them), guarded by Filter: fields the model doesn't map survive,
+// and documents that stopped matching in the meantime are left alone.
+type DataFix[Model any] struct {
+	// Name identifies the fix in the operations collection and its
+	// checkpoint. Required.
+	Name string
+	// Filter selects the documents to fix. Required, bson.M{} for all.
+	Filter primitive.M
+	// Transform fixes a document in place. Required.
+	Transform func(ctx context.Context, document *Model) error
+	// Verify checks the post-conditions of the applied fix. By default,
+	// VerifyDataFix checks that Transform changes no matching document
+	// anymore.
+	Verify func(ctx context.Context) error
+
+	BatchSize int
+	// Pause between batches keeps the fix from starving other traffic.
+	Pause time.Duration
+	// Samples caps the changes kept in reports, DefaultDataFixSamples when
+	// zero.
+	Samples int
+	// OperationsCollection is where the stages are recorded,
+	// DefaultDataFixCollection when empty.
+	OperationsCollection string
+	// Checkpoints persists ApplyDataFix's progress, on
+	// DefaultCheckpointCollection when nil.
+	Checkpoints *CheckpointStore
+}
+
+type DataFixStage string
+
+const (
+	DataFixPreview DataFixStage = "preview"
+	DataFixApply   DataFixStage = "apply"
+	DataFixVerify  DataFixStage = "verify"
+)
+
+// DataFixChange is the change of a fix to a document, its fields as stored
+// before (Source) and after (Target).
+type DataFixChange struct {
+	ID     bson.RawValue
+	Fields []FieldDiff
+}
+
+type DataFixReport struct {
+	Fix   string
+	Stage DataFixStage
+	// Scanned counts the documents matching the filter Transform ran on.
+	Scanned int64
+	// Changed counts the documents Transform changed; for ApplyDataFix, the
+	// documents modified.
+	Changed int64
+	// Samples are the first changes, up to the fix's Samples.
+	Samples []DataFixChange
+	// Resumed is set when ApplyDataFix continued from a checkpoint; counts
+	// include the batches applied before.
+	Resumed  bool
+	Duration time.Duration
+}
+
+// dataFixOperation is the record of a stage in the operations collection.
+type dataFixOperation struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Fix        string             `bson:"fix"`
+	Stage      DataFixStage       `bson:"stage"`
+	Database   string             `bson:"database"`
+	Collection string             `bson:"collection"`
+	Filter     primitive.M        `bson:"filter"`
+	Status     string             `bson:"status"`
+	StartedAt  time.Time          `bson:"started_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty"`
+	Scanned    int64              `bson:"scanned"`
+	Changed    int64              `bson:"changed"`
+	Resumed    bool               `bson:"resumed,omitempty"`
+	Error      string             `bson:"error,omitempty"`
+}
+
+// dataFixCheckpoint is ApplyDataFix's progress.
+type dataFixCheckpoint struct {
+	LastID  bson.RawValue `bson:"last_id"`
+	Scanned int64         `bson:"scanned"`
+	Changed int64         `bson:"changed"`
+}
+
+func (fix *DataFix[Model]) validate() error {
+	switch {
+	case fix.Name == "":
+		return fmt.Errorf("%w: Name is required", ErrInvalidDataFix)
+	case fix.Filter == nil:
+		return fmt.Errorf("%w: %s has no Filter, use bson.M{} to fix every document", ErrInvalidDataFix, fix.Name)
+	case fix.Transform == nil:
+		return fmt.Errorf("%w: %s has no Transform", ErrInvalidDataFix, fix.Name)
+	}
+	return nil
+}
+
+// PreviewDataFix runs fix's Transform on the matching documents without
+// writing anything, reporting how many it would change and samples of the
+// changes.
+func (q *Querier[Model, IDModel]) PreviewDataFix(ctx context.Context, fix *DataFix[Model]) (*DataFixReport, error) {
+	return q.runDataFixStage(ctx, fix, DataFixPreview, "PreviewDataFix")
+}
+
+// ApplyDataFix writes fix in batches, checkpointing after each one, so an
+// interrupted fix resumes after the last batch applied. The checkpoint is
+// deleted once the fix completed.
+func (q *Querier[Model, IDModel]) ApplyDataFix(ctx context.Context, fix *DataFix[Model]) (*DataFixReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	return q.runDataFixStage(ctx, fix, DataFixApply, "ApplyDataFix")
+}
+
+// VerifyDataFix checks the post-conditions of an applied fix, failing with
+// ErrDataFixVerification: fix's Verify, or by default that Transform
+// changes none of the matching documents anymore, the report sampling the
+// ones it still would.
+func (q *Querier[Model, IDModel]) VerifyDataFix(ctx context.Context, fix *DataFix[Model]) (*DataFixReport, error) {
+	return q.runDataFixStage(ctx, fix, DataFixVerify, "VerifyDataFix")
+}
+
+// RunDataFix previews fix, applies it once confirm approves the preview,
+// then verifies it. A declined fix fails with ErrDataFixDeclined, having
+// written nothing:
+//
+//	report, err := orders.RunDataFix(ctx, fix, func(preview *mongoquerier.DataFixReport) bool {
+//		return preview.Changed < 10_000
+//	})
+func (q *Querier[Model, IDModel]) RunDataFix(ctx context.Context, fix *DataFix[Model], confirm func(preview *DataFixReport) bool) (*DataFixReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	preview, err := q.PreviewDataFix(ctx, fix)
+	if err != nil {
+		return preview, err
+	}
+	if confirm != nil && !confirm(preview) {
+		return preview, fmt.Errorf("%w: %s", ErrDataFixDeclined, fix.Name)
+	}
+
+	if report, err := q.ApplyDataFix(ctx, fix); err != nil {
+		return report, err
+	}
+	return q.VerifyDataFix(ctx, fix)
+}
+
+func (q *Querier[Model, IDModel]) runDataFixStage(ctx context.Context, fix *DataFix[Model], stage DataFixStage, operation string) (report *DataFixReport, err error) {
+	if err = fix.validate(); err != nil {
+		return nil, q.opError(err, time.Now(), operation, nil)
+	}
+	if err = q.preflight(ctx, operation, fix.Filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, operation, fix.Filter)
+	defer q.observe(span, time.Now(), operation, fix.Filter, &err)
+
+	report = &DataFixReport{Fix: fix.Name, Stage: stage}
+	record, err := q.beginDataFixOperation(ctx, fix, stage)
+	if err != nil {
+		// Fixes don't run without their audit trail
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		report.Duration = time.Since(start)
+		if recordErr := q.finishDataFixOperation(ctx, fix, record, report, err); recordErr != nil && err == nil {
+			err = recordErr
+		}
+	}()
+
+	if stage == DataFixVerify && fix.Verify != nil {
+		if err = fix.Verify(ctx); err != nil {
+			return report, fmt.Errorf("%w: %s: %v", ErrDataFixVerification, fix.Name, err)
+		}
+		return report, nil
+	}
+
+	if err = q.scanDataFix(ctx, fix, stage, operation, report); err != nil {
+		return report, err
+	}
+	if stage == DataFixVerify && report.Changed > 0 {
+		return report, fmt.Errorf("%w: %s would still change %d documents", ErrDataFixVerification, fix.Name, report.Changed)
+	}
+
+	q.MongoAdapter.Info(
+		"Ran data fix stage",
+		LogField("collection_name", q.collection.Name()),
+		LogField("fix", fix.Name),
+		LogField("stage", string(stage)),
+		LogField("documents_scanned", report.Scanned),
+		LogField("documents_changed", report.Changed),
+	)
+	return report, nil
+}
+
+// scanDataFix runs fix's Transform on the matching documents in _id order,
+// writing the changes when applying it.
+func (q *Querier[Model, IDModel]) scanDataFix(ctx context.Context, fix *DataFix[Model], stage DataFixStage, operation string, report *DataFixReport) error {
+	batchSize, pause, samples := DefaultDataFixBatchSize, DefaultDataFixPause, DefaultDataFixSamples
+	if fix.BatchSize > 0 {
+		batchSize = fix.BatchSize
+	}
+	if fix.Pause > 0 {
+		pause = fix.Pause
+	}
+	if fix.Samples > 0 {
+		samples = fix.Samples
+	}
+	apply := stage == DataFixApply
+
+	checkpoints := fix.Checkpoints
+	if checkpoints == nil {
+		checkpoints = NewCheckpointStore(q.MongoAdapter, "")
+	}
+	job, err := q.dataFixJob(ctx, fix)
+	if err != nil {
+		return err
+	}
+
+	var lastID interface{}
+	if apply {
+		var checkpoint dataFixCheckpoint
+		err := checkpoints.LoadCheckpoint(ctx, job, &checkpoint)
+		switch {
+		case err == nil:
+			lastID = checkpoint.LastID
+			report.Scanned, report.Changed, report.Resumed = checkpoint.Scanned, checkpoint.Changed, true
+		case !errors.Is(err, ErrCheckpointNotFound):
+			return err
+		}
+	}
+
+	for {
+		batchFilter := fix.Filter
+		if lastID != nil {
+			batchFilter = bson.M{"$and": bson.A{fix.Filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(int64(batchSize))
+		cursor, err := retrying(ctx, q, operation, func() (*mongo.Cursor, error) {
+			return q.tenantCollection(ctx).Find(ctx, batchFilter, findOptions)
+		})
+		if err != nil {
+			return err
+		}
+		var batch []bson.Raw
+		if err = cursor.All(ctx, &batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		var models []mongo.WriteModel
+		for _, raw := range batch {
+			id := raw.Lookup("_id")
+			lastID = id
+			report.Scanned++
+
+			changes, err := q.dataFixChanges(ctx, fix, raw)
+			if err != nil {
+				return fmt.Errorf("data fix %s on document %v: %w", fix.Name, id, err)
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			if len(report.Samples) < samples {
+				report.Samples = append(report.Samples, DataFixChange{ID: id, Fields: changes})
+			}
+			if !apply {
+				report.Changed++
+				continue
+			}
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"$and": bson.A{fix.Filter, bson.M{"_id": id}}}).
+				SetUpdate(diffUpdate(changes)))
+		}
+
+		if len(models) > 0 {
+			res, err := retrying(ctx, q, operation, func() (*mongo.BulkWriteResult, error) {
+				return q.writeCollection(ctx).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			})
+			if err != nil {
+				q.logWriteFailure(ctx, operation, err)
+				return err
+			}
+			report.Changed += res.ModifiedCount
+		}
+		if apply {
+			checkpoint := dataFixCheckpoint{LastID: lastID.(bson.RawValue), Scanned: report.Scanned, Changed: report.Changed}
+			if err := checkpoints.SaveCheckpoint(ctx, job, checkpoint); err != nil {
+				return err
+			}
+		}
+
+		q.MongoAdapter.Debug(
+			"Ran data fix on a batch of documents",
+			LogField("collection_name", q.collection.Name()),
+			LogField("fix", fix.Name),
+			LogField("stage", string(stage)),
+			LogField("documents_scanned", report.Scanned),
+			LogField("documents_changed", report.Changed),
+		)
+
+		if len(batch) < batchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+
+	if apply {
+		return checkpoints.DeleteCheckpoint(ctx, job)
+	}
+	return nil
+}
+
+// dataFixJob names fix's checkpoint after the namespace it runs in and its
+// filter, so a fix of the same name never resumes from the progress of
+// another tenant's run or of a run over other documents.
+func (q *Querier[Model, IDModel]) dataFixJob(ctx context.Context, fix *DataFix[Model]) (string, error) {
+	filter, err := normalizeFilter(fix.Filter)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(filter))
+	namespace := q.tenantCollection(ctx).Database().Name() + "." + q.collection.Name()
+	return "datafix:" + namespace + ":" + fix.Name + ":" + hex.EncodeToString(sum[:8]), nil
+}
+
+// dataFixChanges returns the fields fix's Transform changes in the stored
+// document raw. Both sides are encoded from the model, so fields it doesn't
+// map aren't reported as removed.
+func (q *Querier[Model, IDModel]) dataFixChanges(ctx context.Context, fix *DataFix[Model], raw bson.Raw) ([]FieldDiff, error) {
+	document, err := q.decode(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	before, err := q.encodeDocument(*document)
+	if err != nil {
+		return nil, err
+	}
+	if err = fix.Transform(ctx, document); err != nil {
+		return nil, err
+	}
+	after, err := q.encodeDocument(*document)
+	if err != nil {
+		return nil, err
+	}
+	return diffDocuments("", before, after, nil), nil
+}
+
+// dataFixOperations returns the collection of fix's audit records, in the
+// database of ctx's tenant alongside the fixed collection.
+func (q *Querier[Model, IDModel]) dataFixOperations(ctx context.Context, fix *DataFix[Model]) (*mongo.Collection, error) {
+	name := fix.OperationsCollection
+	if name == "" {
+		name = DefaultDataFixCollection
+	}
+	return q.MongoAdapter.collectionFor(ctx, name)
+}
+
+func (q *Querier[Model, IDModel]) beginDataFixOperation(ctx context.Context, fix *DataFix[Model], stage DataFixStage) (*dataFixOperation, error) {
+	// The trail outlives the fix, so its filter keeps no classified values
+	record := &dataFixOperation{
+		ID:         primitive.NewObjectID(),
+		Fix:        fix.Name,
+		Stage:      stage,
+		Database:   q.tenantCollection(ctx).Database().Name(),
+		Collection: q.collection.Name(),
+		Filter:     RedactPII(fix.Filter, piiFieldsFor(modelType[Model]())).(primitive.M),
+		Status:     "running",
+		StartedAt:  time.Now().UTC(),
+	}
+	operations, err := q.dataFixOperations(ctx, fix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := operations.InsertOne(ctx, record); err != nil {
+		q.MongoAdapter.Error(
+			"unable to record data fix operation",
+			LogField("collection_name", q.collection.Name()),
+			LogField("fix", fix.Name),
+			LogField("stage", string(stage)),
+			LogError(err),
+		)
+		return nil, err
+	}
+	return record, nil
+}
+
+func (q *Querier[Model, IDModel]) finishDataFixOperation(ctx context.Context, fix *DataFix[Model], record *dataFixOperation, report *DataFixReport, stageErr error) error {
+	finishedAt := time.Now().UTC()
+	set := bson.M{
+		"status":      "done",
+		"finished_at": finishedAt,
+		"scanned":     report.Scanned,
+		"changed":     report.Changed,
+		"resumed":     report.Resumed,
+	}
+	if stageErr != nil {
+		set["status"] = "failed"
+		set["error"] = stageErr.Error()
+	}
+
+	// Record the outcome even when ctx was canceled mid-stage
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, dataFixRecordTimeout)
+	defer cancel()
+	operations, err := q.dataFixOperations(ctx, fix)
+	if err != nil {
+		return err
+	}
+	if _, err := operations.UpdateOne(ctx, bson.M{"_id": record.ID}, bson.M{"$set": set}); err != nil {
+		q.MongoAdapter.Error(
+			"unable to record data fix outcome",
+			LogField("collection_name", q.collection.Name()),
+			LogField("fix", fix.Name),
+			LogField("stage", string(record.Stage)),
+			LogError(err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDataFixJob(t *testing.T) {
+	madp := newTestAdapter(t)
+	madp.Tenants = TenantDatabases("shop_%s")
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	transform := func(ctx context.Context, node *recursiveNode) error { return nil }
+	fix := &DataFix[recursiveNode]{Name: "lowercase", Filter: bson.M{"email": bson.M{"$regex": "[A-Z]"}, "parent": nil}, Transform: transform}
+
+	acme, err := q.dataFixJob(WithTenant(context.Background(), "acme"), fix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := q.dataFixJob(WithTenant(context.Background(), "acme"), &DataFix[recursiveNode]{Name: "lowercase", Filter: bson.M{"parent": nil, "email": bson.M{"$regex": "[A-Z]"}}, Transform: transform})
+	if again != acme {
+		t.Errorf("dataFixJob() = %q for the same filter, want %q", again, acme)
+	}
+	if other, _ := q.dataFixJob(WithTenant(context.Background(), "globex"), fix); other == acme {
+		t.Errorf("dataFixJob() = %q for another tenant", other)
+	}
+	if narrowed, _ := q.dataFixJob(WithTenant(context.Background(), "acme"), &DataFix[recursiveNode]{Name: "lowercase", Filter: bson.M{"email": "A@B.C"}, Transform: transform}); narrowed == acme {
+		t.Errorf("dataFixJob() = %q for another filter", narrowed)
+	}
+}
+
+func TestDataFixErrorsAreOpErrors(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+
+	var opErr *OpError
+	_, err := q.PreviewDataFix(context.Background(), &DataFix[recursiveNode]{Name: "incomplete"})
+	if !errors.Is(err, ErrInvalidDataFix) || !errors.As(err, &opErr) || opErr.Operation != "PreviewDataFix" {
+		t.Errorf("PreviewDataFix() = %v, want an OpError of ErrInvalidDataFix", err)
+	}
+}
@@ -0,0 +1,174 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func modelType[Model any]() reflect.Type {
+	return reflect.TypeOf((*Model)(nil)).Elem()
+}
+
+// AfterReadHook transforms a decoded document before it's returned; an error
+// fails the read.
+type AfterReadHook[Model any] func(ctx context.Context, document *Model) error
+
+func (q *Querier[Model, IDModel]) decode(ctx context.Context, stored bson.Raw) (decoded *Model, err error) {
+	defer q.logPanic(&err)
+	defer func() {
+		// A document decoding panics on is as undecodable as one failing to
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			err = newDecodeError(stored, err)
+		}
+	}()
+	defer recoverPanic(&err, "decode", modelType[Model](), nil)
+
+	raw := stored
+
+	// Documents written under aliased (legacy) keys or with values of legacy
+	// types are rewritten before decoding
+	aliases := aliasesFor(modelType[Model]())
+	coercions := coercionsFor(modelType[Model]())
+	if len(aliases) > 0 || len(coercions) > 0 {
+		var doc bson.D
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, newDecodeError(stored, err)
+		}
+
+		doc, err = coerceDocument(resolveAliases(doc, aliases), coercions)
+		if err != nil {
+			return nil, newDecodeError(stored, err)
+		}
+		raw, err = bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var document Model
+	if registered, ok := subtypesOf(modelType[Model]()); ok {
+		subtype, err := registered.decode(raw)
+		if err != nil {
+			return nil, newDecodeError(stored, err)
+		}
+		document = subtype.(Model)
+	} else if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, newDecodeError(stored, err)
+	}
+
+	if err := q.repair(ctx, stored, &document); err != nil {
+		return nil, err
+	}
+
+	// After-read hooks see the repaired document, write-back doesn't see theirs
+	for _, hook := range q.AfterRead {
+		if err := hook(ctx, &document); err != nil {
+			return nil, err
+		}
+	}
+	return &document, nil
+}
+
+func (q *Querier[Model, IDModel]) decodeSingle(ctx context.Context, res *mongo.SingleResult) (*Model, error) {
+	raw, err := res.Raw()
+	if err != nil {
+		return nil, err
+	}
+	return q.decode(ctx, raw)
+}
+
+func (q *Querier[Model, IDModel]) decodeCursor(ctx context.Context, cursor *mongo.Cursor) (documents []*Model, err error) {
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document *Model
+		if document, err = q.decode(ctx, cursor.Current); err != nil {
+			if skipUndecodable(ctx, err) {
+				err = nil
+				continue
+			}
+			return
+		}
+
+		documents = append(documents, document)
+	}
+
+	err = cursor.Err()
+	return
+}
+
+// prepareDocument converts a document for insertion, dual-writing aliased
+// fields under their legacy keys when DualWriteAliases is enabled.
+func (q *Querier[Model, IDModel]) prepareDocument(document Model) (interface{}, error) {
+	aliases := aliasesFor(modelType[Model]())
+	_, polymorphic := subtypesOf(modelType[Model]())
+	if !polymorphic && (!q.DualWriteAliases || len(aliases) == 0) {
+		return document, nil
+	}
+
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if q.DualWriteAliases {
+		dualWriteAliases(doc, aliases, "")
+	}
+	if err := q.stampSubtype(document, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// prepareReplacement converts a replacement document like prepareDocument,
+// into a bson.M of the whole document as the BSON codecs encode it, zero
+// fields included: a replaced document keeps no field it doesn't hold. A
+// zero _id is left out, as a replacement can't change the stored one.
+func (q *Querier[Model, IDModel]) prepareReplacement(document Model) (bson.M, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if id, ok := doc["_id"]; ok && (id == nil || reflect.ValueOf(id).IsZero()) {
+		delete(doc, "_id")
+	}
+	q.dualWrite(doc)
+	if err := q.stampSubtype(document, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// encodeDocument marshals document as prepareDocument stores it.
+func (q *Querier[Model, IDModel]) encodeDocument(document Model) (bson.Raw, error) {
+	prepared, err := q.prepareDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(prepared)
+}
+
+func (q *Querier[Model, IDModel]) prepareSet(updateM bson.M) bson.M {
+	q.dualWrite(updateM)
+	return bson.M{"$set": updateM}
+}
+
+func (q *Querier[Model, IDModel]) dualWrite(m bson.M) {
+	if q.DualWriteAliases {
+		dualWriteAliases(m, aliasesFor(modelType[Model]()), "")
+	}
+}
@@ -0,0 +1,339 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultDiffMaxDifferences = 1000
+
+type DiffOptions struct {
+	// Filter restricts both sides of the diff.
+	Filter primitive.M
+	// IgnoreFields are (dotted) paths left out of the comparison, such as
+	// timestamps the migration rewrites.
+	IgnoreFields []string
+	// MaxDifferences caps how many missing, extra and differing documents
+	// are kept in the report; counts are always complete.
+	MaxDifferences int
+}
+
+type FieldDiff struct {
+	Path string
+	// Source or Target has a zero Type when the field is absent there.
+	Source bson.RawValue
+	Target bson.RawValue
+}
+
+type DocumentDiff struct {
+	Key    bson.D
+	Fields []FieldDiff
+}
+
+type DiffReport struct {
+	Compared int64
+	// Missing are keys found in the source only, Extra in the target only.
+	Missing        []bson.D
+	Extra          []bson.D
+	Differing      []DocumentDiff
+	MissingCount   int64
+	ExtraCount     int64
+	DifferingCount int64
+}
+
+func (r *DiffReport) Equal() bool {
+	return r.MissingCount == 0 && r.ExtraCount == 0 && r.DifferingCount == 0
+}
+
+// DiffCollections streams the source and target collections sorted by
+// keyFields (_id when empty) and reports documents missing from the target,
+// extra in it, and differing between both down to the field. keyFields
+// should be unique on both sides, ideally backed by an index.
+func DiffCollections[Model any, IDModel any](ctx context.Context, source *Querier[Model, IDModel], target *Querier[Model, IDModel], keyFields []string, opts *DiffOptions) (*DiffReport, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	if len(keyFields) == 0 {
+		keyFields = []string{"_id"}
+	}
+	maxDifferences := opts.MaxDifferences
+	if maxDifferences <= 0 {
+		maxDifferences = DefaultDiffMaxDifferences
+	}
+
+	sourceCursor, err := source.diffCursor(ctx, keyFields, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	defer sourceCursor.Close(ctx)
+	targetCursor, err := target.diffCursor(ctx, keyFields, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	defer targetCursor.Close(ctx)
+
+	report := &DiffReport{}
+	hasSource, hasTarget := sourceCursor.Next(ctx), targetCursor.Next(ctx)
+	for hasSource || hasTarget {
+		order := 0
+		switch {
+		case !hasTarget:
+			order = -1
+		case !hasSource:
+			order = 1
+		default:
+			order = compareKeys(diffKey(sourceCursor.Current, keyFields), diffKey(targetCursor.Current, keyFields))
+		}
+
+		switch {
+		case order < 0:
+			report.MissingCount++
+			if len(report.Missing) < maxDifferences {
+				report.Missing = append(report.Missing, keyDocument(sourceCursor.Current, keyFields))
+			}
+			hasSource = sourceCursor.Next(ctx)
+		case order > 0:
+			report.ExtraCount++
+			if len(report.Extra) < maxDifferences {
+				report.Extra = append(report.Extra, keyDocument(targetCursor.Current, keyFields))
+			}
+			hasTarget = targetCursor.Next(ctx)
+		default:
+			report.Compared++
+			fields := diffDocuments("", sourceCursor.Current, targetCursor.Current, opts.IgnoreFields)
+			if len(fields) > 0 {
+				report.DifferingCount++
+				if len(report.Differing) < maxDifferences {
+					report.Differing = append(report.Differing, DocumentDiff{
+						Key:    keyDocument(sourceCursor.Current, keyFields),
+						Fields: fields,
+					})
+				}
+			}
+			hasSource, hasTarget = sourceCursor.Next(ctx), targetCursor.Next(ctx)
+		}
+	}
+	if err = sourceCursor.Err(); err != nil {
+		return report, err
+	}
+	if err = targetCursor.Err(); err != nil {
+		return report, err
+	}
+
+	source.MongoAdapter.Info(
+		"Diffed collections",
+		LogField("collection_name", source.collection.Name()),
+		LogField("target_collection_name", target.collection.Name()),
+		LogField("documents_compared", report.Compared),
+		LogField("documents_missing", report.MissingCount),
+		LogField("documents_extra", report.ExtraCount),
+		LogField("documents_differing", report.DifferingCount),
+	)
+	return report, nil
+}
+
+func (q *Querier[Model, IDModel]) diffCursor(ctx context.Context, keyFields []string, filter primitive.M) (cursor *mongo.Cursor, err error) {
+	if err = q.preflight(ctx, "DiffCollections", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "DiffCollections", filter)
+	defer q.observe(span, time.Now(), "DiffCollections", filter, &err)
+	if filter == nil {
+		filter = primitive.M{}
+	}
+
+	sort := bson.D{}
+	for _, field := range keyFields {
+		sort = append(sort, bson.E{Key: field, Value: 1})
+	}
+	return retrying(ctx, q, "DiffCollections", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "DiffCollections", []*options.FindOptions{options.Find().SetSort(sort)})...)
+	})
+}
+
+func diffKey(document bson.Raw, keyFields []string) []bson.RawValue {
+	key := make([]bson.RawValue, len(keyFields))
+	for i, field := range keyFields {
+		key[i], _ = document.LookupErr(strings.Split(field, ".")...)
+	}
+	return key
+}
+
+func keyDocument(document bson.Raw, keyFields []string) bson.D {
+	key := bson.D{}
+	for i, value := range diffKey(document, keyFields) {
+		var decoded interface{}
+		if value.Type != 0 {
+			value.Unmarshal(&decoded)
+		}
+		key = append(key, bson.E{Key: keyFields[i], Value: decoded})
+	}
+	return key
+}
+
+func compareKeys(a []bson.RawValue, b []bson.RawValue) int {
+	for i := range a {
+		if order := compareRawValues(a[i], b[i]); order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+// compareRawValues orders values the way the server sorts them for the
+// types commonly used as keys: by type bracket first, then by value.
+func compareRawValues(a bson.RawValue, b bson.RawValue) int {
+	if bracketA, bracketB := typeBracket(a.Type), typeBracket(b.Type); bracketA != bracketB {
+		if bracketA < bracketB {
+			return -1
+		}
+		return 1
+	}
+
+	if isNumber(a.Type) && isNumber(b.Type) {
+		if a.Type != bson.TypeDouble && b.Type != bson.TypeDouble {
+			return compareOrdered(a.AsInt64(), b.AsInt64())
+		}
+		return compareOrdered(numberAsFloat(a), numberAsFloat(b))
+	}
+
+	switch a.Type {
+	case bson.TypeString:
+		return strings.Compare(a.StringValue(), b.StringValue())
+	case bson.TypeDateTime:
+		return compareOrdered(a.DateTime(), b.DateTime())
+	case bson.TypeBoolean:
+		x, y := a.Boolean(), b.Boolean()
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	}
+	// ObjectIDs, binaries and the rest compare by their encoding
+	return bytes.Compare(a.Value, b.Value)
+}
+
+func isNumber(t bsontype.Type) bool {
+	return t == bson.TypeInt32 || t == bson.TypeInt64 || t == bson.TypeDouble
+}
+
+func numberAsFloat(value bson.RawValue) float64 {
+	if value.Type == bson.TypeDouble {
+		return value.Double()
+	}
+	return float64(value.AsInt64())
+}
+
+func compareOrdered[T int64 | float64](x T, y T) int {
+	if x < y {
+		return -1
+	}
+	if x > y {
+		return 1
+	}
+	return 0
+}
+
+// typeBracket is the server's sort order of BSON types.
+func typeBracket(t bsontype.Type) int {
+	switch t {
+	case 0, bson.TypeUndefined, bson.TypeNull:
+		// Missing fields sort as null
+		return 1
+	case bson.TypeInt32, bson.TypeInt64, bson.TypeDouble, bson.TypeDecimal128:
+		return 2
+	case bson.TypeString, bson.TypeSymbol:
+		return 3
+	case bson.TypeEmbeddedDocument:
+		return 4
+	case bson.TypeArray:
+		return 5
+	case bson.TypeBinary:
+		return 6
+	case bson.TypeObjectID:
+		return 7
+	case bson.TypeBoolean:
+		return 8
+	case bson.TypeDateTime:
+		return 9
+	case bson.TypeTimestamp:
+		return 10
+	case bson.TypeRegex:
+		return 11
+	}
+	return 12
+}
+
+// diffDocuments compares two documents field by field, recursing into
+// embedded documents.
+func diffDocuments(prefix string, source bson.Raw, target bson.Raw, ignoreFields []string) []FieldDiff {
+	var diffs []FieldDiff
+
+	sourceElements, _ := source.Elements()
+	for _, element := range sourceElements {
+		path := prefix + element.Key()
+		if containsString(ignoreFields, path) {
+			continue
+		}
+
+		sourceValue := element.Value()
+		targetValue, err := target.LookupErr(element.Key())
+		if err != nil {
+			diffs = append(diffs, FieldDiff{Path: path, Source: sourceValue})
+			continue
+		}
+
+		if sourceValue.Type == bson.TypeEmbeddedDocument && targetValue.Type == bson.TypeEmbeddedDocument {
+			diffs = append(diffs, diffDocuments(path+".", sourceValue.Document(), targetValue.Document(), ignoreFields)...)
+			continue
+		}
+		if sourceValue.Type != targetValue.Type || !bytes.Equal(sourceValue.Value, targetValue.Value) {
+			diffs = append(diffs, FieldDiff{Path: path, Source: sourceValue, Target: targetValue})
+		}
+	}
+
+	targetElements, _ := target.Elements()
+	for _, element := range targetElements {
+		path := prefix + element.Key()
+		if containsString(ignoreFields, path) {
+			continue
+		}
+		if _, err := source.LookupErr(element.Key()); err != nil {
+			diffs = append(diffs, FieldDiff{Path: path, Target: element.Value()})
+		}
+	}
+	return diffs
+}
+
+// diffUpdate turns the changes from a stored document to its new version
+// into an update setting the changed fields and unsetting the removed ones.
+func diffUpdate(changes []FieldDiff) bson.M {
+	set, unset := bson.M{}, bson.M{}
+	for _, change := range changes {
+		if change.Target.Type == 0 {
+			unset[change.Path] = ""
+		} else {
+			set[change.Path] = change.Target
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
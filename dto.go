@@ -0,0 +1,237 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidDTO = errors.New("DTO field has no matching model field")
+
+// dtoMapping copies the fields of a model into a DTO. It's validated once
+// per (Model, DTO) pair and cached.
+type dtoMapping struct {
+	fields  []dtoField
+	mappers []func(model interface{}, dto interface{}) error
+}
+
+type dtoField struct {
+	dto     int
+	model   []int
+	convert bool
+}
+
+type dtoTypes struct {
+	model reflect.Type
+	dto   reflect.Type
+}
+
+var dtoMappings sync.Map // dtoTypes -> *dtoMapping
+
+var dtoMappers sync.Map // dtoTypes -> []func(model interface{}, dto interface{}) error
+
+var registerDTOMu sync.Mutex
+
+// RegisterDTOMapper adds a function filling DTO fields that aren't copied
+// from Model, e.g. ones derived from several model fields. Such fields are
+// tagged `mq:"computed"`. Mappers run in registration order, after the copy.
+func RegisterDTOMapper[Model any, DTO any](mapper func(model *Model, dto *DTO) error) {
+	types := dtoTypes{modelType[Model](), modelType[DTO]()}
+
+	registerDTOMu.Lock()
+	defer registerDTOMu.Unlock()
+
+	var mappers []func(model interface{}, dto interface{}) error
+	if existing, ok := dtoMappers.Load(types); ok {
+		mappers = append(mappers, existing.([]func(model interface{}, dto interface{}) error)...)
+	}
+	mappers = append(mappers, func(model interface{}, dto interface{}) error {
+		return mapper(model.(*Model), dto.(*DTO))
+	})
+	dtoMappers.Store(types, mappers)
+	dtoMappings.Delete(types)
+}
+
+// ValidateDTO checks that every field of DTO is either copied from a Model
+// field of a compatible type or tagged `mq:"computed"`. A DTO field copies
+// the model field of the same name, or the one named by `mq:"from=Field"`
+// (`from=Address.City` for nested fields).
+func ValidateDTO[Model any, DTO any]() error {
+	_, err := dtoMappingFor(modelType[Model](), modelType[DTO]())
+	return err
+}
+
+// MapDTO copies document into a new DTO; see ValidateDTO.
+//
+//	type PublicUser struct {
+//		ID    primitive.ObjectID `json:"id"`
+//		Name  string             `json:"name" mq:"from=DisplayName"`
+//		Since string             `json:"since" mq:"computed"`
+//	}
+func MapDTO[Model any, DTO any](document *Model) (*DTO, error) {
+	mapping, err := dtoMappingFor(modelType[Model](), modelType[DTO]())
+	if err != nil {
+		return nil, err
+	}
+	return mapDTO[Model, DTO](mapping, document)
+}
+
+func mapDTO[Model any, DTO any](mapping *dtoMapping, document *Model) (dto *DTO, err error) {
+	defer recoverPanic(&err, "MapDTO", modelType[DTO](), nil)
+
+	dto = new(DTO)
+	source := reflect.ValueOf(document).Elem()
+	destination := reflect.ValueOf(dto).Elem()
+	for _, field := range mapping.fields {
+		value, ok := fieldByIndex(source, field.model)
+		if !ok {
+			continue
+		}
+		if field.convert {
+			value = value.Convert(destination.Field(field.dto).Type())
+		}
+		destination.Field(field.dto).Set(value)
+	}
+
+	for _, mapper := range mapping.mappers {
+		if err := mapper(document, dto); err != nil {
+			return nil, err
+		}
+	}
+	return dto, nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, reporting false instead of
+// panicking on a nil embedded or nested pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func dtoMappingFor(modelT, dtoT reflect.Type) (*dtoMapping, error) {
+	types := dtoTypes{modelT, dtoT}
+	if cached, ok := dtoMappings.Load(types); ok {
+		return cached.(*dtoMapping), nil
+	}
+	if modelT.Kind() != reflect.Struct || dtoT.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s and %s must be structs", ErrInvalidDTO, modelT, dtoT)
+	}
+
+	mapping := &dtoMapping{}
+	if mappers, ok := dtoMappers.Load(types); ok {
+		mapping.mappers = mappers.([]func(model interface{}, dto interface{}) error)
+	}
+	for i := 0; i < dtoT.NumField(); i++ {
+		field := dtoT.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := parseMQTag(field.Tag.Get("mq"))
+		if _, computed := tag["computed"]; computed {
+			continue
+		}
+
+		from := field.Name
+		if names := tag["from"]; len(names) > 0 {
+			from = names[0]
+		}
+		modelField, ok := modelT.FieldByName(from)
+		if !ok {
+			modelField, ok = nestedField(modelT, from)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s.%s (from %s.%s)", ErrInvalidDTO, dtoT.Name(), field.Name, modelT.Name(), from)
+		}
+
+		mapped := dtoField{dto: i, model: modelField.Index}
+		switch {
+		case modelField.Type.AssignableTo(field.Type):
+		// Named types of the same kind (type Status string), never int to string
+		case modelField.Type.Kind() == field.Type.Kind() && modelField.Type.ConvertibleTo(field.Type):
+			mapped.convert = true
+		default:
+			return nil, fmt.Errorf("%w: %s.%s is %s, %s.%s is %s", ErrInvalidDTO, dtoT.Name(), field.Name, field.Type, modelT.Name(), from, modelField.Type)
+		}
+		mapping.fields = append(mapping.fields, mapped)
+	}
+
+	dtoMappings.Store(types, mapping)
+	return mapping, nil
+}
+
+// nestedField resolves a dotted path of field names, e.g. "Address.City".
+func nestedField(t reflect.Type, path string) (reflect.StructField, bool) {
+	var result reflect.StructField
+	var index []int
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return reflect.StructField{}, false
+		}
+		field, ok := t.FieldByName(name)
+		if !ok || len(field.Index) > 1 {
+			return reflect.StructField{}, false
+		}
+		index = append(index, field.Index...)
+		result, t = field, field.Type
+	}
+	result.Index = index
+	return result, len(index) > 0
+}
+
+// FindDTO finds documents like q.FindByM and maps them to DTO, so storage
+// models don't leak to API layers. The mapping is validated before the query
+// runs.
+func FindDTO[DTO any, Model any, IDModel any](ctx context.Context, q *Querier[Model, IDModel], filter primitive.M, opts ...*options.FindOptions) ([]*DTO, error) {
+	mapping, err := dtoMappingFor(modelType[Model](), modelType[DTO]())
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := q.FindByM(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]*DTO, 0, len(documents))
+	for _, document := range documents {
+		dto, err := mapDTO[Model, DTO](mapping, document)
+		if err != nil {
+			return nil, err
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos, nil
+}
+
+// FindOneDTO finds one document like q.FindOneByM and maps it to DTO.
+func FindOneDTO[DTO any, Model any, IDModel any](ctx context.Context, q *Querier[Model, IDModel], filter primitive.M, opts ...*options.FindOneOptions) (*DTO, error) {
+	mapping, err := dtoMappingFor(modelType[Model](), modelType[DTO]())
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := q.FindOneByM(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return mapDTO[Model, DTO](mapping, document)
+}
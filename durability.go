@@ -0,0 +1,75 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Durability is a class of writes mapped to a write concern preset, so one
+// adapter serves audit-grade and telemetry-grade writes alike.
+type Durability string
+
+const (
+	// DurabilityDefault keeps the adapter's write concern.
+	DurabilityDefault Durability = ""
+	// DurabilityCritical writes like Critical: acknowledged by a journaled
+	// majority and alerted on failure.
+	DurabilityCritical Durability = "critical"
+	// DurabilityBestEffort is acknowledged by the primary alone, without
+	// waiting for the journal, for high-volume data that can be lost.
+	DurabilityBestEffort Durability = "best-effort"
+)
+
+// DurabilityClassifier is implemented by models whose writes belong to a
+// durability class:
+//
+//	func (AuditEvent) Durability() mongoquerier.Durability { return mongoquerier.DurabilityCritical }
+type DurabilityClassifier interface {
+	Durability() Durability
+}
+
+type durabilityKey struct{}
+
+// WithDurability sets the durability class of the writes issued with the
+// returned context, over the querier's and the model's.
+func WithDurability(ctx context.Context, durability Durability) context.Context {
+	return context.WithValue(ctx, durabilityKey{}, durability)
+}
+
+// durability resolves the durability class of a write: Critical or the
+// context's class first, then the querier's, then the model's.
+func (q *Querier[Model, IDModel]) durability(ctx context.Context) Durability {
+	if IsCritical(ctx) {
+		return DurabilityCritical
+	}
+	if durability, ok := ctx.Value(durabilityKey{}).(Durability); ok {
+		return durability
+	}
+	if q.Durability != DurabilityDefault {
+		return q.Durability
+	}
+	var model Model
+	if classifier, ok := interface{}(model).(DurabilityClassifier); ok {
+		return classifier.Durability()
+	}
+	return DurabilityDefault
+}
+
+// durabilityWriteConcern returns the write concern of durability, nil for
+// the adapter's. Adapters' DurabilityPresets override and extend the
+// built-in classes.
+func (madp *MongoAdapter) durabilityWriteConcern(durability Durability) *writeconcern.WriteConcern {
+	if preset, ok := madp.DurabilityPresets[durability]; ok {
+		return preset
+	}
+
+	switch durability {
+	case DurabilityCritical:
+		return madp.criticalWriteConcern()
+	case DurabilityBestEffort:
+		journal := false
+		return &writeconcern.WriteConcern{W: 1, Journal: &journal}
+	}
+	return nil
+}
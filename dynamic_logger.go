@@ -0,0 +1,238 @@
+package mongoquerier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Level int8
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int8(l))
+}
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// DynamicLogger is a Logger whose underlying logger and levels change at
+// runtime, without restarting: swap the logger, or bump a single collection
+// to debug during an incident.
+//
+//	logger := mongoquerier.NewDynamicLogger(zaplog.New(debugEnabledZapLogger), mongoquerier.LevelInfo)
+//	mongoAdapter, err := mongoquerier.NewMongoAdapter(ctx, uri, "shop", mongoquerier.WithLogger(logger))
+//	...
+//	logger.SetCollectionLevel("orders", mongoquerier.LevelDebug)
+//
+// Entries are filtered by the level of the collection they name (their
+// "collection_name" field), or the default level, before reaching the
+// underlying logger, which must let through every level that may be turned
+// on.
+type DynamicLogger struct {
+	state atomic.Pointer[dynamicLoggerState]
+	// mu serializes updates, which copy the state
+	mu sync.Mutex
+}
+
+type dynamicLoggerState struct {
+	logger      Logger
+	level       Level
+	collections map[string]Level
+}
+
+func NewDynamicLogger(logger Logger, level Level) *DynamicLogger {
+	d := &DynamicLogger{}
+	d.state.Store(&dynamicLoggerState{logger: logger, level: level})
+	return d
+}
+
+func (d *DynamicLogger) update(change func(state *dynamicLoggerState)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := d.state.Load()
+	next := &dynamicLoggerState{
+		logger:      current.logger,
+		level:       current.level,
+		collections: make(map[string]Level, len(current.collections)),
+	}
+	for collection, level := range current.collections {
+		next.collections[collection] = level
+	}
+	change(next)
+	d.state.Store(next)
+}
+
+// SetLogger replaces the underlying logger. Loggers derived with With
+// switch too.
+func (d *DynamicLogger) SetLogger(logger Logger) {
+	d.update(func(state *dynamicLoggerState) { state.logger = logger })
+}
+
+func (d *DynamicLogger) SetLevel(level Level) {
+	d.update(func(state *dynamicLoggerState) { state.level = level })
+}
+
+func (d *DynamicLogger) Level() Level {
+	return d.state.Load().level
+}
+
+// SetCollectionLevel overrides the level of the entries naming collection.
+func (d *DynamicLogger) SetCollectionLevel(collection string, level Level) {
+	d.update(func(state *dynamicLoggerState) { state.collections[collection] = level })
+}
+
+// ClearCollectionLevel puts collection back on the default level.
+func (d *DynamicLogger) ClearCollectionLevel(collection string) {
+	d.update(func(state *dynamicLoggerState) { delete(state.collections, collection) })
+}
+
+// CollectionLevels returns the collections with a level of their own.
+func (d *DynamicLogger) CollectionLevels() map[string]Level {
+	levels := map[string]Level{}
+	for collection, level := range d.state.Load().collections {
+		levels[collection] = level
+	}
+	return levels
+}
+
+func (d *DynamicLogger) Debug(msg string, fields ...Field) { d.log(LevelDebug, msg, nil, fields) }
+func (d *DynamicLogger) Info(msg string, fields ...Field)  { d.log(LevelInfo, msg, nil, fields) }
+func (d *DynamicLogger) Warn(msg string, fields ...Field)  { d.log(LevelWarn, msg, nil, fields) }
+func (d *DynamicLogger) Error(msg string, fields ...Field) { d.log(LevelError, msg, nil, fields) }
+
+func (d *DynamicLogger) With(fields ...Field) Logger {
+	return &dynamicChild{parent: d, fields: fields}
+}
+
+func (d *DynamicLogger) log(level Level, msg string, context []Field, fields []Field) {
+	state := d.state.Load()
+	if level < state.enabledLevel(context, fields) {
+		return
+	}
+
+	if len(context) > 0 {
+		fields = append(append([]Field(nil), context...), fields...)
+	}
+	switch level {
+	case LevelDebug:
+		state.logger.Debug(msg, fields...)
+	case LevelInfo:
+		state.logger.Info(msg, fields...)
+	case LevelWarn:
+		state.logger.Warn(msg, fields...)
+	default:
+		state.logger.Error(msg, fields...)
+	}
+}
+
+func (s *dynamicLoggerState) enabledLevel(context []Field, fields []Field) Level {
+	if len(s.collections) == 0 {
+		return s.level
+	}
+	for _, group := range [][]Field{fields, context} {
+		for _, field := range group {
+			if field.Key != "collection_name" {
+				continue
+			}
+			if collection, ok := field.Value.(string); ok {
+				if level, ok := s.collections[collection]; ok {
+					return level
+				}
+			}
+		}
+	}
+	return s.level
+}
+
+// dynamicChild is a DynamicLogger with fields, added to its entries when
+// they're written so that it follows the parent's logger.
+type dynamicChild struct {
+	parent *DynamicLogger
+	fields []Field
+}
+
+func (c *dynamicChild) Debug(msg string, fields ...Field) {
+	c.parent.log(LevelDebug, msg, c.fields, fields)
+}
+func (c *dynamicChild) Info(msg string, fields ...Field) {
+	c.parent.log(LevelInfo, msg, c.fields, fields)
+}
+func (c *dynamicChild) Warn(msg string, fields ...Field) {
+	c.parent.log(LevelWarn, msg, c.fields, fields)
+}
+func (c *dynamicChild) Error(msg string, fields ...Field) {
+	c.parent.log(LevelError, msg, c.fields, fields)
+}
+
+func (c *dynamicChild) With(fields ...Field) Logger {
+	return &dynamicChild{parent: c.parent, fields: append(append([]Field(nil), c.fields...), fields...)}
+}
+
+// LevelControl returns the level of collection, or the default level when
+// collection is empty, as a LogLevel for ControlPanel.RegisterLogLevel.
+// Setting a collection's level to "" clears it.
+func (d *DynamicLogger) LevelControl(collection string) LogLevel {
+	return &dynamicLevelControl{logger: d, collection: collection}
+}
+
+type dynamicLevelControl struct {
+	logger     *DynamicLogger
+	collection string
+}
+
+func (c *dynamicLevelControl) String() string {
+	if c.collection == "" {
+		return c.logger.Level().String()
+	}
+	if level, ok := c.logger.state.Load().collections[c.collection]; ok {
+		return level.String()
+	}
+	return ""
+}
+
+func (c *dynamicLevelControl) UnmarshalText(text []byte) error {
+	if c.collection != "" && len(text) == 0 {
+		c.logger.ClearCollectionLevel(c.collection)
+		return nil
+	}
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	if c.collection == "" {
+		c.logger.SetLevel(level)
+	} else {
+		c.logger.SetCollectionLevel(c.collection, level)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrUnsupportedEncryptedFilter = errors.New("filter not supported on encrypted field")
+
+// EncryptedField is a field of a model encrypted with Queryable Encryption,
+// declared with `mq:"encrypted"`, or `mq:"encrypted=equality"` to query it
+// for equality, or the same option of its PII classification:
+//
+//	type Patient struct {
+//		ID        primitive.ObjectID `bson:"_id,omitempty"`
+//		SSN       string             `bson:"ssn" pii:"id,encrypted=equality"`
+//		Diagnosis string             `bson:"diagnosis" mq:"encrypted"`
+//	}
+//
+// The BSON type is derived from the Go type; fields whose type doesn't
+// determine it (int, interface{}) name it with `mq:"bsontype=..."`.
+type EncryptedField struct {
+	Path      string
+	BSONType  string
+	Queryable bool
+}
+
+var encryptedFieldsCache sync.Map // reflect.Type -> []EncryptedField
+
+// EncryptedFields returns the encrypted fields of Model, ordered by path.
+func EncryptedFields[Model any]() []EncryptedField {
+	return encryptedFieldsFor(modelType[Model]())
+}
+
+func encryptedFieldsFor(t reflect.Type) []EncryptedField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if cached, ok := encryptedFieldsCache.Load(t); ok {
+		return cached.([]EncryptedField)
+	}
+
+	fields := collectEncryptedFields(t, map[reflect.Type]bool{})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	encryptedFieldsCache.Store(t, fields)
+	return fields
+}
+
+// collectEncryptedFields walks t's fields, skipping the types already being
+// walked so self-referential models terminate.
+func collectEncryptedFields(t reflect.Type, visiting map[reflect.Type]bool) []EncryptedField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	if cached, ok := encryptedFieldsCache.Load(t); ok {
+		return cached.([]EncryptedField)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []EncryptedField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("bson") == "-" {
+			continue
+		}
+
+		key := bsonKey(field)
+		tag := parseMQTag(field.Tag.Get("mq"))
+		if _, piiOptions := piiTag(field); piiOptions["encrypted"] != nil {
+			tag["encrypted"] = piiOptions["encrypted"]
+		}
+		if encrypted, ok := tag["encrypted"]; ok {
+			bsonType := bsonTypeOf(field.Type)
+			if declared := tag["bsontype"]; len(declared) > 0 {
+				bsonType = declared[0]
+			}
+			fields = append(fields, EncryptedField{
+				Path:      key,
+				BSONType:  bsonType,
+				Queryable: encrypted[0] == "equality",
+			})
+			continue
+		}
+		for _, nested := range collectEncryptedFields(field.Type, visiting) {
+			nested.Path = key + "." + nested.Path
+			fields = append(fields, nested)
+		}
+	}
+	return fields
+}
+
+var binaryType = reflect.TypeOf(primitive.Binary{})
+
+// bsonTypeOf returns the $type alias of the values the default codec stores
+// for t, empty when it depends on the value (int, uint, uint64, interfaces).
+func bsonTypeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, dateTimeType:
+		return "date"
+	case objectIDType:
+		return "objectId"
+	case decimalType:
+		return "decimal"
+	case uuidType, binaryType:
+		return "binData"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int"
+	case reflect.Int64, reflect.Uint32:
+		return "long"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "binData"
+		}
+		return "array"
+	}
+	return ""
+}
+
+// EncryptedFieldsMap returns the encryptedFields document of Model, with
+// null key IDs for CreateEncryptedCollection to create the data keys.
+func EncryptedFieldsMap[Model any]() bson.M {
+	fields := bson.A{}
+	for _, field := range EncryptedFields[Model]() {
+		spec := bson.M{"path": field.Path, "bsonType": field.BSONType, "keyId": nil}
+		if field.Queryable {
+			spec["queries"] = bson.M{"queryType": "equality"}
+		}
+		fields = append(fields, spec)
+	}
+	return bson.M{"fields": fields}
+}
+
+// CreateEncryptedCollection creates the querier's collection with the
+// encryptedFields of Model, creating a data key per field with kmsProvider
+// and masterKey. It returns the encryptedFields holding the key IDs, to pass
+// in the EncryptedFieldsMap of the clients' auto encryption options:
+//
+//	encryptedFields, err := patients.CreateEncryptedCollection(ctx, clientEncryption, "aws", masterKey)
+//	autoEncryption := options.AutoEncryption().
+//		SetKeyVaultNamespace("encryption.__keyVault").
+//		SetKmsProviders(kmsProviders).
+//		SetEncryptedFieldsMap(map[string]interface{}{"clinic.patients": encryptedFields})
+//
+// Reads and writes are then encrypted and decrypted by the driver, which
+// needs to be built with the cse tag and libmongocrypt.
+func (q *Querier[Model, IDModel]) CreateEncryptedCollection(ctx context.Context, clientEncryption *mongo.ClientEncryption, kmsProvider string, masterKey interface{}) (encryptedFields bson.M, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "CreateEncryptedCollection", nil); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "CreateEncryptedCollection", nil)
+	defer q.observe(span, time.Now(), "CreateEncryptedCollection", nil, &err)
+
+	fields := EncryptedFields[Model]()
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s has no encrypted fields", modelType[Model]())
+	}
+	for _, field := range fields {
+		if field.BSONType == "" {
+			return nil, fmt.Errorf("%s: the BSON type of encrypted field %q depends on its value, declare it with `mq:\"bsontype=...\"`", modelType[Model](), field.Path)
+		}
+	}
+
+	opts := options.CreateCollection().SetEncryptedFields(EncryptedFieldsMap[Model]())
+	_, encryptedFields, err = clientEncryption.CreateEncryptedCollection(ctx, q.tenantCollection(ctx).Database(), q.collection.Name(), opts, kmsProvider, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Info(
+		"Created encrypted collection",
+		LogField("collection_name", q.collection.Name()),
+		LogField("encrypted_fields_count", len(fields)),
+	)
+	return encryptedFields, nil
+}
+
+// encryptedOperators are the operators Queryable Encryption supports on
+// equality-queryable fields.
+var encryptedOperators = map[string]bool{"$eq": true, "$ne": true, "$in": true, "$nin": true}
+
+// checkEncryptedFilter rejects filters the server would refuse on Model's
+// encrypted fields, with an error naming the field instead of the server's:
+// conditions on fields that aren't queryable, and operators other than
+// equality and membership on those that are.
+func (q *Querier[Model, IDModel]) checkEncryptedFilter(filter primitive.M) error {
+	fields := EncryptedFields[Model]()
+	if len(fields) == 0 || filter == nil {
+		return nil
+	}
+
+	return checkEncryptedQuery(filter, fields)
+}
+
+func checkEncryptedQuery(query interface{}, fields []EncryptedField) error {
+	document, ok := asDocument(query)
+	if !ok {
+		return nil
+	}
+
+	for _, e := range document {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			clauses, _ := asArray(e.Value)
+			for _, clause := range clauses {
+				if err := checkEncryptedQuery(clause, fields); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		field, ok := encryptedFieldAt(fields, e.Key)
+		if !ok {
+			continue
+		}
+		if field.Path != e.Key {
+			return fmt.Errorf("%w: %q is inside %q", ErrUnsupportedEncryptedFilter, e.Key, field.Path)
+		}
+		if !field.Queryable {
+			return fmt.Errorf("%w: %q isn't queryable", ErrUnsupportedEncryptedFilter, field.Path)
+		}
+		condition, ok := asDocument(e.Value)
+		if !ok || !hasOperator(condition) {
+			continue
+		}
+		for _, c := range condition {
+			if !encryptedOperators[c.Key] {
+				return fmt.Errorf("%w: %s on %q", ErrUnsupportedEncryptedFilter, c.Key, field.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// encryptedFieldAt returns the encrypted field at path, or containing it.
+func encryptedFieldAt(fields []EncryptedField, path string) (EncryptedField, bool) {
+	for _, field := range fields {
+		if path == field.Path || strings.HasPrefix(path, field.Path+".") {
+			return field, true
+		}
+	}
+	return EncryptedField{}, false
+}
@@ -0,0 +1,31 @@
+package mongoquerier
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type encryptedNode struct {
+	SSN    string         `bson:"ssn" mq:"encrypted=equality"`
+	Parent *encryptedNode `bson:"parent"`
+}
+
+type untypedEncrypted struct {
+	Score int `bson:"score" mq:"encrypted"`
+}
+
+func TestEncryptedFieldsRecursiveModel(t *testing.T) {
+	fields := EncryptedFields[encryptedNode]()
+	if len(fields) != 1 || fields[0].Path != "ssn" || fields[0].BSONType != "string" || !fields[0].Queryable {
+		t.Fatalf("EncryptedFields() = %+v, want the queryable ssn string", fields)
+	}
+}
+
+func TestCreateEncryptedCollectionRejectsUntypedFields(t *testing.T) {
+	q := NewQuerier[untypedEncrypted](newTestAdapter(t), "scores")
+	_, err := q.CreateEncryptedCollection(context.Background(), nil, "local", nil)
+	if err == nil || !strings.Contains(err.Error(), "bsontype") {
+		t.Fatalf("CreateEncryptedCollection() error = %v, want the undeclared BSON type of score", err)
+	}
+}
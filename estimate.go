@@ -0,0 +1,286 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hllPrecision gives 2^14 registers, a standard error of about 0.8%.
+const hllPrecision = 14
+
+// DefaultDistinctSampleSize is the number of random documents
+// EstimateDistinct estimates from, unless it scans them all.
+const DefaultDistinctSampleSize = 10000
+
+type EstimateDistinctOptions struct {
+	// FullScan streams the field of every matching document through a
+	// HyperLogLog (within about 1% whatever the distribution) instead of
+	// sampling, reading every matching document.
+	FullScan bool
+}
+
+// hyperLogLog estimates the cardinality of a stream of values in constant
+// memory.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(value bson.RawValue) {
+	hasher := fnv.New64a()
+	valueType, data := hllKey(value)
+	hasher.Write([]byte{byte(valueType)})
+	hasher.Write(data)
+
+	// FNV alone spreads short keys poorly across the high bits
+	x := mix64(hasher.Sum64())
+	index := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, register := range h.registers {
+		sum += 1 / float64(uint64(1)<<register)
+		if register == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// hllKey returns the bytes value is hashed by. Numbers compare by value
+// whatever their type, as Distinct does, so 5, NumberLong(5), 5.0 and
+// NumberDecimal("5") hash alike: integral ones as an int64, others as a
+// double. Decimals beyond a double's precision are rounded to one.
+func hllKey(value bson.RawValue) (bsontype.Type, []byte) {
+	var number float64
+	switch value.Type {
+	case bson.TypeInt32:
+		return hllInt(int64(value.Int32()))
+	case bson.TypeInt64:
+		return hllInt(value.Int64())
+	case bson.TypeDouble:
+		number = value.Double()
+	case bson.TypeDecimal128:
+		parsed, err := strconv.ParseFloat(value.Decimal128().String(), 64)
+		if err != nil {
+			return value.Type, value.Value
+		}
+		number = parsed
+	default:
+		return value.Type, value.Value
+	}
+
+	if number == math.Trunc(number) && number >= math.MinInt64 && number < math.MaxInt64 {
+		return hllInt(int64(number))
+	}
+	return bson.TypeDouble, binary.LittleEndian.AppendUint64(nil, math.Float64bits(number))
+}
+
+func hllInt(number int64) (bsontype.Type, []byte) {
+	return bson.TypeInt64, binary.LittleEndian.AppendUint64(nil, uint64(number))
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (q *Querier[Model, IDModel]) EstimateDistinct(ctx context.Context, fieldName string, filter Model, opts ...*EstimateDistinctOptions) (uint64, error) {
+	filterM, err := q.structToM("EstimateDistinct", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return q.EstimateDistinctByM(ctx, fieldName, filterM, opts...)
+}
+
+// EstimateDistinctByM approximates the number of distinct values of fieldName
+// without Distinct's cost: it estimates from a random sample of
+// DefaultDistinctSampleSize documents, server-side, like
+// EstimateDistinctSampleByM. With EstimateDistinctOptions.FullScan, it
+// streams only that field of every matching document through a HyperLogLog
+// instead, which needs neither the whole value set in memory nor under
+// 16MB. Array values count each element, as Distinct does.
+func (q *Querier[Model, IDModel]) EstimateDistinctByM(ctx context.Context, fieldName string, filter primitive.M, opts ...*EstimateDistinctOptions) (cardinality uint64, err error) {
+	if err = q.preflight(ctx, "EstimateDistinctByM", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "EstimateDistinctByM", filter)
+	defer q.observe(span, time.Now(), "EstimateDistinctByM", filter, &err)
+	if filter == nil {
+		filter = primitive.M{}
+	}
+
+	fullScan := false
+	for _, opt := range opts {
+		fullScan = fullScan || opt.FullScan
+	}
+	if fullScan {
+		cardinality, err = q.scanDistinct(ctx, fieldName, filter)
+	} else {
+		cardinality, err = q.sampleDistinct(ctx, "EstimateDistinctByM", fieldName, filter, DefaultDistinctSampleSize)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Estimated distinct values for field",
+		LogField("collection_name", q.collection.Name()),
+		LogField("field_name", fieldName),
+		q.logValue("filter", filter),
+		LogField("full_scan", fullScan),
+		LogField("estimated_cardinality", cardinality),
+	)
+	return cardinality, nil
+}
+
+// scanDistinct runs the values of fieldName in every document matching
+// filter through a HyperLogLog.
+func (q *Querier[Model, IDModel]) scanDistinct(ctx context.Context, fieldName string, filter primitive.M) (uint64, error) {
+	opts := options.Find().
+		SetProjection(bson.M{fieldName: 1, "_id": 0}).
+		SetBatchSize(10000)
+	cursor, err := retrying(ctx, q, "EstimateDistinctByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "EstimateDistinctByM", []*options.FindOptions{opts})...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var hll hyperLogLog
+	path := strings.Split(fieldName, ".")
+	for cursor.Next(ctx) {
+		value, err := cursor.Current.LookupErr(path...)
+		if err != nil {
+			// Distinct ignores documents missing the field
+			continue
+		}
+
+		if value.Type != bson.TypeArray {
+			hll.add(value)
+			continue
+		}
+		elements, err := value.Array().Values()
+		if err != nil {
+			return 0, err
+		}
+		for _, element := range elements {
+			hll.add(element)
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return 0, err
+	}
+	return hll.estimate(), nil
+}
+
+// EstimateDistinctSampleByM approximates the number of distinct values of
+// fieldName from a random sample of sampleSize documents, extrapolating with
+// the GEE estimator (values seen once in the sample are scaled by
+// sqrt(matching/sampleSize)). It's much faster than EstimateDistinctByM but
+// its error depends on the value distribution. The count is exact when every
+// matching document fits in the sample.
+func (q *Querier[Model, IDModel]) EstimateDistinctSampleByM(ctx context.Context, fieldName string, filter primitive.M, sampleSize int) (cardinality uint64, err error) {
+	if err = q.preflight(ctx, "EstimateDistinctSampleByM", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "EstimateDistinctSampleByM", filter)
+	defer q.observe(span, time.Now(), "EstimateDistinctSampleByM", filter, &err)
+	if filter == nil {
+		filter = primitive.M{}
+	}
+
+	cardinality, err = q.sampleDistinct(ctx, "EstimateDistinctSampleByM", fieldName, filter, sampleSize)
+	if err != nil {
+		return 0, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Estimated distinct values for field from a sample",
+		LogField("collection_name", q.collection.Name()),
+		LogField("field_name", fieldName),
+		q.logValue("filter", filter),
+		LogField("sample_size", sampleSize),
+		LogField("estimated_cardinality", cardinality),
+	)
+	return cardinality, nil
+}
+
+// sampleDistinct estimates the distinct values of fieldName in the documents
+// matching filter from a random sample of sampleSize of them.
+func (q *Querier[Model, IDModel]) sampleDistinct(ctx context.Context, operation string, fieldName string, filter primitive.M, sampleSize int) (uint64, error) {
+	matching, err := retrying(ctx, q, operation, func() (int64, error) {
+		return q.readCollection(ctx).CountDocuments(ctx, filter)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sample", Value: bson.M{"size": sampleSize}}},
+		{{Key: "$unwind", Value: "$" + fieldName}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + fieldName, "occurrences": bson.M{"$sum": 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$occurrences", "values": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := retrying(ctx, q, operation, func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Aggregate(ctx, pipeline, q.aggregateOptions(ctx, operation, nil)...)
+	})
+	if err != nil {
+		return 0, mapPipelineError(pipeline, err)
+	}
+
+	// Frequencies of frequencies: how many values occurred once, twice...
+	var frequencies []struct {
+		Occurrences int64 `bson:"_id"`
+		Values      int64 `bson:"values"`
+	}
+	if err = cursor.All(ctx, &frequencies); err != nil {
+		return 0, mapPipelineError(pipeline, err)
+	}
+
+	scale := 1.0
+	if matching > int64(sampleSize) {
+		scale = math.Sqrt(float64(matching) / float64(sampleSize))
+	}
+	estimate := 0.0
+	for _, frequency := range frequencies {
+		if frequency.Occurrences == 1 {
+			estimate += scale * float64(frequency.Values)
+		} else {
+			estimate += float64(frequency.Values)
+		}
+	}
+
+	return uint64(estimate + 0.5), nil
+}
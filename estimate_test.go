@@ -0,0 +1,65 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHLLKeyNormalizesNumbers(t *testing.T) {
+	five, _ := primitive.ParseDecimal128("5.0")
+	half, _ := primitive.ParseDecimal128("2.5")
+	tests := []struct {
+		name  string
+		a, b  interface{}
+		equal bool
+	}{
+		{"int32 and int64", int32(5), int64(5), true},
+		{"int32 and double", int32(5), 5.0, true},
+		{"int64 and decimal", int64(5), five, true},
+		{"double and decimal", 2.5, half, true},
+		{"negative zero", 0.0, math.Copysign(0, -1), true},
+		{"different numbers", int32(5), 5.5, false},
+		{"number and string", int32(5), "5", false},
+		{"strings", "a", "a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aType, aData := hllKey(rawValue(t, tt.a))
+			bType, bData := hllKey(rawValue(t, tt.b))
+			if equal := aType == bType && bytes.Equal(aData, bData); equal != tt.equal {
+				t.Errorf("hllKey(%v) == hllKey(%v) is %v, want %v", tt.a, tt.b, equal, tt.equal)
+			}
+		})
+	}
+}
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{"empty", 0},
+		{"small", 100},
+		{"linear counting range", 10_000},
+		{"large", 200_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hll hyperLogLog
+			for i := 0; i < tt.distinct; i++ {
+				// Every value is added once per numeric type
+				hll.add(rawValue(t, int32(i)))
+				hll.add(rawValue(t, int64(i)))
+				hll.add(rawValue(t, float64(i)))
+			}
+
+			got := float64(hll.estimate())
+			if tolerance := 0.03*float64(tt.distinct) + 1; math.Abs(got-float64(tt.distinct)) > tolerance {
+				t.Errorf("estimate() = %v, want %d ± %v", got, tt.distinct, tolerance)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrUnexpectedCount = errors.New("unexpected number of affected documents")
+
+// CountMismatchError reports a mutation whose matched or modified count
+// didn't meet the expectation set on its context. The write itself has
+// already been applied; run it inside a transaction to roll it back.
+type CountMismatchError struct {
+	Operation   string
+	Expectation string
+	Expected    int64
+	Actual      int64
+}
+
+func (e *CountMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s expected %s %d, got %d", ErrUnexpectedCount, e.Operation, e.Expectation, e.Expected, e.Actual)
+}
+
+func (e *CountMismatchError) Is(target error) bool {
+	return target == ErrUnexpectedCount
+}
+
+type countExpectation struct {
+	matched         *int64
+	modifiedAtLeast *int64
+}
+
+type countExpectationKey struct{}
+
+// ExpectMatched makes update and delete operations issued with the returned
+// context fail with a CountMismatchError unless exactly n documents matched.
+func ExpectMatched(ctx context.Context, n int64) context.Context {
+	expectation := expectationFrom(ctx)
+	expectation.matched = &n
+	return context.WithValue(ctx, countExpectationKey{}, expectation)
+}
+
+// ExpectModifiedAtLeast makes update and delete operations issued with the
+// returned context fail with a CountMismatchError when fewer than n
+// documents were modified.
+func ExpectModifiedAtLeast(ctx context.Context, n int64) context.Context {
+	expectation := expectationFrom(ctx)
+	expectation.modifiedAtLeast = &n
+	return context.WithValue(ctx, countExpectationKey{}, expectation)
+}
+
+func expectationFrom(ctx context.Context) countExpectation {
+	expectation, _ := ctx.Value(countExpectationKey{}).(countExpectation)
+	return expectation
+}
+
+func checkCounts(ctx context.Context, operation string, matched int64, modified int64) error {
+	expectation := expectationFrom(ctx)
+
+	if expectation.matched != nil && matched != *expectation.matched {
+		return &CountMismatchError{
+			Operation:   operation,
+			Expectation: "matched",
+			Expected:    *expectation.matched,
+			Actual:      matched,
+		}
+	}
+
+	if expectation.modifiedAtLeast != nil && modified < *expectation.modifiedAtLeast {
+		return &CountMismatchError{
+			Operation:   operation,
+			Expectation: "modified at least",
+			Expected:    *expectation.modifiedAtLeast,
+			Actual:      modified,
+		}
+	}
+	return nil
+}
+
+// expectSingle applies the expectations to find-and-modify operations, which
+// touch at most one document, given the error they returned.
+func expectSingle(ctx context.Context, operation string, err error) error {
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	var count int64
+	if err == nil {
+		count = 1
+	}
+	if countErr := checkCounts(ctx, operation, count, count); countErr != nil {
+		return countErr
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package mongoquerier
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RowEncoder writes documents to a stream one at a time. Close flushes
+// whatever it buffered; it doesn't close the underlying writer.
+type RowEncoder interface {
+	Encode(document bson.Raw) error
+	Close() error
+}
+
+// ExportFormat starts a RowEncoder writing to w. NDJSON and CSV are built
+// in; other formats (Parquet, Avro...) plug in by implementing RowEncoder.
+type ExportFormat func(w io.Writer) (RowEncoder, error)
+
+type ndjsonEncoder struct {
+	w         *bufio.Writer
+	canonical bool
+}
+
+// NDJSON writes one relaxed extended JSON document per line.
+func NDJSON() ExportFormat {
+	return func(w io.Writer) (RowEncoder, error) {
+		return &ndjsonEncoder{w: bufio.NewWriter(w)}, nil
+	}
+}
+
+// CanonicalNDJSON writes one canonical extended JSON document per line,
+// keeping every BSON type (int64, decimal...) for a lossless restore.
+func CanonicalNDJSON() ExportFormat {
+	return func(w io.Writer) (RowEncoder, error) {
+		return &ndjsonEncoder{w: bufio.NewWriter(w), canonical: true}, nil
+	}
+}
+
+func (e *ndjsonEncoder) Encode(document bson.Raw) error {
+	line, err := bson.MarshalExtJSON(document, e.canonical, false)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(line); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return e.w.Flush()
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+	row     []string
+}
+
+// CSV writes a header of columns, then one row per document with the values
+// at those (dotted) paths: missing fields and nulls are empty, dates
+// RFC 3339, and embedded documents and arrays extended JSON.
+func CSV(columns ...string) ExportFormat {
+	return func(w io.Writer) (RowEncoder, error) {
+		e := &csvEncoder{w: csv.NewWriter(w), columns: columns, row: make([]string, len(columns))}
+		if err := e.w.Write(columns); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+}
+
+func (e *csvEncoder) Encode(document bson.Raw) error {
+	for i, column := range e.columns {
+		value, err := document.LookupErr(strings.Split(column, ".")...)
+		if err != nil {
+			e.row[i] = ""
+			continue
+		}
+		e.row[i] = csvValue(value)
+	}
+	return e.w.Write(e.row)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func csvValue(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return ""
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.DateTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.Decimal128:
+		return value.Decimal128().String()
+	case bsontype.EmbeddedDocument:
+		data, err := bson.MarshalExtJSON(value.Document(), false, false)
+		if err == nil {
+			return string(data)
+		}
+	case bsontype.Array:
+		data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+		if err == nil {
+			// Unwrap {"v":[...]}
+			return string(data[len(`{"v":`) : len(data)-1])
+		}
+	}
+	return value.String()
+}
+
+// AggregateToWriter runs pipeline and streams its results to w in format,
+// one document at a time, for extracts too large to hold in memory:
+//
+//	count, err := querier.AggregateToWriter(ctx, pipeline, file, mongoquerier.CSV("_id", "total", "customer.email"))
+//
+// Results are written as the server returns them, without decoding into
+// Model. It returns the number of documents written.
+func (q *Querier[Model, IDModel]) AggregateToWriter(ctx context.Context, pipeline mongo.Pipeline, w io.Writer, format ExportFormat, opts ...*options.AggregateOptions) (count int64, err error) {
+	filter, err := q.preflightPipeline(ctx, "AggregateToWriter", pipeline)
+	if err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "AggregateToWriter", filter)
+	defer q.observe(span, time.Now(), "AggregateToWriter", filter, &err)
+
+	encoder, err := format(w)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := retrying(ctx, q, "AggregateToWriter", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Aggregate(ctx, pipeline, q.aggregateOptions(ctx, "AggregateToWriter", opts)...)
+	})
+	if err != nil {
+		return 0, mapPipelineError(pipeline, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err = encoder.Encode(cursor.Current); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err = cursor.Err(); err != nil {
+		return count, mapPipelineError(pipeline, err)
+	}
+	if err = encoder.Close(); err != nil {
+		return count, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Aggregated documents to a writer",
+		LogField("collection_name", q.collection.Name()),
+		LogField("stages_count", len(pipeline)),
+		LogField("documents_count", count),
+	)
+	return count, nil
+}
@@ -0,0 +1,96 @@
+package mongoquerier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Override customizes a document built by a Factory.
+type Override[Model any] func(document *Model)
+
+// Factory builds valid documents from defaults, for seeding and tests:
+//
+//	users := mongoquerier.NewFactory(func(seq int64) User {
+//		return User{Email: fmt.Sprintf("user%d@example.com", seq), Active: true}
+//	})
+//	users.Trait("admin", func(u *User) { u.Role = "admin" })
+//
+//	admin := users.Build(users.With("admin"), func(u *User) { u.Name = "Ada" })
+//	ids, err := mongoquerier.Seed(ctx, users, querier, 50)
+//
+// The defaults function gets a sequence number, starting at 1 and shared by
+// every document the factory builds, to keep unique fields unique.
+type Factory[Model any] struct {
+	defaults func(seq int64) Model
+	sequence atomic.Int64
+
+	mu     sync.RWMutex
+	traits map[string]Override[Model]
+}
+
+func NewFactory[Model any](defaults func(seq int64) Model) *Factory[Model] {
+	return &Factory[Model]{defaults: defaults}
+}
+
+// Trait names a set of overrides, applied with With.
+func (f *Factory[Model]) Trait(name string, overrides ...Override[Model]) *Factory[Model] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.traits == nil {
+		f.traits = make(map[string]Override[Model])
+	}
+	f.traits[name] = func(document *Model) {
+		for _, override := range overrides {
+			override(document)
+		}
+	}
+	return f
+}
+
+// With returns the override applying the named trait. It panics on an
+// unknown trait, which is a bug in the calling code.
+func (f *Factory[Model]) With(name string) Override[Model] {
+	f.mu.RLock()
+	trait, ok := f.traits[name]
+	f.mu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("mongoquerier: factory has no trait %q", name))
+	}
+	return trait
+}
+
+// Build returns a document made of the defaults and overrides, applied in
+// order.
+func (f *Factory[Model]) Build(overrides ...Override[Model]) Model {
+	document := f.defaults(f.sequence.Add(1))
+	for _, override := range overrides {
+		override(&document)
+	}
+	return document
+}
+
+func (f *Factory[Model]) BuildMany(n int, overrides ...Override[Model]) []Model {
+	documents := make([]Model, 0, n)
+	for i := 0; i < n; i++ {
+		documents = append(documents, f.Build(overrides...))
+	}
+	return documents
+}
+
+// Create builds a document and inserts it with q.
+func Create[Model any, IDModel any](ctx context.Context, f *Factory[Model], q *Querier[Model, IDModel], overrides ...Override[Model]) (Model, IDModel, error) {
+	document := f.Build(overrides...)
+	insertedID, err := q.InsertOne(ctx, document)
+	return document, insertedID, err
+}
+
+// Seed builds n documents and inserts them with q in one InsertMany.
+func Seed[Model any, IDModel any](ctx context.Context, f *Factory[Model], q *Querier[Model, IDModel], n int, overrides ...Override[Model]) ([]IDModel, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	return q.InsertMany(ctx, f.BuildMany(n, overrides...))
+}
@@ -0,0 +1,117 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultForEachBatchSize = 500
+
+// IterationCheckpoint records how far a ForEach got. Persist it (e.g. with a
+// CheckpointStore) to resume an interrupted iteration with ResumeForEachByM.
+type IterationCheckpoint[IDModel any] struct {
+	// LastID is the _id of the last document fn succeeded on; it's only
+	// meaningful when Started is true.
+	LastID    IDModel
+	Started   bool
+	Processed int64
+	Done      bool
+}
+
+func (q *Querier[Model, IDModel]) ForEach(ctx context.Context, filter Model, batchSize int, fn func(ctx context.Context, document *Model) error) (IterationCheckpoint[IDModel], error) {
+	filterM, err := q.structToM("ForEach", filter)
+	if err != nil {
+		return IterationCheckpoint[IDModel]{}, err
+	}
+
+	return q.ForEachByM(ctx, filterM, batchSize, fn)
+}
+
+// ForEachByM calls fn for every document matching filter in _id order. Each
+// batch is a fresh query for the documents after the last _id seen, fetched
+// whole before fn runs, so slow callbacks never outlive a server cursor and
+// the order is deterministic even while documents are inserted. It stops at
+// the first error, returning the checkpoint to resume from.
+func (q *Querier[Model, IDModel]) ForEachByM(ctx context.Context, filter primitive.M, batchSize int, fn func(ctx context.Context, document *Model) error) (IterationCheckpoint[IDModel], error) {
+	return q.ResumeForEachByM(ctx, filter, IterationCheckpoint[IDModel]{}, batchSize, fn)
+}
+
+// ResumeForEachByM continues a ForEachByM from checkpoint.
+func (q *Querier[Model, IDModel]) ResumeForEachByM(ctx context.Context, filter primitive.M, checkpoint IterationCheckpoint[IDModel], batchSize int, fn func(ctx context.Context, document *Model) error) (resumed IterationCheckpoint[IDModel], err error) {
+	if err = q.preflight(ctx, "ForEachByM", filter); err != nil {
+		return checkpoint, err
+	}
+	ctx, span := q.startOperation(ctx, "ForEachByM", filter)
+	defer q.observe(span, time.Now(), "ForEachByM", filter, &err)
+	if filter == nil {
+		filter = primitive.M{}
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultForEachBatchSize
+	}
+
+	for !checkpoint.Done {
+		batchFilter := filter
+		if checkpoint.Started {
+			batchFilter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": checkpoint.LastID}}}}
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(int64(batchSize)).
+			SetBatchSize(int32(batchSize))
+		cursor, err := retrying(ctx, q, "ForEachByM", func() (*mongo.Cursor, error) {
+			return q.readCollection(ctx).Find(ctx, batchFilter, q.findOptions(ctx, "ForEachByM", []*options.FindOptions{opts})...)
+		})
+		if err != nil {
+			return checkpoint, err
+		}
+		var batch []bson.Raw
+		if err = cursor.All(ctx, &batch); err != nil {
+			return checkpoint, err
+		}
+
+		for _, raw := range batch {
+			if err = ctx.Err(); err != nil {
+				return checkpoint, err
+			}
+
+			var lastID IDModel
+			if err = raw.Lookup("_id").Unmarshal(&lastID); err != nil {
+				return checkpoint, err
+			}
+
+			document, err := q.decode(ctx, raw)
+			if skipUndecodable(ctx, err) {
+				checkpoint.LastID = lastID
+				checkpoint.Started = true
+				continue
+			}
+			if err != nil {
+				return checkpoint, err
+			}
+			if err = fn(ctx, document); err != nil {
+				return checkpoint, err
+			}
+
+			checkpoint.LastID = lastID
+			checkpoint.Started = true
+			checkpoint.Processed++
+		}
+
+		checkpoint.Done = len(batch) < batchSize
+		q.MongoAdapter.Debug(
+			"Iterated a batch of documents",
+			LogField("collection_name", q.collection.Name()),
+			LogField("documents_count", len(batch)),
+			LogField("documents_processed", checkpoint.Processed),
+		)
+	}
+
+	return checkpoint, nil
+}
@@ -0,0 +1,122 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxBSONDocumentSize is the server's hard limit for a single document.
+const MaxBSONDocumentSize = 16 * 1024 * 1024
+
+var ErrDocumentTooLarge = errors.New("document exceeds size limit")
+
+// SizeGuard checks the encoded size of documents and updates before they're
+// sent to the server.
+type SizeGuard struct {
+	// WarnBytes logs a warning for writes above this size (0 disables).
+	WarnBytes int
+	// MaxBytes rejects writes above this size with ErrDocumentTooLarge
+	// (0 disables).
+	MaxBytes int
+	// ArrayCaps bounds arrays grown through Push, keeping only the most
+	// recent N elements with $slice.
+	ArrayCaps map[string]int
+}
+
+func (q *Querier[Model, IDModel]) checkSize(operation string, document interface{}) error {
+	if q.SizeGuard == nil || (q.SizeGuard.WarnBytes <= 0 && q.SizeGuard.MaxBytes <= 0) {
+		return nil
+	}
+
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	size := len(data)
+
+	if q.SizeGuard.MaxBytes > 0 && size > q.SizeGuard.MaxBytes {
+		q.MongoAdapter.Error(
+			"Rejected oversized write",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("size_bytes", size),
+			LogField("max_bytes", q.SizeGuard.MaxBytes),
+		)
+		return fmt.Errorf("%w: %s of %d bytes (limit %d)", ErrDocumentTooLarge, operation, size, q.SizeGuard.MaxBytes)
+	}
+
+	if q.SizeGuard.WarnBytes > 0 && size > q.SizeGuard.WarnBytes {
+		q.MongoAdapter.Warn(
+			"Large write",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("size_bytes", size),
+			LogField("warn_bytes", q.SizeGuard.WarnBytes),
+		)
+	}
+	return nil
+}
+
+// CappedPush builds a $push of values onto field that keeps only the last
+// maxLength elements. A non-positive maxLength pushes without a cap.
+func CappedPush(field string, maxLength int, values ...interface{}) bson.M {
+	push := bson.M{"$each": values}
+	if maxLength > 0 {
+		push["$slice"] = -maxLength
+	}
+	return bson.M{"$push": bson.M{field: push}}
+}
+
+// PushByM appends values to an array field of the documents matching filter,
+// capped according to SizeGuard.ArrayCaps.
+func (q *Querier[Model, IDModel]) PushByM(ctx context.Context, filter primitive.M, field string, values []interface{}, opts ...*options.UpdateOptions) (modified int64, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "PushByM", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "PushByM", filter)
+	defer q.observe(span, time.Now(), "PushByM", filter, &err)
+
+	maxLength := 0
+	if q.SizeGuard != nil {
+		maxLength = q.SizeGuard.ArrayCaps[field]
+	}
+	updateM := CappedPush(field, maxLength, values...)
+
+	if err := q.checkSize("PushByM", updateM); err != nil {
+		return 0, err
+	}
+
+	// Perform the push operation on documents based on the filter.
+	result, err := retrying(ctx, q, "PushByM", func() (*mongo.UpdateResult, error) {
+		return q.writeCollection(ctx).UpdateMany(ctx, filter, updateM, opts...)
+	})
+	if err != nil {
+		q.logWriteFailure(ctx, "PushByM", err)
+		return 0, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Pushed values by filter (primitive.M)",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("field", field),
+		LogField("max_length", maxLength),
+		LogField("documents_modified", result.ModifiedCount),
+	)
+
+	if err = checkCounts(ctx, "PushByM", result.MatchedCount, result.ModifiedCount); err != nil {
+		return result.ModifiedCount, err
+	}
+
+	return result.ModifiedCount, nil
+}
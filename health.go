@@ -0,0 +1,115 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// DefaultHealthTimeout bounds a health check, unless its context has an
+// earlier deadline.
+const DefaultHealthTimeout = 2 * time.Second
+
+// Topologies reported by Health.
+const (
+	TopologyStandalone = "standalone"
+	TopologyReplicaSet = "replicaSet"
+	TopologySharded    = "sharded"
+)
+
+// HealthReport is the outcome of a health check. Healthy reports that a
+// server could be selected and answered a ping, PrimaryAvailable that writes
+// can be served too.
+type HealthReport struct {
+	Healthy          bool          `json:"healthy"`
+	PrimaryAvailable bool          `json:"primary_available"`
+	Error            string        `json:"error,omitempty"`
+	Latency          time.Duration `json:"latency"`
+	Topology         string        `json:"topology,omitempty"`
+	ReplicaSet       string        `json:"replica_set,omitempty"`
+	Primary          string        `json:"primary,omitempty"`
+	// SessionsInProgress are the client's sessions not ended yet.
+	SessionsInProgress int `json:"sessions_in_progress"`
+	// Pools are the connection pools per server, reported when the adapter
+	// has Metrics.
+	Pools     []PoolStats `json:"pools,omitempty"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// Health checks the adapter's connection within DefaultHealthTimeout: it
+// pings the nearest server, which also checks server selection, then asks it
+// for the topology and pings the primary.
+func (madp *MongoAdapter) Health(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthTimeout)
+	defer cancel()
+
+	report := HealthReport{
+		SessionsInProgress: madp.Client.NumberSessionsInProgress(),
+		CheckedAt:          time.Now(),
+	}
+	if madp.Metrics != nil {
+		report.Pools = madp.Metrics.PoolStats()
+	}
+
+	admin := madp.Client.Database("admin")
+	start := time.Now()
+	if err := madp.Client.Ping(ctx, readpref.Nearest()); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Latency = time.Since(start)
+	report.Healthy = true
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+		Primary string `bson:"primary"`
+	}
+	helloOptions := options.RunCmd().SetReadPreference(readpref.Nearest())
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}, helloOptions).Decode(&hello); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	switch {
+	case hello.Msg == "isdbgrid":
+		report.Topology = TopologySharded
+	case hello.SetName != "":
+		report.Topology = TopologyReplicaSet
+		report.ReplicaSet = hello.SetName
+		report.Primary = hello.Primary
+	default:
+		report.Topology = TopologyStandalone
+	}
+
+	if err := madp.Client.Ping(ctx, readpref.Primary()); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.PrimaryAvailable = true
+	return report
+}
+
+// HealthHandler serves the adapter's HealthReport as JSON, with status 503
+// when the check fails, for liveness probes, or readiness probes of services
+// that write with requirePrimary:
+//
+//	http.Handle("/healthz", mongoAdapter.HealthHandler(false))
+//	http.Handle("/readyz", mongoAdapter.HealthHandler(true))
+func (madp *MongoAdapter) HealthHandler(requirePrimary bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := madp.Health(r.Context())
+
+		status := http.StatusOK
+		if !report.Healthy || (requirePrimary && !report.PrimaryAvailable) {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
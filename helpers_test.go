@@ -0,0 +1,20 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestAdapter returns an adapter on a client that is never connected,
+// for tests that don't reach a server.
+func newTestAdapter(t *testing.T) *MongoAdapter {
+	t.Helper()
+
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMongoAdapterFromClient(NopLogger(), client, "test")
+}
@@ -0,0 +1,478 @@
+package mongoquerier
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultImportBatchSize = 500
+	// DefaultImportMaxErrors bounds the errors an ImportReport keeps.
+	DefaultImportMaxErrors = 100
+)
+
+var ErrInvalidRecord = errors.New("invalid import record")
+
+// ImportSource reads the records of an import one at a time, returning
+// io.EOF after the last one.
+type ImportSource interface {
+	Next() (map[string]interface{}, error)
+}
+
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+}
+
+// NDJSONSource reads one extended JSON document per line; blank lines are
+// skipped.
+func NDJSONSource(r io.Reader) ImportSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	return &ndjsonSource{scanner: scanner}
+}
+
+func (s *ndjsonSource) Next() (map[string]interface{}, error) {
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var record bson.M
+		if err := bson.UnmarshalExtJSON(line, false, &record); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+		}
+		return record, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type csvSource struct {
+	reader *csv.Reader
+	header []string
+}
+
+// CSVSource reads rows keyed by the header row. Values are strings, parsed
+// into the type of the Model field they're mapped to.
+func CSVSource(r io.Reader) ImportSource {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	return &csvSource{reader: reader}
+}
+
+func (s *csvSource) Next() (map[string]interface{}, error) {
+	if s.header == nil {
+		header, err := s.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		s.header = append([]string(nil), header...)
+	}
+
+	row, err := s.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+		}
+		return nil, err
+	}
+	record := make(map[string]interface{}, len(row))
+	for i, value := range row {
+		if i < len(s.header) {
+			record[s.header[i]] = value
+		}
+	}
+	return record, nil
+}
+
+// ImportOptions configures Querier.Import.
+type ImportOptions[Model any] struct {
+	// Columns maps source columns to (dotted) stored keys; without it,
+	// columns are stored keys. Unmapped columns are dropped when Columns is
+	// set.
+	Columns map[string]string
+	// Transforms run in order on every decoded record, then Validate.
+	Transforms []func(ctx context.Context, document *Model) error
+	Validate   func(document *Model) error
+
+	// KeyFields make commits idempotent: records replace (or insert) the
+	// document with the same key fields. Without them, records are inserted
+	// and duplicate key errors ignored, which is idempotent for records
+	// carrying their _id.
+	KeyFields []string
+	BatchSize int
+
+	// DryRun reads, maps and validates every record without writing.
+	DryRun bool
+
+	// Checkpoints, when set, records after every committed batch how many
+	// records of Job were read, and resumes Job past them.
+	Checkpoints *CheckpointStore
+	Job         string
+
+	MaxErrors int
+}
+
+type ImportError struct {
+	// Record is the position of the record in the source, from 1.
+	Record int64
+	Err    error
+}
+
+type ImportReport struct {
+	DryRun   bool
+	Read     int64
+	Skipped  int64
+	Invalid  int64
+	Inserted int64
+	Replaced int64
+	// Errors are the first MaxErrors invalid records.
+	Errors []ImportError
+}
+
+// importCheckpoint is the token an import saves to its CheckpointStore.
+type importCheckpoint struct {
+	Records int64 `bson:"records"`
+}
+
+// Import reads records from source, maps them to Model, transforms and
+// validates them, and commits the valid ones in batches:
+//
+//	report, err := customers.Import(ctx, mongoquerier.CSVSource(file), mongoquerier.ImportOptions[Customer]{
+//		Columns:   map[string]string{"E-mail": "email", "City": "address.city"},
+//		Validate:  validateCustomer,
+//		KeyFields: []string{"email"},
+//		DryRun:    true,
+//	})
+//
+// Invalid records are reported and skipped, not fatal; read and write
+// errors stop the import, which resumes from its last committed batch when
+// Checkpoints is set.
+func (q *Querier[Model, IDModel]) Import(ctx context.Context, source ImportSource, opts ImportOptions[Model]) (report ImportReport, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "Import", nil); err != nil {
+		return report, err
+	}
+	ctx, span := q.startOperation(ctx, "Import", nil)
+	defer q.observe(span, time.Now(), "Import", nil, &err)
+
+	report.DryRun = opts.DryRun
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	maxErrors := opts.MaxErrors
+	if maxErrors <= 0 {
+		maxErrors = DefaultImportMaxErrors
+	}
+
+	var resume importCheckpoint
+	if opts.Checkpoints != nil && !opts.DryRun {
+		err = opts.Checkpoints.LoadCheckpoint(ctx, opts.Job, &resume)
+		if err != nil && !errors.Is(err, ErrCheckpointNotFound) {
+			return report, err
+		}
+	}
+
+	invalid := func(err error) {
+		report.Invalid++
+		if len(report.Errors) < maxErrors {
+			report.Errors = append(report.Errors, ImportError{Record: report.Read, Err: err})
+		}
+	}
+
+	batch := make([]*Model, 0, batchSize)
+	commit := func() error {
+		if len(batch) > 0 && !opts.DryRun {
+			if err := q.commitImport(ctx, batch, opts.KeyFields, &report); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		if opts.Checkpoints != nil && !opts.DryRun {
+			return opts.Checkpoints.SaveCheckpoint(ctx, opts.Job, importCheckpoint{Records: report.Read})
+		}
+		return nil
+	}
+
+	for {
+		record, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		report.Read++
+		if err != nil && !errors.Is(err, ErrInvalidRecord) {
+			return report, err
+		}
+		if report.Read <= resume.Records {
+			report.Skipped++
+			continue
+		}
+		if err != nil {
+			invalid(err)
+			continue
+		}
+
+		document, err := mapRecord[Model](record, opts.Columns)
+		if err == nil {
+			for _, transform := range opts.Transforms {
+				if err = transform(ctx, document); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && opts.Validate != nil {
+			err = opts.Validate(document)
+		}
+		if err == nil && len(opts.KeyFields) > 0 {
+			_, err = importKey(document, opts.KeyFields)
+		}
+		if err != nil {
+			invalid(err)
+			continue
+		}
+
+		batch = append(batch, document)
+		if len(batch) == batchSize {
+			if err := commit(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := commit(); err != nil {
+		return report, err
+	}
+
+	q.MongoAdapter.Info(
+		"Imported records",
+		LogField("collection_name", q.collection.Name()),
+		LogField("dry_run", report.DryRun),
+		LogField("records_read", report.Read),
+		LogField("records_skipped", report.Skipped),
+		LogField("records_invalid", report.Invalid),
+		LogField("documents_inserted", report.Inserted),
+		LogField("documents_replaced", report.Replaced),
+	)
+	return report, nil
+}
+
+func (q *Querier[Model, IDModel]) commitImport(ctx context.Context, batch []*Model, keyFields []string, report *ImportReport) error {
+	if len(keyFields) == 0 {
+		documents := make([]interface{}, 0, len(batch))
+		for _, document := range batch {
+			prepared, err := q.prepareDocument(*document)
+			if err != nil {
+				return err
+			}
+			documents = append(documents, prepared)
+		}
+
+		res, err := retrying(ctx, q, "Import", func() (*mongo.InsertManyResult, error) {
+			return q.writeCollection(ctx).InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+		})
+		if res != nil {
+			report.Inserted += int64(len(res.InsertedIDs))
+			q.trackInserted(ctx, res.InsertedIDs...)
+		}
+		if err != nil && !onlyDuplicateKeys(err) {
+			return err
+		}
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(batch))
+	for _, document := range batch {
+		filter, err := importKey(document, keyFields)
+		if err != nil {
+			return err
+		}
+		replacement, err := q.prepareReplacement(*document)
+		if err != nil {
+			return err
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(replacement).SetUpsert(true))
+	}
+
+	res, err := retrying(ctx, q, "Import", func() (*mongo.BulkWriteResult, error) {
+		return q.writeCollection(ctx).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	})
+	if res != nil {
+		report.Inserted += res.UpsertedCount
+		report.Replaced += res.MatchedCount
+		for _, id := range res.UpsertedIDs {
+			q.trackInserted(ctx, id)
+		}
+	}
+	return err
+}
+
+// importKey returns the filter matching document's key fields, which must
+// all be set.
+func importKey[Model any](document *Model, keyFields []string) (bson.M, error) {
+	fields, err := StructToM(*document)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{}
+	for _, field := range keyFields {
+		value, ok := fields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: key field %s is empty", ErrInvalidRecord, field)
+		}
+		filter[field] = value
+	}
+	return filter, nil
+}
+
+// onlyDuplicateKeys reports whether every write error of a bulk write is a
+// duplicate key.
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+// mapRecord builds a Model from record, renaming its columns and parsing
+// string values into their field's type.
+func mapRecord[Model any](record map[string]interface{}, columns map[string]string) (*Model, error) {
+	t := modelType[Model]()
+	document := bson.M{}
+	for column, value := range record {
+		key := column
+		if columns != nil {
+			mapped, ok := columns[column]
+			if !ok {
+				continue
+			}
+			key = mapped
+		}
+
+		path := strings.Split(key, ".")
+		if s, ok := value.(string); ok {
+			if fieldType, ok := fieldTypeAt(t, path); ok {
+				parsed, err := parseImportValue(s, fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("%w: column %s: %v", ErrInvalidRecord, column, err)
+				}
+				value = parsed
+			}
+		}
+		setPath(document, path, value)
+	}
+
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	model := new(Model)
+	if err := bson.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	return model, nil
+}
+
+func setPath(document bson.M, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		nested, ok := document[key].(bson.M)
+		if !ok {
+			nested = bson.M{}
+			document[key] = nested
+		}
+		document = nested
+	}
+	document[path[len(path)-1]] = value
+}
+
+// fieldTypeAt returns the type of the field stored under path in t.
+func fieldTypeAt(t reflect.Type, path []string) (reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(path) == 0 {
+		return t, true
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for _, meta := range structLayoutOf(t) {
+		fieldType := t.Field(meta.index).Type
+		if meta.inline {
+			if found, ok := fieldTypeAt(fieldType, path); ok {
+				return found, true
+			}
+			continue
+		}
+		if meta.key == path[0] {
+			return fieldTypeAt(fieldType, path[1:])
+		}
+	}
+	return nil, false
+}
+
+// parseImportValue parses s as a value of type t. Empty strings are null
+// for every type but strings.
+func parseImportValue(s string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return s, nil
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	switch t {
+	case timeType:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, fmt.Errorf("%q isn't an RFC 3339 date", s)
+	case objectIDType:
+		return primitive.ObjectIDFromHex(s)
+	case uuidType:
+		return ParseUUID(s)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(s, 10, 64)
+		return int64(parsed), err
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	}
+	return nil, fmt.Errorf("can't parse %q into %s", s, t)
+}
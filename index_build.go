@@ -0,0 +1,279 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultIndexBuildPollInterval = 5 * time.Second
+
+var ErrIndexBuildCanceled = errors.New("index build canceled")
+
+type IndexBuildProgress struct {
+	Index string
+	// Phase is the server's description of the current step, e.g.
+	// "Index Build: scanning collection".
+	Phase   string
+	Done    int64
+	Total   int64
+	Percent float64
+}
+
+type IndexBuildOptions struct {
+	OnProgress   func(progress IndexBuildProgress)
+	PollInterval time.Duration
+}
+
+// IndexBuild is an index build running on the server.
+type IndexBuild struct {
+	Name string
+
+	cancel func()
+	done   chan struct{}
+	err    error
+}
+
+// Wait blocks until the build finishes and returns its outcome.
+func (b *IndexBuild) Wait() error {
+	<-b.done
+	return b.err
+}
+
+// Done is closed once the build finished.
+func (b *IndexBuild) Done() <-chan struct{} {
+	return b.done
+}
+
+// Cancel aborts the build by dropping the index being built (MongoDB 4.4+).
+// An index that existed before CreateIndexAsync, or whose build already
+// finished, is kept.
+func (b *IndexBuild) Cancel() {
+	b.cancel()
+}
+
+// CreateIndexAsync starts building model in the background and returns at
+// once. While the build runs, currentOp is polled and progress reported to
+// OnProgress. Canceling ctx (or calling Cancel) aborts the build on the
+// server, not just the wait for it. The build holds Shutdown until it's
+// done.
+func (q *Querier[Model, IDModel]) CreateIndexAsync(ctx context.Context, model mongo.IndexModel, opts ...*IndexBuildOptions) (build *IndexBuild, err error) {
+	if err = q.preflight(ctx, "CreateIndexAsync", nil); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "CreateIndexAsync", nil)
+	defer q.observe(span, time.Now(), "CreateIndexAsync", nil, &err)
+
+	name, err := indexName(model)
+	if err != nil {
+		return nil, err
+	}
+	// Name the index explicitly so currentOp and aborts find it
+	if model.Options == nil {
+		model.Options = options.Index()
+	}
+	model.Options.SetName(name)
+	pollInterval := DefaultIndexBuildPollInterval
+	var onProgress func(IndexBuildProgress)
+	for _, opt := range opts {
+		if opt.PollInterval > 0 {
+			pollInterval = opt.PollInterval
+		}
+		if opt.OnProgress != nil {
+			onProgress = opt.OnProgress
+		}
+	}
+
+	collection := q.tenantCollection(ctx)
+	// Creating an existing index succeeds without building anything, and
+	// canceling must not drop it then
+	specs, err := retrying(ctx, q, "CreateIndexAsync", func() ([]*mongo.IndexSpecification, error) {
+		return collection.Indexes().ListSpecifications(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	existed := false
+	for _, spec := range specs {
+		if spec.Name == name {
+			existed = true
+		}
+	}
+
+	buildCtx, cancel := context.WithCancel(ctx)
+	build = &IndexBuild{Name: name, cancel: cancel, done: make(chan struct{})}
+	untrack := q.MongoAdapter.trackCursor()
+
+	created := make(chan error, 1)
+	go func() {
+		// The build must outlive buildCtx, which only signals cancellation
+		_, err := collection.Indexes().CreateOne(detachedContext{ctx}, model)
+		created <- err
+	}()
+
+	go func() {
+		defer untrack()
+		defer close(build.done)
+		defer cancel()
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case err := <-created:
+				build.err = err
+				q.logIndexBuild(name, err)
+				return
+			case <-buildCtx.Done():
+				// The build may have finished as the cancel came in
+				select {
+				case err := <-created:
+					build.err = err
+					q.logIndexBuild(name, err)
+					return
+				default:
+				}
+				if !existed {
+					q.abortIndexBuild(collection, name)
+				}
+				build.err = fmt.Errorf("%w: %s: %v", ErrIndexBuildCanceled, name, buildCtx.Err())
+				// Wait for the server to acknowledge the abort
+				<-created
+				return
+			case <-ticker.C:
+				if onProgress == nil {
+					continue
+				}
+				if progress, ok := q.indexBuildProgress(buildCtx, name); ok {
+					onProgress(progress)
+				}
+			}
+		}
+	}()
+
+	q.MongoAdapter.Info(
+		"Started index build",
+		LogField("collection_name", q.collection.Name()),
+		LogField("index_name", name),
+	)
+	return build, nil
+}
+
+func (q *Querier[Model, IDModel]) indexBuildProgress(ctx context.Context, name string) (IndexBuildProgress, bool) {
+	command := bson.D{
+		{Key: "currentOp", Value: true},
+		{Key: "ns", Value: q.tenantCollection(ctx).Database().Name() + "." + q.collection.Name()},
+		{Key: "command.createIndexes", Value: bson.M{"$exists": true}},
+	}
+	var result struct {
+		InProgress []struct {
+			Message  string `bson:"msg"`
+			Progress struct {
+				Done  int64 `bson:"done"`
+				Total int64 `bson:"total"`
+			} `bson:"progress"`
+			Command struct {
+				Indexes []struct {
+					Name string `bson:"name"`
+				} `bson:"indexes"`
+			} `bson:"command"`
+		} `bson:"inprog"`
+	}
+	err := q.MongoAdapter.Client.Database("admin").RunCommand(ctx, command).Decode(&result)
+	if err != nil {
+		q.MongoAdapter.Warn("unable to poll index build", LogField("index_name", name), LogError(err))
+		return IndexBuildProgress{}, false
+	}
+
+	for _, op := range result.InProgress {
+		for _, index := range op.Command.Indexes {
+			if index.Name != name {
+				continue
+			}
+
+			progress := IndexBuildProgress{
+				Index: name,
+				Phase: op.Message,
+				Done:  op.Progress.Done,
+				Total: op.Progress.Total,
+			}
+			if progress.Total > 0 {
+				progress.Percent = 100 * float64(progress.Done) / float64(progress.Total)
+			}
+			return progress, true
+		}
+	}
+	return IndexBuildProgress{}, false
+}
+
+func (q *Querier[Model, IDModel]) abortIndexBuild(collection *mongo.Collection, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultIndexBuildPollInterval)
+	defer cancel()
+
+	if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+		q.MongoAdapter.Error(
+			"unable to abort index build",
+			LogField("collection_name", q.collection.Name()),
+			LogField("index_name", name),
+			LogError(err),
+		)
+		return
+	}
+	q.MongoAdapter.Warn(
+		"Aborted index build",
+		LogField("collection_name", q.collection.Name()),
+		LogField("index_name", name),
+	)
+}
+
+func (q *Querier[Model, IDModel]) logIndexBuild(name string, err error) {
+	if err != nil {
+		q.MongoAdapter.Error(
+			"unable to build index",
+			LogField("collection_name", q.collection.Name()),
+			LogField("index_name", name),
+			LogError(err),
+		)
+		return
+	}
+	q.MongoAdapter.Info(
+		"Built index",
+		LogField("collection_name", q.collection.Name()),
+		LogField("index_name", name),
+	)
+}
+
+// indexName returns the name of model, generating the server's default
+// ("field_1_other_-1") when none is set.
+func indexName(model mongo.IndexModel) (string, error) {
+	if model.Options != nil && model.Options.Name != nil {
+		return *model.Options.Name, nil
+	}
+
+	var keys bson.D
+	switch k := model.Keys.(type) {
+	case bson.D:
+		keys = k
+	case bson.M:
+		if len(k) > 1 {
+			return "", errors.New("index keys must be ordered (bson.D) or the index named")
+		}
+		for key, value := range k {
+			keys = bson.D{{Key: key, Value: value}}
+		}
+	default:
+		return "", fmt.Errorf("unsupported index keys type %T, use bson.D or name the index", model.Keys)
+	}
+
+	parts := make([]string, 0, len(keys))
+	for _, e := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", e.Key, e.Value))
+	}
+	return strings.Join(parts, "_"), nil
+}
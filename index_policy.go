@@ -0,0 +1,135 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnindexedQuery = errors.New("query would scan the whole collection")
+
+type IndexPolicyMode int
+
+const (
+	// IndexPolicyWarn logs unindexed queries and lets them run, unless the
+	// adapter is in strict mode (see MongoAdapter.SetStrict).
+	IndexPolicyWarn IndexPolicyMode = iota
+	// IndexPolicyDeny rejects unindexed queries with ErrUnindexedQuery.
+	IndexPolicyDeny
+)
+
+// IndexPolicy explains every novel query shape once (queryPlanner only) and
+// flags shapes whose winning plan is a COLLSCAN. Verdicts are cached per
+// collection and shape for the lifetime of the policy.
+type IndexPolicy struct {
+	Mode IndexPolicyMode
+
+	verdicts sync.Map // collection name + shape -> bool (indexed)
+}
+
+func (q *Querier[Model, IDModel]) checkIndexPolicy(ctx context.Context, operation string, filter primitive.M) error {
+	if q.IndexPolicy == nil || filter == nil {
+		return nil
+	}
+
+	shape := QueryShape(filter)
+	key := q.collection.Name() + " " + shape
+
+	indexed, ok := q.IndexPolicy.verdicts.Load(key)
+	if !ok {
+		scans, err := q.scansCollection(ctx, filter)
+		if err != nil {
+			// The policy is a safety net, an explain failure shouldn't fail the query
+			q.MongoAdapter.Warn(
+				"unable to explain query shape",
+				LogField("collection_name", q.collection.Name()),
+				LogField("query_shape", shape),
+				LogError(err),
+			)
+			return nil
+		}
+		indexed = !scans
+		q.IndexPolicy.verdicts.Store(key, indexed)
+	}
+
+	if indexed.(bool) {
+		return nil
+	}
+
+	if q.IndexPolicy.Mode == IndexPolicyDeny || q.MongoAdapter.Strict() {
+		q.MongoAdapter.Error(
+			"Rejected unindexed query",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("query_shape", shape),
+		)
+		return fmt.Errorf("%w: %s on %s with shape %s", ErrUnindexedQuery, operation, q.collection.Name(), shape)
+	}
+
+	q.MongoAdapter.Warn(
+		"Unindexed query",
+		LogField("collection_name", q.collection.Name()),
+		LogField("operation", operation),
+		LogField("query_shape", shape),
+	)
+	return nil
+}
+
+func (q *Querier[Model, IDModel]) scansCollection(ctx context.Context, filter primitive.M) (bool, error) {
+	explain, err := q.explain(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	planner, ok := explain["queryPlanner"].(bson.M)
+	if !ok {
+		return false, errors.New("explain output has no queryPlanner")
+	}
+	return planHasStage(planner["winningPlan"], "COLLSCAN"), nil
+}
+
+func (q *Querier[Model, IDModel]) explain(ctx context.Context, filter primitive.M) (bson.M, error) {
+	return q.explainWith(ctx, filter, "queryPlanner")
+}
+
+// explainWith explains finding filter at verbosity; anything past
+// queryPlanner runs the query.
+func (q *Querier[Model, IDModel]) explainWith(ctx context.Context, filter primitive.M, verbosity string) (bson.M, error) {
+	command := bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: q.collection.Name()},
+			{Key: "filter", Value: filter},
+		}},
+		{Key: "verbosity", Value: verbosity},
+	}
+
+	var explain bson.M
+	err := q.tenantCollection(ctx).Database().RunCommand(ctx, command).Decode(&explain)
+	return explain, err
+}
+
+// planHasStage walks a (possibly sharded or SBE) plan tree looking for stage.
+func planHasStage(plan interface{}, stage string) bool {
+	switch plan := plan.(type) {
+	case bson.M:
+		if plan["stage"] == stage {
+			return true
+		}
+		for _, value := range plan {
+			if planHasStage(value, stage) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, value := range plan {
+			if planHasStage(value, stage) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IndexUsage struct {
+	Collection string
+	Name       string
+	Key        bson.D
+	// Accesses counts the operations that used the index since Since, when
+	// the server started tracking it (mongod restart or index creation).
+	Accesses int64
+	Since    time.Time
+	// Hosts are the members the statistics come from, one per shard on a
+	// sharded cluster, whose accesses are summed. They aren't aggregated
+	// across replica set members.
+	Hosts []string
+
+	Unused bool
+	// RedundantWith names an index whose key starts with this one's, making
+	// this one unnecessary for queries.
+	RedundantWith string
+}
+
+type IndexUsageReport struct {
+	GeneratedAt time.Time
+	Indexes     []IndexUsage
+}
+
+// Unused returns the indexes with no recorded access.
+func (r *IndexUsageReport) Unused() []IndexUsage {
+	var unused []IndexUsage
+	for _, index := range r.Indexes {
+		if index.Unused {
+			unused = append(unused, index)
+		}
+	}
+	return unused
+}
+
+// Redundant returns the indexes covered by a longer index.
+func (r *IndexUsageReport) Redundant() []IndexUsage {
+	var redundant []IndexUsage
+	for _, index := range r.Indexes {
+		if index.RedundantWith != "" {
+			redundant = append(redundant, index)
+		}
+	}
+	return redundant
+}
+
+// IndexUsageReport reports the access counts of every index of collections
+// (all collections of ctx's tenant database when none are given) from
+// $indexStats,
+// flagging unused indexes and indexes made redundant by a longer one. The
+// _id index is never flagged, nor are unique, partial, sparse or TTL
+// indexes reported redundant since they constrain data too.
+func (madp *MongoAdapter) IndexUsageReport(ctx context.Context, collections ...string) (*IndexUsageReport, error) {
+	if len(collections) == 0 {
+		database, err := madp.databaseFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names, err := database.ListCollectionNames(ctx, bson.M{"type": "collection"})
+		if err != nil {
+			return nil, err
+		}
+		collections = names
+	}
+
+	report := &IndexUsageReport{GeneratedAt: time.Now()}
+	for _, collectionName := range collections {
+		indexes, err := madp.indexUsage(ctx, collectionName)
+		if err != nil {
+			return nil, err
+		}
+		report.Indexes = append(report.Indexes, indexes...)
+	}
+
+	madp.Info(
+		"Generated index usage report",
+		LogField("collections_count", len(collections)),
+		LogField("indexes_count", len(report.Indexes)),
+		LogField("unused_indexes_count", len(report.Unused())),
+		LogField("redundant_indexes_count", len(report.Redundant())),
+	)
+	return report, nil
+}
+
+func (madp *MongoAdapter) indexUsage(ctx context.Context, collectionName string) ([]IndexUsage, error) {
+	collection, err := madp.collectionFor(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{{{Key: "$indexStats", Value: bson.M{}}}}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []indexStat
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	stats := groupIndexStats(rows)
+
+	indexes := make([]IndexUsage, len(stats))
+	for i, stat := range stats {
+		indexes[i] = IndexUsage{
+			Collection: collectionName,
+			Name:       stat.Name,
+			Key:        stat.Key,
+			Accesses:   stat.Accesses.Ops,
+			Since:      stat.Accesses.Since,
+			Hosts:      stat.hosts,
+			Unused:     stat.Name != "_id_" && stat.Accesses.Ops == 0,
+		}
+	}
+
+	for i, stat := range stats {
+		if stat.Name == "_id_" || constrainsData(stat.Spec) {
+			continue
+		}
+		for j, other := range stats {
+			// Of two identical indexes, only the later one is flagged
+			if i != j && isKeyPrefix(stat.Key, other.Key) && (len(stat.Key) < len(other.Key) || i > j) {
+				indexes[i].RedundantWith = other.Name
+				break
+			}
+		}
+	}
+	return indexes, nil
+}
+
+type indexStat struct {
+	Name     string `bson:"name"`
+	Key      bson.D `bson:"key"`
+	Host     string `bson:"host"`
+	Accesses struct {
+		Ops   int64     `bson:"ops"`
+		Since time.Time `bson:"since"`
+	} `bson:"accesses"`
+	Spec bson.M `bson:"spec"`
+
+	hosts []string
+}
+
+// groupIndexStats merges the rows $indexStats returns per shard for the same
+// index, summing their accesses since the earliest of them, in the order the
+// indexes first appear.
+func groupIndexStats(rows []indexStat) []indexStat {
+	var stats []indexStat
+	positions := map[string]int{}
+	for _, row := range rows {
+		i, ok := positions[row.Name]
+		if !ok {
+			positions[row.Name] = len(stats)
+			row.hosts = []string{row.Host}
+			stats = append(stats, row)
+			continue
+		}
+
+		stats[i].Accesses.Ops += row.Accesses.Ops
+		if row.Accesses.Since.Before(stats[i].Accesses.Since) {
+			stats[i].Accesses.Since = row.Accesses.Since
+		}
+		stats[i].hosts = append(stats[i].hosts, row.Host)
+	}
+	return stats
+}
+
+func constrainsData(spec bson.M) bool {
+	for _, option := range []string{"unique", "partialFilterExpression", "sparse", "expireAfterSeconds"} {
+		if value, ok := spec[option]; ok && value != false {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyPrefix reports whether key is a prefix of other, directions included.
+func isKeyPrefix(key bson.D, other bson.D) bool {
+	if len(key) > len(other) {
+		return false
+	}
+	for i, e := range key {
+		if e.Key != other[i].Key || !sameKeyDirection(e.Value, other[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameKeyDirection compares index key values, which may be any numeric type
+// or a string for special indexes ("text", "2dsphere", "hashed"...).
+func sameKeyDirection(a interface{}, b interface{}) bool {
+	direction := func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case int32:
+			return value > 0
+		case int64:
+			return value > 0
+		case float64:
+			return value > 0
+		}
+		return value
+	}
+	return direction(a) == direction(b)
+}
@@ -0,0 +1,37 @@
+package mongoquerier
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIndexStatsGroupedAcrossShards(t *testing.T) {
+	row := func(name string, host string, ops int64, since time.Time) indexStat {
+		stat := indexStat{Name: name, Key: bson.D{{Key: name, Value: 1}}, Host: host}
+		stat.Accesses.Ops = ops
+		stat.Accesses.Since = since
+		return stat
+	}
+	now := time.Now()
+	rows := []indexStat{
+		row("status_1", "shard0:27018", 0, now),
+		row("_id_", "shard0:27018", 3, now),
+		row("status_1", "shard1:27018", 5, now.Add(-time.Hour)),
+	}
+
+	stats := groupIndexStats(rows)
+	if len(stats) != 2 || stats[0].Name != "status_1" || stats[1].Name != "_id_" {
+		t.Fatalf("groupIndexStats() = %+v, want status_1 then _id_", stats)
+	}
+	if stats[0].Accesses.Ops != 5 {
+		t.Errorf("status_1 accesses = %d, want 5", stats[0].Accesses.Ops)
+	}
+	if !stats[0].Accesses.Since.Equal(now.Add(-time.Hour)) {
+		t.Errorf("status_1 since = %v, want the earliest", stats[0].Accesses.Since)
+	}
+	if len(stats[0].hosts) != 2 {
+		t.Errorf("status_1 hosts = %v, want both shards", stats[0].hosts)
+	}
+}
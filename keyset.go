@@ -0,0 +1,267 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// KeysetOptions configures the order FindAfter pages in. The zero value
+// pages by ascending _id.
+type KeysetOptions struct {
+	// SortKey is the (dotted) key to page by; _id breaks ties, so it
+	// doesn't need to be unique. Empty means _id. Documents missing it or
+	// holding values of different types page in MongoDB's sort order (null
+	// and missing first); array values aren't supported.
+	SortKey    string
+	Descending bool
+}
+
+// KeysetPage is one page of a keyset-paginated query. Next is the token of
+// the following page, empty after the last one.
+type KeysetPage[Model any] struct {
+	Documents []*Model
+	Next      string
+}
+
+// pageToken is the position after the last document of a page, encoded
+// into an opaque token.
+type pageToken struct {
+	SortKey string        `bson:"k"`
+	Value   bson.RawValue `bson:"v,omitempty"`
+	ID      bson.RawValue `bson:"id"`
+}
+
+func (q *Querier[Model, IDModel]) FindAfter(ctx context.Context, filter Model, token string, limit int, opts ...KeysetOptions) (*KeysetPage[Model], error) {
+	filterM, err := q.structToM("FindAfter", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindAfterByM(ctx, filterM, token, limit, opts...)
+}
+
+// FindAfterByM returns up to limit documents matching filter that come after
+// the position token points at; an empty token starts from the beginning.
+// Unlike skip, it seeks through the sort key's index, so late pages cost as
+// much as the first:
+//
+//	page, err := querier.FindAfterByM(ctx, filter, "", 100)
+//	for page.Next != "" {
+//		page, err = querier.FindAfterByM(ctx, filter, page.Next, 100)
+//	}
+//
+// A token is only valid with the options it was returned for.
+func (q *Querier[Model, IDModel]) FindAfterByM(ctx context.Context, filter primitive.M, token string, limit int, opts ...KeysetOptions) (result *KeysetPage[Model], err error) {
+	if err = q.preflight(ctx, "FindAfterByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindAfterByM", filter)
+	defer q.observe(span, time.Now(), "FindAfterByM", filter, &err)
+
+	if limit < 1 {
+		return nil, ErrInvalidPageSize
+	}
+	var keyset KeysetOptions
+	if len(opts) > 0 {
+		keyset = opts[len(opts)-1]
+	}
+	if keyset.SortKey == "" {
+		keyset.SortKey = "_id"
+	}
+	direction, after := 1, "$gt"
+	if keyset.Descending {
+		direction, after = -1, "$lt"
+	}
+
+	pageFilter := filter
+	if pageFilter == nil {
+		pageFilter = primitive.M{}
+	}
+	if token != "" {
+		position, err := decodePageToken(token, keyset.SortKey)
+		if err != nil {
+			return nil, err
+		}
+
+		seek := bson.M{"_id": bson.M{after: position.ID}}
+		if keyset.SortKey != "_id" {
+			seek = keysetSeek(keyset.SortKey, keyset.Descending, position)
+		}
+		pageFilter = bson.M{"$and": bson.A{pageFilter, seek}}
+	}
+
+	sort := bson.D{{Key: keyset.SortKey, Value: direction}}
+	if keyset.SortKey != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: direction})
+	}
+	// One more than asked tells whether there's a next page
+	findOptions := options.Find().SetSort(sort).SetLimit(int64(limit) + 1)
+	cursor, err := retrying(ctx, q, "FindAfterByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, pageFilter, q.findOptions(ctx, "FindAfterByM", []*options.FindOptions{findOptions})...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var batch []bson.Raw
+	if err = cursor.All(ctx, &batch); err != nil {
+		return nil, err
+	}
+
+	result = &KeysetPage[Model]{}
+	if len(batch) > limit {
+		batch = batch[:limit]
+		if result.Next, err = encodePageToken(batch[limit-1], keyset.SortKey); err != nil {
+			return nil, err
+		}
+	}
+	for _, raw := range batch {
+		document, err := q.decode(ctx, raw)
+		if skipUndecodable(ctx, err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Documents = append(result.Documents, document)
+	}
+
+	q.MongoAdapter.Debug(
+		"Found a page of documents after a token",
+		LogField("collection_name", q.collection.Name()),
+		LogField("sort_key", keyset.SortKey),
+		LogField("documents_count", len(result.Documents)),
+		LogField("has_next", result.Next != ""),
+	)
+	return result, nil
+}
+
+// sortTypeOrder lists BSON types ($type aliases) in the order MongoDB sorts
+// them. Types of one rank compare by value; null ranks with missing fields.
+var sortTypeOrder = [][]string{
+	{"minKey"},
+	{"null"},
+	{"double", "int", "long", "decimal"},
+	{"string", "symbol"},
+	{"object"},
+	{"array"},
+	{"binData"},
+	{"objectId"},
+	{"bool"},
+	{"date"},
+	{"timestamp"},
+	{"regex"},
+	{"maxKey"},
+}
+
+const nullSortRank = 1
+
+func sortRank(t bsontype.Type) int {
+	switch t {
+	case bson.TypeMinKey:
+		return 0
+	case bson.TypeDouble, bson.TypeInt32, bson.TypeInt64, bson.TypeDecimal128:
+		return 2
+	case bson.TypeString, bson.TypeSymbol:
+		return 3
+	case bson.TypeEmbeddedDocument:
+		return 4
+	case bson.TypeArray:
+		return 5
+	case bson.TypeBinary:
+		return 6
+	case bson.TypeObjectID:
+		return 7
+	case bson.TypeBoolean:
+		return 8
+	case bson.TypeDateTime:
+		return 9
+	case bson.TypeTimestamp:
+		return 10
+	case bson.TypeRegex:
+		return 11
+	case bson.TypeMaxKey:
+		return 12
+	}
+	return nullSortRank
+}
+
+// keysetSeek matches the documents sorting after position on sortKey. $gt
+// and $lt only compare values of the same type, and never match null or
+// missing keys, so documents of the types sorting after the position's are
+// matched by type.
+func keysetSeek(sortKey string, descending bool, position pageToken) bson.M {
+	after := "$gt"
+	if descending {
+		after = "$lt"
+	}
+	rank := sortRank(position.Value.Type)
+
+	// Ties, null and missing keys alike, are broken by _id
+	clauses := bson.A{bson.M{sortKey: position.Value, "_id": bson.M{after: position.ID}}}
+	if rank != nullSortRank {
+		clauses = append(clauses, bson.M{sortKey: bson.M{after: position.Value}})
+	}
+
+	var types bson.A
+	for r, aliases := range sortTypeOrder {
+		if (descending && r >= rank) || (!descending && r <= rank) {
+			continue
+		}
+		if r == nullSortRank {
+			// A null equality also matches missing keys, which $type doesn't
+			clauses = append(clauses, bson.M{sortKey: nil})
+			continue
+		}
+		for _, alias := range aliases {
+			types = append(types, alias)
+		}
+	}
+	if len(types) > 0 {
+		clauses = append(clauses, bson.M{sortKey: bson.M{"$type": types}})
+	}
+	return bson.M{"$or": clauses}
+}
+
+func encodePageToken(last bson.Raw, sortKey string) (string, error) {
+	position := pageToken{SortKey: sortKey, ID: last.Lookup("_id")}
+	if sortKey != "_id" {
+		// A missing key sorts as null
+		position.Value = last.Lookup(strings.Split(sortKey, ".")...)
+		if position.Value.Type == 0 {
+			position.Value = bson.RawValue{Type: bson.TypeNull}
+		}
+	}
+
+	data, err := bson.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(token string, sortKey string) (pageToken, error) {
+	var position pageToken
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return position, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
+	}
+	if err := bson.Unmarshal(data, &position); err != nil {
+		return position, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
+	}
+	if position.SortKey != sortKey {
+		return position, fmt.Errorf("%w: issued for sort key %q, not %q", ErrInvalidPageToken, position.SortKey, sortKey)
+	}
+	return position, nil
+}
@@ -0,0 +1,69 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestKeysetSeekAcrossTypes(t *testing.T) {
+	id := rawValue(t, int32(7))
+
+	// Missing keys page first, then every other type
+	raw, _ := bson.Marshal(bson.M{"_id": 7})
+	token, err := encodePageToken(raw, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	position, err := decodePageToken(token, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Value.Type != bson.TypeNull {
+		t.Fatalf("position of a missing key = %v, want null", position.Value.Type)
+	}
+	clauses := keysetSeek("name", false, position)["$or"].(bson.A)
+	if len(clauses) != 2 {
+		t.Fatalf("seek after null = %v, want the tie and the later types", clauses)
+	}
+	types := clauses[1].(bson.M)["name"].(bson.M)["$type"].(bson.A)
+	if types[0] != "double" || containsValue(types, "null") {
+		t.Errorf("types after null = %v", types)
+	}
+
+	// Descending from a string reaches numbers, then null and missing keys
+	position = pageToken{SortKey: "name", Value: rawValue(t, "ada"), ID: id}
+	clauses = keysetSeek("name", true, position)["$or"].(bson.A)
+	if len(clauses) != 4 {
+		t.Fatalf("seek before a string = %v, want 4 clauses", clauses)
+	}
+	if clauses[1].(bson.M)["name"].(bson.M)["$lt"] == nil {
+		t.Errorf("clause %v, want $lt on name", clauses[1])
+	}
+	if value, ok := clauses[2].(bson.M)["name"]; !ok || value != nil {
+		t.Errorf("clause %v, want a null equality on name", clauses[2])
+	}
+	types = clauses[3].(bson.M)["name"].(bson.M)["$type"].(bson.A)
+	if !containsValue(types, "minKey") || !containsValue(types, "int") || containsValue(types, "object") {
+		t.Errorf("types before string = %v", types)
+	}
+}
+
+func containsValue(values bson.A, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func rawValue(t *testing.T, value interface{}) bson.RawValue {
+	t.Helper()
+
+	valueType, data, err := bson.MarshalValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.RawValue{Type: valueType, Value: data}
+}
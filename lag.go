@@ -0,0 +1,182 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const DefaultLagPollInterval = 5 * time.Second
+
+// ErrNoPrimary is returned by checks finding no primary to measure lag
+// against, e.g. during an election; the lag is then unknown, not zero.
+var ErrNoPrimary = errors.New("no primary in replica set status")
+
+// ReplicationLagMonitor polls replSetGetStatus and tracks how far the most
+// lagging healthy secondary is behind the primary.
+type ReplicationLagMonitor struct {
+	*MongoAdapter
+	PollInterval time.Duration
+
+	mu        sync.RWMutex
+	lag       time.Duration
+	checkedAt time.Time
+	err       error
+}
+
+func NewReplicationLagMonitor(madp *MongoAdapter) *ReplicationLagMonitor {
+	return &ReplicationLagMonitor{MongoAdapter: madp, PollInterval: DefaultLagPollInterval}
+}
+
+// Run polls until ctx is done.
+func (m *ReplicationLagMonitor) Run(ctx context.Context) {
+	interval := m.PollInterval
+	if interval <= 0 {
+		interval = DefaultLagPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check measures the replication lag once. It fails with ErrNoPrimary when
+// no member is primary, leaving the lag unknown.
+func (m *ReplicationLagMonitor) Check(ctx context.Context) (time.Duration, error) {
+	var status struct {
+		Members []replicaSetMember `bson:"members"`
+	}
+	err := m.MongoAdapter.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status)
+
+	var lag time.Duration
+	if err == nil {
+		lag, err = replicationLag(status.Members)
+	}
+
+	m.mu.Lock()
+	m.lag, m.err, m.checkedAt = lag, err, time.Now()
+	m.mu.Unlock()
+
+	if err != nil {
+		m.MongoAdapter.Warn("unable to check replication lag", LogError(err))
+	}
+	return lag, err
+}
+
+type replicaSetMember struct {
+	StateStr   string    `bson:"stateStr"`
+	Health     float64   `bson:"health"`
+	OptimeDate time.Time `bson:"optimeDate"`
+}
+
+// replicationLag returns how far the most lagging healthy secondary is
+// behind the primary.
+func replicationLag(members []replicaSetMember) (time.Duration, error) {
+	var primaryOptime time.Time
+	for _, member := range members {
+		if member.StateStr == "PRIMARY" {
+			primaryOptime = member.OptimeDate
+		}
+	}
+	// Without a primary, lag would read as zero and route reads to stale
+	// secondaries
+	if primaryOptime.IsZero() {
+		return 0, ErrNoPrimary
+	}
+
+	var lag time.Duration
+	for _, member := range members {
+		if member.StateStr == "SECONDARY" && member.Health == 1 {
+			if memberLag := primaryOptime.Sub(member.OptimeDate); memberLag > lag {
+				lag = memberLag
+			}
+		}
+	}
+	return lag, nil
+}
+
+// Lag returns the last measured lag; ok is false when it's unknown or
+// older than three poll intervals.
+func (m *ReplicationLagMonitor) Lag() (lag time.Duration, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	interval := m.PollInterval
+	if interval <= 0 {
+		interval = DefaultLagPollInterval
+	}
+	if m.err != nil || m.checkedAt.IsZero() || time.Since(m.checkedAt) > 3*interval {
+		return 0, false
+	}
+	return m.lag, true
+}
+
+// AdaptiveReads sends a querier's reads to secondaries while replication
+// lag stays under MaxLag and falls back to the primary otherwise, including
+// when the lag is unknown.
+type AdaptiveReads struct {
+	Monitor *ReplicationLagMonitor
+	MaxLag  time.Duration
+	// Secondary is the read preference used while lag is acceptable; it
+	// defaults to secondaryPreferred.
+	Secondary *readpref.ReadPref
+
+	mu          sync.Mutex
+	onSecondary bool
+}
+
+// route decides whether reads go to secondaries, reporting whether that
+// changed since the previous read.
+func (ar *AdaptiveReads) route() (secondary bool, changed bool, lag time.Duration) {
+	lag, ok := ar.Monitor.Lag()
+	secondary = ok && lag <= ar.MaxLag
+
+	ar.mu.Lock()
+	changed = secondary != ar.onSecondary
+	ar.onSecondary = secondary
+	ar.mu.Unlock()
+	return secondary, changed, lag
+}
+
+func (q *Querier[Model, IDModel]) adaptiveCollection(collection *mongo.Collection) *mongo.Collection {
+	secondary, changed, lag := q.AdaptiveReads.route()
+	if changed && secondary {
+		q.MongoAdapter.Info(
+			"Resumed secondary reads",
+			LogField("collection_name", q.collection.Name()),
+			LogField("replication_lag", lag),
+		)
+	} else if changed {
+		q.MongoAdapter.Warn(
+			"Fell back to primary reads",
+			LogField("collection_name", q.collection.Name()),
+			LogField("replication_lag", lag),
+		)
+	}
+
+	rp := readpref.Primary()
+	if secondary {
+		rp = q.AdaptiveReads.Secondary
+		if rp == nil {
+			rp = readpref.SecondaryPreferred()
+		}
+	}
+	routed, err := collection.Clone(options.Collection().SetReadPreference(rp))
+	if err != nil {
+		return collection
+	}
+	return routed
+}
@@ -0,0 +1,24 @@
+package mongoquerier
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplicationLag(t *testing.T) {
+	now := time.Now()
+	members := []replicaSetMember{
+		{StateStr: "PRIMARY", Health: 1, OptimeDate: now},
+		{StateStr: "SECONDARY", Health: 1, OptimeDate: now.Add(-2 * time.Second)},
+		{StateStr: "SECONDARY", Health: 0, OptimeDate: now.Add(-time.Hour)},
+	}
+	if lag, err := replicationLag(members); err != nil || lag != 2*time.Second {
+		t.Errorf("replicationLag() = %v, %v, want 2s", lag, err)
+	}
+
+	// During an election the lag is unknown, not zero
+	if _, err := replicationLag(members[1:]); !errors.Is(err, ErrNoPrimary) {
+		t.Errorf("replicationLag() without primary error = %v, want ErrNoPrimary", err)
+	}
+}
@@ -0,0 +1,205 @@
+package mongoquerier
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const DefaultLatencyMaxShapes = 1000
+
+// OtherQueryShape collects the latencies of shapes beyond MaxShapes.
+const OtherQueryShape = "(other)"
+
+// latencyBuckets are the upper bounds of the histogram buckets, doubling
+// from 1ms to about 33s; slower operations fall in a final open bucket.
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 16)
+	for i := range buckets {
+		buckets[i] = time.Millisecond << i
+	}
+	return buckets
+}()
+
+// LatencyRecorder keeps a latency histogram per collection, operation and
+// query shape (see QueryShape).
+type LatencyRecorder struct {
+	// MaxShapes bounds the number of histograms kept.
+	MaxShapes int
+
+	mu         sync.Mutex
+	histograms map[latencyKey]*latencyHistogram
+}
+
+type latencyKey struct {
+	collection string
+	operation  string
+	shape      string
+}
+
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	total  time.Duration
+	max    time.Duration
+}
+
+type ShapeLatency struct {
+	Collection string
+	Operation  string
+	Shape      string
+	Count      int64
+	Total      time.Duration
+	Mean       time.Duration
+	Max        time.Duration
+	// Percentiles are the upper bounds of the buckets they fall in.
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	// Buckets counts operations per bucket, bounded by BucketBounds.
+	Buckets []int64
+}
+
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{MaxShapes: DefaultLatencyMaxShapes}
+}
+
+// BucketBounds returns the upper bounds of the histogram buckets; the last
+// bucket of ShapeLatency.Buckets is unbounded.
+func BucketBounds() []time.Duration {
+	return append([]time.Duration(nil), latencyBuckets...)
+}
+
+func (lr *LatencyRecorder) Record(collection string, operation string, shape string, latency time.Duration) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if lr.histograms == nil {
+		lr.histograms = make(map[latencyKey]*latencyHistogram)
+	}
+
+	key := latencyKey{collection: collection, operation: operation, shape: shape}
+	histogram, ok := lr.histograms[key]
+	if !ok {
+		maxShapes := lr.MaxShapes
+		if maxShapes <= 0 {
+			maxShapes = DefaultLatencyMaxShapes
+		}
+		if len(lr.histograms) >= maxShapes {
+			key.shape = OtherQueryShape
+			histogram, ok = lr.histograms[key]
+		}
+		if !ok {
+			histogram = &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+			lr.histograms[key] = histogram
+		}
+	}
+
+	bucket := sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })
+	histogram.counts[bucket]++
+	histogram.count++
+	histogram.total += latency
+	if latency > histogram.max {
+		histogram.max = latency
+	}
+}
+
+// Snapshot returns the latency distribution of every recorded shape.
+func (lr *LatencyRecorder) Snapshot() []ShapeLatency {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	snapshot := make([]ShapeLatency, 0, len(lr.histograms))
+	for key, histogram := range lr.histograms {
+		latency := ShapeLatency{
+			Collection: key.collection,
+			Operation:  key.operation,
+			Shape:      key.shape,
+			Count:      histogram.count,
+			Total:      histogram.total,
+			Mean:       histogram.total / time.Duration(histogram.count),
+			Max:        histogram.max,
+			Buckets:    append([]int64(nil), histogram.counts...),
+		}
+		latency.P50 = histogram.percentile(0.50)
+		latency.P95 = histogram.percentile(0.95)
+		latency.P99 = histogram.percentile(0.99)
+		snapshot = append(snapshot, latency)
+	}
+	return snapshot
+}
+
+// Top returns the n shapes with the highest p95 latency, breaking ties by
+// total time spent.
+func (lr *LatencyRecorder) Top(n int) []ShapeLatency {
+	snapshot := lr.Snapshot()
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].P95 != snapshot[j].P95 {
+			return snapshot[i].P95 > snapshot[j].P95
+		}
+		return snapshot[i].Total > snapshot[j].Total
+	})
+	if n > 0 && len(snapshot) > n {
+		snapshot = snapshot[:n]
+	}
+	return snapshot
+}
+
+// Reset drops every histogram.
+func (lr *LatencyRecorder) Reset() {
+	lr.mu.Lock()
+	lr.histograms = nil
+	lr.mu.Unlock()
+}
+
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := int64(p*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i == len(latencyBuckets) {
+				return h.max
+			}
+			return latencyBuckets[i]
+		}
+	}
+	return h.max
+}
+
+// observeLatency records the latency of an operation started at start; see
+// observe.
+func (q *Querier[Model, IDModel]) observeLatency(start time.Time, operation string, filter primitive.M) {
+	if q.MongoAdapter.Latency == nil {
+		return
+	}
+	q.MongoAdapter.Latency.Record(q.collection.Name(), operation, QueryShape(filter), time.Since(start))
+}
+
+// logSlowQuery logs an operation started at start at Warn when it took longer
+// than the querier's or the adapter's slow query threshold; see observe.
+func (q *Querier[Model, IDModel]) logSlowQuery(start time.Time, operation string, filter primitive.M) {
+	threshold := q.SlowQueryThreshold
+	if threshold <= 0 {
+		threshold = q.MongoAdapter.slowQueryThresholdOrDefault()
+	}
+	duration := time.Since(start)
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+
+	q.MongoAdapter.Warn(
+		"Slow query",
+		LogField("collection_name", q.collection.Name()),
+		LogField("operation", operation),
+		LogField("query_shape", QueryShape(filter)),
+		LogField("duration", duration),
+		LogField("slow_query_threshold", threshold),
+	)
+}
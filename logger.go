@@ -0,0 +1,51 @@
+package mongoquerier
+
+// Logger is the structured logger adapters log through. zaplog wraps a zap
+// logger, SlogLogger a log/slog one, and NopLogger discards everything;
+// other libraries plug in by implementing it.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With returns a logger adding fields to every entry.
+	With(fields ...Field) Logger
+}
+
+// Field is a key-value pair of a log entry. Values are logged as is, but
+// LogValuers, resolved when the entry is written.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func LogField(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// LogError logs err under the "error" key.
+func LogError(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// LogValuer is a field value computed only when the entry is written, so
+// entries below the logger's level don't pay for it.
+type LogValuer interface {
+	LogValue() interface{}
+}
+
+type nopLogger struct{}
+
+// NopLogger returns a logger discarding every entry.
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...Field) {}
+func (nopLogger) Info(string, ...Field)  {}
+func (nopLogger) Warn(string, ...Field)  {}
+func (nopLogger) Error(string, ...Field) {}
+
+func (l nopLogger) With(...Field) Logger {
+	return l
+}
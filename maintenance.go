@@ -0,0 +1,235 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOutsideMaintenanceWindow = errors.New("outside the maintenance window")
+	ErrPrimaryMaintenance       = errors.New("maintenance refused on a primary")
+)
+
+// MaintenanceWindow is a daily time range, e.g. 02:00 to 05:00. A window
+// ending before it starts spans midnight.
+type MaintenanceWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Location defaults to UTC.
+	Location *time.Location
+}
+
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	location := w.Location
+	if location == nil {
+		location = time.UTC
+	}
+	t = t.In(location)
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.Start <= w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+// Maintenance runs storage maintenance commands (compact, validate) on a
+// replica set member, with safety checks: it refuses to run on a primary
+// unless AllowPrimary is set, and outside Window when one is set.
+//
+//	node, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://db-2:27017/?directConnection=true"))
+//	maintenance := mongoquerier.NewMaintenance(mongoAdapter)
+//	maintenance.Node = node
+//	maintenance.Window = &mongoquerier.MaintenanceWindow{Start: 2 * time.Hour, End: 5 * time.Hour}
+//	reports, err := maintenance.Compact(ctx, "events", "audit_logs")
+//
+// compact only acts on the member it runs on: connect Node directly to the
+// secondary to compact, and repeat for each member.
+type Maintenance struct {
+	*MongoAdapter
+	// Node is the member to maintain; the adapter's client when nil.
+	Node         *mongo.Client
+	Window       *MaintenanceWindow
+	AllowPrimary bool
+}
+
+func NewMaintenance(madp *MongoAdapter) *Maintenance {
+	return &Maintenance{MongoAdapter: madp}
+}
+
+func (m *Maintenance) node() *mongo.Client {
+	if m.Node != nil {
+		return m.Node
+	}
+	return m.MongoAdapter.Client
+}
+
+// checkSafe verifies the window and the role of the node before each
+// collection, so long runs stop once the window closes.
+func (m *Maintenance) checkSafe(ctx context.Context) error {
+	if m.Window != nil && !m.Window.Contains(time.Now()) {
+		return ErrOutsideMaintenanceWindow
+	}
+	if m.AllowPrimary {
+		return nil
+	}
+
+	var hello struct {
+		Secondary bool   `bson:"secondary"`
+		Me        string `bson:"me"`
+	}
+	err := m.node().Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return err
+	}
+	// Standalones and mongos are neither, and as unsafe as a primary
+	if !hello.Secondary {
+		return fmt.Errorf("%w: %s", ErrPrimaryMaintenance, hello.Me)
+	}
+	return nil
+}
+
+type CompactReport struct {
+	Collection        string
+	StorageSizeBefore int64
+	StorageSizeAfter  int64
+	ReclaimedBytes    int64
+	Duration          time.Duration
+}
+
+// Compact compacts collections one after the other, logging the storage
+// reclaimed by each. It stops at the first failure or unsafe check,
+// returning the reports of the collections compacted so far.
+func (m *Maintenance) Compact(ctx context.Context, collections ...string) ([]CompactReport, error) {
+	database := m.node().Database(m.MongoAdapter.Database)
+
+	var reports []CompactReport
+	for i, collection := range collections {
+		if err := m.checkSafe(ctx); err != nil {
+			m.MongoAdapter.Warn("Stopped compaction", LogField("collection_name", collection), LogError(err))
+			return reports, err
+		}
+
+		m.MongoAdapter.Info(
+			"Compacting collection",
+			LogField("collection_name", collection),
+			LogField("collection_number", i+1),
+			LogField("collections_count", len(collections)),
+		)
+		report := CompactReport{Collection: collection}
+		start := time.Now()
+
+		before, err := storageSize(ctx, database, collection)
+		if err != nil {
+			return reports, err
+		}
+		if err := database.RunCommand(ctx, bson.D{{Key: "compact", Value: collection}}).Err(); err != nil {
+			m.MongoAdapter.Error("Compaction failed", LogField("collection_name", collection), LogError(err))
+			return reports, err
+		}
+		after, err := storageSize(ctx, database, collection)
+		if err != nil {
+			return reports, err
+		}
+
+		report.StorageSizeBefore = before
+		report.StorageSizeAfter = after
+		report.ReclaimedBytes = before - after
+		report.Duration = time.Since(start)
+		reports = append(reports, report)
+
+		m.MongoAdapter.Info(
+			"Compacted collection",
+			LogField("collection_name", collection),
+			LogField("reclaimed_bytes", report.ReclaimedBytes),
+			LogField("duration", report.Duration),
+		)
+	}
+	return reports, nil
+}
+
+func storageSize(ctx context.Context, database *mongo.Database, collection string) (int64, error) {
+	var stats struct {
+		StorageSize int64 `bson:"storageSize"`
+	}
+	err := database.RunCommand(ctx, bson.D{{Key: "collStats", Value: collection}}).Decode(&stats)
+	return stats.StorageSize, err
+}
+
+type ValidateReport struct {
+	Collection       string
+	Valid            bool
+	InvalidDocuments int64
+	Warnings         []string
+	Errors           []string
+	Duration         time.Duration
+}
+
+// Validate checks the structures and, when full, every document of
+// collections. Invalid collections are reported and logged, not returned as
+// errors.
+func (m *Maintenance) Validate(ctx context.Context, full bool, collections ...string) ([]ValidateReport, error) {
+	database := m.node().Database(m.MongoAdapter.Database)
+
+	var reports []ValidateReport
+	for _, collection := range collections {
+		if err := m.checkSafe(ctx); err != nil {
+			m.MongoAdapter.Warn("Stopped validation", LogField("collection_name", collection), LogError(err))
+			return reports, err
+		}
+
+		start := time.Now()
+		var result struct {
+			Valid    bool     `bson:"valid"`
+			NInvalid int64    `bson:"nInvalidDocuments"`
+			Warnings []string `bson:"warnings"`
+			Errors   []string `bson:"errors"`
+		}
+		command := bson.D{{Key: "validate", Value: collection}, {Key: "full", Value: full}}
+		if err := database.RunCommand(ctx, command).Decode(&result); err != nil {
+			m.MongoAdapter.Error("Validation failed", LogField("collection_name", collection), LogError(err))
+			return reports, err
+		}
+
+		report := ValidateReport{
+			Collection:       collection,
+			Valid:            result.Valid,
+			InvalidDocuments: result.NInvalid,
+			Warnings:         result.Warnings,
+			Errors:           result.Errors,
+			Duration:         time.Since(start),
+		}
+		reports = append(reports, report)
+
+		log := m.MongoAdapter.Info
+		if !report.Valid {
+			log = m.MongoAdapter.Error
+		}
+		log(
+			"Validated collection",
+			LogField("collection_name", collection),
+			LogField("valid", report.Valid),
+			LogField("invalid_documents", report.InvalidDocuments),
+			LogField("errors", report.Errors),
+			LogField("duration", report.Duration),
+		)
+	}
+	return reports, nil
+}
+
+// Schedule registers compacting collections on a scheduler. Runs outside
+// the window are skipped rather than failed.
+func (m *Maintenance) Schedule(s *Scheduler, interval time.Duration, collections ...string) {
+	s.Every("compaction", interval, func(ctx context.Context) error {
+		_, err := m.Compact(ctx, collections...)
+		if errors.Is(err, ErrOutsideMaintenanceWindow) {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,145 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (q *Querier[Model, IDModel]) FindMaps(ctx context.Context, filter Model, opts ...*options.FindOptions) ([]map[string]interface{}, error) {
+	filterM, err := q.structToM("FindMaps", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindMapsByM(ctx, filterM, opts...)
+}
+
+// FindMapsByM returns the documents matching filter as maps holding every
+// stored field, including those Model doesn't declare, for generic tooling
+// such as admin UIs. Values keep their BSON types (see RawToMap), so maps
+// written back store what was read.
+func (q *Querier[Model, IDModel]) FindMapsByM(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) (documents []map[string]interface{}, err error) {
+	if err = q.preflight(ctx, "FindMapsByM", filter); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "FindMapsByM", filter)
+	defer q.observe(span, time.Now(), "FindMapsByM", filter, &err)
+
+	cursor, err := retrying(ctx, q, "FindMapsByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "FindMapsByM", opts)...)
+	})
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document map[string]interface{}
+		if document, err = RawToMap(cursor.Current); err != nil {
+			return
+		}
+		documents = append(documents, document)
+	}
+	if err = cursor.Err(); err != nil {
+		return
+	}
+
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", len(documents)))
+
+	q.MongoAdapter.Debug(
+		"Found all documents as maps",
+		LogField("collection_name", q.collection.Name()),
+		LogField("documents_count", len(documents)),
+	)
+	return
+}
+
+// RawToMap decodes a document into a map whose values have one Go type per
+// BSON type:
+//
+//	embedded document  map[string]interface{}
+//	array              []interface{}
+//	double             float64
+//	int32, int64       int32, int64
+//	date               time.Time (UTC)
+//	ObjectId           primitive.ObjectID
+//	decimal            primitive.Decimal128
+//	UUID (binary 4)    UUID
+//	other binary       primitive.Binary
+//	null               nil
+//
+// Strings and booleans are strings and bools, and the remaining types the
+// driver's primitive types (Timestamp, Regex...).
+func RawToMap(document bson.Raw) (map[string]interface{}, error) {
+	elements, err := document.Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, len(elements))
+	for _, element := range elements {
+		value, err := rawToValue(element.Value())
+		if err != nil {
+			return nil, err
+		}
+		m[element.Key()] = value
+	}
+	return m, nil
+}
+
+func rawToValue(value bson.RawValue) (interface{}, error) {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		return RawToMap(value.Document())
+	case bsontype.Array:
+		values, err := value.Array().Values()
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, 0, len(values))
+		for _, element := range values {
+			converted, err := rawToValue(element)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, converted)
+		}
+		return array, nil
+	case bsontype.Double:
+		return value.Double(), nil
+	case bsontype.String:
+		return value.StringValue(), nil
+	case bsontype.Boolean:
+		return value.Boolean(), nil
+	case bsontype.Int32:
+		return value.Int32(), nil
+	case bsontype.Int64:
+		return value.Int64(), nil
+	case bsontype.DateTime:
+		return value.Time().UTC(), nil
+	case bsontype.ObjectID:
+		return value.ObjectID(), nil
+	case bsontype.Decimal128:
+		return value.Decimal128(), nil
+	case bsontype.Null, bsontype.Undefined:
+		return nil, nil
+	case bsontype.Binary:
+		subtype, data := value.Binary()
+		if subtype == bson.TypeBinaryUUID && len(data) == len(UUID{}) {
+			var u UUID
+			copy(u[:], data)
+			return u, nil
+		}
+		return primitive.Binary{Subtype: subtype, Data: data}, nil
+	}
+
+	var converted interface{}
+	err := value.Unmarshal(&converted)
+	return converted, err
+}
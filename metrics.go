@@ -0,0 +1,355 @@
+package mongoquerier
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the operation
+// duration histogram buckets.
+var DefaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics counts querier operations and errors, and tracks their durations
+// and the connection pools, exposing them in the Prometheus text format:
+//
+//	metrics := mongoquerier.NewMetrics()
+//	mongoAdapter, err := mongoquerier.NewMongoAdapter(ctx, uri, "shop", mongoquerier.WithMetrics(metrics))
+//	http.Handle("/metrics/mongo", metrics)
+//
+// Operation metrics are labeled by collection and operation, pool metrics by
+// server address.
+type Metrics struct {
+	// Namespace prefixes metric names, "mongoquerier" by default.
+	Namespace string
+	// Buckets are the duration histogram buckets, DefaultDurationBuckets by
+	// default. They're copied at the first operation, later changes are
+	// ignored.
+	Buckets []float64
+
+	mu         sync.Mutex
+	bounds     []float64
+	operations map[operationMetricsKey]*operationMetrics
+	pools      map[string]*poolMetrics
+}
+
+type operationMetricsKey struct {
+	collection string
+	operation  string
+}
+
+type operationMetrics struct {
+	count   uint64
+	errors  uint64
+	sum     float64
+	buckets []uint64
+}
+
+type poolMetrics struct {
+	open  int64
+	inUse int64
+	// checkouts are the start times of pending checkouts, served in order
+	checkouts []time.Time
+	waits     uint64
+	waitSum   float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		operations: map[operationMetricsKey]*operationMetrics{},
+		pools:      map[string]*poolMetrics{},
+	}
+}
+
+// buckets returns the histogram bounds, copied from Buckets on first use so
+// every operation's histogram keeps the same buckets. m.mu must be held.
+func (m *Metrics) buckets() []float64 {
+	if m.bounds == nil {
+		bounds := DefaultDurationBuckets
+		if len(m.Buckets) > 0 {
+			bounds = m.Buckets
+		}
+		m.bounds = append([]float64(nil), bounds...)
+		sort.Float64s(m.bounds)
+	}
+	return m.bounds
+}
+
+// ObserveOperation records an operation on collection that took duration
+// and failed when err isn't nil. Queriers of adapters with Metrics call it
+// for every operation.
+func (m *Metrics) ObserveOperation(collection string, operation string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := operationMetricsKey{collection: collection, operation: operation}
+	metrics, ok := m.operations[key]
+	if !ok {
+		metrics = &operationMetrics{buckets: make([]uint64, len(m.buckets()))}
+		m.operations[key] = metrics
+	}
+
+	seconds := duration.Seconds()
+	metrics.count++
+	metrics.sum += seconds
+	if err != nil {
+		metrics.errors++
+	}
+	for i, bound := range m.buckets() {
+		if seconds <= bound {
+			metrics.buckets[i]++
+		}
+	}
+}
+
+// OperationStats are the metrics of a querier operation on a collection.
+type OperationStats struct {
+	Collection string
+	Operation  string
+	Count      uint64
+	Errors     uint64
+	// DurationSum is the total duration in seconds; BucketCounts the
+	// cumulative count of operations at most each of Bounds long.
+	DurationSum  float64
+	Bounds       []float64
+	BucketCounts []uint64
+}
+
+// OperationStats returns the operation metrics, ordered by collection and
+// operation, for exporters other than ServeHTTP.
+func (m *Metrics) OperationStats() []OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]OperationStats, 0, len(m.operations))
+	for _, key := range m.operationKeys() {
+		metrics := m.operations[key]
+		stats = append(stats, OperationStats{
+			Collection:   key.collection,
+			Operation:    key.operation,
+			Count:        metrics.count,
+			Errors:       metrics.errors,
+			DurationSum:  metrics.sum,
+			Bounds:       m.buckets(),
+			BucketCounts: append([]uint64(nil), metrics.buckets...),
+		})
+	}
+	return stats
+}
+
+// operationKeys returns the operations observed, ordered by collection and
+// operation. m.mu must be held.
+func (m *Metrics) operationKeys() []operationMetricsKey {
+	keys := make([]operationMetricsKey, 0, len(m.operations))
+	for key := range m.operations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].collection != keys[j].collection {
+			return keys[i].collection < keys[j].collection
+		}
+		return keys[i].operation < keys[j].operation
+	})
+	return keys
+}
+
+// PoolMonitor returns the pool monitor feeding the pool gauges, installed by
+// WithMetrics. The checkout wait time is measured assuming checkouts are
+// served in the order they started, as the driver queues them.
+func (m *Metrics) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.observePool}
+}
+
+func (m *Metrics) observePool(e *event.PoolEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool, ok := m.pools[e.Address]
+	if !ok {
+		pool = &poolMetrics{}
+		m.pools[e.Address] = pool
+	}
+
+	switch e.Type {
+	case event.ConnectionCreated:
+		pool.open++
+	case event.ConnectionClosed:
+		pool.open--
+	case event.GetStarted:
+		pool.checkouts = append(pool.checkouts, time.Now())
+	case event.GetSucceeded, event.GetFailed:
+		if len(pool.checkouts) > 0 {
+			pool.waits++
+			pool.waitSum += time.Since(pool.checkouts[0]).Seconds()
+			pool.checkouts = pool.checkouts[1:]
+		}
+		if e.Type == event.GetSucceeded {
+			pool.inUse++
+		}
+	case event.ConnectionReturned:
+		pool.inUse--
+	case event.PoolCleared, event.PoolClosedEvent:
+		pool.checkouts = nil
+	}
+}
+
+// PoolStats is the state of a server's connection pool. Waits counts the
+// checkouts completed so far and WaitSeconds their total wait.
+type PoolStats struct {
+	Address     string  `json:"address"`
+	InUse       int64   `json:"in_use"`
+	Idle        int64   `json:"idle"`
+	Waiting     int     `json:"waiting"`
+	Waits       uint64  `json:"waits"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// PoolStats returns the state of the connection pools, ordered by address.
+func (m *Metrics) PoolStats() []PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]PoolStats, 0, len(m.pools))
+	for address, pool := range m.pools {
+		stats = append(stats, PoolStats{
+			Address:     address,
+			InUse:       pool.inUse,
+			Idle:        pool.open - pool.inUse,
+			Waiting:     len(pool.checkouts),
+			Waits:       pool.waits,
+			WaitSeconds: pool.waitSum,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Address < stats[j].Address })
+	return stats
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	namespace := m.Namespace
+	if namespace == "" {
+		namespace = "mongoquerier"
+	}
+	out := &countingWriter{w: bufio.NewWriter(w)}
+
+	keys := m.operationKeys()
+	operationLabels := func(key operationMetricsKey) string {
+		return fmt.Sprintf(`collection="%s",operation="%s"`, escapeLabel(key.collection), escapeLabel(key.operation))
+	}
+
+	name := namespace + "_operations_total"
+	out.header(name, "counter", "Querier operations by collection and operation.")
+	for _, key := range keys {
+		out.printf("%s{%s} %d\n", name, operationLabels(key), m.operations[key].count)
+	}
+
+	name = namespace + "_operation_errors_total"
+	out.header(name, "counter", "Querier operations that returned an error.")
+	for _, key := range keys {
+		out.printf("%s{%s} %d\n", name, operationLabels(key), m.operations[key].errors)
+	}
+
+	name = namespace + "_operation_duration_seconds"
+	out.header(name, "histogram", "Duration of querier operations.")
+	for _, key := range keys {
+		metrics, labels := m.operations[key], operationLabels(key)
+		for i, bound := range m.buckets() {
+			out.printf("%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), metrics.buckets[i])
+		}
+		out.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, metrics.count)
+		out.printf("%s_sum{%s} %s\n", name, labels, formatFloat(metrics.sum))
+		out.printf("%s_count{%s} %d\n", name, labels, metrics.count)
+	}
+
+	addresses := make([]string, 0, len(m.pools))
+	for address := range m.pools {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	name = namespace + "_pool_connections_in_use"
+	out.header(name, "gauge", "Connections checked out of the pool.")
+	for _, address := range addresses {
+		out.printf("%s{address=\"%s\"} %d\n", name, escapeLabel(address), m.pools[address].inUse)
+	}
+
+	name = namespace + "_pool_connections_idle"
+	out.header(name, "gauge", "Open connections waiting in the pool.")
+	for _, address := range addresses {
+		pool := m.pools[address]
+		out.printf("%s{address=\"%s\"} %d\n", name, escapeLabel(address), pool.open-pool.inUse)
+	}
+
+	name = namespace + "_pool_checkouts_waiting"
+	out.header(name, "gauge", "Operations waiting for a connection.")
+	for _, address := range addresses {
+		out.printf("%s{address=\"%s\"} %d\n", name, escapeLabel(address), len(m.pools[address].checkouts))
+	}
+
+	name = namespace + "_pool_wait_seconds"
+	out.header(name, "summary", "Time operations waited for a connection.")
+	for _, address := range addresses {
+		pool := m.pools[address]
+		out.printf("%s_sum{address=\"%s\"} %s\n", name, escapeLabel(address), formatFloat(pool.waitSum))
+		out.printf("%s_count{address=\"%s\"} %d\n", name, escapeLabel(address), pool.waits)
+	}
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name string, kind string, help string) {
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// observeMetrics records an operation in the adapter's Metrics, if any.
+func (q *Querier[Model, IDModel]) observeMetrics(start time.Time, operation string, err error) {
+	if q.MongoAdapter.Metrics == nil {
+		return
+	}
+	q.MongoAdapter.Metrics.ObserveOperation(q.collection.Name(), operation, time.Since(start), err)
+}
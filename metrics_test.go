@@ -0,0 +1,32 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsBucketsChangedAfterFirstOperation(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Buckets = []float64{0.1, 1}
+	metrics.ObserveOperation("orders", "FindByM", 50*time.Millisecond, nil)
+
+	// Later changes are ignored rather than indexing past the histograms
+	metrics.Buckets = []float64{0.01, 0.1, 1, 10}
+	metrics.ObserveOperation("orders", "FindByM", 2*time.Second, errors.New("timeout"))
+
+	var out bytes.Buffer
+	if _, err := metrics.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `le="1"} 1`) || strings.Contains(out.String(), `le="10"`) {
+		t.Errorf("WriteTo() buckets changed after the first operation:\n%s", out.String())
+	}
+
+	stats := metrics.OperationStats()
+	if len(stats) != 1 || stats[0].Count != 2 || stats[0].Errors != 1 || len(stats[0].Bounds) != 2 || stats[0].BucketCounts[0] != 1 {
+		t.Errorf("OperationStats() = %+v", stats)
+	}
+}
@@ -2,55 +2,171 @@ package mongoquerier
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.uber.org/zap"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 type MongoAdapter struct {
-	*zap.Logger
+	Logger
 	Client   *mongo.Client
 	Database string
+
+	// WriteConcern is the default write concern of collections handed out by
+	// the adapter; nil keeps the one configured on the client/URI.
+	WriteConcern *writeconcern.WriteConcern
+	// CriticalWTimeout overrides DefaultCriticalWTimeout for Critical writes.
+	CriticalWTimeout time.Duration
+	// DurabilityPresets map durability classes to write concerns, over the
+	// built-in ones.
+	DurabilityPresets map[Durability]*writeconcern.WriteConcern
+	// Analytics is an optional adapter on a cluster mirroring this one, used
+	// for reads routed with Analytics or Querier.UseAnalytics.
+	Analytics *MongoAdapter
+	// Authorize, when set, is called before every operation of the adapter's
+	// queriers and consoles; a non-nil error denies the operation.
+	Authorize func(ctx context.Context, op OperationDescriptor) error
+	// Latency, when set, records the latency of querier operations per
+	// query shape.
+	Latency *LatencyRecorder
+	// Chaos, when set and enabled, injects latency and errors into querier
+	// operations.
+	Chaos *Chaos
+	// Tracer, when set, starts a span per querier operation.
+	Tracer Tracer
+	// Metrics, when set, counts and times querier operations.
+	Metrics *Metrics
+	// SlowQueryThreshold, when set, logs the querier operations taking
+	// longer at Warn; SetSlowQueryThreshold overrides it at runtime.
+	SlowQueryThreshold time.Duration
+	// RateLimit, when set, rejects the querier operations over its rate with
+	// ErrRateLimited.
+	RateLimit *RateLimiter
+	// Tenants, when set, routes each operation of the adapter's queriers to
+	// the database of the request's tenant instead of Database.
+	Tenants TenantResolver
+
+	piiFields sync.Map // collection name -> map[string]string
+
+	// slowQueryThreshold overrides SlowQueryThreshold when set, see
+	// SetSlowQueryThreshold
+	slowQueryThreshold atomic.Pointer[time.Duration]
+	// strict escalates the index and shard key policies, see SetStrict
+	strict atomic.Bool
+
+	// databaseOptions are the options of the *mongo.Database the adapter
+	// was built from, if any
+	databaseOptions *options.DatabaseOptions
+	// borrowedClient is set on adapters wrapping a client the application
+	// connected, and disconnects, itself
+	borrowedClient bool
+	// operations tracks the operations in flight, for Shutdown
+	operations     *operationTracker
+	operationsOnce sync.Once
+}
+
+// NewMongoAdapter connects to uri and pings the server:
+//
+//	mongoAdapter, err := mongoquerier.NewMongoAdapter(ctx, uri, "shop",
+//		mongoquerier.WithLogger(logger),
+//		mongoquerier.WithMaxPoolSize(200),
+//		mongoquerier.WithAppName("orders-api"),
+//	)
+func NewMongoAdapter(ctx context.Context, uri string, database string, opts ...AdapterOption) (*MongoAdapter, error) {
+	return connectMongoAdapter(ctx, options.Client().ApplyURI(uri), database, opts)
 }
 
-func NewMongoAdapter(ctx context.Context, logger *zap.Logger, uri string, database string) (*MongoAdapter, error) {
+func connectMongoAdapter(ctx context.Context, clientOptions *options.ClientOptions, database string, opts []AdapterOption) (*MongoAdapter, error) {
+	config := newAdapterConfig(opts)
 	// Setting package specific fields for log entry
-	logger = logger.With(zap.String("package", "adapters.MongoAdapter"))
+	logger := config.logger.With(LogField("package", "adapters.MongoAdapter"))
 
-	clientOptions := options.Client().ApplyURI(uri)
+	// A monitor of the options (WithTracing's) joins the one clientOptions
+	// already has (a recording cassette's) instead of replacing it
+	if clientOptions.Monitor != nil && config.clientOptions.Monitor != nil {
+		config.clientOptions.SetMonitor(combineMonitors(clientOptions.Monitor, config.clientOptions.Monitor))
+	}
 
 	// Connect to the MongoDB server
-	client, err := mongo.Connect(ctx, clientOptions)
+	client, err := mongo.Connect(ctx, clientOptions, config.clientOptions)
 	if err != nil {
-		logger.Error("unable to connect to mongo", zap.Error(err))
+		logger.Error("unable to connect to mongo", LogError(err))
 		return nil, err
 	}
 
 	// Ping the MongoDB server to verify that the connection is working
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		logger.Error("unable to ping mongo", zap.Error(err))
-		return nil, err
+	if !config.skipPing {
+		err = client.Ping(ctx, nil)
+		if err != nil {
+			logger.Error("unable to ping mongo", LogError(err))
+			return nil, err
+		}
+		logger.Debug("successfully connected to MongoDB!")
 	}
 
-	logger.Debug("successfully connected to MongoDB!")
-
 	return &MongoAdapter{
 		Logger:   logger,
 		Client:   client,
 		Database: database,
+		Tracer:   config.tracer,
+		Metrics:  config.metrics,
+
+		SlowQueryThreshold: config.slowQueryThreshold,
 	}, nil
 }
 
+// NewMongoAdapterFromClient wraps a client the application already
+// connected, e.g. to share its pool. The adapter doesn't ping it, and
+// Disconnect leaves it connected: its lifecycle stays the application's.
+// A nil logger discards the adapter's logs, like NewMongoAdapter's default.
+func NewMongoAdapterFromClient(logger Logger, client *mongo.Client, database string) *MongoAdapter {
+	if logger == nil {
+		logger = NopLogger()
+	}
+	return &MongoAdapter{
+		Logger:         logger.With(LogField("package", "adapters.MongoAdapter")),
+		Client:         client,
+		Database:       database,
+		borrowedClient: true,
+	}
+}
+
+// NewMongoAdapterFromDatabase is NewMongoAdapterFromClient for a database
+// handle, whose read concern, read preference and write concern the
+// adapter's collections keep.
+func NewMongoAdapterFromDatabase(logger Logger, database *mongo.Database) *MongoAdapter {
+	madp := NewMongoAdapterFromClient(logger, database.Client(), database.Name())
+	madp.databaseOptions = options.Database().
+		SetReadConcern(database.ReadConcern()).
+		SetReadPreference(database.ReadPreference()).
+		SetWriteConcern(database.WriteConcern())
+	return madp
+}
+
 func (madp *MongoAdapter) GetDatabase() *mongo.Database {
-	return madp.Client.Database(madp.Database)
+	opts := []*options.DatabaseOptions{}
+	if madp.databaseOptions != nil {
+		opts = append(opts, madp.databaseOptions)
+	}
+	if madp.WriteConcern != nil {
+		opts = append(opts, options.Database().SetWriteConcern(madp.WriteConcern))
+	}
+	return madp.Client.Database(madp.Database, opts...)
 }
 
 func (madp *MongoAdapter) GetCollection(collection string, opts ...*options.CollectionOptions) *mongo.Collection {
 	return madp.GetDatabase().Collection(collection, opts...)
 }
 
+// Disconnect disconnects the adapter's client, unless the adapter wraps
+// one the application connected.
 func (madp *MongoAdapter) Disconnect(ctx context.Context) error {
+	if madp.borrowedClient {
+		return nil
+	}
 	return madp.Client.Disconnect(ctx)
 }
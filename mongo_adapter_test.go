@@ -0,0 +1,21 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestNewMongoAdapterFromClientWithoutLogger(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	madp := NewMongoAdapterFromClient(nil, client, "test")
+	if madp.Logger == nil {
+		t.Fatal("Logger = nil, want the no-op logger")
+	}
+	madp.Info("logged nowhere")
+}
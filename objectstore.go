@@ -0,0 +1,224 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPartSize is the multipart upload part size when ObjectOptions has
+// none, above the 5 MiB minimum S3 puts on every part but the last.
+const DefaultPartSize = 8 << 20
+
+// abortTimeout bounds aborting a failed multipart upload, which goes on
+// when the upload's context is done so no parts are left billed.
+const abortTimeout = 30 * time.Second
+
+var (
+	ErrObjectClosed     = errors.New("object writer closed")
+	ErrInvalidObjectKey = errors.New("invalid object key")
+)
+
+// ObjectOptions are the options of an object written to an ObjectStore.
+// Stores ignore the options their provider doesn't support.
+type ObjectOptions struct {
+	ContentType string
+	Metadata    map[string]string
+
+	// ServerSideEncryption names the provider's encryption mode, e.g.
+	// "aws:kms" or "AES256" on S3; KMSKeyID is the key to encrypt with.
+	ServerSideEncryption string
+	KMSKeyID             string
+
+	// PartSize is the size of multipart upload parts, DefaultPartSize when
+	// zero.
+	PartSize int64
+}
+
+// ObjectWriter writes an object. The object only becomes visible once Close
+// succeeds; Abort discards what was written.
+type ObjectWriter interface {
+	io.Writer
+	Close() error
+	Abort() error
+}
+
+// ObjectStore is an object storage destination for exports and archives.
+// S3Store and GCSStore upload to S3 and GCS; other providers (Azure
+// Blob...) are implemented over their SDK, those with multipart uploads
+// implementing MultipartUpload and using MultipartStore. LocalStore writes
+// to disk.
+type ObjectStore interface {
+	Create(ctx context.Context, key string, opts ObjectOptions) (ObjectWriter, error)
+}
+
+// Upload writes an object with write, committing it when write succeeds and
+// discarding it otherwise:
+//
+//	err := mongoquerier.Upload(ctx, store, "extracts/orders.ndjson", mongoquerier.ObjectOptions{ServerSideEncryption: "aws:kms"},
+//		func(w io.Writer) error {
+//			_, err := orders.AggregateToWriter(ctx, pipeline, w, mongoquerier.NDJSON())
+//			return err
+//		})
+func Upload(ctx context.Context, store ObjectStore, key string, opts ObjectOptions, write func(w io.Writer) error) error {
+	object, err := store.Create(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+	if err := write(object); err != nil {
+		object.Abort()
+		return err
+	}
+	return object.Close()
+}
+
+// MultipartUpload is an upload in progress on a provider with multipart
+// uploads, e.g. S3's CreateMultipartUpload, UploadPart and
+// CompleteMultipartUpload.
+type MultipartUpload interface {
+	// UploadPart uploads part number (from 1) of the object.
+	UploadPart(ctx context.Context, number int, data []byte) error
+	Complete(ctx context.Context) error
+	Abort(ctx context.Context) error
+}
+
+// MultipartStore is an ObjectStore buffering writes into parts of
+// ObjectOptions.PartSize and uploading them one at a time, so objects of
+// any size are uploaded with bounded memory.
+type MultipartStore struct {
+	// Start starts the upload of an object, passing the options (content
+	// type, encryption...) on to the provider.
+	Start func(ctx context.Context, key string, opts ObjectOptions) (MultipartUpload, error)
+}
+
+func (s MultipartStore) Create(ctx context.Context, key string, opts ObjectOptions) (ObjectWriter, error) {
+	upload, err := s.Start(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	return &multipartWriter{ctx: ctx, upload: upload, buffer: make([]byte, 0, partSize)}, nil
+}
+
+type multipartWriter struct {
+	ctx    context.Context
+	upload MultipartUpload
+	buffer []byte
+	parts  int
+	closed bool
+}
+
+func (w *multipartWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrObjectClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buffer[len(w.buffer):cap(w.buffer)], p)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		written += n
+		p = p[n:]
+
+		if len(w.buffer) == cap(w.buffer) {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *multipartWriter) flush() error {
+	w.parts++
+	if err := w.upload.UploadPart(w.ctx, w.parts, w.buffer); err != nil {
+		return err
+	}
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+func (w *multipartWriter) Close() error {
+	if w.closed {
+		return ErrObjectClosed
+	}
+	w.closed = true
+
+	// An empty object still takes one (empty) part
+	if len(w.buffer) > 0 || w.parts == 0 {
+		if err := w.flush(); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	if err := w.upload.Complete(w.ctx); err != nil {
+		w.abort()
+		return err
+	}
+	return nil
+}
+
+// abort discards the parts of a failed upload, even once w.ctx is done.
+func (w *multipartWriter) abort() {
+	ctx, cancel := context.WithTimeout(detachedContext{w.ctx}, abortTimeout)
+	defer cancel()
+	w.upload.Abort(ctx)
+}
+
+func (w *multipartWriter) Abort() error {
+	if w.closed {
+		return ErrObjectClosed
+	}
+	w.closed = true
+	return w.upload.Abort(w.ctx)
+}
+
+// LocalStore writes objects as files under Dir, keys being slash-separated
+// paths. Objects are written to a temporary file and renamed into place on
+// Close. Keys resolving outside Dir (absolute, or climbing out with "..")
+// fail with ErrInvalidObjectKey.
+type LocalStore struct {
+	Dir string
+}
+
+func (s LocalStore) Create(ctx context.Context, key string, opts ObjectOptions) (ObjectWriter, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return nil, fmt.Errorf("%w: %q is outside the store", ErrInvalidObjectKey, key)
+	}
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &localObject{File: file, path: path}, nil
+}
+
+type localObject struct {
+	*os.File
+	path string
+}
+
+func (o *localObject) Close() error {
+	if err := o.File.Close(); err != nil {
+		os.Remove(o.File.Name())
+		return err
+	}
+	return os.Rename(o.File.Name(), o.path)
+}
+
+func (o *localObject) Abort() error {
+	o.File.Close()
+	return os.Remove(o.File.Name())
+}
@@ -0,0 +1,559 @@
+package mongoquerier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+var (
+	ErrWriteQueued   = errors.New("write queued for replay after outage")
+	ErrNotIdempotent = errors.New("write can't be replayed safely")
+)
+
+// QueuedWriteError is returned by writes captured by the offline queue. It
+// matches ErrWriteQueued and unwraps to the outage error.
+type QueuedWriteError struct {
+	// Seq is the sequence number of the last write queued, that of the
+	// last document when several were.
+	Seq uint64
+	Err error
+}
+
+func (e *QueuedWriteError) Error() string {
+	return fmt.Sprintf("%s (seq %d): %v", ErrWriteQueued, e.Seq, e.Err)
+}
+
+func (e *QueuedWriteError) Is(target error) bool {
+	return target == ErrWriteQueued
+}
+
+func (e *QueuedWriteError) Unwrap() error {
+	return e.Err
+}
+
+// Operations recorded in the offline queue.
+const (
+	QueuedInsertOne  = "insertOne"
+	QueuedUpdateOne  = "updateOne"
+	QueuedUpdateMany = "updateMany"
+	QueuedReplaceOne = "replaceOne"
+	QueuedDeleteOne  = "deleteOne"
+	QueuedDeleteMany = "deleteMany"
+)
+
+// idempotentOperators are the update operators applying the same change
+// twice leaves as once. An outage can hide whether a write reached the
+// server, so only writes of these are replayed.
+var idempotentOperators = map[string]bool{
+	"$set":         true,
+	"$unset":       true,
+	"$setOnInsert": true,
+	"$min":         true,
+	"$max":         true,
+	"$addToSet":    true,
+	"$pull":        true,
+	"$pullAll":     true,
+}
+
+type QueuedWrite struct {
+	Seq uint64
+	// Database is the database the write was meant for, the tenant's when
+	// the adapter routes tenants; empty replays it in the adapter's.
+	Database   string
+	Collection string
+	Operation  string
+	Filter     interface{}
+	Document   interface{}
+	EnqueuedAt time.Time
+}
+
+// queuedWriteRecord is the on-disk form of a QueuedWrite, with BSON values
+// kept as canonical extended JSON so their types survive the round trip.
+type queuedWriteRecord struct {
+	Seq        uint64          `json:"seq"`
+	Database   string          `json:"database,omitempty"`
+	Collection string          `json:"collection"`
+	Operation  string          `json:"operation"`
+	Filter     json.RawMessage `json:"filter,omitempty"`
+	Document   json.RawMessage `json:"document,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+}
+
+// deadLetterRecord is a queued write the server rejected on replay, with the
+// reason, as written to the dead-letter file.
+type deadLetterRecord struct {
+	queuedWriteRecord
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type ConflictPolicy int
+
+const (
+	// ConflictSkip drops writes that conflict on replay (duplicate inserts,
+	// updates matching nothing) and carries on.
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite turns duplicate inserts into replacements by _id.
+	ConflictOverwrite
+	// ConflictStop halts the replay at the first conflict, keeping it and
+	// every later write queued.
+	ConflictStop
+)
+
+type ReplayReport struct {
+	Applied int
+	Skipped int
+	// DeadLettered counts the writes the server rejected, moved to the
+	// dead-letter file.
+	DeadLettered int
+	Remaining    int
+}
+
+// OfflineQueue is a file-backed journal of writes that failed because the
+// cluster was unreachable. Writes are replayed in their original order once
+// connectivity returns.
+//
+// A write failing on an outage may still have been applied, so only writes
+// that can be applied twice are queued: inserts with an _id, replacements,
+// deletes, and updates using only $set, $unset, $setOnInsert, $min, $max,
+// $addToSet, $pull and $pullAll. Others, e.g. $inc or $push, fail with the
+// outage error.
+//
+// Writes the server rejects on replay, e.g. failing validation or a unique
+// index, would fail again on every replay; they're moved to a dead-letter
+// file for inspection and the replay carries on.
+type OfflineQueue struct {
+	*MongoAdapter
+	Path   string
+	Policy ConflictPolicy
+	// DeadLetterPath is the NDJSON file rejected writes are appended to,
+	// with their error; Path + ".dead" when empty.
+	DeadLetterPath string
+
+	mu      sync.Mutex
+	lastSeq uint64
+}
+
+func OpenOfflineQueue(madp *MongoAdapter, path string) (*OfflineQueue, error) {
+	oq := &OfflineQueue{MongoAdapter: madp, Path: path}
+
+	records, err := oq.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		oq.lastSeq = records[len(records)-1].Seq
+	}
+	return oq, nil
+}
+
+// IsOutage reports whether err means the cluster couldn't be reached, as
+// opposed to the write being rejected.
+func IsOutage(err error) bool {
+	return mongo.IsNetworkError(err) || errors.Is(err, topology.ErrServerSelectionTimeout)
+}
+
+// Enqueue journals write, failing with ErrNotIdempotent when replaying it
+// could apply it twice.
+func (oq *OfflineQueue) Enqueue(write QueuedWrite) (uint64, error) {
+	seqs, err := oq.EnqueueAll(write)
+	if err != nil {
+		return 0, err
+	}
+	return seqs[0], nil
+}
+
+// EnqueueAll journals writes, all of them or none: it fails with
+// ErrNotIdempotent, queuing nothing, when replaying any could apply it twice,
+// and a failed append leaves the journal as it was.
+func (oq *OfflineQueue) EnqueueAll(writes ...QueuedWrite) ([]uint64, error) {
+	for _, write := range writes {
+		if err := checkIdempotent(write.Operation, write.Document); err != nil {
+			return nil, err
+		}
+	}
+
+	oq.mu.Lock()
+	defer oq.mu.Unlock()
+
+	seqs := make([]uint64, len(writes))
+	var lines []byte
+	for i, write := range writes {
+		write.Seq = oq.lastSeq + uint64(i) + 1
+		if write.EnqueuedAt.IsZero() {
+			write.EnqueuedAt = time.Now().UTC()
+		}
+
+		record, err := encodeQueuedWrite(write)
+		if err != nil {
+			return nil, err
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, line...), '\n')
+		seqs[i] = write.Seq
+	}
+
+	file, err := os.OpenFile(oq.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = file.Write(lines)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		// A partial append would replay some of the writes, or leave a
+		// truncated line load can't read
+		if truncateErr := file.Truncate(info.Size()); truncateErr != nil {
+			oq.MongoAdapter.Error("unable to roll back offline queue append", LogField("path", oq.Path), LogError(truncateErr))
+		}
+		return nil, err
+	}
+	oq.lastSeq += uint64(len(writes))
+
+	for i, write := range writes {
+		oq.MongoAdapter.Warn(
+			"Queued write during outage",
+			LogField("collection_name", write.Collection),
+			LogField("operation", write.Operation),
+			LogField("seq", seqs[i]),
+		)
+	}
+	return seqs, nil
+}
+
+// checkIdempotent checks that the write of document by operation can be
+// replayed after it was applied: an insert must carry its _id, else the
+// replay inserts a copy, and an update must only use idempotentOperators.
+func checkIdempotent(operation string, document interface{}) error {
+	if operation != QueuedInsertOne && operation != QueuedUpdateOne && operation != QueuedUpdateMany {
+		return nil
+	}
+
+	// Update pipelines don't marshal as a document, and aren't idempotent
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("%w: %s of %T", ErrNotIdempotent, operation, document)
+	}
+	if operation == QueuedInsertOne {
+		if id, err := bson.Raw(raw).LookupErr("_id"); err != nil || id.Type == bson.TypeNull {
+			return fmt.Errorf("%w: %s without an _id", ErrNotIdempotent, operation)
+		}
+		return nil
+	}
+
+	elements, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		if !idempotentOperators[element.Key()] {
+			return fmt.Errorf("%w: %s with %s", ErrNotIdempotent, operation, element.Key())
+		}
+	}
+	return nil
+}
+
+func encodeQueuedWrite(write QueuedWrite) (queuedWriteRecord, error) {
+	record := queuedWriteRecord{
+		Seq:        write.Seq,
+		Database:   write.Database,
+		Collection: write.Collection,
+		Operation:  write.Operation,
+		EnqueuedAt: write.EnqueuedAt,
+	}
+
+	var err error
+	if write.Filter != nil {
+		if record.Filter, err = bson.MarshalExtJSON(write.Filter, true, false); err != nil {
+			return record, err
+		}
+	}
+	if write.Document != nil {
+		if record.Document, err = bson.MarshalExtJSON(write.Document, true, false); err != nil {
+			return record, err
+		}
+	}
+	return record, nil
+}
+
+func (oq *OfflineQueue) load() ([]queuedWriteRecord, error) {
+	file, err := os.Open(oq.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []queuedWriteRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), MaxBSONDocumentSize*2)
+	for scanner.Scan() {
+		var record queuedWriteRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// store atomically replaces the journal with records.
+func (oq *OfflineQueue) store(records []queuedWriteRecord) error {
+	tmpPath := oq.Path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, oq.Path)
+}
+
+func (oq *OfflineQueue) Len() (int, error) {
+	oq.mu.Lock()
+	defer oq.mu.Unlock()
+
+	records, err := oq.load()
+	return len(records), err
+}
+
+// Replay applies queued writes in order. It stops at the first transient
+// error, such as an outage (or conflict, under ConflictStop), and keeps the
+// unapplied writes queued. Writes failing otherwise are dead-lettered.
+func (oq *OfflineQueue) Replay(ctx context.Context) (ReplayReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	oq.mu.Lock()
+	defer oq.mu.Unlock()
+
+	var report ReplayReport
+	records, err := oq.load()
+	if err != nil {
+		return report, err
+	}
+
+	var replayErr error
+	processed := 0
+	for _, record := range records {
+		conflict, err := oq.apply(ctx, record)
+		if err != nil && isPermanent(ctx, err) {
+			if err = oq.deadLetter(record, err); err == nil {
+				report.DeadLettered++
+				processed++
+				continue
+			}
+		}
+		if err != nil {
+			replayErr = err
+			break
+		}
+		if conflict {
+			if oq.Policy == ConflictStop {
+				replayErr = fmt.Errorf("replay stopped at conflicting %s on %s (seq %d)", record.Operation, record.Collection, record.Seq)
+				break
+			}
+			report.Skipped++
+		} else {
+			report.Applied++
+		}
+		processed++
+	}
+
+	remaining := records[processed:]
+	report.Remaining = len(remaining)
+	if err := oq.store(remaining); err != nil {
+		return report, err
+	}
+
+	oq.MongoAdapter.Info(
+		"Replayed queued writes",
+		LogField("writes_applied", report.Applied),
+		LogField("writes_skipped", report.Skipped),
+		LogField("writes_dead_lettered", report.DeadLettered),
+		LogField("writes_remaining", report.Remaining),
+	)
+	return report, replayErr
+}
+
+// isPermanent reports whether a replayed write failing with err would fail
+// again however often it's retried.
+func isPermanent(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !IsTransient(err) && !mongo.IsTimeout(err)
+}
+
+// deadLetter appends record, rejected with cause, to the dead-letter file.
+func (oq *OfflineQueue) deadLetter(record queuedWriteRecord, cause error) error {
+	path := oq.DeadLetterPath
+	if path == "" {
+		path = oq.Path + ".dead"
+	}
+	line, err := json.Marshal(deadLetterRecord{queuedWriteRecord: record, Error: cause.Error(), FailedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+
+	oq.MongoAdapter.Error(
+		"Dead-lettered queued write",
+		LogField("collection_name", record.Collection),
+		LogField("operation", record.Operation),
+		LogField("seq", record.Seq),
+		LogError(cause),
+	)
+	return nil
+}
+
+// apply runs a single queued write, reporting whether it conflicted.
+func (oq *OfflineQueue) apply(ctx context.Context, record queuedWriteRecord) (bool, error) {
+	// Documents keep their field order, bson.M would shuffle it
+	var filter bson.M
+	var document bson.D
+	if len(record.Filter) > 0 {
+		if err := bson.UnmarshalExtJSON(record.Filter, true, &filter); err != nil {
+			return false, err
+		}
+	}
+	if len(record.Document) > 0 {
+		if err := bson.UnmarshalExtJSON(record.Document, true, &document); err != nil {
+			return false, err
+		}
+	}
+
+	collection := oq.MongoAdapter.GetCollection(record.Collection)
+	if record.Database != "" {
+		collection = oq.MongoAdapter.GetCollectionIn(record.Database, record.Collection)
+	}
+	switch record.Operation {
+	case QueuedInsertOne:
+		_, err := collection.InsertOne(ctx, document)
+		if mongo.IsDuplicateKeyError(err) {
+			if oq.Policy != ConflictOverwrite {
+				return true, nil
+			}
+			for _, e := range document {
+				if e.Key == "_id" {
+					_, err = collection.ReplaceOne(ctx, bson.M{"_id": e.Value}, document)
+					break
+				}
+			}
+		}
+		return false, err
+	case QueuedUpdateOne, QueuedUpdateMany, QueuedReplaceOne:
+		var res *mongo.UpdateResult
+		var err error
+		switch record.Operation {
+		case QueuedUpdateOne:
+			res, err = collection.UpdateOne(ctx, filter, document)
+		case QueuedUpdateMany:
+			res, err = collection.UpdateMany(ctx, filter, document)
+		default:
+			res, err = collection.ReplaceOne(ctx, filter, document, options.Replace())
+		}
+		if err != nil {
+			return false, err
+		}
+		return res.MatchedCount == 0, nil
+	case QueuedDeleteOne, QueuedDeleteMany:
+		var res *mongo.DeleteResult
+		var err error
+		if record.Operation == QueuedDeleteOne {
+			res, err = collection.DeleteOne(ctx, filter)
+		} else {
+			res, err = collection.DeleteMany(ctx, filter)
+		}
+		if err != nil {
+			return false, err
+		}
+		return res.DeletedCount == 0, nil
+	}
+	return false, fmt.Errorf("unknown queued operation %q", record.Operation)
+}
+
+// queueOnOutage captures a failed write in the querier's offline queue when
+// the failure is an outage, returning the error the caller should see. Each
+// document is queued as its own write, a write without documents once; all
+// of them are queued or none, and none unless all can be replayed safely.
+// Writes of a transaction aren't queued, since it's aborted as a whole.
+func (q *Querier[Model, IDModel]) queueOnOutage(ctx context.Context, err error, operation string, filter interface{}, documents ...interface{}) error {
+	// A canceled write's network error isn't an outage
+	if q.OfflineQueue == nil || !IsOutage(err) || inTransaction(ctx) || ctx.Err() != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		documents = []interface{}{nil}
+	}
+
+	writes := make([]QueuedWrite, len(documents))
+	for i, document := range documents {
+		writes[i] = QueuedWrite{
+			Database:   q.tenantCollection(ctx).Database().Name(),
+			Collection: q.collection.Name(),
+			Operation:  operation,
+			Filter:     filter,
+			Document:   document,
+		}
+	}
+	seqs, queueErr := q.OfflineQueue.EnqueueAll(writes...)
+	if errors.Is(queueErr, ErrNotIdempotent) {
+		q.MongoAdapter.Warn("Not queuing write", LogField("collection_name", q.collection.Name()), LogError(queueErr))
+		return err
+	}
+	if queueErr != nil {
+		q.MongoAdapter.Error("unable to queue write", LogField("collection_name", q.collection.Name()), LogError(queueErr))
+		return err
+	}
+	return &QueuedWriteError{Seq: seqs[len(seqs)-1], Err: err}
+}
@@ -0,0 +1,117 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestCheckIdempotent(t *testing.T) {
+	tests := []struct {
+		operation string
+		document  interface{}
+		want      error
+	}{
+		{QueuedInsertOne, bson.M{"_id": 1, "email": "a@b.c"}, nil},
+		{QueuedInsertOne, bson.M{"email": "a@b.c"}, ErrNotIdempotent},
+		{QueuedInsertOne, bson.D{{Key: "_id", Value: nil}}, ErrNotIdempotent},
+		{QueuedUpdateOne, bson.M{"$set": bson.M{"name": "a"}, "$addToSet": bson.M{"tags": "b"}}, nil},
+		{QueuedUpdateOne, bson.M{"$inc": bson.M{"visits": 1}}, ErrNotIdempotent},
+		{QueuedUpdateMany, bson.D{{Key: "$set", Value: bson.M{"a": 1}}, {Key: "$push", Value: bson.M{"log": "b"}}}, ErrNotIdempotent},
+		{QueuedUpdateMany, bson.A{bson.M{"$set": bson.M{"a": 1}}}, ErrNotIdempotent},
+		{QueuedReplaceOne, bson.M{"name": "a"}, nil},
+		{QueuedDeleteMany, nil, nil},
+	}
+	for _, test := range tests {
+		if err := checkIdempotent(test.operation, test.document); !errors.Is(err, test.want) {
+			t.Errorf("checkIdempotent(%s, %v) = %v, want %v", test.operation, test.document, err, test.want)
+		}
+	}
+}
+
+func TestOfflineQueueSkipsNonIdempotentWrites(t *testing.T) {
+	madp := newTestAdapter(t)
+	oq, err := OpenOfflineQueue(madp, filepath.Join(t.TempDir(), "queue.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	q.OfflineQueue = oq
+
+	outage := topology.ErrServerSelectionTimeout
+	err = q.queueOnOutage(context.Background(), outage, QueuedUpdateOne, bson.M{"_id": 1}, bson.M{"$inc": bson.M{"visits": 1}})
+	if errors.Is(err, ErrWriteQueued) || !errors.Is(err, outage) {
+		t.Errorf("queueOnOutage($inc) = %v, want the outage error", err)
+	}
+	if _, err := oq.Enqueue(QueuedWrite{Collection: "nodes", Operation: QueuedUpdateOne, Document: bson.M{"$push": bson.M{"log": "a"}}}); !errors.Is(err, ErrNotIdempotent) {
+		t.Errorf("Enqueue($push) = %v, want ErrNotIdempotent", err)
+	}
+	if n, err := oq.Len(); err != nil || n != 0 {
+		t.Errorf("Len() = %d, %v, want 0", n, err)
+	}
+
+	err = q.queueOnOutage(context.Background(), outage, QueuedUpdateOne, bson.M{"_id": 1}, bson.M{"$set": bson.M{"name": "a"}})
+	if !errors.Is(err, ErrWriteQueued) {
+		t.Errorf("queueOnOutage($set) = %v, want ErrWriteQueued", err)
+	}
+}
+
+func TestOfflineQueueEnqueuesAllOrNone(t *testing.T) {
+	madp := newTestAdapter(t)
+	oq, err := OpenOfflineQueue(madp, filepath.Join(t.TempDir(), "queue.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	q.OfflineQueue = oq
+
+	// The second document has no _id, so none of them is queued
+	outage := topology.ErrServerSelectionTimeout
+	err = q.queueOnOutage(context.Background(), outage, QueuedInsertOne, nil, bson.M{"_id": 1}, bson.M{"email": "a@b.c"}, bson.M{"_id": 3})
+	if errors.Is(err, ErrWriteQueued) {
+		t.Errorf("queueOnOutage() = %v, want the outage error", err)
+	}
+	if n, err := oq.Len(); err != nil || n != 0 {
+		t.Errorf("Len() = %d, %v, want 0", n, err)
+	}
+
+	seqs, err := oq.EnqueueAll(
+		QueuedWrite{Collection: "nodes", Operation: QueuedInsertOne, Document: bson.M{"_id": 1}},
+		QueuedWrite{Collection: "nodes", Operation: QueuedInsertOne, Document: bson.M{"_id": 2}},
+	)
+	if err != nil || len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 2 {
+		t.Errorf("EnqueueAll() = %v, %v, want [1 2]", seqs, err)
+	}
+}
+
+func TestOfflineQueueDeadLettersRejectedWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.ndjson")
+	oq, err := OpenOfflineQueue(newTestAdapter(t), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A write the replay can't apply, however often it's retried
+	if err := oq.store([]queuedWriteRecord{{Seq: 1, Collection: "nodes", Operation: "renameOne"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := oq.Replay(context.Background())
+	if err != nil {
+		t.Fatalf("Replay() = %v, want nil", err)
+	}
+	if report.DeadLettered != 1 || report.Remaining != 0 {
+		t.Errorf("Replay() report = %+v, want one dead-lettered write and none remaining", report)
+	}
+
+	dead, err := (&OfflineQueue{Path: path + ".dead"}).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Seq != 1 {
+		t.Errorf("dead letters = %+v, want seq 1", dead)
+	}
+}
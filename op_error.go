@@ -0,0 +1,118 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no document matches a single-document
+// operation, e.g. FindOne, UpdateOne or DeleteOne. It wraps
+// mongo.ErrNoDocuments, which errors.Is still matches:
+//
+//	order, err := orders.FindOneByM(ctx, bson.M{"_id": id})
+//	if errors.Is(err, mongoquerier.ErrNotFound) {
+//		return http.StatusNotFound
+//	}
+var ErrNotFound = errors.New("document not found")
+
+// notFound wraps mongo.ErrNoDocuments in ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
+// OpError attributes an error to the querier operation that returned it.
+// The underlying error stays reachable, so errors.Is(err, ErrNotFound),
+// errors.As(err, &conflict) (see ConflictError) and the driver's helpers keep
+// working:
+//
+//	var opErr *mongoquerier.OpError
+//	if errors.As(err, &opErr) {
+//		log.Printf("%s on %s failed after %s", opErr.Operation, opErr.Collection, opErr.Duration)
+//	}
+type OpError struct {
+	Collection string
+	Operation  string
+	// FilterShape is the filter with its values elided (see QueryShape).
+	FilterShape string
+	// Filter is a copy of the filter as of the failure, with the values of
+	// the model's PII fields redacted (see RedactPII), for error reports.
+	// It's left out of Error, which only shows the shape.
+	Filter   primitive.M
+	Duration time.Duration
+	Err      error
+}
+
+// QuerierError is OpError, under the name error reporting integrations
+// look for.
+type QuerierError = OpError
+
+func (e *OpError) Error() string {
+	if e.FilterShape == "" {
+		return fmt.Sprintf("%s.%s (%s): %v", e.Collection, e.Operation, e.Duration, e.Err)
+	}
+	return fmt.Sprintf("%s.%s %s (%s): %v", e.Collection, e.Operation, e.FilterShape, e.Duration, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// opError wraps err in an OpError unless it's nil or already attributed to
+// an operation, e.g. one calling another.
+func (q *Querier[Model, IDModel]) opError(err error, start time.Time, operation string, filter primitive.M) error {
+	if err == nil {
+		return nil
+	}
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		return err
+	}
+
+	var shape string
+	var snapshot primitive.M
+	if filter != nil {
+		shape = QueryShape(filter)
+		// redactM copies the filter, whose maps the caller may reuse
+		snapshot = redactM(filter, piiFieldsFor(modelType[Model]()), "")
+	}
+	return &OpError{
+		Collection:  q.collection.Name(),
+		Operation:   operation,
+		FilterShape: shape,
+		Filter:      snapshot,
+		Duration:    time.Since(start),
+		Err:         conflictError(notFound(err)),
+	}
+}
+
+// observe records the latency and metrics of an operation started at start,
+// attributes the error it returns and ends its span and its tracking as an
+// operation in flight:
+//
+//	ctx, span := q.startOperation(ctx, "FindByM", filter)
+//	defer q.observe(span, time.Now(), "FindByM", filter, &err)
+func (q *Querier[Model, IDModel]) observe(span Span, start time.Time, operation string, filter primitive.M, err *error) {
+	q.observeLatency(start, operation, filter)
+	q.logSlowQuery(start, operation, filter)
+	*err = q.opError(*err, start, operation, filter)
+	q.observeMetrics(start, operation, *err)
+	endSpan(span, *err)
+	q.MongoAdapter.inFlight().end()
+}
+
+// structToM is StructToM for a model argument of operation, attributing a
+// failure to the operation like the errors it returns later.
+func (q *Querier[Model, IDModel]) structToM(operation string, model Model) (primitive.M, error) {
+	m, err := StructToM(model)
+	if err != nil {
+		return nil, q.opError(err, time.Now(), operation, nil)
+	}
+	return m, nil
+}
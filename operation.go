@@ -0,0 +1,53 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// preflight runs the checks every operation goes through before it reaches
+// the server. filter is nil for operations without one, such as inserts.
+// Rejections are returned as an OpError. An operation passing them is
+// admitted in flight, for Shutdown to drain, until observe ends it.
+func (q *Querier[Model, IDModel]) preflight(ctx context.Context, operation string, filter primitive.M) error {
+	start := time.Now()
+	if err := q.checkOperation(ctx, operation, filter); err != nil {
+		return err
+	}
+	if err := q.MongoAdapter.RateLimit.admit(); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	// Admitted last, leaving nothing to end when rejected
+	return q.opError(q.MongoAdapter.inFlight().begin(), start, operation, filter)
+}
+
+// checkOperation runs preflight's checks without admitting the operation,
+// for the filters of one already admitted, such as a BulkWrite's models.
+func (q *Querier[Model, IDModel]) checkOperation(ctx context.Context, operation string, filter primitive.M) error {
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	if _, err := q.MongoAdapter.tenantDatabase(ctx); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	descriptor := describeOperation(q.collection.Name(), operation, filter)
+	if err := q.MongoAdapter.authorize(ctx, descriptor); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	if err := q.checkAllowlist(ctx, operation, filter); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	if err := q.checkEncryptedFilter(filter); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	if err := q.checkIndexPolicy(ctx, operation, filter); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	if err := q.checkShardKeyPolicy(ctx, operation, filter); err != nil {
+		return q.opError(err, start, operation, filter)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidPageSize = errors.New("page size must be positive")
+
+// Page is one page of an offset-paginated query. Pages are numbered from 1.
+type Page[Model any] struct {
+	Documents  []*Model
+	Page       int
+	PageSize   int
+	TotalCount int64
+	TotalPages int
+}
+
+func (q *Querier[Model, IDModel]) FindPage(ctx context.Context, filter Model, page int, pageSize int, sort ...primitive.E) (*Page[Model], error) {
+	filterM, err := q.structToM("FindPage", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindPageByM(ctx, filterM, page, pageSize, sort...)
+}
+
+// FindPageByM returns the page-th page (from 1) of pageSize documents
+// matching filter in sort order, along with the total number of matching
+// documents, counted concurrently:
+//
+//	page, err := querier.FindPageByM(ctx, bson.M{"status": "active"}, 2, 20,
+//		bson.E{Key: "created_at", Value: -1},
+//	)
+//
+// Without a sort the order, and so the pages, are unspecified.
+func (q *Querier[Model, IDModel]) FindPageByM(ctx context.Context, filter primitive.M, page int, pageSize int, sort ...primitive.E) (result *Page[Model], err error) {
+	if err = q.preflight(ctx, "FindPageByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindPageByM", filter)
+	defer q.observe(span, time.Now(), "FindPageByM", filter, &err)
+
+	if filter == nil {
+		filter = primitive.M{}
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		return nil, ErrInvalidPageSize
+	}
+
+	opts := options.Find().
+		SetSkip(int64(page-1) * int64(pageSize)).
+		SetLimit(int64(pageSize))
+	if len(sort) > 0 {
+		opts.SetSort(bson.D(sort))
+	}
+
+	type countResult struct {
+		count int64
+		err   error
+	}
+	counted := make(chan countResult, 1)
+	count := func() {
+		count, err := q.countDocuments(ctx, filter)
+		counted <- countResult{count, err}
+	}
+	// A session can't run operations concurrently
+	if inSession(ctx) {
+		count()
+	} else {
+		go count()
+	}
+
+	cursor, err := retrying(ctx, q, "FindPageByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "FindPageByM", []*options.FindOptions{opts})...)
+	})
+	if err != nil {
+		<-counted
+		return nil, err
+	}
+	documents, err := q.decodeCursor(ctx, cursor)
+	total := <-counted
+	if err != nil {
+		return nil, err
+	}
+	if total.err != nil {
+		return nil, total.err
+	}
+
+	result = &Page[Model]{
+		Documents:  documents,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalCount: total.count,
+		TotalPages: int((total.count + int64(pageSize) - 1) / int64(pageSize)),
+	}
+
+	q.MongoAdapter.Debug(
+		"Found a page of documents",
+		LogField("collection_name", q.collection.Name()),
+		LogField("page", page),
+		LogField("documents_count", len(documents)),
+		LogField("total_count", total.count),
+	)
+	return result, nil
+}
+
+func (q *Querier[Model, IDModel]) FindRecentPage(ctx context.Context, filter Model, timeField string, page int, pageSize int) (*Page[Model], error) {
+	filterM, err := q.structToM("FindRecentPage", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindRecentPageByM(ctx, filterM, timeField, page, pageSize)
+}
+
+// FindRecentPageByM returns the page-th page (from 1) of pageSize documents
+// matching filter, most recent first by timeField (then _id), with the total
+// number of matching documents, for feeds and comment threads:
+//
+//	comments, err := querier.FindRecentPageByM(ctx, bson.M{"post_id": postID}, "created_at", 1, 20)
+//
+// Unlike FindPageByM it's a single aggregation, sorting then splitting the
+// matches with $facet between the page and the count. An index on the
+// filter's fields followed by timeField (descending) keeps the sort out of
+// memory.
+func (q *Querier[Model, IDModel]) FindRecentPageByM(ctx context.Context, filter primitive.M, timeField string, page int, pageSize int) (result *Page[Model], err error) {
+	if err = q.preflight(ctx, "FindRecentPageByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindRecentPageByM", filter)
+	defer q.observe(span, time.Now(), "FindRecentPageByM", filter, &err)
+
+	if filter == nil {
+		filter = primitive.M{}
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		return nil, ErrInvalidPageSize
+	}
+
+	// Sorting before $facet, whose sub-pipelines can't use indexes
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: timeField, Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$facet", Value: bson.M{
+			"documents": bson.A{
+				bson.M{"$skip": int64(page-1) * int64(pageSize)},
+				bson.M{"$limit": pageSize},
+			},
+			"total": bson.A{bson.M{"$count": "n"}},
+		}}},
+	}
+	facets, err := retrying(ctx, q, "FindRecentPageByM", func() ([]recentPageFacets, error) {
+		cursor, err := q.readCollection(ctx).Aggregate(ctx, pipeline, q.aggregateOptions(ctx, "FindRecentPageByM", nil)...)
+		if err != nil {
+			return nil, err
+		}
+		var facets []recentPageFacets
+		err = cursor.All(ctx, &facets)
+		return facets, err
+	})
+	if err != nil {
+		return nil, mapPipelineError(pipeline, err)
+	}
+
+	result = &Page[Model]{Page: page, PageSize: pageSize}
+	if len(facets) > 0 {
+		for _, raw := range facets[0].Documents {
+			document, err := q.decode(ctx, raw)
+			if skipUndecodable(ctx, err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			result.Documents = append(result.Documents, document)
+		}
+		if len(facets[0].Total) > 0 {
+			result.TotalCount = facets[0].Total[0].N
+		}
+	}
+	result.TotalPages = int((result.TotalCount + int64(pageSize) - 1) / int64(pageSize))
+
+	q.MongoAdapter.Debug(
+		"Found a page of recent documents",
+		LogField("collection_name", q.collection.Name()),
+		LogField("page", page),
+		LogField("documents_count", len(result.Documents)),
+		LogField("total_count", result.TotalCount),
+	)
+	return result, nil
+}
+
+type recentPageFacets struct {
+	Documents []bson.Raw `bson:"documents"`
+	Total     []struct {
+		N int64 `bson:"n"`
+	} `bson:"total"`
+}
@@ -0,0 +1,479 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNoPartition           = errors.New("document has no partition")
+	ErrInvalidPartitionCount = errors.New("partition count must be positive")
+)
+
+// Partitioner maps documents and filters onto partition collections.
+type Partitioner[Model any] interface {
+	// Partition returns the collection a document is written to.
+	Partition(document Model) (string, error)
+	// Partitions returns the collections a filter can match. A nil result
+	// means the filter can't be narrowed and every partition is read.
+	Partitions(filter primitive.M) ([]string, error)
+	// Pattern returns an anchored regular expression matching the names of
+	// the partitioner's collections and no others, such as orders_archive
+	// next to orders_<n>, which filters that can't be narrowed must not reach.
+	Pattern() string
+}
+
+// MonthlyPartitioner stores documents in one collection per calendar month,
+// named <Base>_<yyyy>_<mm>.
+type MonthlyPartitioner[Model any] struct {
+	Base      string
+	TimeField string
+	TimeOf    func(document Model) time.Time
+}
+
+func (p MonthlyPartitioner[Model]) name(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%s_%04d_%02d", p.Base, t.Year(), int(t.Month()))
+}
+
+func (p MonthlyPartitioner[Model]) Partition(document Model) (string, error) {
+	t := p.TimeOf(document)
+	if t.IsZero() {
+		return "", ErrNoPartition
+	}
+	return p.name(t), nil
+}
+
+func (p MonthlyPartitioner[Model]) Partitions(filter primitive.M) ([]string, error) {
+	var from, to time.Time
+	switch value := filter[p.TimeField].(type) {
+	case time.Time:
+		return []string{p.name(value)}, nil
+	case primitive.DateTime:
+		return []string{p.name(value.Time())}, nil
+	case primitive.M:
+		from, _ = firstTime(value, "$gte", "$gt")
+		to, _ = firstTime(value, "$lte", "$lt")
+	}
+
+	// Open ranges could touch any month
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return nil, nil
+	}
+
+	var names []string
+	month := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		names = append(names, p.name(month))
+		month = month.AddDate(0, 1, 0)
+	}
+	return names, nil
+}
+
+func (p MonthlyPartitioner[Model]) Pattern() string {
+	return "^" + regexp.QuoteMeta(p.Base) + `_\d{4}_\d{2}$`
+}
+
+func firstTime(m primitive.M, operators ...string) (time.Time, bool) {
+	for _, operator := range operators {
+		switch t := m[operator].(type) {
+		case time.Time:
+			return t, true
+		case primitive.DateTime:
+			return t.Time(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// HashPartitioner spreads documents over Count collections named
+// <Base>_<n> by the FNV hash of a string key. Build it with
+// NewHashPartitioner, which checks Count.
+type HashPartitioner[Model any] struct {
+	Base     string
+	Count    int
+	KeyField string
+	KeyOf    func(document Model) string
+}
+
+// NewHashPartitioner returns a partitioner over count collections, failing
+// with ErrInvalidPartitionCount unless count is positive:
+//
+//	partitioner, err := mongoquerier.NewHashPartitioner("events", 16, "tenant", func(e Event) string { return e.Tenant })
+func NewHashPartitioner[Model any](base string, count int, keyField string, keyOf func(document Model) string) (HashPartitioner[Model], error) {
+	p := HashPartitioner[Model]{Base: base, Count: count, KeyField: keyField, KeyOf: keyOf}
+	return p, p.checkCount()
+}
+
+func (p HashPartitioner[Model]) checkCount() error {
+	if p.Count < 1 {
+		return fmt.Errorf("%w: %d for %s", ErrInvalidPartitionCount, p.Count, p.Base)
+	}
+	return nil
+}
+
+func (p HashPartitioner[Model]) name(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%s_%d", p.Base, h.Sum32()%uint32(p.Count))
+}
+
+func (p HashPartitioner[Model]) Partition(document Model) (string, error) {
+	if err := p.checkCount(); err != nil {
+		return "", err
+	}
+	key := p.KeyOf(document)
+	if key == "" {
+		return "", ErrNoPartition
+	}
+	return p.name(key), nil
+}
+
+func (p HashPartitioner[Model]) Pattern() string {
+	return "^" + regexp.QuoteMeta(p.Base) + `_\d+$`
+}
+
+func (p HashPartitioner[Model]) Partitions(filter primitive.M) ([]string, error) {
+	if err := p.checkCount(); err != nil {
+		return nil, err
+	}
+	if key, ok := filter[p.KeyField].(string); ok {
+		return []string{p.name(key)}, nil
+	}
+
+	names := make([]string, p.Count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_%d", p.Base, i)
+	}
+	return names, nil
+}
+
+// PartitionedQuerier presents a set of partition collections through the
+// single-collection Querier API: writes are routed to one partition and reads
+// fan out over the partitions a filter can match.
+type PartitionedQuerier[Model any, IDModel any] struct {
+	*MongoAdapter
+	Base        string
+	Partitioner Partitioner[Model]
+
+	mu       sync.Mutex
+	queriers map[string]*Querier[Model, IDModel]
+}
+
+func NewPartitionedQuerier[Model any](madp *MongoAdapter, base string, partitioner Partitioner[Model]) *PartitionedQuerier[Model, primitive.ObjectID] {
+	return &PartitionedQuerier[Model, primitive.ObjectID]{
+		MongoAdapter: madp,
+		Base:         base,
+		Partitioner:  partitioner,
+		queriers:     map[string]*Querier[Model, primitive.ObjectID]{},
+	}
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) Querier(collectionName string) *Querier[Model, IDModel] {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	if q, ok := pq.queriers[collectionName]; ok {
+		return q
+	}
+	q := newQuerier[Model, IDModel](pq.MongoAdapter, collectionName)
+	pq.queriers[collectionName] = q
+	return q
+}
+
+// opError is the OpError of a failure of operation before it reaches a
+// partition, attributed to the base collection name.
+func (pq *PartitionedQuerier[Model, IDModel]) opError(err error, start time.Time, operation string, filter primitive.M) error {
+	return pq.Querier(pq.Base).opError(err, start, operation, filter)
+}
+
+// structToM is StructToM for a model argument of operation.
+func (pq *PartitionedQuerier[Model, IDModel]) structToM(operation string, model Model) (primitive.M, error) {
+	return pq.Querier(pq.Base).structToM(operation, model)
+}
+
+// partitionsFor returns the queriers of the partitions filter can match,
+// attributing a failure to operation as an OpError.
+func (pq *PartitionedQuerier[Model, IDModel]) partitionsFor(ctx context.Context, operation string, filter primitive.M) ([]*Querier[Model, IDModel], error) {
+	start := time.Now()
+	names, err := pq.Partitioner.Partitions(filter)
+	if err != nil {
+		return nil, pq.opError(err, start, operation, filter)
+	}
+
+	if names == nil {
+		names, err = pq.ListPartitions(ctx)
+		if err != nil {
+			return nil, pq.opError(err, start, operation, filter)
+		}
+	}
+
+	queriers := make([]*Querier[Model, IDModel], 0, len(names))
+	for _, name := range names {
+		queriers = append(queriers, pq.Querier(name))
+	}
+	return queriers, nil
+}
+
+// ListPartitions returns the existing partition collections, sorted by name,
+// in the database of ctx's tenant.
+func (pq *PartitionedQuerier[Model, IDModel]) ListPartitions(ctx context.Context) ([]string, error) {
+	database, err := pq.MongoAdapter.databaseFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names, err := database.ListCollectionNames(ctx, bson.M{
+		"name": bson.M{"$regex": pq.Partitioner.Pattern()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) InsertOne(ctx context.Context, document Model, opts ...*options.InsertOneOptions) (insertedID IDModel, err error) {
+	name, err := pq.Partitioner.Partition(document)
+	if err != nil {
+		err = pq.opError(err, time.Now(), "InsertOne", nil)
+		return
+	}
+
+	return pq.Querier(name).InsertOne(ctx, document, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) InsertMany(ctx context.Context, documents []Model, opts ...*options.InsertManyOptions) ([]IDModel, error) {
+	// Group documents by partition, keeping their relative order
+	var names []string
+	groups := map[string][]Model{}
+	for _, document := range documents {
+		name, err := pq.Partitioner.Partition(document)
+		if err != nil {
+			return nil, pq.opError(err, time.Now(), "InsertMany", nil)
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], document)
+	}
+
+	var insertedIDs []IDModel
+	for _, name := range names {
+		ids, err := pq.Querier(name).InsertMany(ctx, groups[name], opts...)
+		if err != nil {
+			return insertedIDs, err
+		}
+		insertedIDs = append(insertedIDs, ids...)
+	}
+
+	pq.MongoAdapter.Debug(
+		"Inserted multiple documents across partitions",
+		LogField("partition_base", pq.Base),
+		LogField("partitions_count", len(names)),
+		LogField("documents_count", len(insertedIDs)),
+	)
+
+	return insertedIDs, nil
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) Find(ctx context.Context, filter Model, opts ...*options.FindOptions) ([]*Model, error) {
+	filterM, err := pq.structToM("Find", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.FindByM(ctx, filterM, opts...)
+}
+
+// FindByM reads every relevant partition in name order. Options such as
+// limit and sort apply per partition.
+func (pq *PartitionedQuerier[Model, IDModel]) FindByM(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) ([]*Model, error) {
+	queriers, err := pq.partitionsFor(ctx, "FindByM", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []*Model
+	for _, q := range queriers {
+		found, err := q.FindByM(ctx, filter, opts...)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, found...)
+	}
+	return documents, nil
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) FindOne(ctx context.Context, filter Model, opts ...*options.FindOneOptions) (*Model, error) {
+	filterM, err := pq.structToM("FindOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.FindOneByM(ctx, filterM, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) FindOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneOptions) (*Model, error) {
+	queriers, err := pq.partitionsFor(ctx, "FindOneByM", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range queriers {
+		document, err := q.FindOneByM(ctx, filter, opts...)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		return document, err
+	}
+	return nil, notFound(mongo.ErrNoDocuments)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) UpdateOne(ctx context.Context, filter Model, update Model, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+	filterM, err := pq.structToM("UpdateOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.UpdateOneByM(ctx, filterM, update, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) UpdateOneByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+	queriers, err := pq.partitionsFor(ctx, "UpdateOneByM", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range queriers {
+		document, err := q.UpdateOneByM(ctx, filter, update, opts...)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		return document, err
+	}
+	return nil, notFound(mongo.ErrNoDocuments)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) UpdateMany(ctx context.Context, filter Model, update Model, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	filterM, err := pq.structToM("UpdateMany", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.UpdateManyByM(ctx, filterM, update, opts...)
+}
+
+// UpdateManyByM updates the matching documents of every partition the filter
+// targets, summing their results.
+func (pq *PartitionedQuerier[Model, IDModel]) UpdateManyByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	queriers, err := pq.partitionsFor(ctx, "UpdateManyByM", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateResult[Model, IDModel]{}
+	for _, q := range queriers {
+		partitionResult, err := q.UpdateManyByM(ctx, filter, update, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		result.MatchedCount += partitionResult.MatchedCount
+		result.ModifiedCount += partitionResult.ModifiedCount
+		if partitionResult.Upserted {
+			result.Upserted = true
+			result.UpsertedID = partitionResult.UpsertedID
+		}
+		result.Documents = append(result.Documents, partitionResult.Documents...)
+	}
+	return result, nil
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) DeleteOne(ctx context.Context, filter Model, opts ...*options.FindOneAndDeleteOptions) (*Model, error) {
+	filterM, err := pq.structToM("DeleteOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.DeleteOneByM(ctx, filterM, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) DeleteOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneAndDeleteOptions) (*Model, error) {
+	queriers, err := pq.partitionsFor(ctx, "DeleteOneByM", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range queriers {
+		document, err := q.DeleteOneByM(ctx, filter, opts...)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		return document, err
+	}
+	return nil, notFound(mongo.ErrNoDocuments)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) DeleteMany(ctx context.Context, filter Model, opts ...*options.DeleteOptions) (int64, error) {
+	filterM, err := pq.structToM("DeleteMany", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return pq.DeleteManyByM(ctx, filterM, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) DeleteManyByM(ctx context.Context, filter primitive.M, opts ...*options.DeleteOptions) (int64, error) {
+	queriers, err := pq.partitionsFor(ctx, "DeleteManyByM", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, q := range queriers {
+		count, err := q.DeleteManyByM(ctx, filter, opts...)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) CountDocuments(ctx context.Context, filter Model, opts ...*options.CountOptions) (int64, error) {
+	filterM, err := pq.structToM("CountDocuments", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return pq.CountDocumentsByM(ctx, filterM, opts...)
+}
+
+func (pq *PartitionedQuerier[Model, IDModel]) CountDocumentsByM(ctx context.Context, filter primitive.M, opts ...*options.CountOptions) (int64, error) {
+	queriers, err := pq.partitionsFor(ctx, "CountDocumentsByM", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, q := range queriers {
+		count, err := q.CountDocumentsByM(ctx, filter, opts...)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
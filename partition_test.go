@@ -0,0 +1,72 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestHashPartitionerRejectsZeroCount(t *testing.T) {
+	keyOf := func(node recursiveNode) string { return node.Email }
+	if _, err := NewHashPartitioner("nodes", 0, "email", keyOf); !errors.Is(err, ErrInvalidPartitionCount) {
+		t.Errorf("NewHashPartitioner(0) error = %v, want ErrInvalidPartitionCount", err)
+	}
+
+	// A partitioner built as a literal fails instead of dividing by zero
+	p := HashPartitioner[recursiveNode]{Base: "nodes", KeyField: "email", KeyOf: keyOf}
+	if _, err := p.Partition(recursiveNode{Email: "a@b.c"}); !errors.Is(err, ErrInvalidPartitionCount) {
+		t.Errorf("Partition() error = %v, want ErrInvalidPartitionCount", err)
+	}
+	if _, err := p.Partitions(bson.M{"email": "a@b.c"}); !errors.Is(err, ErrInvalidPartitionCount) {
+		t.Errorf("Partitions() error = %v, want ErrInvalidPartitionCount", err)
+	}
+
+	p, err := NewHashPartitioner("nodes", 4, "email", keyOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, err := p.Partitions(bson.M{}); err != nil || len(names) != 4 {
+		t.Errorf("Partitions() = %v, %v, want 4 partitions", names, err)
+	}
+}
+
+func TestPartitionPatterns(t *testing.T) {
+	tests := []struct {
+		partitioner Partitioner[recursiveNode]
+		name        string
+		want        bool
+	}{
+		{HashPartitioner[recursiveNode]{Base: "orders", Count: 4}, "orders_3", true},
+		{HashPartitioner[recursiveNode]{Base: "orders", Count: 4}, "orders_archive", false},
+		{HashPartitioner[recursiveNode]{Base: "orders", Count: 4}, "orders_3_backup", false},
+		{HashPartitioner[recursiveNode]{Base: "orders.v2", Count: 4}, "ordersXv2_3", false},
+		{MonthlyPartitioner[recursiveNode]{Base: "orders"}, "orders_2026_10", true},
+		{MonthlyPartitioner[recursiveNode]{Base: "orders"}, "orders_backup", false},
+		{MonthlyPartitioner[recursiveNode]{Base: "orders"}, "orders_2026_10_old", false},
+	}
+	for _, test := range tests {
+		if got := regexp.MustCompile(test.partitioner.Pattern()).MatchString(test.name); got != test.want {
+			t.Errorf("%T pattern matches %q = %t, want %t", test.partitioner, test.name, got, test.want)
+		}
+	}
+}
+
+func TestPartitionedQuerierReturnsOpErrors(t *testing.T) {
+	partitioner := MonthlyPartitioner[recursiveNode]{
+		Base:   "nodes",
+		TimeOf: func(recursiveNode) time.Time { return time.Time{} },
+	}
+	pq := NewPartitionedQuerier[recursiveNode](newTestAdapter(t), "nodes", partitioner)
+
+	var opErr *OpError
+	if _, err := pq.InsertOne(context.Background(), recursiveNode{}); !errors.As(err, &opErr) || !errors.Is(err, ErrNoPartition) {
+		t.Errorf("InsertOne() = %v, want an OpError of ErrNoPartition", err)
+	}
+	if opErr != nil && (opErr.Operation != "InsertOne" || opErr.Collection != "nodes") {
+		t.Errorf("OpError = %s on %s, want InsertOne on nodes", opErr.Operation, opErr.Collection)
+	}
+}
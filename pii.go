@@ -0,0 +1,212 @@
+package mongoquerier
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PII classifications understood by the package. Any other `pii` tag value
+// is accepted and reported as-is.
+const (
+	PIIEmail = "email"
+	PIIName  = "name"
+	PIIID    = "id"
+	// PIIEncrypted classifies Queryable Encryption fields not tagged as PII.
+	PIIEncrypted = "encrypted"
+)
+
+var piiCache sync.Map // reflect.Type -> map[string]string
+
+// PIIFields returns the PII classification of every field of Model tagged
+// with `pii:"..."`, keyed by stored (dotted) path. Fields of the structs in
+// slices are classified under the slice's path, as MongoDB queries them.
+// The tag may also have the field encrypted (see EncryptedField), so it's
+// declared sensitive once: `pii:"id,encrypted=equality"`.
+func PIIFields[Model any]() map[string]string {
+	return piiFieldsFor(modelType[Model]())
+}
+
+func piiFieldsFor(t reflect.Type) map[string]string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if cached, ok := piiCache.Load(t); ok {
+		return cached.(map[string]string)
+	}
+
+	fields := classifyPII(t, map[reflect.Type]bool{})
+	piiCache.Store(t, fields)
+	return fields
+}
+
+// classifyPII walks t's fields, skipping the types already being walked:
+// the paths through a self-referential model are endless.
+func classifyPII(t reflect.Type, visiting map[reflect.Type]bool) map[string]string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	if cached, ok := piiCache.Load(t); ok {
+		return cached.(map[string]string)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	fields := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("bson") == "-" {
+			continue
+		}
+
+		key := bsonKey(field)
+		if kind, _ := piiTag(field); kind != "" {
+			fields[key] = kind
+		} else if _, ok := parseMQTag(field.Tag.Get("mq"))["encrypted"]; ok {
+			// Encrypted fields are redacted from logs like PII
+			fields[key] = PIIEncrypted
+		}
+		for path, kind := range classifyPII(field.Type, visiting) {
+			fields[key+"."+path] = kind
+		}
+	}
+	return fields
+}
+
+// piiTag splits a field's `pii` tag into its classification and options.
+func piiTag(field reflect.StructField) (string, map[string][]string) {
+	kind, options, _ := strings.Cut(field.Tag.Get("pii"), ",")
+	return strings.TrimSpace(kind), parseMQTag(options)
+}
+
+// PIIFieldsOf returns the PII classification registered for a collection by
+// the queriers created on it.
+func (madp *MongoAdapter) PIIFieldsOf(collectionName string) map[string]string {
+	fields, _ := madp.piiFields.Load(collectionName)
+	classification, _ := fields.(map[string]string)
+	return classification
+}
+
+func (madp *MongoAdapter) classifyCollection(collectionName string, t reflect.Type) {
+	if fields := piiFieldsFor(t); len(fields) > 0 {
+		madp.piiFields.Store(collectionName, fields)
+	}
+}
+
+func redactedMarker(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// RedactPII returns a copy of document (a model or a filter/update map) with
+// every value classified in fields replaced by a marker.
+func RedactPII(document interface{}, fields map[string]string) interface{} {
+	if len(fields) == 0 || document == nil {
+		return document
+	}
+
+	m, ok := document.(bson.M)
+	if !ok {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			return document
+		}
+		if err := bson.Unmarshal(data, &m); err != nil {
+			return document
+		}
+	}
+	return redactM(m, fields, "")
+}
+
+func redactM(m bson.M, fields map[string]string, prefix string) bson.M {
+	redacted := make(bson.M, len(m))
+	for key, value := range m {
+		redacted[key] = redactValue(key, value, fields, prefix)
+	}
+	return redacted
+}
+
+func redactValue(key string, value interface{}, fields map[string]string, prefix string) interface{} {
+	// Operators ($set, $and, $in, ...) don't extend the field path
+	path := prefix
+	if !strings.HasPrefix(key, "$") {
+		path = prefix + key
+		if kind, ok := fields[path]; ok {
+			return redactedMarker(kind)
+		}
+		path += "."
+	}
+
+	// Documents and arrays are copied, so the result shares no maps or
+	// slices a caller may reuse
+	switch value := value.(type) {
+	case bson.M:
+		return redactM(value, fields, path)
+	case map[string]interface{}:
+		return redactM(value, fields, path)
+	case bson.D:
+		redacted := make(bson.D, len(value))
+		for i, element := range value {
+			redacted[i] = bson.E{Key: element.Key, Value: redactValue(element.Key, element.Value, fields, path)}
+		}
+		return redacted
+	case bson.A:
+		redacted := make(bson.A, len(value))
+		for i, element := range value {
+			redacted[i] = redactValue("$", element, fields, path)
+		}
+		return redacted
+	case []interface{}:
+		return redactValue(key, bson.A(value), fields, prefix)
+	case []bson.M:
+		redacted := make([]bson.M, len(value))
+		for i, element := range value {
+			redacted[i] = redactM(element, fields, path)
+		}
+		return redacted
+	case []bson.D:
+		redacted := make([]bson.D, len(value))
+		for i, element := range value {
+			redacted[i] = redactValue("$", element, fields, path).(bson.D)
+		}
+		return redacted
+	}
+	return value
+}
+
+// redactedField defers redaction until the entry is actually encoded, so
+// disabled debug logs don't pay for it.
+type redactedField struct {
+	value  interface{}
+	fields map[string]string
+}
+
+func (rf redactedField) LogValue() interface{} {
+	return RedactPII(rf.value, rf.fields)
+}
+
+// logValue logs a document, filter or update with the Model's PII redacted.
+func (q *Querier[Model, IDModel]) logValue(key string, value interface{}) Field {
+	fields := piiFieldsFor(modelType[Model]())
+	if len(fields) == 0 {
+		return LogField(key, value)
+	}
+	return LogField(key, redactedField{value: value, fields: fields})
+}
+
+// logFieldValues logs values read from fieldName, redacted as a whole when
+// the field is classified.
+func (q *Querier[Model, IDModel]) logFieldValues(key string, fieldName string, values interface{}) Field {
+	if kind, ok := piiFieldsFor(modelType[Model]())[fieldName]; ok {
+		return LogField(key, redactedMarker(kind))
+	}
+	return LogField(key, values)
+}
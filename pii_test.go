@@ -0,0 +1,152 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type recursiveNode struct {
+	Email    string           `bson:"email" pii:"email"`
+	Parent   *recursiveNode   `bson:"parent"`
+	Children []*recursiveNode `bson:"children"`
+	Link     *recursiveLink   `bson:"link"`
+}
+
+type recursiveLink struct {
+	Name string         `bson:"name" pii:"name"`
+	Node *recursiveNode `bson:"node"`
+}
+
+func TestPIIFieldsRecursiveModel(t *testing.T) {
+	fields := PIIFields[recursiveNode]()
+
+	want := map[string]string{
+		"email":     PIIEmail,
+		"link.name": PIIName,
+	}
+	for path, kind := range want {
+		if fields[path] != kind {
+			t.Errorf("PIIFields()[%q] = %q, want %q", path, fields[path], kind)
+		}
+	}
+
+	// The nested type alone is classified through its own walk
+	if kind := PIIFields[recursiveLink]()["node.email"]; kind != PIIEmail {
+		t.Errorf("PIIFields[recursiveLink]()[node.email] = %q, want %q", kind, PIIEmail)
+	}
+}
+
+type piiContact struct {
+	Email string `bson:"email" pii:"email"`
+}
+
+type piiCustomer struct {
+	SSN      string       `bson:"ssn" pii:"id,encrypted=equality"`
+	Contacts []piiContact `bson:"contacts"`
+	Backups  [2]*piiContact
+}
+
+func TestPIIFieldsInSlicesAndEncrypted(t *testing.T) {
+	fields := PIIFields[piiCustomer]()
+	want := map[string]string{
+		"ssn":            PIIID,
+		"contacts.email": PIIEmail,
+		"backups.email":  PIIEmail,
+	}
+	for path, kind := range want {
+		if fields[path] != kind {
+			t.Errorf("PIIFields()[%q] = %q, want %q", path, fields[path], kind)
+		}
+	}
+
+	encrypted := EncryptedFields[piiCustomer]()
+	if len(encrypted) != 1 || encrypted[0].Path != "ssn" || !encrypted[0].Queryable {
+		t.Errorf("EncryptedFields() = %+v, want ssn queryable", encrypted)
+	}
+}
+
+func TestNewQuerierRecursiveModel(t *testing.T) {
+	if q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes"); q == nil {
+		t.Fatal("NewQuerier returned nil")
+	}
+}
+
+func TestRedactNestedDocuments(t *testing.T) {
+	fields := map[string]string{"email": PIIEmail, "link.name": PIIName}
+	link := map[string]interface{}{"name": "Ada"}
+	filter := bson.M{
+		"$or":  []bson.D{{{Key: "email", Value: "a@b.c"}}},
+		"link": link,
+	}
+
+	redacted := redactM(filter, fields, "")
+	marker := redactedMarker(PIIEmail)
+	if got := redacted["$or"].([]bson.D)[0][0].Value; got != marker {
+		t.Errorf("redacted $or email = %v, want %s", got, marker)
+	}
+	if got := redacted["link"].(bson.M)["name"]; got != redactedMarker(PIIName) {
+		t.Errorf("redacted link.name = %v, want %s", got, redactedMarker(PIIName))
+	}
+
+	// The copy doesn't share the filter's maps
+	link["url"] = "https://example.com"
+	if _, ok := redacted["link"].(bson.M)["url"]; ok {
+		t.Error("redacted link changed with the filter")
+	}
+}
+
+func TestOperationsReturnOpErrors(t *testing.T) {
+	q := NewQuerier[recursiveNode](newTestAdapter(t), "nodes")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	operations := map[string]func() error{
+		"AggregateIter": func() error {
+			_, err := q.AggregateIter(ctx, nil)
+			return err
+		},
+		"FindUnionByM": func() error {
+			_, err := q.FindUnionByM(ctx, primitive.M{"email": "a@b.c"})
+			return err
+		},
+		"EstimateDistinctByM": func() error {
+			_, err := q.EstimateDistinctByM(ctx, "email", nil)
+			return err
+		},
+		"Watch": func() error {
+			_, err := q.Watch(ctx, nil)
+			return err
+		},
+		"Find": func() error {
+			_, err := q.Find(ctx, recursiveNode{Email: "a@b.c"})
+			return err
+		},
+		"FindProfile": func() error {
+			_, err := q.FindProfile(context.Background(), "unknown", nil)
+			return err
+		},
+		"Anonymize": func() error {
+			_, err := q.Anonymize(context.Background(), nil, map[string]Masker{"link": nil, "link.url": nil})
+			return err
+		},
+	}
+	for name, operation := range operations {
+		var opErr *OpError
+		if err := operation(); !errors.As(err, &opErr) || opErr.Operation != name {
+			t.Errorf("%s() = %v, want an OpError of %s", name, err, name)
+		}
+	}
+
+	// Past preflight too, here failing on the disconnected client
+	var opErr *OpError
+	if _, err := q.FindDistinctByM(context.Background(), primitive.M{"email": "a@b.c"}, "email"); !errors.As(err, &opErr) || opErr.Operation != "FindDistinctByM" {
+		t.Errorf("FindDistinctByM() = %v, want an OpError of FindDistinctByM", err)
+	}
+	if opErr != nil && opErr.Filter["email"] != redactedMarker(PIIEmail) {
+		t.Errorf("OpError.Filter = %v, want the email redacted", opErr.Filter)
+	}
+}
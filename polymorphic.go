@@ -0,0 +1,153 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ErrUnknownSubtype = errors.New("unknown subtype")
+	ErrInvalidSubtype = errors.New("invalid subtype")
+)
+
+// subtypes describes the concrete types stored in the collections of a
+// polymorphic model, told apart by the discriminator field.
+type subtypes struct {
+	field     string
+	types     map[string]reflect.Type
+	names     map[reflect.Type]string
+	byPointer map[reflect.Type]bool // whether *Sub rather than Sub implements Base
+}
+
+var polymorphicModels sync.Map // reflect.Type (base) -> *subtypes
+
+var registerSubtypeMu sync.Mutex
+
+// RegisterSubtype lets queriers of the interface Base store and read Sub
+// documents, tagged with name under the discriminator field:
+//
+//	type Event interface{ EventName() string }
+//	err := RegisterSubtype[Event, SignedUp]("type", "signed_up")
+//	err = RegisterSubtype[Event, LoggedIn]("type", "logged_in")
+//
+//	events := NewQuerier[Event](mongoAdapter, "events")
+//	_, err = events.InsertOne(ctx, SignedUp{...}) // stored with "type": "signed_up"
+//	found, err := events.FindByM(ctx, bson.M{})    // *found[i] is a SignedUp or a LoggedIn
+//
+// Base must be an interface implemented by Sub or *Sub, and every subtype of
+// Base must use the same field.
+func RegisterSubtype[Base any, Sub any](field string, name string) error {
+	baseT, subT := modelType[Base](), modelType[Sub]()
+
+	if baseT.Kind() != reflect.Interface {
+		return fmt.Errorf("%w: %s isn't an interface", ErrInvalidSubtype, baseT)
+	}
+	var byPointer bool
+	switch {
+	case subT.Implements(baseT):
+	case reflect.PointerTo(subT).Implements(baseT):
+		byPointer = true
+	default:
+		return fmt.Errorf("%w: neither %s nor *%s implement %s", ErrInvalidSubtype, subT, subT, baseT)
+	}
+
+	registerSubtypeMu.Lock()
+	defer registerSubtypeMu.Unlock()
+
+	registered := &subtypes{
+		field:     field,
+		types:     map[string]reflect.Type{},
+		names:     map[reflect.Type]string{},
+		byPointer: map[reflect.Type]bool{},
+	}
+	if existing, ok := polymorphicModels.Load(baseT); ok {
+		existing := existing.(*subtypes)
+		if existing.field != field {
+			return fmt.Errorf("%w: %s is discriminated by %q, not %q", ErrInvalidSubtype, baseT, existing.field, field)
+		}
+		for subtypeName, t := range existing.types {
+			registered.types[subtypeName] = t
+			registered.names[t] = subtypeName
+			registered.byPointer[t] = existing.byPointer[t]
+		}
+	}
+	registered.types[name] = subT
+	registered.names[subT] = name
+	registered.byPointer[subT] = byPointer
+	polymorphicModels.Store(baseT, registered)
+	return nil
+}
+
+// SubtypeFilter returns the filter matching only Sub documents among Base's.
+func SubtypeFilter[Base any, Sub any]() (bson.M, error) {
+	registered, ok := subtypesOf(modelType[Base]())
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no subtypes", ErrUnknownSubtype, modelType[Base]())
+	}
+	name, ok := registered.names[modelType[Sub]()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSubtype, modelType[Sub]())
+	}
+	return bson.M{registered.field: name}, nil
+}
+
+func subtypesOf(t reflect.Type) (*subtypes, bool) {
+	registered, ok := polymorphicModels.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return registered.(*subtypes), true
+}
+
+// decode decodes raw into the subtype its discriminator names.
+func (s *subtypes) decode(raw bson.Raw) (interface{}, error) {
+	value, err := raw.LookupErr(s.field)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing %q", ErrUnknownSubtype, s.field)
+	}
+	name, ok := value.StringValueOK()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is %s", ErrUnknownSubtype, s.field, value)
+	}
+	t, ok := s.types[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSubtype, name)
+	}
+
+	document := reflect.New(t)
+	if err = bson.Unmarshal(raw, document.Interface()); err != nil {
+		return nil, err
+	}
+	if s.byPointer[t] {
+		return document.Interface(), nil
+	}
+	return document.Elem().Interface(), nil
+}
+
+// stamp sets the discriminator of document's subtype on its marshaled form.
+func (s *subtypes) stamp(document interface{}, m bson.M) error {
+	t := reflect.TypeOf(document)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name, ok := s.names[t]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownSubtype, t)
+	}
+	m[s.field] = name
+	return nil
+}
+
+// stampSubtype sets the discriminator on m, the marshaled form of document,
+// when Model is polymorphic.
+func (q *Querier[Model, IDModel]) stampSubtype(document Model, m bson.M) error {
+	registered, ok := subtypesOf(modelType[Model]())
+	if !ok {
+		return nil
+	}
+	return registered.stamp(document, m)
+}
@@ -0,0 +1,171 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrUnknownProjectionProfile = errors.New("unknown projection profile")
+	ErrInvalidProjectionProfile = errors.New("projection profile field is not a model field")
+)
+
+var projectionProfiles sync.Map // reflect.Type (model) -> map[string]bson.D
+
+var registerProfileMu sync.Mutex
+
+// RegisterProjectionProfile names the subset of Model's fields declared by
+// the Profile struct, e.g. a "list" profile with only the fields listings
+// show:
+//
+//	type ProductListing struct {
+//		ID   primitive.ObjectID `bson:"_id"`
+//		Name string             `bson:"name"`
+//	}
+//	err := RegisterProjectionProfile[Product, ProductListing]("list")
+//
+// Every field of Profile must exist in Model. Aliased keys are projected
+// too, so documents still written under them decode.
+func RegisterProjectionProfile[Model any, Profile any](name string) error {
+	modelT, profileT := modelType[Model](), modelType[Profile]()
+
+	modelKeys := map[string]reflect.StructField{}
+	for _, field := range structFields(modelT) {
+		modelKeys[bsonKey(field)] = field
+	}
+
+	projection := bson.D{}
+	for _, field := range structFields(profileT) {
+		key := bsonKey(field)
+		modelField, ok := modelKeys[key]
+		if !ok {
+			return fmt.Errorf("%w: %s.%s (%q) in profile %q", ErrInvalidProjectionProfile, profileT.Name(), field.Name, key, name)
+		}
+
+		projection = append(projection, bson.E{Key: key, Value: 1})
+		for _, alias := range parseMQTag(modelField.Tag.Get("mq"))["alias"] {
+			projection = append(projection, bson.E{Key: alias, Value: 1})
+		}
+	}
+
+	registerProfileMu.Lock()
+	defer registerProfileMu.Unlock()
+
+	profiles := map[string]bson.D{}
+	if existing, ok := projectionProfiles.Load(modelT); ok {
+		for profile, existingProjection := range existing.(map[string]bson.D) {
+			profiles[profile] = existingProjection
+		}
+	}
+	profiles[name] = projection
+	projectionProfiles.Store(modelT, profiles)
+	return nil
+}
+
+// ProjectionProfile returns the projection registered for Model under name.
+func ProjectionProfile[Model any](name string) (bson.D, bool) {
+	profiles, ok := projectionProfiles.Load(modelType[Model]())
+	if !ok {
+		return nil, false
+	}
+	projection, ok := profiles.(map[string]bson.D)[name]
+	return projection, ok
+}
+
+func structFields(t reflect.Type) []reflect.StructField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("bson") != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+type partialReadKey struct{}
+
+// partialRead marks reads whose documents aren't stored ones as read, such as
+// projections and aggregations, which read repair never writes back.
+func partialRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, partialReadKey{}, true)
+}
+
+func isPartialRead(ctx context.Context) bool {
+	partial, _ := ctx.Value(partialReadKey{}).(bool)
+	return partial
+}
+
+func findProjects(opts []*options.FindOptions) bool {
+	for _, opt := range opts {
+		if opt != nil && opt.Projection != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func findOneProjects(opts []*options.FindOneOptions) bool {
+	for _, opt := range opts {
+		if opt != nil && opt.Projection != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Querier[Model, IDModel]) profileProjection(profile string) (bson.D, error) {
+	projection, ok := ProjectionProfile[Model](profile)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q for collection %s", ErrUnknownProjectionProfile, profile, q.collection.Name())
+	}
+	return projection, nil
+}
+
+// FindProfile finds documents like FindByM, reading only the fields of the
+// named projection profile; the other fields of the results are left zero.
+func (q *Querier[Model, IDModel]) FindProfile(ctx context.Context, profile string, filter primitive.M, opts ...*options.FindOptions) ([]*Model, error) {
+	projection, err := q.profileProjection(profile)
+	if err != nil {
+		return nil, q.opError(err, time.Now(), "FindProfile", filter)
+	}
+
+	documents, err := q.FindByM(ctx, filter, append(opts, options.Find().SetProjection(projection))...)
+	if err != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Found documents with a projection profile",
+		LogField("collection_name", q.collection.Name()),
+		LogField("profile", profile),
+		LogField("documents_count", len(documents)),
+	)
+	return documents, nil
+}
+
+// FindOneProfile finds one document like FindOneByM, reading only the fields
+// of the named projection profile.
+func (q *Querier[Model, IDModel]) FindOneProfile(ctx context.Context, profile string, filter primitive.M, opts ...*options.FindOneOptions) (*Model, error) {
+	projection, err := q.profileProjection(profile)
+	if err != nil {
+		return nil, q.opError(err, time.Now(), "FindOneProfile", filter)
+	}
+
+	return q.FindOneByM(ctx, filter, append(opts, options.FindOne().SetProjection(projection))...)
+}
@@ -0,0 +1,78 @@
+// Package prommetrics registers mongoquerier.Metrics with a Prometheus
+// registry, keeping client_golang out of the builds of applications
+// scraping Metrics' own handler.
+package prommetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"mongoquerier"
+)
+
+type collector struct {
+	metrics *mongoquerier.Metrics
+
+	operations *prometheus.Desc
+	errors     *prometheus.Desc
+	durations  *prometheus.Desc
+	inUse      *prometheus.Desc
+	idle       *prometheus.Desc
+	waiting    *prometheus.Desc
+	waits      *prometheus.Desc
+}
+
+// NewCollector collects metrics, under the same names and labels as
+// Metrics.ServeHTTP:
+//
+//	metrics := mongoquerier.NewMetrics()
+//	mongoAdapter, err := mongoquerier.NewMongoAdapter(ctx, uri, "shop", mongoquerier.WithMetrics(metrics))
+//	prometheus.MustRegister(prommetrics.NewCollector(metrics))
+func NewCollector(metrics *mongoquerier.Metrics) prometheus.Collector {
+	namespace := metrics.Namespace
+	if namespace == "" {
+		namespace = "mongoquerier"
+	}
+	operationLabels := []string{"collection", "operation"}
+	poolLabels := []string{"address"}
+
+	return &collector{
+		metrics:    metrics,
+		operations: prometheus.NewDesc(namespace+"_operations_total", "Querier operations by collection and operation.", operationLabels, nil),
+		errors:     prometheus.NewDesc(namespace+"_operation_errors_total", "Querier operations that returned an error.", operationLabels, nil),
+		durations:  prometheus.NewDesc(namespace+"_operation_duration_seconds", "Duration of querier operations.", operationLabels, nil),
+		inUse:      prometheus.NewDesc(namespace+"_pool_connections_in_use", "Connections checked out of the pool.", poolLabels, nil),
+		idle:       prometheus.NewDesc(namespace+"_pool_connections_idle", "Open connections waiting in the pool.", poolLabels, nil),
+		waiting:    prometheus.NewDesc(namespace+"_pool_checkouts_waiting", "Operations waiting for a connection.", poolLabels, nil),
+		waits:      prometheus.NewDesc(namespace+"_pool_wait_seconds", "Time operations waited for a connection.", poolLabels, nil),
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.operations
+	ch <- c.errors
+	ch <- c.durations
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waiting
+	ch <- c.waits
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.metrics.OperationStats() {
+		ch <- prometheus.MustNewConstMetric(c.operations, prometheus.CounterValue, float64(stats.Count), stats.Collection, stats.Operation)
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stats.Errors), stats.Collection, stats.Operation)
+
+		buckets := make(map[float64]uint64, len(stats.Bounds))
+		for i, bound := range stats.Bounds {
+			buckets[bound] = stats.BucketCounts[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(c.durations, stats.Count, stats.DurationSum, buckets, stats.Collection, stats.Operation)
+	}
+
+	for _, pool := range c.metrics.PoolStats() {
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(pool.InUse), pool.Address)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(pool.Idle), pool.Address)
+		ch <- prometheus.MustNewConstMetric(c.waiting, prometheus.GaugeValue, float64(pool.Waiting), pool.Address)
+		ch <- prometheus.MustNewConstSummary(c.waits, pool.Waits, pool.WaitSeconds, nil, pool.Address)
+	}
+}
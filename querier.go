@@ -3,12 +3,13 @@ package mongoquerier
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.uber.org/zap"
 )
 
 var (
@@ -21,36 +22,98 @@ type Querier[Model any, IDModel any] struct {
 	*MongoAdapter
 	collection    *mongo.Collection
 	IsIDComposite bool
+
+	// DualWriteAliases also writes aliased fields under their legacy keys
+	// (see `mq:"alias=..."`) while a rename is being rolled out.
+	DualWriteAliases bool
+	ReadRepair       *ReadRepair[Model]
+	SizeGuard        *SizeGuard
+	IndexPolicy      *IndexPolicy
+	ShardKeyPolicy   *ShardKeyPolicy
+	QueryAllowlist   *QueryAllowlist
+	OfflineQueue     *OfflineQueue
+	CountCache       *CountCache
+	Retry            *RetryPolicy
+	StaleReads       *StaleReads
+
+	// AfterRead hooks transform every decoded document, in order, before
+	// it's returned (masking, decryption, mapping legacy values...).
+	AfterRead []AfterReadHook[Model]
+
+	// UseAnalytics routes every read of the querier to the adapter's
+	// analytics cluster; see also Analytics for per-call routing.
+	UseAnalytics  bool
+	AdaptiveReads *AdaptiveReads
+
+	// VerifyAllWrites re-reads written documents to check the stored state,
+	// as VerifyWrites does per call.
+	VerifyAllWrites bool
+
+	// Durability is the durability class of the querier's writes, over the
+	// model's (see DurabilityClassifier).
+	Durability Durability
+
+	// SlowQueryThreshold overrides the adapter's for the querier's
+	// operations.
+	SlowQueryThreshold time.Duration
+
+	tenantCollections sync.Map // database -> *mongo.Collection
 }
 
-func NewQuerier[Model any](madp *MongoAdapter, collectionName string) *Querier[Model, primitive.ObjectID] {
+func newQuerier[Model any, IDModel any](madp *MongoAdapter, collectionName string) *Querier[Model, IDModel] {
+	madp.classifyCollection(collectionName, modelType[Model]())
+
 	collection := madp.GetCollection(collectionName)
-	return &Querier[Model, primitive.ObjectID]{
+	return &Querier[Model, IDModel]{
 		MongoAdapter: madp,
 		collection:   collection,
 	}
 }
 
+func NewQuerier[Model any](madp *MongoAdapter, collectionName string) *Querier[Model, primitive.ObjectID] {
+	return newQuerier[Model, primitive.ObjectID](madp, collectionName)
+}
+
 type IDContainer[IDModel any] struct {
 	ID IDModel `json:"_id,omitempty"`
 }
 
 func NewQuerierWithCompositeID[Model any, IDModel any](madp *MongoAdapter, collectionName string) *Querier[Model, IDModel] {
-	collection := madp.GetCollection(collectionName)
-	return &Querier[Model, IDModel]{
-		MongoAdapter:  madp,
-		collection:    collection,
-		IsIDComposite: true,
-	}
+	q := newQuerier[Model, IDModel](madp, collectionName)
+	q.IsIDComposite = true
+	return q
 }
 
 func (q *Querier[Model, IDModel]) InsertOne(ctx context.Context, document Model, opts ...*options.InsertOneOptions) (insertedID IDModel, err error) {
-	res, err := q.collection.InsertOne(ctx, document, opts...)
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "InsertOne", nil); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "InsertOne", nil)
+	defer q.observe(span, time.Now(), "InsertOne", nil, &err)
+
+	insertDocument, err := q.prepareDocument(document)
 	if err != nil {
 		return
 	}
+	if err = q.checkSize("InsertOne", insertDocument); err != nil {
+		return
+	}
 
-	insertedID, ok := res.InsertedID.(IDModel)
+	res, err := retrying(ctx, q, "InsertOne", func() (*mongo.InsertOneResult, error) {
+		return q.writeCollection(ctx).InsertOne(ctx, insertDocument, opts...)
+	})
+	if err != nil {
+		q.logWriteFailure(ctx, "InsertOne", err)
+		err = q.queueOnOutage(ctx, err, QueuedInsertOne, nil, insertDocument)
+		return
+	}
+	q.trackInserted(ctx, res.InsertedID)
+	q.verifyDocument(ctx, "InsertOne", res.InsertedID, insertDocument)
+
+	insertedID, ok := castID[IDModel](res.InsertedID)
 	if !ok {
 		if q.IsIDComposite == true {
 			var idContainer IDContainer[IDModel]
@@ -60,7 +123,7 @@ func (q *Querier[Model, IDModel]) InsertOne(ctx context.Context, document Model,
 				return
 			}
 		} else {
-			q.MongoAdapter.Error("Unable to cast InsertedID into ObjectID", zap.Error(err))
+			q.MongoAdapter.Error("Unable to cast InsertedID into ObjectID", LogError(err))
 			err = ErrFailedToCastInsertedID
 			return
 		}
@@ -68,479 +131,765 @@ func (q *Querier[Model, IDModel]) InsertOne(ctx context.Context, document Model,
 
 	q.MongoAdapter.Debug(
 		"Created a document",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("_id", insertedID),
+		LogField("collection_name", q.collection.Name()),
+		LogField("_id", insertedID),
 	)
 	return
 }
 
-func (q *Querier[Model, IDModel]) InsertMany(ctx context.Context, documents []Model, opts ...*options.InsertManyOptions) ([]IDModel, error) {
-	var insertedIDs []IDModel
+func (q *Querier[Model, IDModel]) InsertMany(ctx context.Context, documents []Model, opts ...*options.InsertManyOptions) (insertedIDs []IDModel, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "InsertMany", nil); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "InsertMany", nil)
+	defer q.observe(span, time.Now(), "InsertMany", nil, &err)
 
-	// Prepare a slice to store the inserted IDs.
 	// Loop through the documents and perform bulk insertion.
 	var insertModels []interface{}
 	for _, doc := range documents {
-		insertModels = append(insertModels, doc)
+		insertDocument, err := q.prepareDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.checkSize("InsertMany", insertDocument); err != nil {
+			return nil, err
+		}
+		insertModels = append(insertModels, insertDocument)
 	}
 
-	res, err := q.collection.InsertMany(ctx, insertModels, opts...)
+	res, err := retrying(ctx, q, "InsertMany", func() (*mongo.InsertManyResult, error) {
+		return q.writeCollection(ctx).InsertMany(ctx, insertModels, opts...)
+	})
 	if err != nil {
+		q.logWriteFailure(ctx, "InsertMany", err)
+		err = q.queueOnOutage(ctx, err, QueuedInsertOne, nil, insertModels...)
 		return nil, err
 	}
+	q.trackInserted(ctx, res.InsertedIDs...)
 
 	// Retrieve the inserted IDs from the result.
 	for _, id := range res.InsertedIDs {
-		insertedID, ok := id.(IDModel)
+		insertedID, ok := castID[IDModel](id)
 		if !ok {
 			return nil, ErrFailedToCastInsertedID
 		}
 		insertedIDs = append(insertedIDs, insertedID)
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", len(insertedIDs)))
+
 	q.MongoAdapter.Debug(
 		"Inserted multiple documents",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Int("documents_count", len(insertedIDs)),
+		LogField("collection_name", q.collection.Name()),
+		LogField("documents_count", len(insertedIDs)),
 	)
 
 	return insertedIDs, nil
 }
 
 func (q *Querier[Model, IDModel]) Find(ctx context.Context, filter Model, opts ...*options.FindOptions) (documents []*Model, err error) {
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("Find", filter)
 	if err != nil {
 		return
 	}
-
-	cursor, err := q.collection.Find(ctx, filterM, opts...)
-	if err != nil {
+	if err = q.preflight(ctx, "Find", filterM); err != nil {
 		return
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var document Model
-		if err = cursor.Decode(&document); err != nil {
-			return
-		}
+	ctx, span := q.startOperation(ctx, "Find", filterM)
+	defer q.observe(span, time.Now(), "Find", filterM, &err)
+	if findProjects(opts) {
+		ctx = partialRead(ctx)
+	}
 
-		documents = append(documents, &document)
+	cursor, err := retrying(ctx, q, "Find", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filterM, q.findOptions(ctx, "Find", opts)...)
+	})
+	if err != nil {
+		return
 	}
 
-	if err = cursor.Err(); err != nil {
+	documents, err = q.decodeCursor(ctx, cursor)
+	if err != nil {
 		return
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", len(documents)))
+
 	q.MongoAdapter.Debug(
 		"Found all documents",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Int("documents_count", len(documents)),
+		LogField("collection_name", q.collection.Name()),
+		LogField("documents_count", len(documents)),
 	)
 	return
 }
 
 func (q *Querier[Model, IDModel]) FindByM(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) (documents []*Model, err error) {
-	cursor, err := q.collection.Find(ctx, filter, opts...)
-	if err != nil {
+	if err = q.preflight(ctx, "FindByM", filter); err != nil {
 		return
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var document Model
-		if err = cursor.Decode(&document); err != nil {
-			return
-		}
+	ctx, span := q.startOperation(ctx, "FindByM", filter)
+	defer q.observe(span, time.Now(), "FindByM", filter, &err)
+	if findProjects(opts) {
+		ctx = partialRead(ctx)
+	}
 
-		documents = append(documents, &document)
+	cursor, err := retrying(ctx, q, "FindByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "FindByM", opts)...)
+	})
+	if err != nil {
+		return
 	}
 
-	if err = cursor.Err(); err != nil {
+	documents, err = q.decodeCursor(ctx, cursor)
+	if err != nil {
 		return
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", len(documents)))
+
 	q.MongoAdapter.Debug(
 		"Found all documents",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Int("documents_count", len(documents)),
+		LogField("collection_name", q.collection.Name()),
+		LogField("documents_count", len(documents)),
 	)
 	return
 }
 
+func (q *Querier[Model, IDModel]) FindIter(ctx context.Context, filter Model, opts ...*options.FindOptions) (*Cursor[Model], error) {
+	filterM, err := q.structToM("FindIter", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.FindIterByM(ctx, filterM, opts...)
+}
+
+// FindIterByM streams the documents matching filter through a Cursor,
+// decoding them as they're iterated instead of loading them all in memory.
+func (q *Querier[Model, IDModel]) FindIterByM(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) (cursor *Cursor[Model], err error) {
+	if err = q.preflight(ctx, "FindIterByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindIterByM", filter)
+	defer q.observe(span, time.Now(), "FindIterByM", filter, &err)
+
+	mongoCursor, err := retrying(ctx, q, "FindIterByM", func() (*mongo.Cursor, error) {
+		return q.readCollection(ctx).Find(ctx, filter, q.findOptions(ctx, "FindIterByM", opts)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor = newCursor(q, mongoCursor)
+	if findProjects(opts) {
+		cursor.decode = func(ctx context.Context, raw bson.Raw) (*Model, error) {
+			return q.decode(partialRead(ctx), raw)
+		}
+	}
+	return cursor, nil
+}
+
 func (q *Querier[Model, IDModel]) FindOne(ctx context.Context, filter Model, opts ...*options.FindOneOptions) (document *Model, err error) {
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("FindOne", filter)
 	if err != nil {
 		return
 	}
+	if err = q.preflight(ctx, "FindOne", filterM); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "FindOne", filterM)
+	defer q.observe(span, time.Now(), "FindOne", filterM, &err)
+	if findOneProjects(opts) {
+		ctx = partialRead(ctx)
+	}
 
-	err = q.collection.FindOne(context.Background(), filterM, opts...).Decode(&document)
+	document, err = retrying(ctx, q, "FindOne", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.readCollection(ctx).FindOne(ctx, filterM, q.findOneOptions(ctx, "FindOne", opts)...))
+	})
 	if err != nil {
 		return
 	}
 
 	q.MongoAdapter.Debug(
 		"Found one document",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("document", document),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("document", document),
 	)
 	return
 }
 
 func (q *Querier[Model, IDModel]) FindOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneOptions) (document *Model, err error) {
-	err = q.collection.FindOne(context.Background(), filter, opts...).Decode(&document)
+	if err = q.preflight(ctx, "FindOneByM", filter); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "FindOneByM", filter)
+	defer q.observe(span, time.Now(), "FindOneByM", filter, &err)
+	if findOneProjects(opts) {
+		ctx = partialRead(ctx)
+	}
+
+	document, err = retrying(ctx, q, "FindOneByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.readCollection(ctx).FindOne(ctx, filter, q.findOneOptions(ctx, "FindOneByM", opts)...))
+	})
 	if err != nil {
 		return
 	}
 
 	q.MongoAdapter.Debug(
 		"Found one document",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("document", document),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("document", document),
 	)
 	return
 }
 
 func (q *Querier[Model, IDModel]) UpdateOne(ctx context.Context, filter Model, update Model, opts ...*options.FindOneAndUpdateOptions) (document *Model, err error) {
-	filterM, err := StructToM(filter)
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	filterM, err := q.structToM("UpdateOne", filter)
 	if err != nil {
 		return
 	}
+	if err = q.preflight(ctx, "UpdateOne", filterM); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "UpdateOne", filterM)
+	defer q.observe(span, time.Now(), "UpdateOne", filterM, &err)
 
 	updateM, err := StructToM(update)
 	if err != nil {
 		return
 	}
-	updateM = bson.M{"$set": updateM}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("UpdateOne", updateM); err != nil {
+		return
+	}
 
 	// opts = append(opts, options.FindOneAndUpdate().SetReturnDocument(options.After))
-	err = q.collection.FindOneAndUpdate(
-		ctx,
-		filterM,
-		updateM,
-		opts...,
-	).Decode(&document)
-	if err != nil {
+	document, err = retrying(ctx, q, "UpdateOne", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndUpdate(
+			ctx,
+			filterM,
+			updateM,
+			opts...,
+		))
+	})
+	err = expectSingle(ctx, "UpdateOne", err)
+	if err != nil {
+		q.logWriteFailure(ctx, "UpdateOne", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateOne, filterM, updateM)
 		return
 	}
+	q.verifyUpdate(ctx, "UpdateOne", document, updateM)
 
 	q.MongoAdapter.Debug(
 		"Updated one document",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("document", document),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("document", document),
 	)
 	return
 }
 
-func (q *Querier[Model, IDModel]) UpdateOneByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+func (q *Querier[Model, IDModel]) UpdateOneByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.FindOneAndUpdateOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "UpdateOneByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "UpdateOneByM", filter)
+	defer q.observe(span, time.Now(), "UpdateOneByM", filter, &err)
+
 	// Convert the update model to primitive.M for use in the update operation.
 	updateM, err := StructToM(update)
 	if err != nil {
 		return nil, err
 	}
-	updateM = bson.M{"$set": updateM}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("UpdateOneByM", updateM); err != nil {
+		return nil, err
+	}
 
 	// opts = append(opts, options.FindOneAndUpdate().SetReturnDocument(options.After))
-	var updatedDocument Model
-	err = q.collection.FindOneAndUpdate(ctx, filter, updateM, opts...).Decode(&updatedDocument)
+	updatedDocument, err := retrying(ctx, q, "UpdateOneByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndUpdate(ctx, filter, updateM, opts...))
+	})
+	err = expectSingle(ctx, "UpdateOneByM", err)
 	if err != nil {
+		q.logWriteFailure(ctx, "UpdateOneByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateOne, filter, updateM)
 		return nil, err
 	}
+	q.verifyUpdate(ctx, "UpdateOneByM", updatedDocument, updateM)
 
 	q.MongoAdapter.Debug(
 		"Updated one document by filter",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Any("update", updateM),
-		zap.Any("updated_document", updatedDocument),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("update", updateM),
+		q.logValue("updated_document", updatedDocument),
 	)
 
-	return &updatedDocument, nil
+	return updatedDocument, nil
 }
 
-func (q *Querier[Model, IDModel]) UpdateMany(ctx context.Context, filter Model, update Model, opts ...*options.UpdateOptions) ([]*Model, error) {
+func (q *Querier[Model, IDModel]) UpdateMany(ctx context.Context, filter Model, update Model, opts ...*options.UpdateOptions) (result *UpdateResult[Model, IDModel], err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
 	// Convert filter and update models to primitive.M for use in the update operation.
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("UpdateMany", filter)
 	if err != nil {
 		return nil, err
 	}
+	if err = q.preflight(ctx, "UpdateMany", filterM); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "UpdateMany", filterM)
+	defer q.observe(span, time.Now(), "UpdateMany", filterM, &err)
 
 	updateM, err := StructToM(update)
 	if err != nil {
 		return nil, err
 	}
-	updateM = bson.M{"$set": updateM}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("UpdateMany", updateM); err != nil {
+		return nil, err
+	}
 
 	// Perform the update operation on multiple documents.
-	result, err := q.collection.UpdateMany(ctx, filterM, updateM, opts...)
+	result, err = q.updateMany(ctx, filterM, updateM, opts...)
 	if err != nil {
+		q.logWriteFailure(ctx, "UpdateMany", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateMany, filterM, updateM)
 		return nil, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.matched_count", result.MatchedCount), LogField("db.mongoquerier.modified_count", result.ModifiedCount))
+
 	q.MongoAdapter.Debug(
 		"Updated multiple documents by filter",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filterM),
-		zap.Any("update", updateM),
-		zap.Int("documents_modified", int(result.ModifiedCount)),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filterM),
+		q.logValue("update", updateM),
+		LogField("documents_modified", int(result.ModifiedCount)),
 	)
 
-	// Optionally, you can return some information about the updated documents if needed.
-	// Here, we'll return nil to indicate success without specific document details.
-	return nil, nil
+	if err = checkCounts(ctx, "UpdateMany", result.MatchedCount, result.ModifiedCount); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
-func (q *Querier[Model, IDModel]) UpdateManyByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.UpdateOptions) ([]*Model, error) {
+func (q *Querier[Model, IDModel]) UpdateManyByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.UpdateOptions) (result *UpdateResult[Model, IDModel], err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "UpdateManyByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "UpdateManyByM", filter)
+	defer q.observe(span, time.Now(), "UpdateManyByM", filter, &err)
+
 	// Convert the update model to primitive.M for use in the update operation.
 	updateM, err := StructToM(update)
 	if err != nil {
 		return nil, err
 	}
-	updateM = bson.M{"$set": updateM}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("UpdateManyByM", updateM); err != nil {
+		return nil, err
+	}
 
 	// Perform the update operation on multiple documents based on the filter.
 	// options := options.Update().SetUpsert(false)
-	result, err := q.collection.UpdateMany(ctx, filter, updateM, opts...)
+	result, err = q.updateMany(ctx, filter, updateM, opts...)
 	if err != nil {
+		q.logWriteFailure(ctx, "UpdateManyByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateMany, filter, updateM)
 		return nil, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.matched_count", result.MatchedCount), LogField("db.mongoquerier.modified_count", result.ModifiedCount))
+
 	q.MongoAdapter.Debug(
 		"Updated multiple documents by filter (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Any("update", updateM),
-		zap.Int("documents_modified", int(result.ModifiedCount)),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("update", updateM),
+		LogField("documents_modified", int(result.ModifiedCount)),
 	)
 
-	// Optionally, you can return some information about the updated documents if needed.
-	// Here, we'll return nil to indicate success without specific document details.
-	return nil, nil
+	if err = checkCounts(ctx, "UpdateManyByM", result.MatchedCount, result.ModifiedCount); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
-func (q *Querier[Model, IDModel]) ReplaceOne(ctx context.Context, filter Model, replacement Model, opts ...*options.FindOneAndReplaceOptions) (*Model, error) {
+func (q *Querier[Model, IDModel]) ReplaceOne(ctx context.Context, filter Model, replacement Model, opts ...*options.FindOneAndReplaceOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
 	// Convert filter and replacement models to primitive.M for use in the replace operation.
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("ReplaceOne", filter)
 	if err != nil {
 		return nil, err
 	}
+	if err = q.preflight(ctx, "ReplaceOne", filterM); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "ReplaceOne", filterM)
+	defer q.observe(span, time.Now(), "ReplaceOne", filterM, &err)
 
-	replacementM, err := StructToM(replacement)
+	replacementM, err := q.prepareReplacement(replacement)
 	if err != nil {
 		return nil, err
 	}
+	if err = q.checkSize("ReplaceOne", replacementM); err != nil {
+		return nil, err
+	}
 
 	// Perform the replace operation on a single document.
 	// options := options.Replace().SetUpsert(false)
-	var replacedDocument Model
-	err = q.collection.FindOneAndReplace(ctx, filterM, replacementM, opts...).Decode(&replacedDocument)
+	replacedDocument, err := retrying(ctx, q, "ReplaceOne", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndReplace(ctx, filterM, replacementM, opts...))
+	})
+	err = expectSingle(ctx, "ReplaceOne", err)
 	if err != nil {
+		q.logWriteFailure(ctx, "ReplaceOne", err)
+		err = q.queueOnOutage(ctx, err, QueuedReplaceOne, filterM, replacementM)
 		return nil, err
 	}
+	q.verifyUpdate(ctx, "ReplaceOne", replacedDocument, bson.M{"$set": replacementM})
 
 	q.MongoAdapter.Debug(
 		"Replaced one document by filter",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filterM),
-		zap.Any("replacement", replacementM),
-		zap.Any("replaced_document", replacedDocument),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filterM),
+		q.logValue("replacement", replacementM),
+		q.logValue("replaced_document", replacedDocument),
 	)
 
-	return &replacedDocument, nil
+	return replacedDocument, nil
 }
 
-func (q *Querier[Model, IDModel]) ReplaceOneByM(ctx context.Context, filter primitive.M, replacement Model, opts ...*options.FindOneAndReplaceOptions) (*Model, error) {
+func (q *Querier[Model, IDModel]) ReplaceOneByM(ctx context.Context, filter primitive.M, replacement Model, opts ...*options.FindOneAndReplaceOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "ReplaceOneByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "ReplaceOneByM", filter)
+	defer q.observe(span, time.Now(), "ReplaceOneByM", filter, &err)
+
 	// Convert the replacement model to primitive.M for use in the replace operation.
-	replacementM, err := StructToM(replacement)
+	replacementM, err := q.prepareReplacement(replacement)
 	if err != nil {
 		return nil, err
 	}
+	if err = q.checkSize("ReplaceOneByM", replacementM); err != nil {
+		return nil, err
+	}
 
 	// Perform the replace operation on a single document based on the filter.
 	// options := options.Replace().SetUpsert(false)
-	var replacedDocument Model
-	err = q.collection.FindOneAndReplace(ctx, filter, replacementM, opts...).Decode(&replacedDocument)
+	replacedDocument, err := retrying(ctx, q, "ReplaceOneByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndReplace(ctx, filter, replacementM, opts...))
+	})
+	err = expectSingle(ctx, "ReplaceOneByM", err)
 	if err != nil {
+		q.logWriteFailure(ctx, "ReplaceOneByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedReplaceOne, filter, replacementM)
 		return nil, err
 	}
+	q.verifyUpdate(ctx, "ReplaceOneByM", replacedDocument, bson.M{"$set": replacementM})
 
 	q.MongoAdapter.Debug(
 		"Replaced one document by filter (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Any("replacement", replacementM),
-		zap.Any("replaced_document", replacedDocument),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("replacement", replacementM),
+		q.logValue("replaced_document", replacedDocument),
 	)
 
-	return &replacedDocument, nil
+	return replacedDocument, nil
 }
 
 func (q *Querier[Model, IDModel]) DeleteOne(ctx context.Context, filter Model, opts ...*options.FindOneAndDeleteOptions) (document *Model, err error) {
-	filterM, err := StructToM(filter)
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	filterM, err := q.structToM("DeleteOne", filter)
 	if err != nil {
 		return
 	}
+	if err = q.preflight(ctx, "DeleteOne", filterM); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "DeleteOne", filterM)
+	defer q.observe(span, time.Now(), "DeleteOne", filterM, &err)
 
-	err = q.collection.FindOneAndDelete(
-		ctx,
-		filterM,
-		opts...,
-	).Decode(&document)
+	document, err = retrying(ctx, q, "DeleteOne", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndDelete(
+			ctx,
+			filterM,
+			opts...,
+		))
+	})
+	err = expectSingle(ctx, "DeleteOne", err)
 	if err != nil {
+		q.logWriteFailure(ctx, "DeleteOne", err)
+		err = q.queueOnOutage(ctx, err, QueuedDeleteOne, filterM)
 		return
 	}
 
 	q.MongoAdapter.Debug(
 		"Deleted one document",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("document", document),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("document", document),
 	)
 	return
 }
 
-func (q *Querier[Model, IDModel]) DeleteOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneAndDeleteOptions) (*Model, error) {
+func (q *Querier[Model, IDModel]) DeleteOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneAndDeleteOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "DeleteOneByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "DeleteOneByM", filter)
+	defer q.observe(span, time.Now(), "DeleteOneByM", filter, &err)
+
 	// Perform the delete operation on a single document based on the filter.
-	var deletedDocument Model
-	err := q.collection.FindOneAndDelete(ctx, filter, opts...).Decode(&deletedDocument)
+	deletedDocument, err := retrying(ctx, q, "DeleteOneByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndDelete(ctx, filter, opts...))
+	})
+	err = expectSingle(ctx, "DeleteOneByM", err)
 	if err != nil {
+		q.logWriteFailure(ctx, "DeleteOneByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedDeleteOne, filter)
 		return nil, err
 	}
 
 	q.MongoAdapter.Debug(
 		"Deleted one document by filter (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Any("deleted_document", deletedDocument),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("deleted_document", deletedDocument),
 	)
 
-	return &deletedDocument, nil
+	return deletedDocument, nil
 }
 
-func (q *Querier[Model, IDModel]) DeleteMany(ctx context.Context, filter Model, opts ...*options.DeleteOptions) (int64, error) {
+func (q *Querier[Model, IDModel]) DeleteMany(ctx context.Context, filter Model, opts ...*options.DeleteOptions) (deletedCount int64, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
 	// Convert the filter model to primitive.M for use in the delete operation.
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("DeleteMany", filter)
 	if err != nil {
 		return 0, err
 	}
+	if err = q.preflight(ctx, "DeleteMany", filterM); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "DeleteMany", filterM)
+	defer q.observe(span, time.Now(), "DeleteMany", filterM, &err)
 
 	// Perform the delete operation on multiple documents based on the filter.
-	result, err := q.collection.DeleteMany(ctx, filterM, opts...)
+	result, err := retrying(ctx, q, "DeleteMany", func() (*mongo.DeleteResult, error) {
+		return q.writeCollection(ctx).DeleteMany(ctx, filterM, opts...)
+	})
 	if err != nil {
+		q.logWriteFailure(ctx, "DeleteMany", err)
+		err = q.queueOnOutage(ctx, err, QueuedDeleteMany, filterM)
 		return 0, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.deleted_count", result.DeletedCount))
+
 	q.MongoAdapter.Debug(
 		"Deleted multiple documents by filter",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filterM),
-		zap.Int64("documents_deleted", result.DeletedCount),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filterM),
+		LogField("documents_deleted", result.DeletedCount),
 	)
 
+	if err = checkCounts(ctx, "DeleteMany", result.DeletedCount, result.DeletedCount); err != nil {
+		return result.DeletedCount, err
+	}
+
 	return result.DeletedCount, nil
 }
 
-func (q *Querier[Model, IDModel]) DeleteManyByM(ctx context.Context, filter primitive.M, opts ...*options.DeleteOptions) (int64, error) {
+func (q *Querier[Model, IDModel]) DeleteManyByM(ctx context.Context, filter primitive.M, opts ...*options.DeleteOptions) (deletedCount int64, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "DeleteManyByM", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "DeleteManyByM", filter)
+	defer q.observe(span, time.Now(), "DeleteManyByM", filter, &err)
+
 	// Perform the delete operation on multiple documents based on the filter.
-	result, err := q.collection.DeleteMany(ctx, filter, opts...)
+	result, err := retrying(ctx, q, "DeleteManyByM", func() (*mongo.DeleteResult, error) {
+		return q.writeCollection(ctx).DeleteMany(ctx, filter, opts...)
+	})
 	if err != nil {
+		q.logWriteFailure(ctx, "DeleteManyByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedDeleteMany, filter)
 		return 0, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.deleted_count", result.DeletedCount))
+
 	q.MongoAdapter.Debug(
 		"Deleted multiple documents by filter (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Int64("documents_deleted", result.DeletedCount),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("documents_deleted", result.DeletedCount),
 	)
 
+	if err = checkCounts(ctx, "DeleteManyByM", result.DeletedCount, result.DeletedCount); err != nil {
+		return result.DeletedCount, err
+	}
+
 	return result.DeletedCount, nil
 }
 
-func (q *Querier[Model, IDModel]) CountDocuments(ctx context.Context, filter Model, opts ...*options.CountOptions) (int64, error) {
+func (q *Querier[Model, IDModel]) CountDocuments(ctx context.Context, filter Model, opts ...*options.CountOptions) (count int64, err error) {
 	// Convert the filter model to primitive.M for use in the count operation.
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("CountDocuments", filter)
 	if err != nil {
 		return 0, err
 	}
+	if err = q.preflight(ctx, "CountDocuments", filterM); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "CountDocuments", filterM)
+	defer q.observe(span, time.Now(), "CountDocuments", filterM, &err)
 
 	// Perform the count operation on documents based on the filter.
-	count, err := q.collection.CountDocuments(ctx, filterM, opts...)
+	count, err = q.countDocuments(ctx, filterM, opts...)
 	if err != nil {
 		return 0, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", count))
+
 	q.MongoAdapter.Debug(
 		"Counted documents by filter",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filterM),
-		zap.Int64("documents_count", count),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filterM),
+		LogField("documents_count", count),
 	)
 
 	return count, nil
 }
 
-func (q *Querier[Model, IDModel]) CountDocumentsByM(ctx context.Context, filter primitive.M, opts ...*options.CountOptions) (int64, error) {
+func (q *Querier[Model, IDModel]) CountDocumentsByM(ctx context.Context, filter primitive.M, opts ...*options.CountOptions) (count int64, err error) {
+	if err := q.preflight(ctx, "CountDocumentsByM", filter); err != nil {
+		return 0, err
+	}
+	ctx, span := q.startOperation(ctx, "CountDocumentsByM", filter)
+	defer q.observe(span, time.Now(), "CountDocumentsByM", filter, &err)
+
 	// Perform the count operation on documents based on the filter.
-	count, err := q.collection.CountDocuments(ctx, filter, opts...)
+	count, err = q.countDocuments(ctx, filter, opts...)
 	if err != nil {
 		return 0, err
 	}
 
+	span.SetAttributes(LogField("db.mongoquerier.documents_count", count))
+
 	q.MongoAdapter.Debug(
 		"Counted documents by filter (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.Any("filter", filter),
-		zap.Int64("documents_count", count),
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("documents_count", count),
 	)
 
 	return count, nil
 }
 
-func (q *Querier[Model, IDModel]) Distinct(ctx context.Context, fieldName string, filter Model, opts ...*options.DistinctOptions) ([]interface{}, error) {
+func (q *Querier[Model, IDModel]) Distinct(ctx context.Context, fieldName string, filter Model, opts ...*options.DistinctOptions) (values []interface{}, err error) {
 	// Convert the filter model to primitive.M for use in the distinct operation.
-	filterM, err := StructToM(filter)
+	filterM, err := q.structToM("Distinct", filter)
 	if err != nil {
 		return nil, err
 	}
+	if err = q.preflight(ctx, "Distinct", filterM); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "Distinct", filterM)
+	defer q.observe(span, time.Now(), "Distinct", filterM, &err)
 
 	// Perform the distinct operation on the specified field based on the filter.
-	distinctValues, err := q.collection.Distinct(ctx, fieldName, filterM, opts...)
+	distinctValues, err := retrying(ctx, q, "Distinct", func() ([]interface{}, error) {
+		return q.readCollection(ctx).Distinct(ctx, fieldName, filterM, opts...)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	q.MongoAdapter.Debug(
 		"Retrieved distinct values for field",
-		zap.String("collection_name", q.collection.Name()),
-		zap.String("field_name", fieldName),
-		zap.Any("filter", filterM),
-		zap.Any("distinct_values", distinctValues),
+		LogField("collection_name", q.collection.Name()),
+		LogField("field_name", fieldName),
+		q.logValue("filter", filterM),
+		q.logFieldValues("distinct_values", fieldName, distinctValues),
 	)
 
 	return distinctValues, nil
 }
 
-func (q *Querier[Model, IDModel]) DistinctByM(ctx context.Context, fieldName string, filter primitive.M, opts ...*options.DistinctOptions) ([]interface{}, error) {
+func (q *Querier[Model, IDModel]) DistinctByM(ctx context.Context, fieldName string, filter primitive.M, opts ...*options.DistinctOptions) (values []interface{}, err error) {
+	if err := q.preflight(ctx, "DistinctByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "DistinctByM", filter)
+	defer q.observe(span, time.Now(), "DistinctByM", filter, &err)
+
 	// Perform the distinct operation on the specified field based on the filter.
-	distinctValues, err := q.collection.Distinct(ctx, fieldName, filter, opts...)
+	distinctValues, err := retrying(ctx, q, "DistinctByM", func() ([]interface{}, error) {
+		return q.readCollection(ctx).Distinct(ctx, fieldName, filter, opts...)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	q.MongoAdapter.Debug(
 		"Retrieved distinct values for field (primitive.M)",
-		zap.String("collection_name", q.collection.Name()),
-		zap.String("field_name", fieldName),
-		zap.Any("filter", filter),
-		zap.Any("distinct_values", distinctValues),
+		LogField("collection_name", q.collection.Name()),
+		LogField("field_name", fieldName),
+		q.logValue("filter", filter),
+		q.logFieldValues("distinct_values", fieldName, distinctValues),
 	)
 
 	return distinctValues, nil
 }
 
-func (q *Querier[Model, IDModel]) DeleteCollection(ctx context.Context, collectionName string) error {
+func (q *Querier[Model, IDModel]) DeleteCollection(ctx context.Context, collectionName string) (err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "DeleteCollection", nil); err != nil {
+		return err
+	}
+	ctx, span := q.startOperation(ctx, "DeleteCollection", nil)
+	defer q.observe(span, time.Now(), "DeleteCollection", nil, &err)
+
 	if collectionName == q.collection.Name() {
-		return q.collection.Drop(ctx)
+		return q.tenantCollection(ctx).Drop(ctx)
 	} else {
 		return ErrCollectionNameMismatch
 	}
@@ -0,0 +1,94 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("operation rate limit exceeded")
+
+// RateLimiter is a token bucket admitting up to Rate operations per second,
+// in bursts of up to Burst. Operations over the rate are rejected rather
+// than delayed, so callers shed load instead of queuing it. The rate can be
+// changed at runtime, e.g. from a ControlPanel.
+//
+//	mongoAdapter.RateLimit = mongoquerier.NewRateLimiter(500, 100)
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // operations per second, 0 for no limit
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter admitting rate operations per second in
+// bursts of up to burst (rate when burst < 1). A zero rate admits them all.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{burst: float64(burst)}
+	l.SetRate(rate)
+	return l
+}
+
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// SetRate changes the rate; a zero or negative one lifts the limit.
+func (l *RateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rate < 0 {
+		rate = 0
+	}
+	l.rate = rate
+	l.tokens = l.capacity()
+	l.last = time.Now()
+}
+
+// capacity is the size of the bucket: burst, or a second's worth of
+// operations without one, at least one operation.
+func (l *RateLimiter) capacity() float64 {
+	capacity := l.burst
+	if capacity < 1 {
+		capacity = l.rate
+	}
+	if capacity < 1 {
+		return 1
+	}
+	return capacity
+}
+
+// Allow takes a token, reporting whether the operation is admitted.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return true
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if capacity := l.capacity(); l.tokens > capacity {
+		l.tokens = capacity
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// admit is Allow as an error, nil on a nil limiter.
+func (l *RateLimiter) admit() error {
+	if l == nil || l.Allow() {
+		return nil
+	}
+	return fmt.Errorf("%w: %g operations per second", ErrRateLimited, l.Rate())
+}
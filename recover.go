@@ -0,0 +1,82 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+// RecoverPanics turns panics in decoding and reflection paths (StructToM,
+// CastStruct, decoding documents and running AfterRead hooks) into
+// PanicErrors. Disable it during development to crash with the original
+// stack trace instead.
+var RecoverPanics = true
+
+var ErrPanic = errors.New("recovered from panic")
+
+// PanicError describes a recovered panic; errors.Is(err, ErrPanic) matches
+// it, and it unwraps to the panic value when that's an error.
+type PanicError struct {
+	Operation string
+	// Type and Field locate the value being processed, when known.
+	Type  string
+	Field string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	location := e.Type
+	if e.Field != "" {
+		location += " field " + e.Field
+	}
+	if location == "" {
+		return fmt.Sprintf("%s panicked: %v", e.Operation, e.Value)
+	}
+	return fmt.Sprintf("%s panicked on %s: %v", e.Operation, location, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic must be deferred directly; it stores the recovered panic in
+// err. field, when not nil, points at the field being processed.
+func recoverPanic(err *error, operation string, t reflect.Type, field *string) {
+	if !RecoverPanics {
+		return
+	}
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	panicErr := &PanicError{Operation: operation, Value: value, Stack: debug.Stack()}
+	if t != nil {
+		panicErr.Type = t.String()
+	}
+	if field != nil {
+		panicErr.Field = *field
+	}
+	*err = panicErr
+}
+
+func (q *Querier[Model, IDModel]) logPanic(err *error) {
+	var panicErr *PanicError
+	if errors.As(*err, &panicErr) {
+		q.MongoAdapter.Error(
+			"Recovered from panic",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", panicErr.Operation),
+			LogField("type", panicErr.Type),
+			LogField("panic", panicErr.Value),
+			LogField("stack", panicErr.Stack),
+		)
+	}
+}
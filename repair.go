@@ -0,0 +1,108 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const DefaultReadRepairTimeout = 10 * time.Second
+
+// ReadRepair lazily migrates documents as they're read. Repair receives the
+// stored document and its decoded model, upgrades the model in place and
+// reports whether anything changed.
+type ReadRepair[Model any] struct {
+	Repair func(raw bson.Raw, document *Model) (repaired bool, err error)
+
+	// WriteBack asynchronously persists the fields Repair changed, with $set
+	// and $unset. The write is guarded by the originally read state, so
+	// documents modified in the meantime are left for the next read to
+	// repair. Projected reads, aggregations and reads in a transaction aren't
+	// written back.
+	WriteBack bool
+	Timeout   time.Duration
+}
+
+func (q *Querier[Model, IDModel]) repair(ctx context.Context, raw bson.Raw, document *Model) error {
+	if q.ReadRepair == nil || q.ReadRepair.Repair == nil {
+		return nil
+	}
+
+	// Fields the read didn't return are zero before and after Repair, so
+	// only those it changed are written back
+	var before bson.Raw
+	writeBack := q.ReadRepair.WriteBack && !isPartialRead(ctx) && !inTransaction(ctx)
+	if writeBack {
+		var err error
+		if before, err = q.encodeDocument(*document); err != nil {
+			q.MongoAdapter.Error("unable to encode document before repair", LogError(err))
+			writeBack = false
+		}
+	}
+
+	repaired, err := q.ReadRepair.Repair(raw, document)
+	if err != nil || !repaired {
+		return err
+	}
+
+	q.MongoAdapter.Debug(
+		"Repaired document on read",
+		LogField("collection_name", q.collection.Name()),
+		LogField("_id", raw.Lookup("_id")),
+	)
+
+	if !writeBack {
+		return nil
+	}
+	after, err := q.encodeDocument(*document)
+	if err != nil {
+		q.MongoAdapter.Error("unable to prepare repaired document", LogError(err))
+		return nil
+	}
+	if changes := diffDocuments("", before, after, nil); len(changes) > 0 {
+		// Detached, the write outlives the read but stays in its tenant's
+		// database
+		go q.writeBack(detachedContext{ctx}, raw, diffUpdate(changes))
+	}
+	return nil
+}
+
+func (q *Querier[Model, IDModel]) writeBack(ctx context.Context, raw bson.Raw, update bson.M) {
+	timeout := q.ReadRepair.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadRepairTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	elements, err := raw.Elements()
+	if err != nil {
+		q.MongoAdapter.Error("unable to read repaired document", LogError(err))
+		return
+	}
+
+	// Only update the document if it's still what we read
+	filter := bson.D{}
+	for _, element := range elements {
+		filter = append(filter, bson.E{Key: element.Key(), Value: element.Value()})
+	}
+
+	res, err := q.tenantCollection(ctx).UpdateOne(ctx, filter, update)
+	if err != nil {
+		q.MongoAdapter.Error(
+			"unable to write back repaired document",
+			LogField("collection_name", q.collection.Name()),
+			LogField("_id", raw.Lookup("_id")),
+			LogError(err),
+		)
+		return
+	}
+
+	q.MongoAdapter.Debug(
+		"Wrote back repaired document",
+		LogField("collection_name", q.collection.Name()),
+		LogField("_id", raw.Lookup("_id")),
+		LogField("documents_modified", res.ModifiedCount),
+	)
+}
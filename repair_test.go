@@ -0,0 +1,44 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type repairedUser struct {
+	ID      string `bson:"_id"`
+	Name    string `bson:"name"`
+	Email   string `bson:"email"`
+	Version int    `bson:"version,omitempty"`
+	Legacy  string `bson:"legacy,omitempty"`
+}
+
+func TestRepairWritesBackChangedFieldsOnly(t *testing.T) {
+	q := NewQuerier[repairedUser](newTestAdapter(t), "users")
+
+	// Read with only _id and legacy: name and email are zero, not empty
+	document := repairedUser{ID: "u1", Legacy: "Ada"}
+	before, err := q.encodeDocument(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document.Name, document.Legacy, document.Version = document.Legacy, "", 2
+	after, err := q.encodeDocument(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := diffUpdate(diffDocuments("", before, after, nil))
+	set, _ := update["$set"].(bson.M)
+	unset, _ := update["$unset"].(bson.M)
+	if len(set) != 2 || set["name"] == nil || set["version"] == nil {
+		t.Errorf("$set = %v, want name and version", set)
+	}
+	if _, ok := set["email"]; ok {
+		t.Error("$set overwrites the unread email")
+	}
+	if len(unset) != 1 || unset["legacy"] == nil {
+		t.Errorf("$unset = %v, want legacy", unset)
+	}
+}
@@ -0,0 +1,240 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrValueReserved       = errors.New("value is already reserved")
+	ErrReservationNotFound = errors.New("reservation not found")
+)
+
+// Reservation claims a value within a scope (e.g. "username") across every
+// collection that agrees to reserve before writing. Pending reservations carry
+// an ExpiresAt and are removed by a TTL index; confirmed ones are permanent.
+type Reservation struct {
+	Scope     string     `bson:"scope" json:"scope"`
+	Value     string     `bson:"value" json:"value"`
+	Owner     string     `bson:"owner" json:"owner"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// ReservationStore keeps reservations in a collection, the one in the
+// database of ctx's tenant when the adapter routes tenants.
+type ReservationStore struct {
+	*MongoAdapter
+	collection *mongo.Collection
+
+	// indexed holds the tenant databases whose collection has been indexed
+	indexed sync.Map
+}
+
+// NewReservationStore creates the store's indexes. When the adapter routes
+// tenants, they're created in each tenant's database on its first use
+// instead.
+func NewReservationStore(ctx context.Context, madp *MongoAdapter, collectionName string) (*ReservationStore, error) {
+	rs := &ReservationStore{
+		MongoAdapter: madp,
+		collection:   madp.GetCollection(collectionName),
+	}
+	if madp.Tenants == nil {
+		if err := rs.createIndexes(ctx, rs.collection); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+func (rs *ReservationStore) createIndexes(ctx context.Context, collection *mongo.Collection) error {
+	// Uniqueness is enforced by the server, expiry by the TTL monitor
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "value", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		rs.MongoAdapter.Error(
+			"unable to create reservation indexes",
+			LogField("database", collection.Database().Name()),
+			LogField("collection_name", collection.Name()),
+			LogError(err),
+		)
+	}
+	return err
+}
+
+// authorizedCollection runs the Authorize hook for operation and returns the
+// store's collection in the database of ctx's tenant, indexing it on first
+// use.
+func (rs *ReservationStore) authorizedCollection(ctx context.Context, operation string, filter primitive.M) (*mongo.Collection, error) {
+	if err := rs.MongoAdapter.authorize(ctx, describeOperation(rs.collection.Name(), operation, filter)); err != nil {
+		return nil, err
+	}
+	if rs.MongoAdapter.Tenants == nil {
+		return rs.collection, nil
+	}
+	collection, err := rs.MongoAdapter.collectionFor(ctx, rs.collection.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	database := collection.Database().Name()
+	if _, ok := rs.indexed.Load(database); !ok {
+		if err := rs.createIndexes(ctx, collection); err != nil {
+			return nil, err
+		}
+		rs.indexed.Store(database, true)
+	}
+	return collection, nil
+}
+
+// Reserve claims value within scope for owner until ttl elapses. Reserving a
+// value again with the same owner refreshes the expiry of a pending
+// reservation, and returns a confirmed one unchanged, still permanent.
+func (rs *ReservationStore) Reserve(ctx context.Context, scope string, value string, owner string, ttl time.Duration) (*Reservation, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	// Take over the value if it's free, pending for us, or held by a
+	// reservation that expired but hasn't been collected by the TTL monitor
+	// yet. Confirmed reservations have no expiry to set back
+	filter := bson.M{
+		"scope": scope,
+		"value": value,
+		"$or": bson.A{
+			bson.M{"owner": owner, "expires_at": bson.M{"$exists": true}},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"owner":      owner,
+			"created_at": now,
+			"expires_at": expiresAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	collection, err := rs.authorizedCollection(ctx, "Reserve", filter)
+	if err != nil {
+		return nil, err
+	}
+	var reservation Reservation
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&reservation)
+	if err != nil {
+		// The upsert collides with the unique index when someone else holds
+		// the value, or we confirmed it
+		if mongo.IsDuplicateKeyError(err) {
+			confirmed := bson.M{"scope": scope, "value": value, "owner": owner, "expires_at": bson.M{"$exists": false}}
+			if findErr := collection.FindOne(ctx, confirmed).Decode(&reservation); findErr == nil {
+				return &reservation, nil
+			}
+			return nil, ErrValueReserved
+		}
+		return nil, err
+	}
+
+	rs.MongoAdapter.Debug(
+		"Reserved value",
+		LogField("collection_name", collection.Name()),
+		LogField("scope", scope),
+		LogField("owner", owner),
+	)
+	return &reservation, nil
+}
+
+// Confirm makes a pending reservation permanent.
+func (rs *ReservationStore) Confirm(ctx context.Context, scope string, value string, owner string) error {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	filter := bson.M{"scope": scope, "value": value, "owner": owner}
+	collection, err := rs.authorizedCollection(ctx, "Confirm", filter)
+	if err != nil {
+		return err
+	}
+	res, err := collection.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"expires_at": ""}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrReservationNotFound
+	}
+
+	rs.MongoAdapter.Debug(
+		"Confirmed reservation",
+		LogField("collection_name", collection.Name()),
+		LogField("scope", scope),
+		LogField("owner", owner),
+	)
+	return nil
+}
+
+// Release frees a value held by owner, whether pending or confirmed.
+func (rs *ReservationStore) Release(ctx context.Context, scope string, value string, owner string) error {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	filter := bson.M{"scope": scope, "value": value, "owner": owner}
+	collection, err := rs.authorizedCollection(ctx, "Release", filter)
+	if err != nil {
+		return err
+	}
+	res, err := collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrReservationNotFound
+	}
+
+	rs.MongoAdapter.Debug(
+		"Released reservation",
+		LogField("collection_name", collection.Name()),
+		LogField("scope", scope),
+		LogField("owner", owner),
+	)
+	return nil
+}
+
+func (rs *ReservationStore) Lookup(ctx context.Context, scope string, value string) (*Reservation, error) {
+	filter := bson.M{
+		"scope": scope,
+		"value": value,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now().UTC()}},
+		},
+	}
+
+	collection, err := rs.authorizedCollection(ctx, "Lookup", filter)
+	if err != nil {
+		return nil, err
+	}
+	var reservation Reservation
+	err = collection.FindOne(ctx, filter).Decode(&reservation)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+	return &reservation, nil
+}
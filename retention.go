@@ -0,0 +1,306 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultRetentionBatchSize = 1000
+
+var ErrInvalidRetentionPolicy = errors.New("invalid retention policy")
+
+// RetentionPolicy declares how long documents of a collection are kept.
+// Documents older than MaxAge (by AgeField) are purged, then the oldest
+// documents beyond MaxCount. With ArchiveCollection set, documents are
+// copied there before being deleted; with ArchiveStore, they're uploaded as
+// canonical NDJSON objects, one per batch, under ArchivePrefix/<collection>/.
+// When the adapter routes tenants, the policy applies to the collections of
+// ctx's tenant, archived under ArchivePrefix/<database>/<collection>/.
+type RetentionPolicy struct {
+	Collection string
+
+	// AgeField orders documents by age; it defaults to _id, whose ObjectID
+	// embeds the creation time.
+	AgeField string
+	MaxAge   time.Duration
+	MaxCount int64
+
+	ArchiveCollection string
+	ArchiveStore      ObjectStore
+	ArchivePrefix     string
+	ArchiveOptions    ObjectOptions
+	BatchSize         int
+}
+
+type RetentionReport struct {
+	Collection string
+	Archived   int64
+	Purged     int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+type RetentionEngine struct {
+	*MongoAdapter
+	Policies []RetentionPolicy
+
+	// OnReport receives the report of every applied policy.
+	OnReport func(report RetentionReport)
+}
+
+func NewRetentionEngine(madp *MongoAdapter, policies ...RetentionPolicy) *RetentionEngine {
+	return &RetentionEngine{
+		MongoAdapter: madp,
+		Policies:     policies,
+	}
+}
+
+// Run applies every policy, continuing past failures, and returns the
+// reports together with the first error encountered.
+func (re *RetentionEngine) Run(ctx context.Context) ([]RetentionReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	var reports []RetentionReport
+	var firstErr error
+	for _, policy := range re.Policies {
+		report, err := re.Apply(ctx, policy)
+		reports = append(reports, report)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return reports, firstErr
+}
+
+// Schedule registers the engine on a scheduler.
+func (re *RetentionEngine) Schedule(s *Scheduler, interval time.Duration) {
+	s.Every("retention", interval, func(ctx context.Context) error {
+		_, err := re.Run(ctx)
+		return err
+	})
+}
+
+func (re *RetentionEngine) Apply(ctx context.Context, policy RetentionPolicy) (RetentionReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	report := RetentionReport{Collection: policy.Collection, StartedAt: time.Now()}
+	if policy.Collection == "" || (policy.MaxAge <= 0 && policy.MaxCount <= 0) {
+		return report, ErrInvalidRetentionPolicy
+	}
+
+	ageField := policy.AgeField
+	if ageField == "" {
+		ageField = "_id"
+	}
+	if err := re.MongoAdapter.authorize(ctx, describeOperation(policy.Collection, "ApplyRetention", nil)); err != nil {
+		return re.finish(report, err)
+	}
+	if policy.ArchiveCollection != "" {
+		if err := re.MongoAdapter.authorize(ctx, describeOperation(policy.ArchiveCollection, "ApplyRetention", nil)); err != nil {
+			return re.finish(report, err)
+		}
+	}
+	collection, err := re.MongoAdapter.collectionFor(ctx, policy.Collection)
+	if err != nil {
+		return re.finish(report, err)
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := time.Now().Add(-policy.MaxAge)
+		var bound interface{} = cutoff
+		if ageField == "_id" {
+			bound = primitive.NewObjectIDFromTimestamp(cutoff)
+		}
+
+		err := re.purge(ctx, collection, policy, bson.M{ageField: bson.M{"$lt": bound}}, ageField, 0, &report)
+		if err != nil {
+			return re.finish(report, err)
+		}
+	}
+
+	if policy.MaxCount > 0 {
+		// The estimate comes from metadata, which orphaned documents and
+		// unclean shutdowns skew; the count must match what purge deletes
+		filter := bson.M{}
+		count, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return re.finish(report, err)
+		}
+
+		if excess := count - policy.MaxCount; excess > 0 {
+			err = re.purge(ctx, collection, policy, filter, ageField, excess, &report)
+			if err != nil {
+				return re.finish(report, err)
+			}
+		}
+	}
+
+	return re.finish(report, nil)
+}
+
+func (re *RetentionEngine) finish(report RetentionReport, err error) (RetentionReport, error) {
+	report.FinishedAt = time.Now()
+	if err != nil {
+		re.MongoAdapter.Error(
+			"Retention policy failed",
+			LogField("collection_name", report.Collection),
+			LogField("documents_archived", report.Archived),
+			LogField("documents_purged", report.Purged),
+			LogError(err),
+		)
+	} else {
+		re.MongoAdapter.Info(
+			"Applied retention policy",
+			LogField("collection_name", report.Collection),
+			LogField("documents_archived", report.Archived),
+			LogField("documents_purged", report.Purged),
+			LogField("duration", report.FinishedAt.Sub(report.StartedAt)),
+		)
+	}
+
+	if re.OnReport != nil {
+		re.OnReport(report)
+	}
+	return report, err
+}
+
+// purge deletes (and archives) the oldest documents matching filter in
+// batches; a positive limit bounds how many are purged.
+func (re *RetentionEngine) purge(ctx context.Context, collection *mongo.Collection, policy RetentionPolicy, filter bson.M, ageField string, limit int64, report *RetentionReport) error {
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultRetentionBatchSize
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: ageField, Value: 1}}).
+		SetBatchSize(int32(batchSize))
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+	if policy.ArchiveCollection == "" && policy.ArchiveStore == nil {
+		findOptions.SetProjection(bson.M{"_id": 1})
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var batch []bson.Raw
+	for cursor.Next(ctx) {
+		batch = append(batch, append(bson.Raw(nil), cursor.Current...))
+		if len(batch) == batchSize {
+			if err := re.purgeBatch(ctx, collection, policy, batch, report); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return re.purgeBatch(ctx, collection, policy, batch, report)
+	}
+	return nil
+}
+
+func (re *RetentionEngine) purgeBatch(ctx context.Context, collection *mongo.Collection, policy RetentionPolicy, batch []bson.Raw, report *RetentionReport) error {
+	ids := make(bson.A, 0, len(batch))
+	for _, document := range batch {
+		ids = append(ids, document.Lookup("_id"))
+	}
+
+	if policy.ArchiveStore != nil {
+		var database string
+		if re.MongoAdapter.Tenants != nil {
+			database = collection.Database().Name()
+		}
+		if err := archiveToStore(ctx, policy, database, batch); err != nil {
+			return err
+		}
+		if policy.ArchiveCollection == "" {
+			report.Archived += int64(len(batch))
+		}
+	}
+
+	if policy.ArchiveCollection != "" {
+		documents := make([]interface{}, 0, len(batch))
+		for _, document := range batch {
+			documents = append(documents, document)
+		}
+
+		// Archiving is idempotent: documents archived by an interrupted
+		// earlier run are duplicates and can be ignored
+		archive, err := re.MongoAdapter.collectionFor(ctx, policy.ArchiveCollection)
+		if err != nil {
+			return err
+		}
+		archived := int64(len(documents))
+		_, err = archive.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+		if err != nil {
+			var bulkErr mongo.BulkWriteException
+			if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+				return err
+			}
+			for _, writeErr := range bulkErr.WriteErrors {
+				if writeErr.Code != 11000 {
+					return err
+				}
+			}
+			archived -= int64(len(bulkErr.WriteErrors))
+		}
+		report.Archived += archived
+	}
+
+	res, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	report.Purged += res.DeletedCount
+	return nil
+}
+
+// archiveToStore uploads batch as a canonical NDJSON object keyed by a hash of its
+// _ids, so a batch retried after an interrupted run overwrites its earlier
+// upload instead of duplicating it. A tenant's database, when not empty,
+// keeps its batches apart from other tenants'.
+func archiveToStore(ctx context.Context, policy RetentionPolicy, database string, batch []bson.Raw) error {
+	hash := sha256.New()
+	for _, document := range batch {
+		hash.Write(document.Lookup("_id").Value)
+	}
+	key := path.Join(policy.ArchivePrefix, database, policy.Collection, hex.EncodeToString(hash.Sum(nil)[:16])+".ndjson")
+
+	opts := policy.ArchiveOptions
+	if opts.ContentType == "" {
+		opts.ContentType = "application/x-ndjson"
+	}
+	return Upload(ctx, policy.ArchiveStore, key, opts, func(w io.Writer) error {
+		encoder, err := CanonicalNDJSON()(w)
+		if err != nil {
+			return err
+		}
+		for _, document := range batch {
+			if err := encoder.Encode(document); err != nil {
+				return err
+			}
+		}
+		return encoder.Close()
+	})
+}
@@ -0,0 +1,191 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	DefaultRetryAttempts   = 3
+	DefaultInitialBackoff  = 100 * time.Millisecond
+	DefaultMaxRetryBackoff = 2 * time.Second
+)
+
+// transientCodes are the server error codes of failures that go away on
+// their own: elections, shutdowns and unreachable hosts.
+var transientCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsTransient reports whether err is a failure worth retrying: a network
+// error, no server to select, an error the server labels retryable, or one
+// of a primary stepping down or a node shutting down.
+func IsTransient(err error) bool {
+	if mongo.IsNetworkError(err) || errors.Is(err, topology.ErrServerSelectionTimeout) {
+		return true
+	}
+
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) && (labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for _, code := range transientCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RetryPolicy retries the querier operations failing with transient errors
+// (see IsTransient), backing off exponentially between attempts:
+//
+//	orders.Retry = &mongoquerier.RetryPolicy{MaxAttempts: 5, Jitter: 0.5}
+//
+// Only reads are retried unless Writes is set. Operations in a session
+// aren't retried: a transaction is retried as a whole, by WithTransaction.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, DefaultRetryAttempts when zero.
+	MaxAttempts int
+	// InitialBackoff doubles after every attempt, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter shortens each backoff by a random fraction up to it (0 to 1),
+	// so clients failing together don't retry together.
+	Jitter float64
+	// Writes retries writes too. The driver already retries a write once
+	// without applying it twice; retries from the policy don't have that
+	// guarantee, so only set it for idempotent writes.
+	Writes bool
+	// Retryable overrides IsTransient.
+	Retryable func(err error) bool
+
+	retries   atomic.Int64
+	recovered atomic.Int64
+	exhausted atomic.Int64
+}
+
+// RetryStats counts the retries of a RetryPolicy: operations that succeeded
+// after a retry are Recovered, those failing on their last attempt
+// Exhausted.
+type RetryStats struct {
+	Retries   int64
+	Recovered int64
+	Exhausted int64
+}
+
+func (p *RetryPolicy) Stats() RetryStats {
+	return RetryStats{
+		Retries:   p.retries.Load(),
+		Recovered: p.recovered.Load(),
+		Exhausted: p.exhausted.Load(),
+	}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return DefaultRetryAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// backoff returns the wait before the attempt following attempt (1-based).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff, maxBackoff := p.InitialBackoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	if p.Jitter > 0 {
+		backoff -= time.Duration(rand.Float64() * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// retrying runs fn, the server call of operation, retrying it by the
+// querier's RetryPolicy. The adapter's chaos rules are applied to every
+// attempt, so injected faults take the path of real ones.
+func retrying[T any, Model any, IDModel any](ctx context.Context, q *Querier[Model, IDModel], operation string, call func() (T, error)) (T, error) {
+	descriptor := describeOperation(q.collection.Name(), operation, nil)
+	fn := func() (T, error) {
+		if err := q.MongoAdapter.injectChaos(ctx, descriptor); err != nil {
+			var zero T
+			return zero, err
+		}
+		return call()
+	}
+
+	result, err := fn()
+	policy := q.Retry
+	if err == nil || policy == nil || inSession(ctx) || (operationKind(operation) == OperationWrite && !policy.Writes) {
+		return result, err
+	}
+
+	for attempt := 1; attempt < policy.maxAttempts() && policy.retryable(err); attempt++ {
+		backoff := policy.backoff(attempt)
+		q.MongoAdapter.Warn(
+			"Retrying operation",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("attempt", attempt),
+			LogField("backoff", backoff),
+			LogError(err),
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			// Matching ctx's error too, as the cancellation contract has it
+			timer.Stop()
+			return result, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		policy.retries.Add(1)
+		if result, err = fn(); err == nil {
+			policy.recovered.Add(1)
+			return result, nil
+		}
+	}
+
+	if policy.retryable(err) {
+		policy.exhausted.Add(1)
+	}
+	return result, err
+}
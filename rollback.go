@@ -0,0 +1,158 @@
+package mongoquerier
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RollbackT is the part of testing.TB RollbackContext uses.
+type RollbackT interface {
+	Helper()
+	Cleanup(fn func())
+	Fatalf(format string, args ...interface{})
+}
+
+// RollbackContext returns a context whose querier writes are undone when the
+// test ends, so integration tests don't need to drop the database between
+// cases:
+//
+//	func TestCheckout(t *testing.T) {
+//		ctx := mongoquerier.RollbackContext(t, mongoAdapter)
+//		...
+//	}
+//
+// See BeginRollback.
+func RollbackContext(t RollbackT, madp *MongoAdapter) context.Context {
+	t.Helper()
+
+	ctx, rollback, err := madp.BeginRollback(context.Background())
+	if err != nil {
+		t.Fatalf("unable to begin rollback: %v", err)
+	}
+	t.Cleanup(rollback)
+	return ctx
+}
+
+// WithRollback runs fn with a context from BeginRollback and undoes its writes
+// afterwards, whatever fn returns.
+func (madp *MongoAdapter) WithRollback(ctx context.Context, fn func(ctx context.Context) error) error {
+	rollbackCtx, rollback, err := madp.BeginRollback(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollback()
+
+	return fn(rollbackCtx)
+}
+
+// BeginRollback returns a context whose writes are undone by calling
+// rollback. On replica sets and sharded clusters the context carries a
+// transaction that rollback aborts; operations using it can't run
+// concurrently or create collections and indexes implicitly on servers
+// before 4.4. On standalone servers, which have no transactions, the
+// documents inserted or upserted through queriers (InsertOne, InsertMany,
+// Upsert, CreateUnlessExists and BulkWrite upserts) are tracked and deleted
+// instead; updates and deletes of existing documents aren't undone.
+func (madp *MongoAdapter) BeginRollback(ctx context.Context) (rollbackCtx context.Context, rollback func(), err error) {
+	transactions, err := madp.supportsTransactions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !transactions {
+		tracker := &writeTracker{inserted: map[trackedNamespace][]interface{}{}}
+		return context.WithValue(ctx, writeTrackerKey{}, tracker), func() { madp.deleteTracked(tracker) }, nil
+	}
+
+	session, err := madp.Client.StartSession()
+	if err != nil {
+		madp.Error("unable to start session", LogError(err))
+		return nil, nil, err
+	}
+	if err = session.StartTransaction(); err != nil {
+		session.EndSession(ctx)
+		return nil, nil, err
+	}
+
+	rollback = func() {
+		if err := session.AbortTransaction(context.Background()); err != nil {
+			madp.Warn("unable to abort rollback transaction", LogError(err))
+		}
+		session.EndSession(context.Background())
+		madp.Debug("Rolled back transaction")
+	}
+	return mongo.NewSessionContext(ctx, session), rollback, nil
+}
+
+// supportsTransactions reports whether the server is a replica set member or
+// a mongos.
+func (madp *MongoAdapter) supportsTransactions(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := madp.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+type writeTrackerKey struct{}
+
+// writeTracker records the _ids of documents inserted under a rollback
+// context on servers without transactions.
+type writeTracker struct {
+	mu       sync.Mutex
+	inserted map[trackedNamespace][]interface{}
+}
+
+// trackedNamespace is the database, the tenant's when the adapter routes
+// tenants, and the collection of tracked documents.
+type trackedNamespace struct {
+	database   string
+	collection string
+}
+
+func (q *Querier[Model, IDModel]) trackInserted(ctx context.Context, ids ...interface{}) {
+	tracker, ok := ctx.Value(writeTrackerKey{}).(*writeTracker)
+	if !ok || len(ids) == 0 {
+		return
+	}
+
+	collection := q.tenantCollection(ctx)
+	namespace := trackedNamespace{database: collection.Database().Name(), collection: collection.Name()}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.inserted[namespace] = append(tracker.inserted[namespace], ids...)
+}
+
+func (madp *MongoAdapter) deleteTracked(tracker *writeTracker) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	for namespace, ids := range tracker.inserted {
+		collection := madp.GetCollectionIn(namespace.database, namespace.collection)
+		res, err := collection.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			madp.Warn(
+				"unable to delete tracked documents",
+				LogField("database", namespace.database),
+				LogField("collection_name", namespace.collection),
+				LogError(err),
+			)
+			continue
+		}
+
+		madp.Debug(
+			"Deleted tracked documents",
+			LogField("database", namespace.database),
+			LogField("collection_name", namespace.collection),
+			LogField("documents_deleted", res.DeletedCount),
+		)
+	}
+	tracker.inserted = map[trackedNamespace][]interface{}{}
+}
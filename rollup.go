@@ -0,0 +1,340 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultRollupStateCollection = "mq_rollups"
+	DefaultRollupLag             = time.Minute
+)
+
+var ErrInvalidRollup = errors.New("invalid rollup")
+
+// Rollup declares a pre-aggregation of Source into Target: one document per
+// combination of GroupBy fields (its _id), with one field per Aggregations
+// entry. Aggregations must use $sum, $min or $max, which can be merged
+// incrementally, e.g. {"orders": {"$sum": 1}, "revenue": {"$sum": "$total"}}.
+//
+// The target's _id holds the GroupBy fields, dotted ones under their path
+// with underscores (customer.country as customer_country).
+//
+// Rollups are maintained by scheduled merges of the documents inserted since
+// the previous merge (by _id, or TimeField). Source documents must be
+// insert-only with ObjectID _ids; Rebuild recomputes a rollup after updates
+// or deletes. When the adapter routes tenants, merges apply to the
+// collections of ctx's tenant, each with its own watermark.
+type Rollup struct {
+	Name         string
+	Source       string
+	Target       string
+	GroupBy      []string
+	Aggregations map[string]bson.M
+	// Filter restricts the source documents rolled up.
+	Filter bson.M
+	// Lag leaves the most recent documents for the next merge, since
+	// ObjectIDs from different clients aren't strictly increasing.
+	Lag time.Duration
+	// TimeField ranges merges by a date the server sets on insert
+	// ($currentDate or $$NOW) instead of _id. An ObjectID takes its time
+	// from the inserting client's clock, so with _id, documents from a
+	// client whose clock runs behind the server's by more than Lag are
+	// inserted behind the watermark and never rolled up until Rebuild.
+	TimeField string
+}
+
+type RollupReport struct {
+	Rollup  string
+	Rebuilt bool
+	// From and Until bound the merged _ids, or with TimeField, the times
+	// of their timestamps.
+	From       primitive.ObjectID
+	Until      primitive.ObjectID
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+type RollupEngine struct {
+	*MongoAdapter
+	Rollups []Rollup
+	// StateCollection stores each rollup's watermark.
+	StateCollection string
+
+	// OnReport receives the report of every applied rollup.
+	OnReport func(report RollupReport)
+}
+
+func NewRollupEngine(madp *MongoAdapter, rollups ...Rollup) *RollupEngine {
+	return &RollupEngine{
+		MongoAdapter:    madp,
+		Rollups:         rollups,
+		StateCollection: DefaultRollupStateCollection,
+	}
+}
+
+// Run merges every rollup, continuing past failures, and returns the reports
+// together with the first error encountered.
+func (re *RollupEngine) Run(ctx context.Context) ([]RollupReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	var reports []RollupReport
+	var firstErr error
+	for _, rollup := range re.Rollups {
+		report, err := re.Apply(ctx, rollup)
+		reports = append(reports, report)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return reports, firstErr
+}
+
+// Schedule registers the engine on a scheduler.
+func (re *RollupEngine) Schedule(s *Scheduler, interval time.Duration) {
+	s.Every("rollups", interval, func(ctx context.Context) error {
+		_, err := re.Run(ctx)
+		return err
+	})
+}
+
+// Apply merges the source documents inserted since the last merge into the
+// rollup's target.
+func (re *RollupEngine) Apply(ctx context.Context, rollup Rollup) (RollupReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	report := RollupReport{Rollup: rollup.Name, StartedAt: time.Now()}
+	if err := validateRollup(rollup); err != nil {
+		return report, err
+	}
+	if err := re.authorizeRollup(ctx, rollup, "ApplyRollup"); err != nil {
+		return re.finish(report, err)
+	}
+
+	var state struct {
+		Watermark primitive.ObjectID `bson:"watermark"`
+	}
+	stateCollection, err := re.stateCollection(ctx)
+	if err != nil {
+		return re.finish(report, err)
+	}
+	err = stateCollection.FindOne(ctx, bson.M{"_id": rollup.Name}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return re.finish(report, err)
+	}
+
+	report.From = state.Watermark
+	return re.merge(ctx, rollup, report)
+}
+
+// Rebuild recomputes a rollup from scratch. The target is emptied first, so
+// it's incomplete until the rebuild finishes.
+func (re *RollupEngine) Rebuild(ctx context.Context, rollup Rollup) (RollupReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	report := RollupReport{Rollup: rollup.Name, Rebuilt: true, StartedAt: time.Now()}
+	if err := validateRollup(rollup); err != nil {
+		return report, err
+	}
+	if err := re.authorizeRollup(ctx, rollup, "RebuildRollup"); err != nil {
+		return re.finish(report, err)
+	}
+
+	target, err := re.MongoAdapter.collectionFor(ctx, rollup.Target)
+	if err != nil {
+		return re.finish(report, err)
+	}
+	if _, err := target.DeleteMany(ctx, bson.M{}); err != nil {
+		return re.finish(report, err)
+	}
+	return re.merge(ctx, rollup, report)
+}
+
+func (re *RollupEngine) merge(ctx context.Context, rollup Rollup, report RollupReport) (RollupReport, error) {
+	source, err := re.MongoAdapter.collectionFor(ctx, rollup.Source)
+	if err != nil {
+		return re.finish(report, err)
+	}
+
+	lag := rollup.Lag
+	if lag <= 0 {
+		lag = DefaultRollupLag
+	}
+	now, err := serverTime(ctx, source.Database())
+	if err != nil {
+		return re.finish(report, err)
+	}
+	report.Until = primitive.NewObjectIDFromTimestamp(now.Add(-lag))
+	if !report.From.IsZero() && report.Until.Hex() <= report.From.Hex() {
+		return re.finish(report, nil)
+	}
+
+	rangeField := "_id"
+	var from, until interface{} = report.From, report.Until
+	if rollup.TimeField != "" {
+		rangeField, from, until = rollup.TimeField, report.From.Timestamp(), report.Until.Timestamp()
+	}
+	fieldRange := bson.M{"$lt": until}
+	if !report.From.IsZero() {
+		fieldRange["$gte"] = from
+	}
+	match := bson.M{rangeField: fieldRange}
+	if len(rollup.Filter) > 0 {
+		match = bson.M{"$and": bson.A{rollup.Filter, match}}
+	}
+
+	// Ordered, the _id of a group must be identical from one merge to the next
+	groupID := bson.D{}
+	for _, field := range rollup.GroupBy {
+		groupID = append(groupID, bson.E{Key: rollupGroupKey(field), Value: "$" + field})
+	}
+	group := bson.M{"_id": groupID}
+	combine := bson.M{}
+	for field, aggregation := range rollup.Aggregations {
+		group[field] = aggregation
+		for operator := range aggregation {
+			combine[field] = mergeAccumulator(operator, field)
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: group}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           rollup.Target,
+			"on":             "_id",
+			"whenMatched":    bson.A{bson.M{"$set": combine}},
+			"whenNotMatched": "insert",
+		}}},
+	}
+	// $merge writes into the source's database, the tenant's
+	cursor, err := source.Aggregate(ctx, pipeline)
+	if err != nil {
+		return re.finish(report, mapPipelineError(pipeline, err))
+	}
+	cursor.Close(ctx)
+
+	// Should recording the watermark fail, the next merge counts these
+	// documents twice; Rebuild repairs the rollup
+	stateCollection, err := re.stateCollection(ctx)
+	if err != nil {
+		return re.finish(report, err)
+	}
+	_, err = stateCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": rollup.Name},
+		bson.M{"$set": bson.M{"watermark": report.Until, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return re.finish(report, err)
+}
+
+// serverTime reads the server's clock, which stamps TimeField values and
+// which the engine's may drift from.
+func serverTime(ctx context.Context, database *mongo.Database) (time.Time, error) {
+	var hello struct {
+		LocalTime time.Time `bson:"localTime"`
+	}
+	if err := database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return time.Time{}, err
+	}
+	if hello.LocalTime.IsZero() {
+		return time.Now(), nil
+	}
+	return hello.LocalTime, nil
+}
+
+// rollupGroupKey is field's key in the target's _id: $group keys can't
+// contain dots.
+func rollupGroupKey(field string) string {
+	return strings.ReplaceAll(field, ".", "_")
+}
+
+// mergeAccumulator combines a rollup field with the same field of a new
+// partial aggregate ($$new).
+func mergeAccumulator(operator string, field string) bson.M {
+	values := bson.A{"$" + field, "$$new." + field}
+	switch operator {
+	case "$min":
+		return bson.M{"$min": values}
+	case "$max":
+		return bson.M{"$max": values}
+	}
+	return bson.M{"$add": values}
+}
+
+func validateRollup(rollup Rollup) error {
+	if rollup.Name == "" || rollup.Source == "" || rollup.Target == "" || len(rollup.Aggregations) == 0 {
+		return fmt.Errorf("%w: name, source, target and aggregations are required", ErrInvalidRollup)
+	}
+	keys := map[string]string{}
+	for _, field := range rollup.GroupBy {
+		key := rollupGroupKey(field)
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("%w: group fields %s and %s are both keyed %s", ErrInvalidRollup, other, field, key)
+		}
+		keys[key] = field
+	}
+	for field, aggregation := range rollup.Aggregations {
+		if len(aggregation) != 1 {
+			return fmt.Errorf("%w: %s must have exactly one accumulator", ErrInvalidRollup, field)
+		}
+		for operator := range aggregation {
+			if operator != "$sum" && operator != "$min" && operator != "$max" {
+				return fmt.Errorf("%w: %s uses %s, only $sum, $min and $max merge incrementally", ErrInvalidRollup, field, operator)
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeRollup runs the Authorize hook for reading the rollup's source and
+// writing its target.
+func (re *RollupEngine) authorizeRollup(ctx context.Context, rollup Rollup, operation string) error {
+	if err := re.MongoAdapter.authorize(ctx, describeOperation(rollup.Source, "AggregateRollup", rollup.Filter)); err != nil {
+		return err
+	}
+	return re.MongoAdapter.authorize(ctx, describeOperation(rollup.Target, operation, nil))
+}
+
+func (re *RollupEngine) stateCollection(ctx context.Context) (*mongo.Collection, error) {
+	name := re.StateCollection
+	if name == "" {
+		name = DefaultRollupStateCollection
+	}
+	return re.MongoAdapter.collectionFor(ctx, name)
+}
+
+func (re *RollupEngine) finish(report RollupReport, err error) (RollupReport, error) {
+	report.FinishedAt = time.Now()
+	if err != nil {
+		re.MongoAdapter.Error(
+			"Rollup merge failed",
+			LogField("rollup", report.Rollup),
+			LogError(err),
+		)
+	} else {
+		re.MongoAdapter.Info(
+			"Merged rollup",
+			LogField("rollup", report.Rollup),
+			LogField("rebuilt", report.Rebuilt),
+			LogField("duration", report.FinishedAt.Sub(report.StartedAt)),
+		)
+	}
+
+	if re.OnReport != nil {
+		re.OnReport(report)
+	}
+	return report, err
+}
@@ -0,0 +1,33 @@
+package mongoquerier
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateRollup(t *testing.T) {
+	valid := Rollup{
+		Name:         "orders_by_country",
+		Source:       "orders",
+		Target:       "orders_by_country",
+		GroupBy:      []string{"customer.country", "status"},
+		Aggregations: map[string]bson.M{"orders": {"$sum": 1}},
+	}
+	if err := validateRollup(valid); err != nil {
+		t.Errorf("validateRollup() = %v", err)
+	}
+
+	colliding := valid
+	colliding.GroupBy = []string{"customer.country", "customer_country"}
+	if err := validateRollup(colliding); !errors.Is(err, ErrInvalidRollup) {
+		t.Errorf("validateRollup(%v) = %v, want ErrInvalidRollup", colliding.GroupBy, err)
+	}
+
+	averaged := valid
+	averaged.Aggregations = map[string]bson.M{"total": {"$avg": "$total"}}
+	if err := validateRollup(averaged); !errors.Is(err, ErrInvalidRollup) {
+		t.Errorf("validateRollup($avg) = %v, want ErrInvalidRollup", err)
+	}
+}
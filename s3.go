@@ -0,0 +1,311 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Store is an ObjectStore on S3, or any storage speaking its REST API,
+// over net/http: requests are signed with Signature Version 4 and objects
+// uploaded in multipart uploads (see MultipartStore), without the AWS SDK.
+//
+//	store := &mongoquerier.S3Store{Region: "eu-west-1", Bucket: "extracts", AccessKeyID: id, SecretAccessKey: secret}
+//
+// See GCSStore for Google Cloud Storage.
+type S3Store struct {
+	// Endpoint is the service URL, https://s3.<Region>.amazonaws.com when
+	// empty.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// PathStyle addresses the bucket in the path (Endpoint/Bucket/key)
+	// rather than the host (Bucket.Endpoint/key), for buckets with dots in
+	// their name and most S3-compatible storages.
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials, if any.
+	SessionToken string
+
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+
+	// now stamps the signatures; tests fix it
+	now func() time.Time
+}
+
+// GCSStore is an S3Store on Google Cloud Storage's XML API, authenticated
+// with an HMAC key of a service account:
+//
+//	store := mongoquerier.GCSStore("extracts", hmacAccessID, hmacSecret)
+//
+// GCS ignores KMSKeyID; set the bucket's default key instead.
+func GCSStore(bucket string, accessID string, secret string) *S3Store {
+	return &S3Store{
+		Endpoint:        "https://storage.googleapis.com",
+		Region:          "auto",
+		Bucket:          bucket,
+		PathStyle:       true,
+		AccessKeyID:     accessID,
+		SecretAccessKey: secret,
+	}
+}
+
+// S3Error is an error response of the S3 API.
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (s *S3Store) Create(ctx context.Context, key string, opts ObjectOptions) (ObjectWriter, error) {
+	return MultipartStore{Start: s.startUpload}.Create(ctx, key, opts)
+}
+
+type s3Upload struct {
+	store    *S3Store
+	key      string
+	uploadID string
+
+	mu    sync.Mutex
+	parts []s3Part
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (s *S3Store) startUpload(ctx context.Context, key string, opts ObjectOptions) (MultipartUpload, error) {
+	header := http.Header{}
+	if opts.ContentType != "" {
+		header.Set("Content-Type", opts.ContentType)
+	}
+	for name, value := range opts.Metadata {
+		header.Set("X-Amz-Meta-"+name, value)
+	}
+	if opts.ServerSideEncryption != "" {
+		header.Set("X-Amz-Server-Side-Encryption", opts.ServerSideEncryption)
+	}
+	if opts.KMSKeyID != "" {
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", opts.KMSKeyID)
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	_, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Upload{store: s, key: key, uploadID: result.UploadID}, nil
+}
+
+func (u *s3Upload) UploadPart(ctx context.Context, number int, data []byte) error {
+	query := url.Values{"partNumber": {fmt.Sprint(number)}, "uploadId": {u.uploadID}}
+	res, err := u.store.do(ctx, http.MethodPut, u.key, query, nil, data, nil)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	u.parts = append(u.parts, s3Part{Number: number, ETag: res.Header.Get("ETag")})
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *s3Upload) Complete(ctx context.Context) error {
+	u.mu.Lock()
+	parts := append([]s3Part(nil), u.parts...)
+	u.mu.Unlock()
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	_, err = u.store.do(ctx, http.MethodPost, u.key, url.Values{"uploadId": {u.uploadID}}, nil, body, nil)
+	return err
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	_, err := u.store.do(ctx, http.MethodDelete, u.key, url.Values{"uploadId": {u.uploadID}}, nil, nil, nil)
+	return err
+}
+
+// do sends a signed request for key, decoding the XML response into result
+// when not nil.
+func (s *S3Store) do(ctx context.Context, method string, key string, query url.Values, header http.Header, body []byte, result interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body)
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	// CompleteMultipartUpload reports some failures in a 200 response
+	if res.StatusCode >= 300 || bytes.Contains(data, []byte("<Error>")) {
+		s3Err := &S3Error{StatusCode: res.StatusCode}
+		xml.Unmarshal(data, s3Err)
+		return nil, s3Err
+	}
+	if result != nil {
+		if err := xml.Unmarshal(data, result); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *S3Store) objectURL(key string, query url.Values) string {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	path := "/" + s3Escape(key, false)
+	if s.PathStyle {
+		path = "/" + s3Escape(s.Bucket, false) + path
+	} else if scheme, host, ok := strings.Cut(endpoint, "://"); ok {
+		endpoint = scheme + "://" + s.Bucket + "." + host
+	}
+	if len(query) == 0 {
+		return endpoint + path
+	}
+	return endpoint + path + "?" + s3Query(query)
+}
+
+// sign signs req with Signature Version 4, covering every header set on it.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	timestamp := now().UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", timestamp)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Query encodes query the way Signature Version 4 canonicalizes it: sorted
+// by name, "=" after every name, even of a value-less parameter.
+func s3Query(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes every byte of value but the unreserved
+// characters, and slashes unless escapeSlash is set.
+func s3Escape(value string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
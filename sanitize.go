@@ -0,0 +1,127 @@
+package mongoquerier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnsafeFilter = errors.New("filter has operator or dotted keys")
+
+type SanitizeMode int
+
+const (
+	// SanitizeReject fails on the first unsafe key with ErrUnsafeFilter.
+	SanitizeReject SanitizeMode = iota
+	// SanitizeStrip drops every field whose key or value is unsafe.
+	SanitizeStrip
+)
+
+// SafeFilter is a filter built from user input holding plain equality
+// conditions only: no key starts with "$" or contains a dot, at any depth,
+// so no operator ($where, $ne, $regex...) can be injected through it. Nor
+// can regular expressions, which a value matches implicitly, or JavaScript
+// code values.
+//
+//	filter, err := mongoquerier.NewSafeFilter(requestParams, mongoquerier.SanitizeReject)
+//	if err != nil { ... }
+//	documents, err := querier.FindByM(ctx, filter.M())
+type SafeFilter primitive.M
+
+// NewSafeFilter sanitizes input in mode. Stripping drops the whole field
+// holding an unsafe key, as dropping the key alone would change what the
+// field matches.
+func NewSafeFilter(input map[string]interface{}, mode SanitizeMode) (SafeFilter, error) {
+	filter, err := SanitizeFilter(input, mode)
+	return SafeFilter(filter), err
+}
+
+func (f SafeFilter) M() primitive.M {
+	return primitive.M(f)
+}
+
+// SanitizeFilter returns a copy of filter without operator or dotted keys,
+// or ErrUnsafeFilter naming the first one in SanitizeReject mode.
+func SanitizeFilter(filter map[string]interface{}, mode SanitizeMode) (primitive.M, error) {
+	sanitized := make(primitive.M, len(filter))
+	for key, value := range filter {
+		err := checkSafeKey(key)
+		if err == nil {
+			err = checkSafeValue(value, key)
+		}
+		if err != nil {
+			if mode == SanitizeReject {
+				return nil, err
+			}
+			continue
+		}
+		sanitized[key] = value
+	}
+	return sanitized, nil
+}
+
+func checkSafeKey(key string) error {
+	if strings.HasPrefix(key, "$") || strings.Contains(key, ".") || key == "" {
+		return fmt.Errorf("%w: %q", ErrUnsafeFilter, key)
+	}
+	return nil
+}
+
+// checkSafeValue checks the keys of the documents nested in value, within
+// arrays too, and rejects regular expressions and JavaScript.
+func checkSafeValue(value interface{}, path string) error {
+	switch value := value.(type) {
+	case primitive.Regex, *primitive.Regex:
+		return fmt.Errorf("%w: regular expression in %s", ErrUnsafeFilter, path)
+	case primitive.JavaScript, primitive.CodeWithScope, *primitive.CodeWithScope:
+		return fmt.Errorf("%w: JavaScript in %s", ErrUnsafeFilter, path)
+	case map[string]interface{}:
+		return checkSafeDocument(value, path)
+	case primitive.M:
+		return checkSafeDocument(value, path)
+	case primitive.D:
+		for _, e := range value {
+			if err := checkSafeEntry(e.Key, e.Value, path); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		return checkSafeArray(value, path)
+	case primitive.A:
+		return checkSafeArray(value, path)
+	case []map[string]interface{}:
+		for _, element := range value {
+			if err := checkSafeDocument(element, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkSafeDocument(document map[string]interface{}, path string) error {
+	for key, value := range document {
+		if err := checkSafeEntry(key, value, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSafeEntry(key string, value interface{}, path string) error {
+	if err := checkSafeKey(key); err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	return checkSafeValue(value, path+"."+key)
+}
+
+func checkSafeArray(values []interface{}, path string) error {
+	for _, element := range values {
+		if err := checkSafeValue(element, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
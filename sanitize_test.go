@@ -0,0 +1,27 @@
+package mongoquerier
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSafeFilterRejectsRegexAndJavaScript(t *testing.T) {
+	for name, value := range map[string]interface{}{
+		"regex":           primitive.Regex{Pattern: ".*"},
+		"regex pointer":   &primitive.Regex{Pattern: ".*"},
+		"javascript":      primitive.JavaScript("sleep(1000)"),
+		"code with scope": primitive.CodeWithScope{Code: "sleep(1000)"},
+		"nested regex":    primitive.A{map[string]interface{}{"name": primitive.Regex{Pattern: "^a"}}},
+	} {
+		if _, err := NewSafeFilter(map[string]interface{}{"name": value}, SanitizeReject); !errors.Is(err, ErrUnsafeFilter) {
+			t.Errorf("NewSafeFilter(%s) error = %v, want ErrUnsafeFilter", name, err)
+		}
+	}
+
+	filter, err := NewSafeFilter(map[string]interface{}{"name": primitive.Regex{Pattern: ".*"}, "email": "a@b.c"}, SanitizeStrip)
+	if err != nil || len(filter) != 1 || filter["email"] != "a@b.c" {
+		t.Errorf("stripped filter = %v, %v, want only email", filter, err)
+	}
+}
@@ -0,0 +1,98 @@
+package mongoquerier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic maintenance work run by the Scheduler.
+type Job func(ctx context.Context) error
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// Scheduler runs maintenance jobs (retention, compaction, ...) on fixed
+// intervals. A job never overlaps with itself; a slow run delays the next one.
+type Scheduler struct {
+	*MongoAdapter
+
+	mu   sync.Mutex
+	jobs []scheduledJob
+}
+
+func NewScheduler(madp *MongoAdapter) *Scheduler {
+	return &Scheduler{MongoAdapter: madp}
+}
+
+// Every registers job to run every interval once the scheduler runs. It
+// panics when interval isn't positive, like time.NewTicker would, but when
+// the job is registered rather than in Run's goroutines.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("mongoquerier: job %q has a non-positive interval %s", name, interval))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, job: job})
+}
+
+// Run starts every registered job and blocks until ctx is done and all
+// running jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]scheduledJob(nil), s.jobs...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job scheduledJob) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunJob(ctx, job.name, job.job)
+		}
+	}
+}
+
+// RunJob runs a single job immediately, logging its outcome.
+func (s *Scheduler) RunJob(ctx context.Context, name string, job Job) error {
+	startedAt := time.Now()
+	err := job(ctx)
+	if err != nil {
+		s.MongoAdapter.Error(
+			"Scheduled job failed",
+			LogField("job", name),
+			LogField("duration", time.Since(startedAt)),
+			LogError(err),
+		)
+		return err
+	}
+
+	s.MongoAdapter.Debug(
+		"Scheduled job finished",
+		LogField("job", name),
+		LogField("duration", time.Since(startedAt)),
+	)
+	return nil
+}
@@ -0,0 +1,16 @@
+package mongoquerier
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSchedulerRejectsNonPositiveInterval(t *testing.T) {
+	s := NewScheduler(newTestAdapter(t))
+	defer func() {
+		if recover() == nil {
+			t.Error("Every(0) didn't panic")
+		}
+	}()
+	s.Every("retention", 0, func(context.Context) error { return nil })
+}
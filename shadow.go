@@ -0,0 +1,423 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultShadowTimeout = 10 * time.Second
+
+// Divergence is a difference observed between the primary and the shadow
+// for the same operation.
+type Divergence struct {
+	Collection string      `bson:"collection"`
+	Operation  string      `bson:"operation"`
+	Filter     interface{} `bson:"filter,omitempty"`
+	Detail     string      `bson:"detail"`
+	ObservedAt time.Time   `bson:"observed_at"`
+}
+
+type DivergenceRecorder interface {
+	RecordDivergence(ctx context.Context, divergence Divergence)
+}
+
+// CollectionDivergenceRecorder stores divergences in a collection for later
+// analysis.
+type CollectionDivergenceRecorder struct {
+	*MongoAdapter
+	CollectionName string
+}
+
+func (r *CollectionDivergenceRecorder) RecordDivergence(ctx context.Context, divergence Divergence) {
+	if _, err := r.GetCollection(r.CollectionName).InsertOne(ctx, divergence); err != nil {
+		r.Error("unable to record divergence", LogField("operation", divergence.Operation), LogError(err))
+	}
+}
+
+// ShadowReport summarizes a ShadowQuerier's comparisons so far.
+type ShadowReport struct {
+	Writes       int64
+	Reads        int64
+	Divergences  int64
+	ShadowErrors int64
+}
+
+// ShadowQuerier mirrors writes from a primary querier to a shadow querier
+// (typically on another cluster) and optionally shadows reads, comparing the
+// outcomes. Callers only ever see the primary's results and errors.
+type ShadowQuerier[Model any, IDModel any] struct {
+	Primary *Querier[Model, IDModel]
+	Shadow  *Querier[Model, IDModel]
+
+	// Async mirrors in the background instead of after each primary call,
+	// one operation at a time in the order of the primary's.
+	Async       bool
+	ShadowReads bool
+	Timeout     time.Duration
+	Recorder    DivergenceRecorder
+
+	writes       atomic.Int64
+	reads        atomic.Int64
+	divergences  atomic.Int64
+	shadowErrors atomic.Int64
+	pending      sync.WaitGroup
+
+	// queue holds the background operations, run in order by a single
+	// worker while draining is set
+	mu       sync.Mutex
+	queue    []func()
+	draining bool
+}
+
+func NewShadowQuerier[Model any, IDModel any](primary *Querier[Model, IDModel], shadow *Querier[Model, IDModel]) *ShadowQuerier[Model, IDModel] {
+	return &ShadowQuerier[Model, IDModel]{
+		Primary: primary,
+		Shadow:  shadow,
+	}
+}
+
+func (s *ShadowQuerier[Model, IDModel]) Report() ShadowReport {
+	return ShadowReport{
+		Writes:       s.writes.Load(),
+		Reads:        s.reads.Load(),
+		Divergences:  s.divergences.Load(),
+		ShadowErrors: s.shadowErrors.Load(),
+	}
+}
+
+// Wait blocks until background shadow operations have finished.
+func (s *ShadowQuerier[Model, IDModel]) Wait() {
+	s.pending.Wait()
+}
+
+// mirror runs the shadow side of an operation and compares its outcome with
+// the primary's.
+func (s *ShadowQuerier[Model, IDModel]) mirror(ctx context.Context, operation string, filter interface{}, primaryResult interface{}, primaryErr error, shadow func(ctx context.Context) (interface{}, error)) {
+	run := func(ctx context.Context) {
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = DefaultShadowTimeout
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		shadowResult, shadowErr := shadow(ctx)
+		if shadowErr != nil && !errors.Is(shadowErr, mongo.ErrNoDocuments) {
+			s.shadowErrors.Add(1)
+		}
+
+		if detail := compareOutcomes(primaryResult, primaryErr, shadowResult, shadowErr); detail != "" {
+			s.divergences.Add(1)
+			divergence := Divergence{
+				Collection: s.Primary.collection.Name(),
+				Operation:  operation,
+				Filter:     filter,
+				Detail:     detail,
+				ObservedAt: time.Now(),
+			}
+
+			s.Primary.MongoAdapter.Warn(
+				"Shadow diverged from primary",
+				LogField("collection_name", divergence.Collection),
+				LogField("operation", operation),
+				LogField("detail", detail),
+			)
+			if s.Recorder != nil {
+				s.Recorder.RecordDivergence(ctx, divergence)
+			}
+		}
+	}
+
+	if !s.Async {
+		run(ctx)
+		return
+	}
+
+	// Background mirroring must outlive the caller's context, not lose its
+	// tenant, trace or untrusted marker
+	detached := detachedContext{ctx}
+	s.enqueue(func() { run(detached) })
+}
+
+// enqueue runs job in the background after the jobs enqueued before it, so
+// that e.g. an update never reaches the shadow before its insert.
+func (s *ShadowQuerier[Model, IDModel]) enqueue(job func()) {
+	s.pending.Add(1)
+	s.mu.Lock()
+	s.queue = append(s.queue, job)
+	start := !s.draining
+	s.draining = true
+	s.mu.Unlock()
+
+	if start {
+		go s.drain()
+	}
+}
+
+func (s *ShadowQuerier[Model, IDModel]) drain() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.draining = false
+			s.mu.Unlock()
+			return
+		}
+		job := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		job()
+		s.pending.Done()
+	}
+}
+
+func compareOutcomes(primaryResult interface{}, primaryErr error, shadowResult interface{}, shadowErr error) string {
+	primaryMissing := errors.Is(primaryErr, mongo.ErrNoDocuments)
+	shadowMissing := errors.Is(shadowErr, mongo.ErrNoDocuments)
+
+	switch {
+	case (primaryErr == nil) != (shadowErr == nil) || primaryMissing != shadowMissing:
+		return fmt.Sprintf("primary error %v, shadow error %v", primaryErr, shadowErr)
+	case primaryErr != nil:
+		// Both failed the same way
+		return ""
+	}
+
+	if !sameBSON(primaryResult, shadowResult) {
+		return "results differ"
+	}
+	return ""
+}
+
+// sameBSON compares values by their BSON encoding, falling back to deep
+// equality for values that aren't documents.
+func sameBSON(a interface{}, b interface{}) bool {
+	encodedA, errA := bson.Marshal(bson.M{"v": a})
+	encodedB, errB := bson.Marshal(bson.M{"v": b})
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(encodedA, encodedB)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) InsertOne(ctx context.Context, document Model, opts ...*options.InsertOneOptions) (IDModel, error) {
+	insertedID, err := s.Primary.InsertOne(ctx, document, opts...)
+	if err != nil {
+		return insertedID, err
+	}
+	s.writes.Add(1)
+
+	// The shadow reuses the primary's _id so both copies stay comparable
+	s.mirror(ctx, "InsertOne", nil, insertedID, nil, func(ctx context.Context) (interface{}, error) {
+		return s.insertWithID(ctx, document, insertedID, opts...)
+	})
+	return insertedID, nil
+}
+
+func (s *ShadowQuerier[Model, IDModel]) InsertMany(ctx context.Context, documents []Model, opts ...*options.InsertManyOptions) ([]IDModel, error) {
+	insertedIDs, err := s.Primary.InsertMany(ctx, documents, opts...)
+	if err != nil {
+		return insertedIDs, err
+	}
+	s.writes.Add(1)
+
+	s.mirror(ctx, "InsertMany", nil, insertedIDs, nil, func(ctx context.Context) (interface{}, error) {
+		shadowDocuments := make([]Model, 0, len(documents))
+		for i, document := range documents {
+			document, err := withID(document, insertedIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			shadowDocuments = append(shadowDocuments, document)
+		}
+		return s.Shadow.InsertMany(ctx, shadowDocuments, opts...)
+	})
+	return insertedIDs, nil
+}
+
+// insertWithID inserts document into the shadow through its querier, under
+// the primary's _id.
+func (s *ShadowQuerier[Model, IDModel]) insertWithID(ctx context.Context, document Model, id IDModel, opts ...*options.InsertOneOptions) (IDModel, error) {
+	document, err := withID(document, id)
+	if err != nil {
+		var zero IDModel
+		return zero, err
+	}
+	return s.Shadow.InsertOne(ctx, document, opts...)
+}
+
+// withID returns a copy of document with its _id field set to id.
+func withID[Model any, IDModel any](document Model, id IDModel) (Model, error) {
+	value := reflect.ValueOf(&document).Elem()
+	idValue := reflect.ValueOf(id)
+	if value.Kind() == reflect.Struct && idValue.IsValid() {
+		for _, field := range structLayoutOf(value.Type()) {
+			if field.key != "_id" {
+				continue
+			}
+			target := value.Field(field.index)
+			switch {
+			case idValue.Type().AssignableTo(target.Type()):
+				target.Set(idValue)
+				return document, nil
+			case target.Kind() == reflect.Pointer && idValue.Type().AssignableTo(target.Type().Elem()):
+				pointer := reflect.New(target.Type().Elem())
+				pointer.Elem().Set(idValue)
+				target.Set(pointer)
+				return document, nil
+			}
+		}
+	}
+	return document, fmt.Errorf("%T has no _id field holding a %T", document, id)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) UpdateOneByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+	document, err := s.Primary.UpdateOneByM(ctx, filter, update, opts...)
+	s.writes.Add(1)
+
+	s.mirror(ctx, "UpdateOneByM", filter, document, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.UpdateOneByM(ctx, filter, update, opts...)
+	})
+	return document, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) UpdateManyByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	result, err := s.Primary.UpdateManyByM(ctx, filter, update, opts...)
+	s.writes.Add(1)
+
+	s.mirror(ctx, "UpdateManyByM", filter, result, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.UpdateManyByM(ctx, filter, update, opts...)
+	})
+	return result, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) ReplaceOneByM(ctx context.Context, filter primitive.M, replacement Model, opts ...*options.FindOneAndReplaceOptions) (*Model, error) {
+	document, err := s.Primary.ReplaceOneByM(ctx, filter, replacement, opts...)
+	s.writes.Add(1)
+
+	s.mirror(ctx, "ReplaceOneByM", filter, document, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.ReplaceOneByM(ctx, filter, replacement, opts...)
+	})
+	return document, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) DeleteOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneAndDeleteOptions) (*Model, error) {
+	document, err := s.Primary.DeleteOneByM(ctx, filter, opts...)
+	s.writes.Add(1)
+
+	s.mirror(ctx, "DeleteOneByM", filter, document, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.DeleteOneByM(ctx, filter, opts...)
+	})
+	return document, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) DeleteManyByM(ctx context.Context, filter primitive.M, opts ...*options.DeleteOptions) (int64, error) {
+	deleted, err := s.Primary.DeleteManyByM(ctx, filter, opts...)
+	s.writes.Add(1)
+
+	s.mirror(ctx, "DeleteManyByM", filter, deleted, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.DeleteManyByM(ctx, filter, opts...)
+	})
+	return deleted, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) FindByM(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) ([]*Model, error) {
+	documents, err := s.Primary.FindByM(ctx, filter, opts...)
+	if !s.ShadowReads {
+		return documents, err
+	}
+	s.reads.Add(1)
+
+	s.mirror(ctx, "FindByM", filter, documents, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.FindByM(ctx, filter, opts...)
+	})
+	return documents, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) FindOneByM(ctx context.Context, filter primitive.M, opts ...*options.FindOneOptions) (*Model, error) {
+	document, err := s.Primary.FindOneByM(ctx, filter, opts...)
+	if !s.ShadowReads {
+		return document, err
+	}
+	s.reads.Add(1)
+
+	s.mirror(ctx, "FindOneByM", filter, document, err, func(ctx context.Context) (interface{}, error) {
+		return s.Shadow.FindOneByM(ctx, filter, opts...)
+	})
+	return document, err
+}
+
+func (s *ShadowQuerier[Model, IDModel]) Find(ctx context.Context, filter Model, opts ...*options.FindOptions) ([]*Model, error) {
+	filterM, err := s.Primary.structToM("Find", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindByM(ctx, filterM, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) FindOne(ctx context.Context, filter Model, opts ...*options.FindOneOptions) (*Model, error) {
+	filterM, err := s.Primary.structToM("FindOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindOneByM(ctx, filterM, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) UpdateOne(ctx context.Context, filter Model, update Model, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+	filterM, err := s.Primary.structToM("UpdateOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UpdateOneByM(ctx, filterM, update, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) UpdateMany(ctx context.Context, filter Model, update Model, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	filterM, err := s.Primary.structToM("UpdateMany", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UpdateManyByM(ctx, filterM, update, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) ReplaceOne(ctx context.Context, filter Model, replacement Model, opts ...*options.FindOneAndReplaceOptions) (*Model, error) {
+	filterM, err := s.Primary.structToM("ReplaceOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ReplaceOneByM(ctx, filterM, replacement, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) DeleteOne(ctx context.Context, filter Model, opts ...*options.FindOneAndDeleteOptions) (*Model, error) {
+	filterM, err := s.Primary.structToM("DeleteOne", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.DeleteOneByM(ctx, filterM, opts...)
+}
+
+func (s *ShadowQuerier[Model, IDModel]) DeleteMany(ctx context.Context, filter Model, opts ...*options.DeleteOptions) (int64, error) {
+	filterM, err := s.Primary.structToM("DeleteMany", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.DeleteManyByM(ctx, filterM, opts...)
+}
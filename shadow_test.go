@@ -0,0 +1,43 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestShadowRunsBackgroundOperationsInOrder(t *testing.T) {
+	s := &ShadowQuerier[recursiveNode, primitive.ObjectID]{Async: true}
+
+	var ran []int
+	for i := 0; i < 100; i++ {
+		i := i
+		s.enqueue(func() { ran = append(ran, i) })
+	}
+	s.Wait()
+
+	if len(ran) != 100 {
+		t.Fatalf("ran %d operations, want 100", len(ran))
+	}
+	for i, n := range ran {
+		if n != i {
+			t.Fatalf("operation %d ran in position %d", n, i)
+		}
+	}
+}
+
+func TestWithID(t *testing.T) {
+	type document struct {
+		ID   primitive.ObjectID `bson:"_id,omitempty"`
+		Name string             `bson:"name"`
+	}
+	id := primitive.NewObjectID()
+
+	got, err := withID(document{Name: "a"}, id)
+	if err != nil || got.ID != id || got.Name != "a" {
+		t.Errorf("withID() = %+v, %v, want the document with its _id set", got, err)
+	}
+	if _, err := withID(recursiveNode{}, id); err == nil {
+		t.Error("withID() on a model without _id = nil, want an error")
+	}
+}
@@ -0,0 +1,77 @@
+package mongoquerier
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// QueryShape normalizes a filter into its shape: field names and operators
+// with values stripped and keys sorted, e.g.
+// {"age": {"$gt": 30}, "name": "x"} becomes {age:{$gt:?},name:?}.
+func QueryShape(filter interface{}) string {
+	var builder strings.Builder
+	writeShape(&builder, filter)
+	return builder.String()
+}
+
+func writeShape(builder *strings.Builder, value interface{}) {
+	switch value := value.(type) {
+	case bson.M:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		builder.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(key)
+			builder.WriteByte(':')
+			writeShape(builder, value[key])
+		}
+		builder.WriteByte('}')
+	case bson.D:
+		m := make(bson.M, len(value))
+		for _, e := range value {
+			m[e.Key] = e.Value
+		}
+		writeShape(builder, m)
+	case bson.A:
+		// Logical operators ($and, $or, ...) keep the shape of each clause,
+		// value lists ($in, $all, ...) collapse regardless of their length
+		if !containsDocument(value) {
+			builder.WriteByte('?')
+			return
+		}
+
+		builder.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			writeShape(builder, element)
+		}
+		builder.WriteByte(']')
+	case []interface{}:
+		writeShape(builder, bson.A(value))
+	case map[string]interface{}:
+		writeShape(builder, bson.M(value))
+	default:
+		builder.WriteByte('?')
+	}
+}
+
+func containsDocument(values bson.A) bool {
+	for _, value := range values {
+		switch value.(type) {
+		case bson.M, bson.D, map[string]interface{}:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		filter interface{}
+		want   string
+	}{
+		{nil, "?"},
+		{bson.M{}, "{}"},
+		{bson.M{"name": "x", "age": bson.M{"$gt": 30}}, "{age:{$gt:?},name:?}"},
+		{bson.D{{Key: "name", Value: "x"}, {Key: "age", Value: 30}}, "{age:?,name:?}"},
+		{bson.M{"status": bson.M{"$in": bson.A{"a", "b", "c"}}}, "{status:{$in:?}}"},
+		{bson.M{"status": bson.M{"$in": []interface{}{"a"}}}, "{status:{$in:?}}"},
+		{bson.M{"$or": bson.A{bson.M{"a": 1}, bson.M{"b": 2}}}, "{$or:[{a:?},{b:?}]}"},
+		{map[string]interface{}{"address": map[string]interface{}{"city": "Oslo"}}, "{address:{city:?}}"},
+	}
+	for _, tt := range tests {
+		if got := QueryShape(tt.filter); got != tt.want {
+			t.Errorf("QueryShape(%v) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrScatterGather   = errors.New("query doesn't target the shard key")
+	ErrMissingShardKey = errors.New("document has no shard key value")
+)
+
+// DefaultShardKeyRetryInterval is how long a ShardKeyPolicy stops reading
+// the shard key of a collection after failing to.
+const DefaultShardKeyRetryInterval = time.Minute
+
+type ShardKeyMode int
+
+const (
+	// ShardKeyWarn logs scatter-gather queries and lets them run, unless the
+	// adapter is in strict mode (see MongoAdapter.SetStrict).
+	ShardKeyWarn ShardKeyMode = iota
+	// ShardKeyDeny rejects scatter-gather queries with ErrScatterGather.
+	ShardKeyDeny
+)
+
+// ShardKeyPolicy flags queries on sharded collections whose filter doesn't
+// constrain the shard key, which mongos broadcasts to every shard. Shard
+// keys are read from config.collections once per collection and cached for
+// the lifetime of the policy; unsharded collections are never flagged.
+//
+// When config.collections can't be read, the collection is let through
+// without reading it again for RetryInterval (DefaultShardKeyRetryInterval
+// when zero).
+type ShardKeyPolicy struct {
+	Mode          ShardKeyMode
+	RetryInterval time.Duration
+
+	keys     sync.Map // namespace -> bson.D (nil when unsharded)
+	failures sync.Map // namespace -> time.Time, until which it isn't read
+}
+
+// shardKey returns the shard key of the querier's collection, nil when it
+// isn't sharded.
+func (p *ShardKeyPolicy) shardKey(ctx context.Context, collection *mongo.Collection) (bson.D, error) {
+	namespace := collection.Database().Name() + "." + collection.Name()
+	if key, ok := p.keys.Load(namespace); ok {
+		return key.(bson.D), nil
+	}
+	if until, ok := p.failures.Load(namespace); ok && time.Now().Before(until.(time.Time)) {
+		return nil, nil
+	}
+
+	var metadata struct {
+		Key     bson.D `bson:"key"`
+		Dropped bool   `bson:"dropped"`
+		// Unsplittable collections are tracked by the config server
+		// without being sharded
+		Unsplittable bool `bson:"unsplittable"`
+	}
+	config := collection.Database().Client().Database("config").Collection("collections")
+	err := config.FindOne(ctx, bson.M{"_id": namespace}).Decode(&metadata)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		retryInterval := p.RetryInterval
+		if retryInterval <= 0 {
+			retryInterval = DefaultShardKeyRetryInterval
+		}
+		p.failures.Store(namespace, time.Now().Add(retryInterval))
+		return nil, err
+	}
+	p.failures.Delete(namespace)
+
+	var key bson.D
+	if err == nil && !metadata.Dropped && !metadata.Unsplittable {
+		key = metadata.Key
+	}
+	p.keys.Store(namespace, key)
+	return key, nil
+}
+
+// targetsShardKey reports whether filter constrains the leading field of
+// key, by equality or $in, or by range on ranged keys, so mongos can route
+// it to the shards owning the matching chunks.
+func targetsShardKey(filter interface{}, key bson.D) bool {
+	document, ok := asDocument(filter)
+	if !ok || len(key) == 0 {
+		return false
+	}
+	leading := key[0].Key
+	hashed := key[0].Value == "hashed"
+
+	for _, e := range document {
+		if e.Key == "$and" {
+			clauses, _ := asArray(e.Value)
+			for _, clause := range clauses {
+				if targetsShardKey(clause, key) {
+					return true
+				}
+			}
+			continue
+		}
+		if e.Key != leading {
+			continue
+		}
+
+		condition, ok := asDocument(e.Value)
+		if !ok || !hasOperator(condition) {
+			return true
+		}
+		for _, c := range condition {
+			switch c.Key {
+			case "$eq", "$in":
+				return true
+			case "$gt", "$gte", "$lt", "$lte":
+				if !hashed {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (q *Querier[Model, IDModel]) checkShardKeyPolicy(ctx context.Context, operation string, filter primitive.M) error {
+	if q.ShardKeyPolicy == nil || filter == nil {
+		return nil
+	}
+
+	key, err := q.ShardKeyPolicy.shardKey(ctx, q.tenantCollection(ctx))
+	if err != nil {
+		// The policy is a safety net, a metadata failure shouldn't fail the
+		// query; it's retried after the policy's RetryInterval
+		q.MongoAdapter.Warn(
+			"unable to read shard key",
+			LogField("collection_name", q.collection.Name()),
+			LogError(err),
+		)
+		return nil
+	}
+	if key == nil || targetsShardKey(filter, key) {
+		return nil
+	}
+
+	shape := QueryShape(filter)
+	if q.ShardKeyPolicy.Mode == ShardKeyDeny || q.MongoAdapter.Strict() {
+		q.MongoAdapter.Error(
+			"Rejected scatter-gather query",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("query_shape", shape),
+		)
+		return fmt.Errorf("%w: %s on %s with shape %s", ErrScatterGather, operation, q.collection.Name(), shape)
+	}
+
+	q.MongoAdapter.Warn(
+		"Scatter-gather query",
+		LogField("collection_name", q.collection.Name()),
+		LogField("operation", operation),
+		LogField("query_shape", shape),
+	)
+	return nil
+}
+
+// shardKeyField is a field tagged `mq:"shardkey"`: its stored (dotted) path
+// and the index sequence reaching it through nested structs.
+type shardKeyField struct {
+	path  string
+	index []int
+}
+
+var shardKeyCache sync.Map // reflect.Type -> []shardKeyField
+
+// ShardKeyFields returns the stored (dotted) paths of the fields of Model
+// tagged `mq:"shardkey"`, in declaration order.
+func ShardKeyFields[Model any]() []string {
+	var paths []string
+	for _, field := range shardKeyFieldsFor(modelType[Model]()) {
+		paths = append(paths, field.path)
+	}
+	return paths
+}
+
+func shardKeyFieldsFor(t reflect.Type) []shardKeyField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if cached, ok := shardKeyCache.Load(t); ok {
+		return cached.([]shardKeyField)
+	}
+
+	fields := collectShardKeyFields(t, map[reflect.Type]bool{})
+	shardKeyCache.Store(t, fields)
+	return fields
+}
+
+// collectShardKeyFields walks t's fields, skipping the types already being
+// walked so self-referential models terminate.
+func collectShardKeyFields(t reflect.Type, visiting map[reflect.Type]bool) []shardKeyField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []shardKeyField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("bson") == "-" {
+			continue
+		}
+
+		key := bsonKey(field)
+		if _, ok := parseMQTag(field.Tag.Get("mq"))["shardkey"]; ok {
+			fields = append(fields, shardKeyField{path: key, index: []int{i}})
+			continue
+		}
+		for _, nested := range collectShardKeyFields(field.Type, visiting) {
+			fields = append(fields, shardKeyField{
+				path:  key + "." + nested.path,
+				index: append([]int{i}, nested.index...),
+			})
+		}
+	}
+	return fields
+}
+
+// WithShardKey adds the shard key values of document, the fields tagged
+// `mq:"shardkey"`, to filter, so the query is routed to a single shard:
+//
+//	type Order struct {
+//		ID         primitive.ObjectID `bson:"_id,omitempty"`
+//		CustomerID string             `bson:"customer_id" mq:"shardkey"`
+//	}
+//
+//	filter, err := mongoquerier.WithShardKey(bson.M{"_id": order.ID}, order)
+//
+// Zero values are shard key values like any other; a nil pointer on the way
+// fails with ErrMissingShardKey. filter isn't modified.
+func WithShardKey[Model any](filter primitive.M, document Model) (primitive.M, error) {
+	fields := shardKeyFieldsFor(modelType[Model]())
+
+	targeted := make(primitive.M, len(filter)+len(fields))
+	for key, value := range filter {
+		targeted[key] = value
+	}
+	root := reflect.Indirect(reflect.ValueOf(document))
+	for _, field := range fields {
+		value, ok := reflect.Value{}, false
+		if root.IsValid() {
+			value, ok = fieldByIndex(root, field.index)
+		}
+		if ok && value.Kind() == reflect.Pointer {
+			ok = !value.IsNil()
+			value = value.Elem()
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingShardKey, field.path)
+		}
+		targeted[field.path] = value.Interface()
+	}
+	return targeted, nil
+}
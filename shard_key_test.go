@@ -0,0 +1,47 @@
+package mongoquerier
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type shardedNode struct {
+	ID       string       `bson:"_id"`
+	Region   string       `bson:",omitempty" json:"region_code" mq:"shardkey"`
+	Parent   *shardedNode `bson:"parent"`
+	Location *struct {
+		Zone string `bson:"zone" mq:"shardkey"`
+	} `bson:"location"`
+}
+
+func TestShardKeyFieldsRecursiveModel(t *testing.T) {
+	paths := ShardKeyFields[shardedNode]()
+	if len(paths) != 2 || paths[0] != "region" || paths[1] != "location.zone" {
+		t.Fatalf("ShardKeyFields() = %v, want [region location.zone]", paths)
+	}
+}
+
+func TestWithShardKeyUsesStoredKeys(t *testing.T) {
+	node := shardedNode{ID: "a", Location: &struct {
+		Zone string `bson:"zone" mq:"shardkey"`
+	}{Zone: "eu-1"}}
+
+	filter, err := WithShardKey(bson.M{"_id": "a"}, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The zero region is a shard key value, under its bson key
+	if region, ok := filter["region"]; !ok || region != "" {
+		t.Errorf("filter[region] = %v, want the zero value", region)
+	}
+	if filter["location.zone"] != "eu-1" {
+		t.Errorf("filter[location.zone] = %v, want eu-1", filter["location.zone"])
+	}
+
+	node.Location = nil
+	if _, err := WithShardKey(bson.M{"_id": "a"}, node); !errors.Is(err, ErrMissingShardKey) {
+		t.Errorf("WithShardKey() error = %v, want ErrMissingShardKey", err)
+	}
+}
@@ -0,0 +1,143 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrShuttingDown = errors.New("adapter is shutting down")
+
+// operationTracker counts the operations in flight on an adapter's client,
+// cursors and change streams included until they're closed.
+type operationTracker struct {
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	drained  chan struct{}
+}
+
+// begin admits an operation unless the tracker is draining, checked under
+// the same lock so that none starts once drain has returned.
+func (t *operationTracker) begin() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return ErrShuttingDown
+	}
+	t.inFlight++
+	return nil
+}
+
+// retain counts a cursor or change stream opened by an operation already
+// admitted, which holds the drain until it's counted.
+func (t *operationTracker) retain() {
+	t.mu.Lock()
+	t.inFlight++
+	t.mu.Unlock()
+}
+
+func (t *operationTracker) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inFlight--
+	if t.draining && t.inFlight == 0 {
+		t.closeDrained()
+	}
+}
+
+// closeDrained closes drained at most once, whatever ends arrive late.
+func (t *operationTracker) closeDrained() {
+	select {
+	case <-t.drained:
+	default:
+		close(t.drained)
+	}
+}
+
+// drain stops new operations and returns a channel closed once the ones in
+// flight are done.
+func (t *operationTracker) drain() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.draining {
+		t.draining = true
+		t.drained = make(chan struct{})
+		if t.inFlight == 0 {
+			t.closeDrained()
+		}
+	}
+	return t.drained
+}
+
+func (t *operationTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inFlight
+}
+
+// inFlight returns the tracker of the adapter's operations, shared with the
+// adapters on other databases of its client (see InDatabase).
+func (madp *MongoAdapter) inFlight() *operationTracker {
+	madp.operationsOnce.Do(func() {
+		if madp.operations == nil {
+			madp.operations = &operationTracker{}
+		}
+	})
+	return madp.operations
+}
+
+// trackCursor marks a cursor, change stream or background index build in
+// flight until the returned func is first called.
+func (madp *MongoAdapter) trackCursor() func() {
+	tracker := madp.inFlight()
+	tracker.retain()
+
+	var once sync.Once
+	return func() { once.Do(tracker.end) }
+}
+
+// Shutdown stops the adapter's queriers from starting new operations, which
+// fail with ErrShuttingDown, waits for the ones in flight and for open
+// cursors and change streams to be closed, then disconnects. When ctx is
+// done first, it disconnects anyway and returns ctx's error:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	err := mongoAdapter.Shutdown(ctx)
+//
+// Unlike Disconnect, it doesn't cut reads off mid-cursor as long as they
+// finish in time.
+func (madp *MongoAdapter) Shutdown(ctx context.Context) error {
+	tracker := madp.inFlight()
+	madp.Info("Shutting down", LogField("operations_in_flight", tracker.count()))
+
+	var err error
+	select {
+	case <-tracker.drain():
+	case <-ctx.Done():
+		err = ctx.Err()
+		madp.Warn(
+			"Disconnecting with operations in flight",
+			LogField("operations_in_flight", tracker.count()),
+			LogError(err),
+		)
+	}
+
+	// Disconnect even when ctx is done, closing connections right away
+	if disconnectErr := madp.Disconnect(detachedContext{ctx}); disconnectErr != nil {
+		return errors.Join(err, disconnectErr)
+	}
+	return err
+}
+
+// startOperation starts the span of an operation preflight admitted in
+// flight, for Shutdown to drain; observe ends both.
+func (q *Querier[Model, IDModel]) startOperation(ctx context.Context, operation string, filter primitive.M) (context.Context, Span) {
+	return q.startSpan(ctx, operation, filter)
+}
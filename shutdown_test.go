@@ -0,0 +1,68 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestOperationTrackerRejectsAfterDrain(t *testing.T) {
+	tracker := &operationTracker{}
+	if err := tracker.begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	drained := tracker.drain()
+	if err := tracker.begin(); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("begin() after drain = %v, want ErrShuttingDown", err)
+	}
+
+	tracker.end()
+	<-drained
+	// A late end, e.g. a cursor closed twice over, must not close drained again
+	tracker.end()
+	if tracker.drain() != drained {
+		t.Error("drain() returned another channel")
+	}
+}
+
+func TestOperationTrackerConcurrentDrain(t *testing.T) {
+	tracker := &operationTracker{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tracker.begin() == nil {
+				tracker.end()
+			}
+		}()
+	}
+	drained := tracker.drain()
+	wg.Wait()
+
+	<-drained
+	if count := tracker.count(); count != 0 {
+		t.Errorf("count() = %d, want 0", count)
+	}
+}
+
+func TestPreflightWhileShuttingDown(t *testing.T) {
+	madp := newTestAdapter(t)
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	<-madp.inFlight().drain()
+
+	err := q.preflight(context.Background(), "FindByM", nil)
+	if !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("preflight() = %v, want ErrShuttingDown", err)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Operation != "FindByM" {
+		t.Errorf("preflight() = %v, want an OpError of FindByM", err)
+	}
+	if count := madp.inFlight().count(); count != 0 {
+		t.Errorf("count() = %d, want 0", count)
+	}
+}
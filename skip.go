@@ -0,0 +1,86 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DecodeError is a stored document that couldn't be decoded into the model.
+type DecodeError struct {
+	// ID is the raw _id of the document, if it has one.
+	ID  bson.RawValue
+	Err error
+}
+
+func newDecodeError(stored bson.Raw, err error) *DecodeError {
+	decodeErr := &DecodeError{Err: err}
+	if id, lookupErr := stored.LookupErr("_id"); lookupErr == nil {
+		// Cursors reuse their buffer, keep a copy
+		decodeErr.ID = bson.RawValue{Type: id.Type, Value: append([]byte(nil), id.Value...)}
+	}
+	return decodeErr
+}
+
+func (e *DecodeError) Error() string {
+	if e.ID.Value == nil {
+		return fmt.Sprintf("unable to decode document: %v", e.Err)
+	}
+	return fmt.Sprintf("unable to decode document %s: %v", e.ID, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeReport collects the documents skipped by reads made with
+// SkipUndecodable.
+type DecodeReport struct {
+	mu      sync.Mutex
+	skipped []*DecodeError
+}
+
+func (r *DecodeReport) Skipped() []*DecodeError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*DecodeError(nil), r.skipped...)
+}
+
+func (r *DecodeReport) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.skipped)
+}
+
+type skipUndecodableKey struct{}
+
+// SkipUndecodable makes the multi-document reads issued with the returned
+// context (Find, FindByM, cursors, ForEach...) skip documents that fail to
+// decode, recording them in report, instead of failing:
+//
+//	report := &mongoquerier.DecodeReport{}
+//	users, err := querier.FindByM(mongoquerier.SkipUndecodable(ctx, report), filter)
+//	for _, skipped := range report.Skipped() { ... }
+//
+// Single-document reads still fail on an undecodable document.
+func SkipUndecodable(ctx context.Context, report *DecodeReport) context.Context {
+	return context.WithValue(ctx, skipUndecodableKey{}, report)
+}
+
+// skipUndecodable reports whether a multi-document read should go past err,
+// recording it.
+func skipUndecodable(ctx context.Context, err error) bool {
+	report, _ := ctx.Value(skipUndecodableKey{}).(*DecodeReport)
+	var decodeErr *DecodeError
+	if report == nil || !errors.As(err, &decodeErr) {
+		return false
+	}
+
+	report.mu.Lock()
+	report.skipped = append(report.skipped, decodeErr)
+	report.mu.Unlock()
+	return true
+}
@@ -0,0 +1,59 @@
+//go:build go1.21
+
+package mongoquerier
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// SlogLogger logs through a log/slog logger.
+func SlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.LogAttrs(ctx, level, msg, slogAttrs(fields)...)
+}
+
+func (l slogLogger) Debug(msg string, fields ...Field) { l.log(slog.LevelDebug, msg, fields) }
+func (l slogLogger) Info(msg string, fields ...Field)  { l.log(slog.LevelInfo, msg, fields) }
+func (l slogLogger) Warn(msg string, fields ...Field)  { l.log(slog.LevelWarn, msg, fields) }
+func (l slogLogger) Error(msg string, fields ...Field) { l.log(slog.LevelError, msg, fields) }
+
+func (l slogLogger) With(fields ...Field) Logger {
+	args := make([]interface{}, 0, len(fields))
+	for _, attr := range slogAttrs(fields) {
+		args = append(args, attr)
+	}
+	return slogLogger{logger: l.logger.With(args...)}
+}
+
+func slogAttrs(fields []Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		if valuer, ok := field.Value.(LogValuer); ok {
+			attrs = append(attrs, slog.Any(field.Key, slogValuer{valuer}))
+			continue
+		}
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+	return attrs
+}
+
+// slogValuer defers a LogValuer to slog's own lazy resolution.
+type slogValuer struct {
+	valuer LogValuer
+}
+
+func (v slogValuer) LogValue() slog.Value {
+	return slog.AnyValue(v.valuer.LogValue())
+}
@@ -0,0 +1,71 @@
+package mongoquerier
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sort is an ordered sort specification, built from Asc, Desc and
+// TextScore keys in order of precedence:
+//
+//	sort := mongoquerier.SortBy(mongoquerier.Desc("created_at"), mongoquerier.Asc("_id"))
+//	documents, err := querier.FindByM(ctx, filter, sort.Find().SetLimit(20))
+//	pipeline := mongo.Pipeline{matchStage, sort.Stage()}
+//
+// The keys are primitive.E values, so they also go wherever the package
+// takes sort keys, e.g. FindPage.
+type Sort bson.D
+
+func SortBy(keys ...primitive.E) Sort {
+	return Sort(keys)
+}
+
+func Asc(key string) primitive.E {
+	return primitive.E{Key: key, Value: 1}
+}
+
+func Desc(key string) primitive.E {
+	return primitive.E{Key: key, Value: -1}
+}
+
+// TextScore sorts by relevance to the filter's $text search, most relevant
+// first.
+func TextScore() primitive.E {
+	return primitive.E{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}
+}
+
+// Then appends keys, breaking ties of the keys before.
+func (s Sort) Then(keys ...primitive.E) Sort {
+	return append(append(Sort(nil), s...), keys...)
+}
+
+func (s Sort) D() bson.D {
+	return bson.D(s)
+}
+
+// Stage returns the $sort stage of an aggregation pipeline.
+func (s Sort) Stage() bson.D {
+	return bson.D{{Key: "$sort", Value: bson.D(s)}}
+}
+
+func (s Sort) Find() *options.FindOptions {
+	return options.Find().SetSort(bson.D(s))
+}
+
+func (s Sort) FindOne() *options.FindOneOptions {
+	return options.FindOne().SetSort(bson.D(s))
+}
+
+// FindOneAndUpdate picks the document UpdateOne updates when several match.
+func (s Sort) FindOneAndUpdate() *options.FindOneAndUpdateOptions {
+	return options.FindOneAndUpdate().SetSort(bson.D(s))
+}
+
+func (s Sort) FindOneAndReplace() *options.FindOneAndReplaceOptions {
+	return options.FindOneAndReplace().SetSort(bson.D(s))
+}
+
+func (s Sort) FindOneAndDelete() *options.FindOneAndDeleteOptions {
+	return options.FindOneAndDelete().SetSort(bson.D(s))
+}
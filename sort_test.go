@@ -0,0 +1,44 @@
+package mongoquerier
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSort(t *testing.T) {
+	byDate := SortBy(Desc("created_at"))
+	tests := []struct {
+		name string
+		sort Sort
+		want bson.D
+	}{
+		{"ascending", SortBy(Asc("name")), bson.D{{Key: "name", Value: 1}}},
+		{"descending", byDate, bson.D{{Key: "created_at", Value: -1}}},
+		{"text score", SortBy(TextScore()), bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}},
+		{"ties broken in order", byDate.Then(Asc("_id")), bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+	for _, tt := range tests {
+		if got := tt.sort.D(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: D() = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.sort.Find().Sort; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Find().Sort = %v, want %v", tt.name, got, tt.want)
+		}
+		if got, want := tt.sort.Stage(), (bson.D{{Key: "$sort", Value: tt.want}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Stage() = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestSortThenDoesNotAlias(t *testing.T) {
+	base := make(Sort, 1, 2)
+	base[0] = Desc("created_at")
+	byID := base.Then(Asc("_id"))
+	byName := base.Then(Asc("name"))
+
+	if byID[1].Key != "_id" || byName[1].Key != "name" {
+		t.Errorf("Then() shared its receiver's array: %v, %v", byID, byName)
+	}
+}
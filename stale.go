@@ -0,0 +1,172 @@
+package mongoquerier
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const DefaultReadCacheEntries = 10000
+
+// ReadCache keeps the last document read per key, for serving stale reads
+// while the cluster is unavailable. Implementations must be safe for
+// concurrent use; MemoryReadCache is an in-process one.
+type ReadCache interface {
+	Get(key string) (document bson.Raw, storedAt time.Time, ok bool)
+	Set(key string, document bson.Raw)
+}
+
+// StaleReads configures FindOneOrStale.
+type StaleReads struct {
+	Cache ReadCache
+	// MaxStaleness bounds the age of a stale document; zero serves any.
+	MaxStaleness time.Duration
+}
+
+// NewStaleReads serves stale documents from cache up to maxStaleness old. It
+// panics on a nil cache, which FindOneOrStale would otherwise only trip on
+// at its first read.
+func NewStaleReads(cache ReadCache, maxStaleness time.Duration) *StaleReads {
+	if cache == nil {
+		panic("mongoquerier: stale reads need a cache")
+	}
+	return &StaleReads{Cache: cache, MaxStaleness: maxStaleness}
+}
+
+type StaleResult[Model any] struct {
+	Document *Model
+	// Stale is set when Document comes from the cache because the read
+	// failed; StoredAt is when it was cached.
+	Stale    bool
+	StoredAt time.Time
+	// Err is the read failure a stale document stands in for.
+	Err error
+}
+
+// FindOneOrStale reads one document like FindOneByM and remembers it. When
+// the read fails because the cluster is unreachable or too slow, it returns
+// the last document read for the same filter instead, flagged as stale. It
+// behaves like FindOneByM when the querier has no StaleReads.
+func (q *Querier[Model, IDModel]) FindOneOrStale(ctx context.Context, filter primitive.M) (result *StaleResult[Model], err error) {
+	if err = q.preflight(ctx, "FindOneOrStale", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "FindOneOrStale", filter)
+	defer q.observe(span, time.Now(), "FindOneOrStale", filter, &err)
+
+	raw, err := retrying(ctx, q, "FindOneOrStale", func() (bson.Raw, error) {
+		return q.readCollection(ctx).FindOne(ctx, filter).Raw()
+	})
+	if err == nil {
+		document, err := q.decode(ctx, raw)
+		if err != nil {
+			return nil, err
+		}
+		if q.StaleReads != nil {
+			if key, err := normalizeFilter(filter); err == nil {
+				q.StaleReads.Cache.Set(q.namespace(ctx)+" "+key, raw)
+			}
+		}
+		return &StaleResult[Model]{Document: document}, nil
+	}
+
+	if q.StaleReads == nil || !isUnavailable(err) {
+		return nil, err
+	}
+	key, keyErr := normalizeFilter(filter)
+	if keyErr != nil {
+		return nil, err
+	}
+	cached, storedAt, ok := q.StaleReads.Cache.Get(q.namespace(ctx) + " " + key)
+	if !ok || (q.StaleReads.MaxStaleness > 0 && time.Since(storedAt) > q.StaleReads.MaxStaleness) {
+		return nil, err
+	}
+
+	document, decodeErr := q.decode(ctx, cached)
+	if decodeErr != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Warn(
+		"Served stale document",
+		LogField("collection_name", q.collection.Name()),
+		LogField("query_shape", QueryShape(filter)),
+		LogField("staleness", time.Since(storedAt)),
+		LogError(err),
+	)
+	return &StaleResult[Model]{Document: document, Stale: true, StoredAt: storedAt, Err: err}, nil
+}
+
+// isUnavailable reports whether a read failed for lack of an answer rather
+// than because of the query.
+func isUnavailable(err error) bool {
+	return IsOutage(err) || mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// MemoryReadCache is a ReadCache holding up to MaxEntries documents, evicting
+// the least recently used.
+type MemoryReadCache struct {
+	MaxEntries int
+
+	mu      sync.Mutex
+	order   *list.List // of *readCacheEntry, most recent first
+	entries map[string]*list.Element
+}
+
+type readCacheEntry struct {
+	key      string
+	document bson.Raw
+	storedAt time.Time
+}
+
+func NewMemoryReadCache(maxEntries int) *MemoryReadCache {
+	return &MemoryReadCache{MaxEntries: maxEntries}
+}
+
+func (c *MemoryReadCache) Get(key string) (bson.Raw, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*readCacheEntry)
+	return entry.document, entry.storedAt, true
+}
+
+func (c *MemoryReadCache) Set(key string, document bson.Raw) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+
+	// The driver reuses its buffers, keep a copy
+	entry := &readCacheEntry{key: key, document: append(bson.Raw(nil), document...), storedAt: time.Now()}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultReadCacheEntries
+	}
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*readCacheEntry).key)
+	}
+}
@@ -0,0 +1,15 @@
+package mongoquerier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewStaleReadsRejectsNilCache(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewStaleReads(nil) didn't panic")
+		}
+	}()
+	NewStaleReads(nil, time.Minute)
+}
@@ -0,0 +1,173 @@
+package mongoquerier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrEmptySubjectFilter rejects subject exports and erasures whose filter,
+// empty, would match every document of the collections.
+var ErrEmptySubjectFilter = errors.New("empty subject filter")
+
+// SubjectExport bundles every document held about a data subject, keyed by
+// collection, for data-subject access requests.
+type SubjectExport struct {
+	SubjectFilter bson.M
+	ExportedAt    time.Time
+	Collections   map[string][]bson.Raw
+
+	// PII holds the classified fields of each exported collection, as
+	// declared by `pii` tags on the models of its queriers.
+	PII map[string]map[string]string
+}
+
+// MarshalJSON renders the bundle with documents as relaxed extended JSON so
+// BSON types survive the export.
+func (se *SubjectExport) MarshalJSON() ([]byte, error) {
+	filter, err := bson.MarshalExtJSON(se.SubjectFilter, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := map[string][]json.RawMessage{}
+	for name, documents := range se.Collections {
+		collections[name] = []json.RawMessage{}
+		for _, document := range documents {
+			data, err := bson.MarshalExtJSON(document, false, false)
+			if err != nil {
+				return nil, err
+			}
+			collections[name] = append(collections[name], data)
+		}
+	}
+
+	return json.Marshal(struct {
+		SubjectFilter json.RawMessage              `json:"subject_filter"`
+		ExportedAt    time.Time                    `json:"exported_at"`
+		Collections   map[string][]json.RawMessage `json:"collections"`
+		PII           map[string]map[string]string `json:"pii,omitempty"`
+	}{filter, se.ExportedAt, collections, se.PII})
+}
+
+func (se *SubjectExport) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(se, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// ExportSubject collects every document matching subjectFilter from the
+// given collections. An empty subjectFilter fails with
+// ErrEmptySubjectFilter rather than exporting whole collections.
+func (madp *MongoAdapter) ExportSubject(ctx context.Context, subjectFilter bson.M, collections ...string) (*SubjectExport, error) {
+	if len(subjectFilter) == 0 {
+		return nil, ErrEmptySubjectFilter
+	}
+
+	export := &SubjectExport{
+		SubjectFilter: subjectFilter,
+		ExportedAt:    time.Now().UTC(),
+		Collections:   map[string][]bson.Raw{},
+		PII:           map[string]map[string]string{},
+	}
+
+	for _, collectionName := range collections {
+		if err := madp.authorize(ctx, describeOperation(collectionName, "ExportSubject", subjectFilter)); err != nil {
+			return nil, err
+		}
+		collection, err := madp.collectionFor(ctx, collectionName)
+		if err != nil {
+			return nil, err
+		}
+		cursor, err := collection.Find(ctx, subjectFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		documents := []bson.Raw{}
+		for cursor.Next(ctx) {
+			documents = append(documents, append(bson.Raw(nil), cursor.Current...))
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		export.Collections[collectionName] = documents
+		if fields := madp.PIIFieldsOf(collectionName); len(fields) > 0 {
+			export.PII[collectionName] = fields
+		}
+	}
+
+	// The filter identifies the subject, so it's deliberately kept out of logs
+	madp.Info(
+		"Exported data subject",
+		LogField("collections", collections),
+	)
+	return export, nil
+}
+
+// ErasureReport holds the number of documents erased per collection.
+type ErasureReport struct {
+	SubjectFilter bson.M
+	ErasedAt      time.Time
+	Deleted       map[string]int64
+}
+
+// EraseSubject deletes every document matching subjectFilter from the given
+// collections, in the database of ctx's tenant when the adapter routes
+// tenants. It stops at the first failure; the report covers the collections
+// erased so far, so the call can be retried safely. An empty subjectFilter
+// fails with ErrEmptySubjectFilter rather than erasing every document.
+//
+// Deleting doesn't reach backups or copies made elsewhere; seal the
+// subject's fields with SubjectKeys to crypto-shred those too.
+func (madp *MongoAdapter) EraseSubject(ctx context.Context, subjectFilter bson.M, collections ...string) (*ErasureReport, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if len(subjectFilter) == 0 {
+		return nil, ErrEmptySubjectFilter
+	}
+
+	report := &ErasureReport{
+		SubjectFilter: subjectFilter,
+		ErasedAt:      time.Now().UTC(),
+		Deleted:       map[string]int64{},
+	}
+
+	for _, collectionName := range collections {
+		if err := madp.authorize(ctx, describeOperation(collectionName, "EraseSubject", subjectFilter)); err != nil {
+			return report, err
+		}
+		collection, err := madp.collectionFor(ctx, collectionName)
+		if err != nil {
+			return report, err
+		}
+		res, err := collection.DeleteMany(ctx, subjectFilter)
+		if err != nil {
+			madp.Error(
+				"unable to erase data subject",
+				LogField("collection_name", collectionName),
+				LogError(err),
+			)
+			return report, err
+		}
+		report.Deleted[collectionName] = res.DeletedCount
+	}
+
+	madp.Info(
+		"Erased data subject",
+		LogField("documents_deleted", report.Deleted),
+	)
+	return report, nil
+}
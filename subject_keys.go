@@ -0,0 +1,201 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSubjectShredded  = errors.New("data subject key shredded")
+	ErrInvalidMasterKey = errors.New("master key must be 32 bytes")
+	ErrInvalidSealed    = errors.New("sealed value is malformed or not the subject's")
+)
+
+// SubjectKeys keeps a data key per data subject, for crypto-shredding: the
+// values sealed with a subject's key can only be opened while the key
+// exists, so shredding it erases them everywhere at once, including the
+// copies EraseSubject can't reach, such as backups, exports and replicas:
+//
+//	keys, err := mongoquerier.NewSubjectKeys(mongoAdapter, "subject_keys", masterKey)
+//	user.SSN, err = keys.Seal(ctx, userID, []byte(ssn))
+//	...
+//	report, err := mongoAdapter.EraseSubject(ctx, bson.M{"user_id": userID}, "users", "orders")
+//	shredded, err := keys.Shred(ctx, userID)
+//
+// Data keys are stored in the collection wrapped with masterKey (AES-256-GCM),
+// which must be kept out of the database. Keep the key collection out of
+// the long-lived backups of the data too, since a restored key opens what it
+// sealed again. Keys live in the database of ctx's tenant when the adapter
+// routes tenants.
+type SubjectKeys struct {
+	*MongoAdapter
+	collectionName string
+	master         cipher.AEAD
+}
+
+// subjectKey is a stored data key, wrapped with the master key.
+type subjectKey struct {
+	Subject   string    `bson:"_id"`
+	Key       []byte    `bson:"key"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func NewSubjectKeys(madp *MongoAdapter, collectionName string, masterKey []byte) (*SubjectKeys, error) {
+	if len(masterKey) != 32 {
+		return nil, ErrInvalidMasterKey
+	}
+	master, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SubjectKeys{MongoAdapter: madp, collectionName: collectionName, master: master}, nil
+}
+
+// Seal encrypts plaintext with subject's key, creating the key on first use.
+// The sealed value is bound to subject: opening it for another fails.
+func (sk *SubjectKeys) Seal(ctx context.Context, subject string, plaintext []byte) (primitive.Binary, error) {
+	key, err := sk.key(ctx, subject, true)
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+	sealed, err := seal(key, plaintext, []byte(subject))
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+	return primitive.Binary{Data: sealed}, nil
+}
+
+// Open decrypts a value sealed for subject. It fails with
+// ErrSubjectShredded once the subject's key was shredded.
+func (sk *SubjectKeys) Open(ctx context.Context, subject string, sealed primitive.Binary) ([]byte, error) {
+	key, err := sk.key(ctx, subject, false)
+	if err != nil {
+		return nil, err
+	}
+	return open(key, sealed.Data, []byte(subject))
+}
+
+// Shred deletes subject's key, reporting whether there was one, which makes
+// every value sealed for the subject unreadable. Sealing for the subject
+// afterwards creates a new key.
+func (sk *SubjectKeys) Shred(ctx context.Context, subject string) (bool, error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	collection, err := sk.authorizedCollection(ctx, "ShredSubjectKey", subject)
+	if err != nil {
+		return false, err
+	}
+	res, err := collection.DeleteOne(ctx, bson.M{"_id": subject})
+	if err != nil {
+		sk.MongoAdapter.Error("unable to shred data subject key", LogField("collection_name", sk.collectionName), LogError(err))
+		return false, err
+	}
+
+	// The subject is deliberately kept out of logs
+	sk.MongoAdapter.Info(
+		"Shredded data subject key",
+		LogField("collection_name", sk.collectionName),
+		LogField("key_deleted", res.DeletedCount > 0),
+	)
+	return res.DeletedCount > 0, nil
+}
+
+func (sk *SubjectKeys) authorizedCollection(ctx context.Context, operation string, subject string) (*mongo.Collection, error) {
+	if err := sk.MongoAdapter.authorize(ctx, describeOperation(sk.collectionName, operation, primitive.M{"_id": subject})); err != nil {
+		return nil, err
+	}
+	return sk.MongoAdapter.collectionFor(ctx, sk.collectionName)
+}
+
+// key returns subject's data key, creating it when create is set.
+func (sk *SubjectKeys) key(ctx context.Context, subject string, create bool) (cipher.AEAD, error) {
+	operation := "LookupSubjectKey"
+	if create {
+		operation = "CreateSubjectKey"
+	}
+	collection, err := sk.authorizedCollection(ctx, operation, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored subjectKey
+	if create {
+		stored, err = sk.createKey(ctx, collection, subject)
+	} else {
+		err = collection.FindOne(ctx, bson.M{"_id": subject}).Decode(&stored)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = ErrSubjectShredded
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := open(sk.master, stored.Key, []byte(subject))
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(data)
+}
+
+// createKey stores a new key for subject unless it has one, and returns the
+// stored one.
+func (sk *SubjectKeys) createKey(ctx context.Context, collection *mongo.Collection, subject string) (subjectKey, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return subjectKey{}, err
+	}
+	wrapped, err := seal(sk.master, data, []byte(subject))
+	if err != nil {
+		return subjectKey{}, err
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{"key": wrapped, "created_at": time.Now().UTC()}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored subjectKey
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": subject}, update, opts).Decode(&stored)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent upsert created it first, keep theirs
+		err = collection.FindOneAndUpdate(ctx, bson.M{"_id": subject}, update, opts).Decode(&stored)
+	}
+	return stored, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with aead under a random nonce, which it prepends,
+// authenticating additional with it.
+func seal(aead cipher.AEAD, plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidSealed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, ErrInvalidSealed
+	}
+	return plaintext, nil
+}
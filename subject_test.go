@@ -0,0 +1,76 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSubjectRequiresFilter(t *testing.T) {
+	madp := newTestAdapter(t)
+	for _, filter := range []bson.M{nil, {}} {
+		if _, err := madp.ExportSubject(context.Background(), filter, "users"); !errors.Is(err, ErrEmptySubjectFilter) {
+			t.Errorf("ExportSubject(%v) error = %v, want ErrEmptySubjectFilter", filter, err)
+		}
+		if _, err := madp.EraseSubject(context.Background(), filter, "users"); !errors.Is(err, ErrEmptySubjectFilter) {
+			t.Errorf("EraseSubject(%v) error = %v, want ErrEmptySubjectFilter", filter, err)
+		}
+	}
+}
+
+func TestSealIsBoundToKeyAndSubject(t *testing.T) {
+	key, err := newGCM(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := seal(key, []byte("078-05-1120"), []byte("u1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext, err := open(key, sealed, []byte("u1")); err != nil || string(plaintext) != "078-05-1120" {
+		t.Errorf("open() = %q, %v, want the plaintext", plaintext, err)
+	}
+	if _, err := open(key, sealed, []byte("u2")); !errors.Is(err, ErrInvalidSealed) {
+		t.Errorf("open() for another subject = %v, want ErrInvalidSealed", err)
+	}
+	other, _ := newGCM(bytes.Repeat([]byte{1}, 32))
+	if _, err := open(other, sealed, []byte("u1")); !errors.Is(err, ErrInvalidSealed) {
+		t.Errorf("open() with another key = %v, want ErrInvalidSealed", err)
+	}
+	if _, err := open(key, sealed[:4], []byte("u1")); !errors.Is(err, ErrInvalidSealed) {
+		t.Errorf("open() of a truncated value = %v, want ErrInvalidSealed", err)
+	}
+}
+
+func TestSubjectKeysRequireMasterKey(t *testing.T) {
+	if _, err := NewSubjectKeys(newTestAdapter(t), "subject_keys", make([]byte, 16)); !errors.Is(err, ErrInvalidMasterKey) {
+		t.Errorf("NewSubjectKeys() with a 16-byte key = %v, want ErrInvalidMasterKey", err)
+	}
+}
+
+func TestShredIsAuthorizedAsWrite(t *testing.T) {
+	madp := newTestAdapter(t)
+	var kinds []OperationKind
+	madp.Authorize = func(ctx context.Context, op OperationDescriptor) error {
+		kinds = append(kinds, op.Kind)
+		return errors.New("denied")
+	}
+	keys, err := NewSubjectKeys(madp, "subject_keys", make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Shred(context.Background(), "u1"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Shred() = %v, want ErrUnauthorized", err)
+	}
+	if _, err := keys.Open(context.Background(), "u1", primitive.Binary{}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Open() = %v, want ErrUnauthorized", err)
+	}
+	if len(kinds) != 2 || kinds[0] != OperationWrite || kinds[1] != OperationRead {
+		t.Errorf("authorized kinds %v, want write then read", kinds)
+	}
+}
@@ -0,0 +1,146 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrNoTenant      = errors.New("no tenant in context")
+	ErrInvalidTenant = errors.New("invalid tenant")
+)
+
+// tenantName is the tenants TenantDatabases formats into database names:
+// no dots, slashes or other characters MongoDB rejects or that would reach
+// another database.
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// reservedDatabases hold the server's own data, never a tenant's.
+var reservedDatabases = []string{"admin", "config", "local"}
+
+// TenantResolver resolves the database of the tenant a request belongs to,
+// for database-per-tenant deployments (see MongoAdapter.Tenants).
+type TenantResolver interface {
+	TenantDatabase(ctx context.Context) (string, error)
+}
+
+// TenantResolverFunc adapts a function to TenantResolver.
+type TenantResolverFunc func(ctx context.Context) (string, error)
+
+func (f TenantResolverFunc) TenantDatabase(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+type tenantKey struct{}
+
+// WithTenant attaches the tenant of the request to the returned context,
+// for TenantDatabases.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant, tenant != ""
+}
+
+// TenantDatabases resolves the database of the tenant set with WithTenant by
+// formatting format with it, e.g. "shop_%s". Contexts without a tenant fail
+// with ErrNoTenant, tenants other than letters, digits, "_" and "-" with
+// ErrInvalidTenant.
+func TenantDatabases(format string) TenantResolver {
+	return TenantResolverFunc(func(ctx context.Context) (string, error) {
+		tenant, ok := TenantFromContext(ctx)
+		if !ok {
+			return "", ErrNoTenant
+		}
+		if !tenantName.MatchString(tenant) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidTenant, tenant)
+		}
+		return fmt.Sprintf(format, tenant), nil
+	})
+}
+
+// tenantDatabase resolves the database of ctx's tenant, empty when the
+// adapter doesn't route tenants. Whatever the resolver, a tenant never
+// resolves to the server's admin, config or local database.
+func (madp *MongoAdapter) tenantDatabase(ctx context.Context) (string, error) {
+	if madp.Tenants == nil {
+		return "", nil
+	}
+	database, err := madp.Tenants.TenantDatabase(ctx)
+	if err != nil {
+		return "", err
+	}
+	if database == "" {
+		return "", ErrNoTenant
+	}
+	if containsString(reservedDatabases, strings.ToLower(database)) {
+		return "", fmt.Errorf("%w: resolves to the %s database", ErrInvalidTenant, database)
+	}
+	return database, nil
+}
+
+// databaseFor returns the database of ctx's tenant, or the adapter's
+// database when it doesn't route tenants.
+func (madp *MongoAdapter) databaseFor(ctx context.Context) (*mongo.Database, error) {
+	database, err := madp.tenantDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if database == "" {
+		return madp.GetDatabase(), nil
+	}
+	return madp.InDatabase(database).GetDatabase(), nil
+}
+
+// collectionFor returns the named collection in the database of ctx's
+// tenant, or in the adapter's database when it doesn't route tenants, for
+// the adapter's own readers and writers that don't go through a querier.
+func (madp *MongoAdapter) collectionFor(ctx context.Context, name string) (*mongo.Collection, error) {
+	database, err := madp.tenantDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if database == "" {
+		return madp.GetCollection(name), nil
+	}
+	return madp.GetCollectionIn(database, name), nil
+}
+
+// tenantCollection returns the querier's collection in the database of ctx's
+// tenant. preflight rejects contexts whose tenant doesn't resolve; should one
+// get here anyway, it gets a collection in no database, which the driver
+// refuses to run commands on rather than falling back to the default one.
+func (q *Querier[Model, IDModel]) tenantCollection(ctx context.Context) *mongo.Collection {
+	if q.MongoAdapter.Tenants == nil {
+		return q.collection
+	}
+	database, err := q.MongoAdapter.tenantDatabase(ctx)
+	if err != nil {
+		database = ""
+	}
+
+	if collection, ok := q.tenantCollections.Load(database); ok {
+		return collection.(*mongo.Collection)
+	}
+	collection := q.MongoAdapter.GetCollectionIn(database, q.collection.Name())
+	if database != "" {
+		q.tenantCollections.Store(database, collection)
+	}
+	return collection
+}
+
+// namespace names the querier's collection for cache keys, qualified with
+// the database of ctx's tenant when the adapter routes tenants.
+func (q *Querier[Model, IDModel]) namespace(ctx context.Context) string {
+	if q.MongoAdapter.Tenants == nil {
+		return q.collection.Name()
+	}
+	return q.tenantCollection(ctx).Database().Name() + "." + q.collection.Name()
+}
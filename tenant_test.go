@@ -0,0 +1,78 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestTenantDatabasesRejectsInvalidTenants(t *testing.T) {
+	resolver := TenantDatabases("shop_%s")
+	for _, tenant := range []string{"acme.orders", "../admin", "a b", "acme$"} {
+		if _, err := resolver.TenantDatabase(WithTenant(context.Background(), tenant)); !errors.Is(err, ErrInvalidTenant) {
+			t.Errorf("TenantDatabase(%q) error = %v, want ErrInvalidTenant", tenant, err)
+		}
+	}
+
+	database, err := resolver.TenantDatabase(WithTenant(context.Background(), "acme-eu_1"))
+	if err != nil || database != "shop_acme-eu_1" {
+		t.Errorf("TenantDatabase(acme-eu_1) = %q, %v, want shop_acme-eu_1", database, err)
+	}
+}
+
+func TestTenantDatabaseRejectsReservedDatabases(t *testing.T) {
+	madp := newTestAdapter(t)
+	madp.Tenants = TenantDatabases("%s")
+
+	for _, tenant := range []string{"admin", "Config", "local"} {
+		if _, err := madp.tenantDatabase(WithTenant(context.Background(), tenant)); !errors.Is(err, ErrInvalidTenant) {
+			t.Errorf("tenantDatabase(%q) error = %v, want ErrInvalidTenant", tenant, err)
+		}
+	}
+}
+
+func TestCollectionForTenant(t *testing.T) {
+	madp := newTestAdapter(t)
+	collection, err := madp.collectionFor(context.Background(), "orders")
+	if err != nil || collection.Database().Name() != "test" {
+		t.Fatalf("collectionFor() without tenants = %v, %v, want test.orders", collection, err)
+	}
+
+	madp.Tenants = TenantDatabases("shop_%s")
+	if _, err := madp.collectionFor(context.Background(), "orders"); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("collectionFor() without a tenant error = %v, want ErrNoTenant", err)
+	}
+	collection, err = madp.collectionFor(WithTenant(context.Background(), "acme"), "orders")
+	if err != nil || collection.Database().Name() != "shop_acme" {
+		t.Errorf("collectionFor() = %v, %v, want shop_acme.orders", collection, err)
+	}
+}
+
+func TestOfflineQueueKeepsTenantDatabase(t *testing.T) {
+	madp := newTestAdapter(t)
+	madp.Tenants = TenantDatabases("shop_%s")
+	oq, err := OpenOfflineQueue(madp, filepath.Join(t.TempDir(), "queue.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewQuerier[recursiveNode](madp, "nodes")
+	q.OfflineQueue = oq
+
+	ctx := WithTenant(context.Background(), "acme")
+	err = q.queueOnOutage(ctx, topology.ErrServerSelectionTimeout, QueuedInsertOne, nil, bson.M{"_id": 1, "email": "a@b.c"})
+	if !errors.Is(err, ErrWriteQueued) {
+		t.Fatalf("queueOnOutage() = %v, want ErrWriteQueued", err)
+	}
+
+	records, err := oq.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Database != "shop_acme" {
+		t.Errorf("queued records = %+v, want one for shop_acme", records)
+	}
+}
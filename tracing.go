@@ -0,0 +1,62 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tracer starts a span per querier operation. It's implemented over a
+// tracing library, e.g. for OpenTelemetry, picking the tracer provider of
+// the span in the context:
+//
+//	func (otelTracer) Start(ctx context.Context, name string, attributes ...mongoquerier.Field) (context.Context, mongoquerier.Span) {
+//		tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer("mongoquerier")
+//		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(otelAttributes(attributes)...))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string, attributes ...Field) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttributes(attributes ...Field)
+	RecordError(err error)
+	End()
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Field) {}
+func (nopSpan) RecordError(error)      {}
+func (nopSpan) End()                   {}
+
+// startSpan starts the span of an operation, named like "FindByM orders",
+// as commands spans (e.g. otelmongo's) started with the returned context
+// nest under it. Its attributes follow the OpenTelemetry database
+// conventions; filters are only described by their shape.
+func (q *Querier[Model, IDModel]) startSpan(ctx context.Context, operation string, filter primitive.M) (context.Context, Span) {
+	tracer := q.MongoAdapter.Tracer
+	if tracer == nil {
+		return ctx, nopSpan{}
+	}
+
+	attributes := []Field{
+		LogField("db.system", "mongodb"),
+		LogField("db.name", q.tenantCollection(ctx).Database().Name()),
+		LogField("db.mongodb.collection", q.collection.Name()),
+		LogField("db.operation", operation),
+		LogField("db.mongoquerier.operation_kind", string(operationKind(operation))),
+	}
+	if filter != nil {
+		attributes = append(attributes, LogField("db.mongoquerier.filter_shape", QueryShape(filter)))
+	}
+	return tracer.Start(ctx, operation+" "+q.collection.Name(), attributes...)
+}
+
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
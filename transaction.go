@@ -0,0 +1,52 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTransaction runs fn in a transaction and commits it when fn returns
+// nil, aborting it otherwise. Every querier operation given txCtx takes part
+// in the transaction. On transient errors (TransientTransactionError or
+// UnknownTransactionCommitResult) the whole transaction is retried, so fn
+// may run more than once and shouldn't have side effects outside MongoDB.
+//
+// Called with a context already in a transaction, fn joins it.
+func (madp *MongoAdapter) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error, opts ...*options.TransactionOptions) error {
+	if inTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := madp.Client.StartSession()
+	if err != nil {
+		madp.Error("unable to start session", LogError(err))
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	}, opts...)
+	if err != nil {
+		madp.Warn("Aborted transaction", LogError(err))
+		return err
+	}
+
+	madp.Debug("Committed transaction")
+	return nil
+}
+
+// inSession reports whether ctx carries a session, which binds operations to
+// the client that started it.
+func inSession(ctx context.Context) bool {
+	return mongo.SessionFromContext(ctx) != nil
+}
+
+// inTransaction reports whether ctx carries a session with a transaction in
+// progress.
+func inTransaction(ctx context.Context) bool {
+	session, ok := mongo.SessionFromContext(ctx).(mongo.XSession)
+	return ok && session.ClientSession().TransactionRunning()
+}
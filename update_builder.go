@@ -0,0 +1,258 @@
+package mongoquerier
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrEmptyUpdate = errors.New("update has no operators")
+
+// Update builds an update document with atomic operators, for the
+// Update*With methods:
+//
+//	update := mongoquerier.NewUpdate().
+//		Set(Product{Name: "Renamed"}).
+//		Inc("views", 1).
+//		Push("tags", "featured").
+//		Unset("legacy_code")
+//	document, err := querier.UpdateOneWithByM(ctx, filter, update)
+type Update struct {
+	operators bson.M
+	err       error
+}
+
+func NewUpdate() *Update {
+	return &Update{operators: bson.M{}}
+}
+
+func (u *Update) field(operator string, key string, value interface{}) *Update {
+	fields, ok := u.operators[operator].(bson.M)
+	if !ok {
+		fields = bson.M{}
+		u.operators[operator] = fields
+	}
+	fields[key] = value
+	return u
+}
+
+// Set sets the non-zero fields of model, like UpdateOne does.
+func (u *Update) Set(model interface{}) *Update {
+	m, err := StructToM(model)
+	if err != nil {
+		if u.err == nil {
+			u.err = err
+		}
+		return u
+	}
+	for key, value := range m {
+		u.field("$set", key, value)
+	}
+	return u
+}
+
+// SetAll sets every field of model, zero or not, e.g. to clear fields
+// through a typed model:
+//
+//	// Sets name to "" and quantity to 0, along with every other field
+//	document, err := querier.UpdateOneWith(ctx, filter, mongoquerier.NewUpdate().SetAll(Product{}))
+//
+// A zero _id and nil pointers are still left out.
+func (u *Update) SetAll(model interface{}) *Update {
+	m, err := StructToMWithZero(model)
+	if err != nil {
+		if u.err == nil {
+			u.err = err
+		}
+		return u
+	}
+	for key, value := range m {
+		u.field("$set", key, value)
+	}
+	return u
+}
+
+// SetDocument sets key to model as a whole embedded document, replacing the
+// stored subdocument, zero fields included (see DocumentMode).
+func (u *Update) SetDocument(key string, model interface{}) *Update {
+	value, err := bsonValue(reflect.ValueOf(model))
+	if err != nil {
+		if u.err == nil {
+			u.err = err
+		}
+		return u
+	}
+	return u.field("$set", key, value)
+}
+
+// SetField sets key to value, zero or not.
+func (u *Update) SetField(key string, value interface{}) *Update {
+	return u.field("$set", key, value)
+}
+
+func (u *Update) Inc(key string, amount interface{}) *Update {
+	return u.field("$inc", key, amount)
+}
+
+// Push appends values to the array at key.
+func (u *Update) Push(key string, values ...interface{}) *Update {
+	if len(values) == 1 {
+		return u.field("$push", key, values[0])
+	}
+	return u.field("$push", key, bson.M{"$each": values})
+}
+
+// AddToSet appends the values not yet in the array at key.
+func (u *Update) AddToSet(key string, values ...interface{}) *Update {
+	if len(values) == 1 {
+		return u.field("$addToSet", key, values[0])
+	}
+	return u.field("$addToSet", key, bson.M{"$each": values})
+}
+
+func (u *Update) Unset(keys ...string) *Update {
+	for _, key := range keys {
+		u.field("$unset", key, "")
+	}
+	return u
+}
+
+// Document returns the update document, or the first error met while
+// building it.
+func (u *Update) Document() (bson.M, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	if len(u.operators) == 0 {
+		return nil, ErrEmptyUpdate
+	}
+	return u.operators, nil
+}
+
+// updateDocument returns update's document with aliased $set fields
+// dual-written when DualWriteAliases is enabled. The builder isn't modified.
+func (q *Querier[Model, IDModel]) updateDocument(update *Update) (bson.M, error) {
+	document, err := update.Document()
+	if err != nil {
+		return nil, err
+	}
+
+	updateM := bson.M{}
+	for operator, fields := range document {
+		updateM[operator] = fields
+	}
+	if set, ok := document["$set"].(bson.M); ok {
+		setM := bson.M{}
+		for key, value := range set {
+			setM[key] = value
+		}
+		q.dualWrite(setM)
+		updateM["$set"] = setM
+	}
+	return updateM, nil
+}
+
+func (q *Querier[Model, IDModel]) UpdateOneWith(ctx context.Context, filter Model, update *Update, opts ...*options.FindOneAndUpdateOptions) (*Model, error) {
+	filterM, err := q.structToM("UpdateOneWith", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.UpdateOneWithByM(ctx, filterM, update, opts...)
+}
+
+// UpdateOneWithByM applies update to the document matching filter, like
+// UpdateOneByM.
+func (q *Querier[Model, IDModel]) UpdateOneWithByM(ctx context.Context, filter primitive.M, update *Update, opts ...*options.FindOneAndUpdateOptions) (document *Model, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "UpdateOneWithByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "UpdateOneWithByM", filter)
+	defer q.observe(span, time.Now(), "UpdateOneWithByM", filter, &err)
+
+	updateM, err := q.updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	if err = q.checkSize("UpdateOneWithByM", updateM); err != nil {
+		return nil, err
+	}
+
+	document, err = retrying(ctx, q, "UpdateOneWithByM", func() (*Model, error) {
+		return q.decodeSingle(ctx, q.writeCollection(ctx).FindOneAndUpdate(ctx, filter, updateM, opts...))
+	})
+	err = expectSingle(ctx, "UpdateOneWithByM", err)
+	if err != nil {
+		q.logWriteFailure(ctx, "UpdateOneWithByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateOne, filter, updateM)
+		return nil, err
+	}
+	q.verifyUpdate(ctx, "UpdateOneWithByM", document, updateM)
+
+	q.MongoAdapter.Debug(
+		"Updated one document with operators",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("update", updateM),
+		q.logValue("updated_document", document),
+	)
+	return document, nil
+}
+
+func (q *Querier[Model, IDModel]) UpdateManyWith(ctx context.Context, filter Model, update *Update, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	filterM, err := q.structToM("UpdateManyWith", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.UpdateManyWithByM(ctx, filterM, update, opts...)
+}
+
+// UpdateManyWithByM applies update to the documents matching filter, like
+// UpdateManyByM.
+func (q *Querier[Model, IDModel]) UpdateManyWithByM(ctx context.Context, filter primitive.M, update *Update, opts ...*options.UpdateOptions) (result *UpdateResult[Model, IDModel], err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err := q.preflight(ctx, "UpdateManyWithByM", filter); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "UpdateManyWithByM", filter)
+	defer q.observe(span, time.Now(), "UpdateManyWithByM", filter, &err)
+
+	updateM, err := q.updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	if err = q.checkSize("UpdateManyWithByM", updateM); err != nil {
+		return nil, err
+	}
+
+	result, err = q.updateMany(ctx, filter, updateM, opts...)
+	if err != nil {
+		q.logWriteFailure(ctx, "UpdateManyWithByM", err)
+		err = q.queueOnOutage(ctx, err, QueuedUpdateMany, filter, updateM)
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Updated multiple documents with operators",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		q.logValue("update", updateM),
+		LogField("documents_modified", int(result.ModifiedCount)),
+	)
+
+	if err = checkCounts(ctx, "UpdateManyWithByM", result.MatchedCount, result.ModifiedCount); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
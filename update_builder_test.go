@@ -0,0 +1,57 @@
+package mongoquerier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUpdateDocument(t *testing.T) {
+	tests := []struct {
+		name   string
+		update *Update
+		want   bson.M
+	}{
+		{"set skips zero fields", NewUpdate().Set(convertedUser{Name: "Ada"}),
+			bson.M{"$set": bson.M{"name": "Ada"}}},
+		{"set all keeps zero fields", NewUpdate().SetAll(convertedAddress{City: "Oslo"}),
+			bson.M{"$set": bson.M{"city": "Oslo", "zip": ""}}},
+		{"set document replaces the subdocument", NewUpdate().SetDocument("address", convertedAddress{City: "Oslo"}),
+			bson.M{"$set": bson.M{"address": bson.D{{Key: "city", Value: "Oslo"}}}}},
+		{"set field keeps zero values", NewUpdate().SetField("age", 0),
+			bson.M{"$set": bson.M{"age": 0}}},
+		{"operators combine", NewUpdate().Set(convertedUser{Name: "Ada"}).Inc("views", 1).Unset("legacy", "old"),
+			bson.M{"$set": bson.M{"name": "Ada"}, "$inc": bson.M{"views": 1}, "$unset": bson.M{"legacy": "", "old": ""}}},
+		{"push one value", NewUpdate().Push("tags", "x"),
+			bson.M{"$push": bson.M{"tags": "x"}}},
+		{"push several values", NewUpdate().Push("tags", "x", "y"),
+			bson.M{"$push": bson.M{"tags": bson.M{"$each": []interface{}{"x", "y"}}}}},
+		{"add to set one value", NewUpdate().AddToSet("tags", "x"),
+			bson.M{"$addToSet": bson.M{"tags": "x"}}},
+		{"add to set several values", NewUpdate().AddToSet("tags", "x", "y"),
+			bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": []interface{}{"x", "y"}}}}},
+		{"later values of a key win", NewUpdate().Inc("views", 1).Inc("views", 2),
+			bson.M{"$inc": bson.M{"views": 2}}},
+	}
+	for _, tt := range tests {
+		got, err := tt.update.Document()
+		if err != nil {
+			t.Fatalf("%s: Document() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Document() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateDocumentErrors(t *testing.T) {
+	if _, err := NewUpdate().Document(); !errors.Is(err, ErrEmptyUpdate) {
+		t.Errorf("empty Document() error = %v, want ErrEmptyUpdate", err)
+	}
+	// A zero model sets nothing
+	if _, err := NewUpdate().Set(convertedUser{}).Document(); !errors.Is(err, ErrEmptyUpdate) {
+		t.Errorf("zero Set Document() error = %v, want ErrEmptyUpdate", err)
+	}
+}
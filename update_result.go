@@ -0,0 +1,112 @@
+package mongoquerier
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpdateResult reports what UpdateMany did. UpsertedID is only set when
+// Upserted is true; Documents only with ReturnUpdated.
+type UpdateResult[Model any, IDModel any] struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	Upserted      bool
+	UpsertedID    IDModel
+	// Documents are the matched (or upserted) documents as updated.
+	Documents []*Model
+}
+
+type returnUpdatedKey struct{}
+
+// ReturnUpdated makes UpdateMany calls issued with the returned context also
+// return the documents they updated. The update and the reads run in one
+// transaction (joining the context's, if any), so the documents are exactly
+// the ones the update matched; this needs a replica set or sharded cluster.
+//
+//	result, err := querier.UpdateManyByM(mongoquerier.ReturnUpdated(ctx), filter, update)
+func ReturnUpdated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, returnUpdatedKey{}, true)
+}
+
+func returnsUpdated(ctx context.Context) bool {
+	returns, _ := ctx.Value(returnUpdatedKey{}).(bool)
+	return returns
+}
+
+func (q *Querier[Model, IDModel]) updateMany(ctx context.Context, filter primitive.M, update primitive.M, opts ...*options.UpdateOptions) (*UpdateResult[Model, IDModel], error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if !returnsUpdated(ctx) {
+		res, err := retrying(ctx, q, "UpdateMany", func() (*mongo.UpdateResult, error) {
+			return q.writeCollection(ctx).UpdateMany(ctx, filter, update, opts...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return q.updateResult(ctx, res)
+	}
+
+	var result *UpdateResult[Model, IDModel]
+	err := q.MongoAdapter.WithTransaction(ctx, func(txCtx context.Context) error {
+		// The transaction's snapshot makes these the documents the update matches
+		cursor, err := q.tenantCollection(txCtx).Find(txCtx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		var matched []struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err = cursor.All(txCtx, &matched); err != nil {
+			return err
+		}
+
+		res, err := q.writeCollection(txCtx).UpdateMany(txCtx, filter, update, opts...)
+		if err != nil {
+			return err
+		}
+		if result, err = q.updateResult(txCtx, res); err != nil {
+			return err
+		}
+
+		ids := make(bson.A, 0, len(matched)+1)
+		for _, document := range matched {
+			ids = append(ids, document.ID)
+		}
+		if res.UpsertedID != nil {
+			ids = append(ids, res.UpsertedID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		cursor, err = q.tenantCollection(txCtx).Find(txCtx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		result.Documents, err = q.decodeCursor(txCtx, cursor)
+		return err
+	})
+	return result, err
+}
+
+func (q *Querier[Model, IDModel]) updateResult(ctx context.Context, res *mongo.UpdateResult) (*UpdateResult[Model, IDModel], error) {
+	result := &UpdateResult[Model, IDModel]{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		Upserted:      res.UpsertedID != nil,
+	}
+	if result.Upserted {
+		q.trackInserted(ctx, res.UpsertedID)
+
+		upsertedID, err := castUpsertedID[IDModel](res.UpsertedID)
+		if err != nil {
+			return nil, err
+		}
+		result.UpsertedID = upsertedID
+	}
+	return result, nil
+}
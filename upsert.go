@@ -0,0 +1,80 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (q *Querier[Model, IDModel]) Upsert(ctx context.Context, filter Model, update Model, opts ...*options.UpdateOptions) (IDModel, bool, error) {
+	filterM, err := q.structToM("Upsert", filter)
+	if err != nil {
+		var zero IDModel
+		return zero, false, err
+	}
+
+	return q.UpsertByM(ctx, filterM, update, opts...)
+}
+
+// UpsertByM sets the non-zero fields of update on the document matching
+// filter, inserting one (made of filter's equality fields and update) when
+// none matches. It reports whether the document was created; upsertedID is
+// only set when it was.
+func (q *Querier[Model, IDModel]) UpsertByM(ctx context.Context, filter primitive.M, update Model, opts ...*options.UpdateOptions) (upsertedID IDModel, created bool, err error) {
+	ctx, cancel := gracefully(ctx)
+	defer cancel()
+
+	if err = q.preflight(ctx, "UpsertByM", filter); err != nil {
+		return
+	}
+	ctx, span := q.startOperation(ctx, "UpsertByM", filter)
+	defer q.observe(span, time.Now(), "UpsertByM", filter, &err)
+
+	updateM, err := StructToM(update)
+	if err != nil {
+		return
+	}
+	updateM = q.prepareSet(updateM)
+	if err = q.checkSize("UpsertByM", updateM); err != nil {
+		return
+	}
+
+	opts = append(opts, options.Update().SetUpsert(true))
+	res, err := retrying(ctx, q, "UpsertByM", func() (*mongo.UpdateResult, error) {
+		return q.writeCollection(ctx).UpdateOne(ctx, filter, updateM, opts...)
+	})
+	if err != nil {
+		q.logWriteFailure(ctx, "UpsertByM", err)
+		return
+	}
+
+	created = res.UpsertedID != nil
+	if created {
+		q.trackInserted(ctx, res.UpsertedID)
+		if upsertedID, err = castUpsertedID[IDModel](res.UpsertedID); err != nil {
+			return
+		}
+	}
+
+	q.MongoAdapter.Debug(
+		"Upserted one document",
+		LogField("collection_name", q.collection.Name()),
+		q.logValue("filter", filter),
+		LogField("created", created),
+	)
+	return
+}
+
+// FindOrCreate returns the document matching filter, creating it from
+// filter's equality fields and defaults when there's none; see
+// CreateUnlessExistsByM.
+func (q *Querier[Model, IDModel]) FindOrCreate(ctx context.Context, filter Model, defaults Model) (*Model, bool, error) {
+	return q.CreateUnlessExists(ctx, filter, defaults)
+}
+
+func (q *Querier[Model, IDModel]) FindOrCreateByM(ctx context.Context, filter primitive.M, defaults Model) (*Model, bool, error) {
+	return q.CreateUnlessExistsByM(ctx, filter, defaults)
+}
@@ -2,69 +2,431 @@ package mongoquerier
 
 import (
 	"encoding/json"
-	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func StructToM(source interface{}) (bson.M, error) {
-	// Marshal source to JSON
-	jsonSource, err := json.Marshal(source)
-	if err != nil {
-		return nil, err
+// StructTagPriority lists the struct tags StructToM takes keys from, in
+// order; the first tag naming a field wins. A field no tag names is keyed
+// like the first tag would default to: lowercased for "bson", as is for
+// "json". Put "json" first for models whose json tags are the stored keys.
+var StructTagPriority = []string{"bson", "json"}
+
+// structKey returns the key a field is stored under, or false when the
+// first of its tags in StructTagPriority skips it ("-").
+func structKey(field reflect.StructField) (string, bool) {
+	for _, tag := range StructTagPriority {
+		value, ok := field.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+		if value == "-" {
+			return "", false
+		}
+		if name := strings.Split(value, ",")[0]; name != "" {
+			return name, true
+		}
 	}
-	// Unmarshal JSON into a map
-	var data map[string]interface{}
-	if err := json.Unmarshal(jsonSource, &data); err != nil {
-		return nil, err
+
+	if len(StructTagPriority) > 0 && StructTagPriority[0] == "bson" {
+		return strings.ToLower(field.Name), true
 	}
+	return field.Name, true
+}
 
-	result := bson.M{}
-	structValues := reflect.ValueOf(source)
-	structTypes := reflect.TypeOf(source)
+// ConversionMode is how StructToMAs converts nested structs and maps.
+type ConversionMode int
+
+const (
+	// FilterMode flattens them into dotted keys ("address.city"), matched
+	// and set field by field. It's StructToM's mode.
+	FilterMode ConversionMode = iota
+	// DocumentMode keeps them as embedded documents and keeps zero fields,
+	// at every level, like the BSON codecs would, for setting whole
+	// subdocuments. A zero _id and nil pointers are still left out.
+	DocumentMode
+)
 
-	for i := 0; i < structTypes.NumField(); i++ {
-		fieldType := structTypes.Field(i)
-		fieldValue := structValues.Field(i)
-		tagValue := fieldType.Tag.Get("json")
-		jsonKey := strings.Split(tagValue, ",")[0]
+func StructToM(source interface{}) (bson.M, error) {
+	return structToM(source, false, FilterMode)
+}
+
+// StructToMAs is StructToM converting nested structs and maps in mode.
+func StructToMAs(source interface{}, mode ConversionMode) (bson.M, error) {
+	return structToM(source, mode == DocumentMode, mode)
+}
 
-		if jsonKey == "" {
-			jsonKey = fieldType.Name
+// StructToMWithZero is StructToM keeping zero fields too, but a zero _id and
+// nil pointers, which still mean unset.
+func StructToMWithZero(source interface{}) (bson.M, error) {
+	return structToM(source, true, FilterMode)
+}
+
+func structToM(source interface{}, withZero bool, mode ConversionMode) (result bson.M, err error) {
+	var field string
+	defer recoverPanic(&err, "StructToM", reflect.TypeOf(source), &field)
+
+	result = bson.M{}
+	structValues := reflect.ValueOf(source)
+
+	for _, meta := range structLayoutOf(structValues.Type()) {
+		field = meta.name
+		fieldValue := structValues.Field(meta.index)
+		// Zero values aren't filter or update criteria, but a pointer to one
+		// is: nil means unset, new(bool) means false
+		if fieldValue.IsZero() && (!withZero || meta.key == "_id" || fieldValue.Kind() == reflect.Pointer) {
+			continue
+		}
+		if fieldValue.Kind() == reflect.Pointer {
+			fieldValue = fieldValue.Elem()
 		}
 
-		// Check if the field exists in the JSON data
-		if _, ok := data[jsonKey]; ok {
-			zeroValue := reflect.Zero(fieldType.Type)
-			// Omit fields that default JSONMarshaler hasn't omitted
-			if reflect.DeepEqual(zeroValue.Interface(), fieldValue.Interface()) {
-				continue
+		conversion := meta.conversion
+		if conversion == convertDynamic && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+			for fieldValue.Kind() == reflect.Pointer && !fieldValue.IsNil() {
+				fieldValue = fieldValue.Elem()
 			}
-
-			if fieldType.Type.Kind() == reflect.Struct {
-				valueMap, err := StructToM(fieldValue.Interface())
-				if err != nil {
-					return nil, err
+			conversion = convertValue
+			if fieldValue.Kind() != reflect.Pointer {
+				conversion = conversionOf(fieldValue.Type())
+			}
+		}
+		if mode == DocumentMode && !meta.inline && conversion != convertValue {
+			conversion = convertValue
+		}
+		switch conversion {
+		case convertFlatten:
+			valueMap, err := structToM(fieldValue.Interface(), withZero, mode)
+			if err != nil {
+				return nil, err
+			}
+			for valueKey, valueValue := range valueMap {
+				if meta.inline {
+					result[valueKey] = valueValue
+				} else {
+					result[meta.key+"."+valueKey] = valueValue
 				}
+			}
+		case convertMap:
+			// Maps are flattened like nested structs, so that they match and
+			// update stored subdocuments key by key
+			prefix := meta.key
+			if meta.inline {
+				prefix = ""
+			}
+			if err := flattenMap(result, prefix, fieldValue); err != nil {
+				return nil, err
+			}
+		default:
+			value, err := bsonValue(fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			result[meta.key] = value
+		}
+	}
 
-				for valueKey, valueValue := range valueMap {
-					result[fmt.Sprintf("%s.%s", jsonKey, valueKey)] = valueValue
-				}
+	return result, nil
+}
+
+type fieldConversion int
+
+const (
+	convertValue fieldConversion = iota
+	// convertFlatten flattens a nested struct to dotted keys
+	convertFlatten
+	// convertMap flattens a string-keyed map to dotted keys
+	convertMap
+	// convertDynamic picks the conversion from the value, for interfaces
+	convertDynamic
+)
+
+func conversionOf(t reflect.Type) fieldConversion {
+	for t.Kind() == reflect.Pointer {
+		if _, ok := converterFor(t); ok {
+			return convertValue
+		}
+		t = t.Elem()
+	}
+	if _, ok := converterFor(t); ok {
+		return convertValue
+	}
+	switch {
+	case t.Kind() == reflect.Interface:
+		return convertDynamic
+	case t.Kind() == reflect.Struct && !encodesAsValue(t):
+		return convertFlatten
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return convertMap
+	}
+	return convertValue
+}
+
+// structField is what converting a stored field takes, worked out once per
+// type.
+type structField struct {
+	index int
+	name  string
+	key   string
+	// inline fields have their keys merged into the parent's
+	inline     bool
+	omitEmpty  bool
+	conversion fieldConversion
+}
+
+type structLayout struct {
+	// priority is the StructTagPriority the keys were taken with
+	priority []string
+	fields   []structField
+}
+
+var structLayouts sync.Map // reflect.Type -> *structLayout
+
+// structLayoutOf returns the stored fields of struct type t: exported, not
+// embedded and not skipped by their tags.
+func structLayoutOf(t reflect.Type) []structField {
+	if cached, ok := structLayouts.Load(t); ok {
+		layout := cached.(*structLayout)
+		if samePriority(layout.priority, StructTagPriority) {
+			return layout.fields
+		}
+	}
 
-				continue
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i)
+		// Unexported fields aren't stored
+		if !fieldType.IsExported() {
+			continue
+		}
+		key, ok := structKey(fieldType)
+		if !ok {
+			continue
+		}
+		fields = append(fields, structField{
+			index:      i,
+			name:       fieldType.Name,
+			key:        key,
+			inline:     inlineField(fieldType),
+			omitEmpty:  strings.Contains(fieldType.Tag.Get("bson"), ",omitempty"),
+			conversion: conversionOf(fieldType.Type),
+		})
+	}
+
+	structLayouts.Store(t, &structLayout{
+		priority: append([]string(nil), StructTagPriority...),
+		fields:   fields,
+	})
+	return fields
+}
+
+// inlineField reports whether a field's keys are stored at its parent's
+// level, like the BSON codecs store fields tagged `bson:",inline"`: it's
+// tagged so, or it's an embedded struct no tag names.
+func inlineField(field reflect.StructField) bool {
+	t := field.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct && (t.Kind() != reflect.Map || t.Key().Kind() != reflect.String) {
+		return false
+	}
+
+	for _, option := range strings.Split(field.Tag.Get("bson"), ",")[1:] {
+		if option == "inline" {
+			return true
+		}
+	}
+	if !field.Anonymous || t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, tag := range StructTagPriority {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+func samePriority(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func flattenMap(result bson.M, prefix string, m reflect.Value) error {
+	iter := m.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		value := iter.Value()
+		if value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+
+		_, converted := converterFor(value.Type())
+		switch {
+		case converted:
+			converted, err := bsonValue(value)
+			if err != nil {
+				return err
+			}
+			result[key] = converted
+		case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String && !value.IsNil():
+			if err := flattenMap(result, key, value); err != nil {
+				return err
+			}
+		case value.Kind() == reflect.Struct && !encodesAsValue(value.Type()):
+			valueMap, err := StructToM(value.Interface())
+			if err != nil {
+				return err
 			}
+			for valueKey, valueValue := range valueMap {
+				result[key+"."+valueKey] = valueValue
+			}
+		default:
+			converted, err := bsonValue(value)
+			if err != nil {
+				return err
+			}
+			result[key] = converted
+		}
+	}
+	return nil
+}
+
+var (
+	dType = reflect.TypeOf(primitive.D{})
+	eType = reflect.TypeOf(primitive.E{})
+)
 
-			result[jsonKey] = fieldValue.Interface()
+// bsonValue converts a value to the form it's stored in: registered types
+// through their converter, dates as primitive.DateTime, slices as arrays of
+// converted elements (usable as is, or under $in), structs in arrays as
+// whole documents keyed like StructToM keys fields. Other values keep their
+// Go types, encoded by the BSON codecs like stored documents are.
+func bsonValue(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	if convert, ok := converterFor(value.Type()); ok {
+		if (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+			return nil, nil
+		}
+		return convert(value)
+	}
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return bsonValue(value.Elem())
+	}
+	if value.Type() == timeType {
+		return primitive.NewDateTimeFromTime(value.Interface().(time.Time)), nil
+	}
+	if encodesAsValue(value.Type()) {
+		return value.Interface(), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == eType {
+			return value.Interface(), nil
+		}
+		return structDocument(value)
+	case reflect.Slice:
+		// Bytes are stored as binary, and bson.D is already a document
+		if value.IsNil() || value.Type().Elem().Kind() == reflect.Uint8 || value.Type() == dType || value.Type().Elem() == eType {
+			return value.Interface(), nil
+		}
+		array := make(bson.A, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := bsonValue(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		return array, nil
+	case reflect.Map:
+		if value.IsNil() || value.Type().Key().Kind() != reflect.String {
+			return value.Interface(), nil
+		}
+		document := bson.M{}
+		iter := value.MapRange()
+		for iter.Next() {
+			element, err := bsonValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			document[iter.Key().String()] = element
 		}
+		return document, nil
+	}
+	return value.Interface(), nil
+}
 
+// structDocument converts a struct stored whole, e.g. as an array element,
+// keeping its zero fields like the BSON codecs would, unless tagged
+// omitempty.
+func structDocument(value reflect.Value) (bson.D, error) {
+	document := bson.D{}
+	for _, meta := range structLayoutOf(value.Type()) {
+		fieldValue := value.Field(meta.index)
+		if meta.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		converted, err := bsonValue(fieldValue)
+		if err != nil {
+			return nil, err
+		}
+		if meta.inline {
+			switch inlined := converted.(type) {
+			case bson.D:
+				document = append(document, inlined...)
+			case bson.M:
+				for key, value := range inlined {
+					document = append(document, primitive.E{Key: key, Value: value})
+				}
+			}
+			continue
+		}
+		document = append(document, primitive.E{Key: meta.key, Value: converted})
 	}
+	return document, nil
+}
 
-	return result, nil
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	valueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+	marshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+)
+
+// encodesAsValue reports whether the BSON codecs encode a struct type as a
+// single value (a date, a custom encoding...) rather than field by field.
+func encodesAsValue(t reflect.Type) bool {
+	return t == timeType || t.Implements(valueMarshalerType) || t.Implements(marshalerType)
 }
 
 func CastStruct[S any, D any](source S) (destination D, err error) {
+	defer recoverPanic(&err, "CastStruct", reflect.TypeOf(source), nil)
+
 	// Convert struct to JSON string
 	sourceJSON, err := json.Marshal(source)
 	if err != nil {
@@ -79,7 +441,9 @@ func CastStruct[S any, D any](source S) (destination D, err error) {
 	return
 }
 
-func CastInto[S any, D any](source S, destination D) error {
+func CastInto[S any, D any](source S, destination D) (err error) {
+	defer recoverPanic(&err, "CastInto", reflect.TypeOf(source), nil)
+
 	// Convert struct to JSON string
 	sourceJSON, err := json.Marshal(source)
 	if err != nil {
@@ -0,0 +1,104 @@
+package mongoquerier
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConvertedAudit is exported as embedded structs of unexported types aren't
+// stored, like the BSON codecs skip them.
+type ConvertedAudit struct {
+	CreatedBy string `bson:"created_by"`
+}
+
+type convertedAddress struct {
+	City string `bson:"city"`
+	Zip  string `bson:"zip,omitempty"`
+}
+
+type convertedUser struct {
+	ConvertedAudit
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	Age       int                `bson:"age,omitempty"`
+	Active    *bool              `bson:"active"`
+	Address   convertedAddress   `bson:"address"`
+	Previous  *convertedAddress  `bson:"previous"`
+	Addresses []convertedAddress `bson:"addresses"`
+	CreatedAt time.Time          `bson:"created_at"`
+	Secret    string             `bson:"-"`
+	Untagged  string
+	internal  string
+}
+
+func TestStructToM(t *testing.T) {
+	id := primitive.NewObjectID()
+	created := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	inactive := false
+
+	tests := []struct {
+		name string
+		user convertedUser
+		want bson.M
+	}{
+		{"zero values are left out", convertedUser{}, bson.M{}},
+		{"stored types are kept", convertedUser{ID: id, CreatedAt: created},
+			bson.M{"_id": id, "created_at": primitive.NewDateTimeFromTime(created)}},
+		{"pointers to zero values are kept", convertedUser{Active: &inactive}, bson.M{"active": false}},
+		{"embedded structs are inlined", convertedUser{ConvertedAudit: ConvertedAudit{CreatedBy: "ops"}}, bson.M{"created_by": "ops"}},
+		{"nested structs are flattened", convertedUser{Address: convertedAddress{City: "Oslo"}}, bson.M{"address.city": "Oslo"}},
+		{"pointed structs are flattened", convertedUser{Previous: &convertedAddress{City: "Bergen"}}, bson.M{"previous.city": "Bergen"}},
+		{"structs in arrays are whole documents", convertedUser{Addresses: []convertedAddress{{City: "Oslo"}}},
+			bson.M{"addresses": bson.A{bson.D{{Key: "city", Value: "Oslo"}}}}},
+		{"skipped and unexported fields are left out", convertedUser{Secret: "s", internal: "i"}, bson.M{}},
+		{"untagged fields are lowercased", convertedUser{Untagged: "u"}, bson.M{"untagged": "u"}},
+	}
+	for _, tt := range tests {
+		got, err := StructToM(tt.user)
+		if err != nil {
+			t.Fatalf("%s: StructToM() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: StructToM() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStructToMWithZero(t *testing.T) {
+	got, err := StructToMWithZero(convertedUser{Name: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.M{
+		"created_by":   "",
+		"name":         "Ada",
+		"age":          0,
+		"address.city": "",
+		"address.zip":  "",
+		"addresses":    []convertedAddress(nil),
+		"created_at":   primitive.NewDateTimeFromTime(time.Time{}),
+		"untagged":     "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StructToMWithZero() = %v, want %v", got, want)
+	}
+}
+
+func TestStructToMAsDocument(t *testing.T) {
+	got, err := StructToMAs(convertedUser{Address: convertedAddress{City: "Oslo"}}, DocumentMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (bson.D{{Key: "city", Value: "Oslo"}}); !reflect.DeepEqual(got["address"], want) {
+		t.Errorf("StructToMAs(DocumentMode)[address] = %v, want %v", got["address"], want)
+	}
+	if _, ok := got["address.city"]; ok {
+		t.Error("StructToMAs(DocumentMode) flattened the nested struct")
+	}
+}
@@ -0,0 +1,189 @@
+package mongoquerier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+var (
+	ErrInvalidUUID       = errors.New("invalid UUID")
+	ErrInvalidUUIDBinary = errors.New("binary value is not a subtype 4 UUID")
+)
+
+// UUID is stored as BSON binary subtype 4 (standard UUID representation), as
+// written by the .NET and Java drivers in standard mode.
+type UUID [16]byte
+
+var NilUUID UUID
+
+func NewUUID() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return NilUUID, err
+	}
+
+	// Set version 4 and RFC 4122 variant bits
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+
+	// Accept both the canonical 8-4-4-4-12 form and the bare 32 hex digits form
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return NilUUID, fmt.Errorf("%w: %q", ErrInvalidUUID, s)
+		}
+		s = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	case 32:
+	default:
+		return NilUUID, fmt.Errorf("%w: %q", ErrInvalidUUID, s)
+	}
+
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return NilUUID, fmt.Errorf("%w: %q", ErrInvalidUUID, s)
+	}
+	return u, nil
+}
+
+func MustParseUUID(s string) UUID {
+	u, err := ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (u UUID) IsZero() bool {
+	return u == NilUUID
+}
+
+func (u UUID) Binary() primitive.Binary {
+	return primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: u[:]}
+}
+
+func UUIDFromBinary(b primitive.Binary) (UUID, error) {
+	var u UUID
+	if b.Subtype != bson.TypeBinaryUUID || len(b.Data) != len(u) {
+		return NilUUID, ErrInvalidUUIDBinary
+	}
+	copy(u[:], b.Data)
+	return u, nil
+}
+
+func (u UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.TypeBinary, bsoncore.AppendBinary(nil, bson.TypeBinaryUUID, u[:]), nil
+}
+
+func (u *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t != bson.TypeBinary {
+		return fmt.Errorf("%w: got BSON type %s", ErrInvalidUUIDBinary, t)
+	}
+
+	subtype, bin, _, ok := bsoncore.ReadBinary(data)
+	if !ok {
+		return ErrInvalidUUIDBinary
+	}
+
+	parsed, err := UUIDFromBinary(primitive.Binary{Subtype: subtype, Data: bin})
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+func (u UUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.String())
+}
+
+func (u *UUID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseUUID(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// UUIDFilter builds a filter matching fieldName against one or many UUIDs;
+// without any, it matches no document.
+func UUIDFilter(fieldName string, ids ...UUID) bson.M {
+	if len(ids) == 1 {
+		return bson.M{fieldName: ids[0]}
+	}
+	if ids == nil {
+		// A nil slice marshals as null, which $in rejects
+		ids = []UUID{}
+	}
+	return bson.M{fieldName: bson.M{"$in": ids}}
+}
+
+func UUIDIndexModel(fieldName string, unique bool) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: fieldName, Value: 1}},
+		Options: options.Index().SetUnique(unique),
+	}
+}
+
+func NewQuerierWithUUID[Model any](madp *MongoAdapter, collectionName string) *Querier[Model, UUID] {
+	return newQuerier[Model, UUID](madp, collectionName)
+}
+
+func (q *Querier[Model, IDModel]) FindByUUID(ctx context.Context, fieldName string, ids ...UUID) ([]*Model, error) {
+	return q.FindByM(ctx, UUIDFilter(fieldName, ids...))
+}
+
+// castID converts a driver inserted ID into IDModel, handling binary UUIDs
+// which the driver reports as primitive.Binary.
+func castID[IDModel any](id interface{}) (IDModel, bool) {
+	if casted, ok := id.(IDModel); ok {
+		return casted, true
+	}
+
+	var casted IDModel
+	if binary, ok := id.(primitive.Binary); ok {
+		if target, ok := any(&casted).(*UUID); ok {
+			u, err := UUIDFromBinary(binary)
+			if err != nil {
+				return casted, false
+			}
+			*target = u
+			return casted, true
+		}
+	}
+	return casted, false
+}
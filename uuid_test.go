@@ -0,0 +1,21 @@
+package mongoquerier
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUUIDFilterWithoutIDs(t *testing.T) {
+	data, err := bson.Marshal(UUIDFilter("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := bson.Raw(data).Lookup("id", "$in")
+	if in.Type != bson.TypeArray {
+		t.Fatalf("UUIDFilter() $in = %v, want an empty array", in)
+	}
+	if values, _ := in.Array().Values(); len(values) != 0 {
+		t.Errorf("UUIDFilter() $in = %v, want an empty array", values)
+	}
+}
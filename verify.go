@@ -0,0 +1,232 @@
+package mongoquerier
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type verifyWritesKey struct{}
+
+// VerifyWrites re-reads the documents written with the returned context and
+// logs, field by field, where the stored state differs from the intended
+// write. It doubles every write's cost; use it in debug and staging runs to
+// validate the generated updates against a real server.
+//
+//	document, err := querier.UpdateOne(mongoquerier.VerifyWrites(ctx), filter, update)
+//
+// InsertOne, UpdateOne, UpdateOneWith and ReplaceOne are verified; set
+// Querier.VerifyAllWrites to verify them on every call.
+func VerifyWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifyWritesKey{}, true)
+}
+
+func (q *Querier[Model, IDModel]) verifiesWrites(ctx context.Context) bool {
+	verify, _ := ctx.Value(verifyWritesKey{}).(bool)
+	return verify || q.VerifyAllWrites
+}
+
+type expectedField struct {
+	key   string
+	value bson.RawValue
+}
+
+// expectedFields lists the fields a $set-like document writes, keyed by
+// their (dotted) path.
+func expectedFields(m bson.M) ([]expectedField, error) {
+	fields := make([]expectedField, 0, len(m))
+	for key, value := range m {
+		t, data, err := bson.MarshalValue(value)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, expectedField{key, bson.RawValue{Type: t, Value: data}})
+	}
+	return fields, nil
+}
+
+// documentFields lists the top-level fields of document.
+func documentFields(document interface{}) ([]expectedField, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]expectedField, 0, len(elements))
+	for _, element := range elements {
+		fields = append(fields, expectedField{element.Key(), element.Value()})
+	}
+	return fields, nil
+}
+
+// documentID reads the _id of a document returned by a write.
+func documentID(document interface{}) (interface{}, bool) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, false
+	}
+	value, err := bson.Raw(raw).LookupErr("_id")
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// verifyUpdate verifies the $set and $unset fields of updateM on the document
+// returned by an update.
+func (q *Querier[Model, IDModel]) verifyUpdate(ctx context.Context, operation string, document *Model, updateM bson.M) {
+	if document == nil || !q.verifiesWrites(ctx) {
+		return
+	}
+
+	id, ok := documentID(document)
+	if !ok {
+		q.MongoAdapter.Warn(
+			"Unable to verify write: the document has no _id",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+		)
+		return
+	}
+
+	set, _ := updateM["$set"].(bson.M)
+	expected, err := expectedFields(set)
+	if err != nil {
+		q.MongoAdapter.Warn("Unable to verify write", LogField("operation", operation), LogError(err))
+		return
+	}
+	unset, _ := updateM["$unset"].(bson.M)
+	unsetKeys := make([]string, 0, len(unset))
+	for key := range unset {
+		unsetKeys = append(unsetKeys, key)
+	}
+	q.verifyWrite(ctx, operation, id, expected, unsetKeys)
+}
+
+// verifyDocument verifies every field of a document written whole, by an
+// insert or a replacement.
+func (q *Querier[Model, IDModel]) verifyDocument(ctx context.Context, operation string, id interface{}, document interface{}) {
+	if !q.verifiesWrites(ctx) {
+		return
+	}
+
+	expected, err := documentFields(document)
+	if err != nil {
+		q.MongoAdapter.Warn("Unable to verify write", LogField("operation", operation), LogError(err))
+		return
+	}
+	q.verifyWrite(ctx, operation, id, expected, nil)
+}
+
+// verifyWrite re-reads the document id from the primary and logs every
+// expected field it doesn't hold, and every unset field it still holds.
+// Verification never fails the write it checks.
+func (q *Querier[Model, IDModel]) verifyWrite(ctx context.Context, operation string, id interface{}, expected []expectedField, unset []string) {
+	collection, err := q.writeCollection(ctx).Clone(options.Collection().SetReadPreference(readpref.Primary()))
+	if err != nil {
+		q.MongoAdapter.Warn("Unable to verify write", LogField("operation", operation), LogError(err))
+		return
+	}
+	stored, err := collection.FindOne(ctx, bson.M{"_id": id}).DecodeBytes()
+	if err != nil {
+		q.MongoAdapter.Warn(
+			"Unable to verify write: the document couldn't be read back",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("_id", id),
+			LogError(err),
+		)
+		return
+	}
+
+	discrepancies := 0
+	for _, field := range expected {
+		value, err := stored.LookupErr(strings.Split(field.key, ".")...)
+		if err == nil && sameValue(field.value, value) {
+			continue
+		}
+		discrepancies++
+		storedValue := "<missing>"
+		if err == nil {
+			storedValue = value.String()
+		}
+		q.MongoAdapter.Warn(
+			"Write verification found a discrepancy",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("_id", id),
+			LogField("field", field.key),
+			q.logFieldValues("expected", field.key, field.value.String()),
+			q.logFieldValues("stored", field.key, storedValue),
+		)
+	}
+	for _, key := range unset {
+		value, err := stored.LookupErr(strings.Split(key, ".")...)
+		if err != nil {
+			continue
+		}
+		discrepancies++
+		q.MongoAdapter.Warn(
+			"Write verification found a discrepancy",
+			LogField("collection_name", q.collection.Name()),
+			LogField("operation", operation),
+			LogField("_id", id),
+			LogField("field", key),
+			LogField("expected", "<unset>"),
+			q.logFieldValues("stored", key, value.String()),
+		)
+	}
+
+	q.MongoAdapter.Debug(
+		"Verified write",
+		LogField("collection_name", q.collection.Name()),
+		LogField("operation", operation),
+		LogField("_id", id),
+		LogField("fields_count", len(expected)+len(unset)),
+		LogField("discrepancies_count", discrepancies),
+	)
+}
+
+// sameValue compares BSON values, ignoring the order of embedded documents'
+// fields, which maps don't keep.
+func sameValue(a bson.RawValue, b bson.RawValue) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case bsontype.EmbeddedDocument:
+		aElements, aErr := a.Document().Elements()
+		bElements, bErr := b.Document().Elements()
+		if aErr != nil || bErr != nil || len(aElements) != len(bElements) {
+			return false
+		}
+		for _, element := range aElements {
+			value, err := b.Document().LookupErr(element.Key())
+			if err != nil || !sameValue(element.Value(), value) {
+				return false
+			}
+		}
+		return true
+	case bsontype.Array:
+		aValues, aErr := a.Array().Values()
+		bValues, bErr := b.Array().Values()
+		if aErr != nil || bErr != nil || len(aValues) != len(bValues) {
+			return false
+		}
+		for i := range aValues {
+			if !sameValue(aValues[i], bValues[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return bytes.Equal(a.Value, b.Value)
+}
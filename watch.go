@@ -0,0 +1,140 @@
+package mongoquerier
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// ChangeEvent is a change stream event on a querier's collection.
+// FullDocument is nil for deletes, and for updates unless the stream was
+// opened with options.ChangeStream().SetFullDocument(options.UpdateLookup).
+type ChangeEvent[Model any] struct {
+	OperationType     string
+	FullDocument      *Model
+	DocumentKey       bson.Raw
+	UpdateDescription *UpdateDescription
+	ClusterTime       primitive.Timestamp
+	ResumeToken       bson.Raw
+}
+
+type changeEventRecord struct {
+	OperationType     string              `bson:"operationType"`
+	FullDocument      bson.RawValue       `bson:"fullDocument"`
+	DocumentKey       bson.Raw            `bson:"documentKey"`
+	UpdateDescription *UpdateDescription  `bson:"updateDescription"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+}
+
+// ChangeStream decodes change events lazily, like Cursor:
+//
+//	stream, err := querier.Watch(ctx, nil)
+//	if err != nil { ... }
+//	defer stream.Close(ctx)
+//	for stream.Next(ctx) {
+//		event := stream.Event()
+//	}
+//	err = stream.Err()
+type ChangeStream[Model any] struct {
+	stream  *mongo.ChangeStream
+	decode  func(ctx context.Context, raw bson.Raw) (*Model, error)
+	current *ChangeEvent[Model]
+	err     error
+	// release ends the stream's tracking as an operation in flight
+	release func()
+}
+
+// Watch opens a change stream on the querier's collection; pipeline filters
+// or reshapes the events, e.g. mongo.Pipeline{{{Key: "$match", Value:
+// bson.M{"operationType": "insert"}}}}. Save ResumeToken (see CheckpointStore)
+// and pass it to options.ChangeStream().SetResumeAfter to resume after a
+// restart.
+func (q *Querier[Model, IDModel]) Watch(ctx context.Context, pipeline mongo.Pipeline, opts ...*options.ChangeStreamOptions) (changes *ChangeStream[Model], err error) {
+	if err = q.preflight(ctx, "Watch", nil); err != nil {
+		return nil, err
+	}
+	ctx, span := q.startOperation(ctx, "Watch", nil)
+	defer q.observe(span, time.Now(), "Watch", nil, &err)
+
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+	stream, err := retrying(ctx, q, "Watch", func() (*mongo.ChangeStream, error) {
+		return q.tenantCollection(ctx).Watch(ctx, pipeline, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.MongoAdapter.Debug(
+		"Opened change stream",
+		LogField("collection_name", q.collection.Name()),
+	)
+	return &ChangeStream[Model]{
+		stream:  stream,
+		release: q.MongoAdapter.trackCursor(),
+		// Events carry the document as it is now, nothing to write back
+		decode: func(ctx context.Context, raw bson.Raw) (*Model, error) {
+			return q.decode(partialRead(ctx), raw)
+		},
+	}, nil
+}
+
+// Next blocks until the next event, returning false when the stream ends,
+// ctx is done or an event failed to decode.
+func (cs *ChangeStream[Model]) Next(ctx context.Context) bool {
+	if cs.err != nil || !cs.stream.Next(ctx) {
+		return false
+	}
+
+	var record changeEventRecord
+	if cs.err = cs.stream.Decode(&record); cs.err != nil {
+		return false
+	}
+
+	event := &ChangeEvent[Model]{
+		OperationType:     record.OperationType,
+		DocumentKey:       record.DocumentKey,
+		UpdateDescription: record.UpdateDescription,
+		ClusterTime:       record.ClusterTime,
+		ResumeToken:       cs.stream.ResumeToken(),
+	}
+	// Deletes carry no document, lookups of deleted documents a null one
+	if document, ok := record.FullDocument.DocumentOK(); ok {
+		if event.FullDocument, cs.err = cs.decode(ctx, document); cs.err != nil {
+			return false
+		}
+	}
+	cs.current = event
+	return true
+}
+
+func (cs *ChangeStream[Model]) Event() *ChangeEvent[Model] {
+	return cs.current
+}
+
+// ResumeToken returns the token to resume the stream after the last event.
+func (cs *ChangeStream[Model]) ResumeToken() bson.Raw {
+	return cs.stream.ResumeToken()
+}
+
+func (cs *ChangeStream[Model]) Err() error {
+	if cs.err != nil {
+		return cs.err
+	}
+	return cs.stream.Err()
+}
+
+func (cs *ChangeStream[Model]) Close(ctx context.Context) error {
+	defer cs.release()
+	return cs.stream.Close(ctx)
+}
@@ -0,0 +1,92 @@
+// Package zaplog adapts zap loggers to mongoquerier.Logger, keeping zap out
+// of the builds of applications logging otherwise.
+package zaplog
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"mongoquerier"
+)
+
+type logger struct {
+	*zap.Logger
+}
+
+// New logs through l:
+//
+//	mongoAdapter, err := mongoquerier.NewMongoAdapter(ctx, uri, "shop", mongoquerier.WithLogger(zaplog.New(l)))
+func New(l *zap.Logger) mongoquerier.Logger {
+	// Report the caller of the adapter's logging method, not this package
+	return logger{Logger: l.WithOptions(zap.AddCallerSkip(1))}
+}
+
+func (l logger) Debug(msg string, fields ...mongoquerier.Field) {
+	if ce := l.Logger.Check(zapcore.DebugLevel, msg); ce != nil {
+		ce.Write(zapFields(fields)...)
+	}
+}
+
+func (l logger) Info(msg string, fields ...mongoquerier.Field) {
+	if ce := l.Logger.Check(zapcore.InfoLevel, msg); ce != nil {
+		ce.Write(zapFields(fields)...)
+	}
+}
+
+func (l logger) Warn(msg string, fields ...mongoquerier.Field) {
+	if ce := l.Logger.Check(zapcore.WarnLevel, msg); ce != nil {
+		ce.Write(zapFields(fields)...)
+	}
+}
+
+func (l logger) Error(msg string, fields ...mongoquerier.Field) {
+	if ce := l.Logger.Check(zapcore.ErrorLevel, msg); ce != nil {
+		ce.Write(zapFields(fields)...)
+	}
+}
+
+func (l logger) With(fields ...mongoquerier.Field) mongoquerier.Logger {
+	return logger{Logger: l.Logger.With(zapFields(fields)...)}
+}
+
+func zapFields(fields []mongoquerier.Field) []zap.Field {
+	converted := make([]zap.Field, 0, len(fields))
+	for _, field := range fields {
+		switch value := field.Value.(type) {
+		case mongoquerier.LogValuer:
+			converted = append(converted, zap.Object(field.Key, lazyObject{valuer: value}))
+		case []byte:
+			converted = append(converted, zap.ByteString(field.Key, value))
+		default:
+			converted = append(converted, zap.Any(field.Key, value))
+		}
+	}
+	return converted
+}
+
+// lazyObject resolves a LogValuer when zap encodes the entry, logging
+// documents field by field.
+type lazyObject struct {
+	valuer mongoquerier.LogValuer
+}
+
+func (o lazyObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	value := o.valuer.LogValue()
+	m, ok := value.(bson.M)
+	if !ok {
+		return enc.AddReflected("value", value)
+	}
+
+	for key, value := range m {
+		if err := enc.AddReflected(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Level adapts a zap level to mongoquerier.ControlPanel.RegisterLogLevel.
+func Level(level zap.AtomicLevel) mongoquerier.LogLevel {
+	return &level
+}